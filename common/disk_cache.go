@@ -2,8 +2,10 @@ package common
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,6 +22,9 @@ const (
 // 统一的缓存目录名
 const diskCacheDir = "new-api-body-cache"
 
+// 持久化文件存储子目录名（/v1/files 本地存储），位于缓存目录下，不参与过期清理
+const diskFileStoreDir = "files"
+
 // GetDiskCacheDir 获取统一的磁盘缓存目录
 // 注意：每次调用都会重新计算，以响应配置变化
 func GetDiskCacheDir() string {
@@ -163,6 +168,88 @@ func GetDiskCacheInfo() (fileCount int, totalSize int64, err error) {
 	return fileCount, totalSize, nil
 }
 
+// FileStoreDir 持久化文件存储目录（FILE_STORE_DIR），多节点部署时应指向各节点共享的目录
+var FileStoreDir string
+
+// GetDiskFileStoreDir 获取持久化文件存储目录
+func GetDiskFileStoreDir() string {
+	if FileStoreDir != "" {
+		return FileStoreDir
+	}
+	return filepath.Join(GetDiskCacheDir(), diskFileStoreDir)
+}
+
+// WriteDiskStoreFile 将 reader 内容写入持久化文件存储
+// maxBytes 大于 0 时限制写入大小，超出返回 ErrRequestBodyTooLarge
+// 返回文件路径和写入字节数
+func WriteDiskStoreFile(reader io.Reader, maxBytes int64) (string, int64, error) {
+	dir := GetDiskFileStoreDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create file store directory: %w", err)
+	}
+	filename := fmt.Sprintf("%s-%d.bin", uuid.New().String(), time.Now().UnixNano())
+	filePath := filepath.Join(dir, filename)
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_RDWR|os.O_EXCL, 0600)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create store file: %w", err)
+	}
+	if maxBytes > 0 {
+		reader = io.LimitReader(reader, maxBytes+1)
+	}
+	written, err := io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return "", 0, fmt.Errorf("failed to write store file: %w", err)
+	}
+	if maxBytes > 0 && written > maxBytes {
+		os.Remove(filePath)
+		return "", 0, ErrRequestBodyTooLarge
+	}
+	return filePath, written, nil
+}
+
+// CreateDiskStoreFile 在持久化文件存储中创建一个空文件，返回文件路径
+func CreateDiskStoreFile() (string, error) {
+	filePath, _, err := WriteDiskStoreFile(strings.NewReader(""), 0)
+	return filePath, err
+}
+
+// AppendDiskStoreFile 向持久化存储文件追加内容
+func AppendDiskStoreFile(filePath string, data []byte) error {
+	if err := checkDiskStoreFilePath(filePath); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func checkDiskStoreFilePath(filePath string) error {
+	rel, err := filepath.Rel(GetDiskFileStoreDir(), filePath)
+	if err != nil || strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) {
+		return fmt.Errorf("invalid store file path")
+	}
+	return nil
+}
+
+// OpenDiskStoreFile 打开持久化存储文件，仅允许访问存储目录内的文件
+func OpenDiskStoreFile(filePath string) (*os.File, error) {
+	if err := checkDiskStoreFilePath(filePath); err != nil {
+		return nil, err
+	}
+	return os.Open(filePath)
+}
+
 // ShouldUseDiskCache 判断是否应该使用磁盘缓存
 func ShouldUseDiskCache(dataSize int64) bool {
 	if !IsDiskCacheEnabled() {
@@ -0,0 +1,54 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//go:embed lua/circuit_breaker.lua
+var circuitBreakerScript string
+
+var circuitBreaker = redis.NewScript(circuitBreakerScript)
+
+const (
+	CircuitEventNone   = 0
+	CircuitEventOpened = 1
+	CircuitEventClosed = 2
+)
+
+// CircuitBreakerParams 熔断器状态迁移参数
+type CircuitBreakerParams struct {
+	Success                  bool
+	Force                    bool
+	Now                      int64
+	FailureThreshold         int
+	HalfOpenSuccessThreshold int
+	CooldownSeconds          int
+	MaxCooldownSeconds       int
+	TTLSeconds               int64
+}
+
+// UpdateCircuitBreaker 原子地记录一次请求结果并更新熔断器状态，返回发生的事件和熔断冷却时间（秒）
+func UpdateCircuitBreaker(ctx context.Context, rdb *redis.Client, key string, params CircuitBreakerParams) (int, int, error) {
+	boolArg := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	result, err := circuitBreaker.Run(ctx, rdb, []string{key},
+		boolArg(params.Success), boolArg(params.Force), params.Now,
+		params.FailureThreshold, params.HalfOpenSuccessThreshold,
+		params.CooldownSeconds, params.MaxCooldownSeconds, params.TTLSeconds,
+	).Int64Slice()
+	if err != nil {
+		return CircuitEventNone, 0, fmt.Errorf("circuit breaker update failed: %w", err)
+	}
+	if len(result) != 2 {
+		return CircuitEventNone, 0, fmt.Errorf("circuit breaker update returned %d values", len(result))
+	}
+	return int(result[0]), int(result[1]), nil
+}
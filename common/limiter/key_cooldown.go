@@ -0,0 +1,27 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//go:embed lua/key_cooldown.lua
+var keyCooldownScript string
+
+var keyCooldown = redis.NewScript(keyCooldownScript)
+
+// SetKeyCooldown 原子地设置 Key 的冷却截止时间，已有更晚的截止时间时保留
+func SetKeyCooldown(ctx context.Context, rdb *redis.Client, key string, until time.Time) error {
+	ttl := time.Until(until).Milliseconds()
+	if ttl <= 0 {
+		return nil
+	}
+	if err := keyCooldown.Run(ctx, rdb, []string{key}, until.UnixMilli(), ttl).Err(); err != nil {
+		return fmt.Errorf("key cooldown update failed: %w", err)
+	}
+	return nil
+}
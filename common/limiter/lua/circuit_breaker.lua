@@ -0,0 +1,95 @@
+-- 熔断器状态更新，读取、状态迁移和写回在一次脚本中完成
+-- KEYS[1]: 熔断器标识，值为 JSON 格式的状态
+-- ARGV[1]: 本次请求是否成功（1/0）
+-- ARGV[2]: 是否立即熔断（1/0）
+-- ARGV[3]: 当前时间（秒）
+-- ARGV[4]: 连续失败阈值
+-- ARGV[5]: 半开状态下关闭所需的连续成功次数
+-- ARGV[6]: 基础冷却时间（秒）
+-- ARGV[7]: 最大冷却时间（秒）
+-- ARGV[8]: 状态过期时间（秒）
+-- 返回 {事件, 冷却时间}：事件 0 表示无变化，1 表示熔断，2 表示恢复关闭
+
+local key = KEYS[1]
+local success = ARGV[1] == '1'
+local force = ARGV[2] == '1'
+local now = tonumber(ARGV[3])
+local failureThreshold = tonumber(ARGV[4])
+local halfOpenSuccessThreshold = tonumber(ARGV[5])
+local cooldownSeconds = tonumber(ARGV[6])
+local maxCooldownSeconds = tonumber(ARGV[7])
+local ttl = tonumber(ARGV[8])
+
+local state = { state = 'closed', failures = 0, successes = 0, trips = 0, open_until = 0 }
+local raw = redis.call('GET', key)
+if raw then
+    local ok, decoded = pcall(cjson.decode, raw)
+    if ok and type(decoded) == 'table' then
+        state.state = decoded.state or 'closed'
+        state.failures = tonumber(decoded.failures) or 0
+        state.successes = tonumber(decoded.successes) or 0
+        state.trips = tonumber(decoded.trips) or 0
+        state.open_until = tonumber(decoded.open_until) or 0
+    end
+end
+
+local effective = state.state
+if effective == '' then
+    effective = 'closed'
+elseif effective == 'open' and now >= state.open_until then
+    effective = 'half_open'
+end
+
+local function trip()
+    local cooldown = cooldownSeconds
+    local i = 1
+    while i < state.trips + 1 and cooldown < maxCooldownSeconds do
+        cooldown = cooldown * 2
+        i = i + 1
+    end
+    if maxCooldownSeconds > 0 and cooldown > maxCooldownSeconds then
+        cooldown = maxCooldownSeconds
+    end
+    state.state = 'open'
+    state.trips = state.trips + 1
+    state.failures = 0
+    state.successes = 0
+    state.open_until = now + cooldown
+    return cooldown
+end
+
+local event = 0
+local cooldown = 0
+if effective == 'closed' then
+    if success then
+        if state.failures == 0 then
+            return { 0, 0 }
+        end
+        state.failures = 0
+    else
+        state.failures = state.failures + 1
+        if force or state.failures >= failureThreshold then
+            cooldown = trip()
+            event = 1
+        end
+    end
+elseif effective == 'half_open' then
+    if success then
+        state.successes = state.successes + 1
+        if state.successes >= halfOpenSuccessThreshold then
+            state = { state = 'closed', failures = 0, successes = 0, trips = 0, open_until = 0 }
+            event = 2
+        else
+            state.state = 'half_open'
+        end
+    else
+        cooldown = trip()
+        event = 1
+    end
+else
+    -- 熔断前发出的请求在熔断后返回，不影响状态
+    return { 0, 0 }
+end
+
+redis.call('SET', key, cjson.encode(state), 'EX', ttl)
+return { event, cooldown }
@@ -0,0 +1,9 @@
+-- 参数：KEYS[1] 冷却键，ARGV[1] 冷却截止时间（Unix 毫秒），ARGV[2] 过期时间（毫秒）
+-- 已有更晚的截止时间时保留
+local until_ms = tonumber(ARGV[1])
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current >= until_ms then
+    return current
+end
+redis.call('SET', KEYS[1], until_ms, 'PX', tonumber(ARGV[2]))
+return until_ms
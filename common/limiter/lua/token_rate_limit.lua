@@ -0,0 +1,24 @@
+-- 固定窗口 token 计数器，一次检查并预占多个计数器
+-- KEYS[i]: 计数器标识（键名包含窗口编号）
+-- ARGV[1]: 预占的 token 数
+-- ARGV[2i]: KEYS[i] 的上限
+-- ARGV[2i+1]: KEYS[i] 的过期时间（秒）
+-- 返回 0 表示全部通过并已预占，否则返回第一个超限的计数器序号（从 1 开始）
+
+local requested = tonumber(ARGV[1])
+
+for i, key in ipairs(KEYS) do
+    local limit = tonumber(ARGV[2 * i])
+    local used = tonumber(redis.call('GET', key) or '0')
+    if used >= limit or used + requested > limit then
+        return i
+    end
+end
+
+for i, key in ipairs(KEYS) do
+    local ttl = tonumber(ARGV[2 * i + 1])
+    redis.call('INCRBY', key, requested)
+    redis.call('EXPIRE', key, ttl)
+end
+
+return 0
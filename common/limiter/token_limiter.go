@@ -0,0 +1,41 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+//go:embed lua/token_rate_limit.lua
+var tokenRateLimitScript string
+
+var tokenRateLimit = redis.NewScript(tokenRateLimitScript)
+
+// TokenCounter 固定窗口的 token 计数器
+type TokenCounter struct {
+	Key        string
+	Limit      int64
+	TTLSeconds int64
+}
+
+// ReserveTokens 原子地检查所有计数器，全部未超限时各预占 requested 个 token。
+// 返回第一个超限计数器的下标，全部通过时返回 -1
+func ReserveTokens(ctx context.Context, rdb *redis.Client, counters []TokenCounter, requested int64) (int, error) {
+	if len(counters) == 0 {
+		return -1, nil
+	}
+	keys := make([]string, 0, len(counters))
+	args := make([]interface{}, 0, 1+2*len(counters))
+	args = append(args, requested)
+	for _, counter := range counters {
+		keys = append(keys, counter.Key)
+		args = append(args, counter.Limit, counter.TTLSeconds)
+	}
+	result, err := tokenRateLimit.Run(ctx, rdb, keys, args...).Int()
+	if err != nil {
+		return 0, fmt.Errorf("token rate limit failed: %w", err)
+	}
+	return result - 1, nil
+}
@@ -11,4 +11,8 @@ const (
 const (
 	TokenFiledRemainQuota = "RemainQuota"
 	TokenFieldGroup       = "Group"
+
+	TokenFieldBudgetUsed      = "BudgetUsed"
+	TokenFieldBudgetStartTime = "BudgetStartTime"
+	TokenFieldBudgetResetTime = "BudgetResetTime"
 )
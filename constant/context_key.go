@@ -19,6 +19,39 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenModelFallbacks    ContextKey = "token_model_fallbacks"
+	ContextKeyTokenHedgeDelayMs      ContextKey = "token_hedge_delay_ms"
+	ContextKeyTokenTPM               ContextKey = "token_tpm"
+	ContextKeyTokenTPD               ContextKey = "token_tpd"
+	ContextKeyTokenHasBudget         ContextKey = "token_has_budget"
+	ContextKeyTokenPolicy            ContextKey = "token_policy"
+
+	// ContextKeyHedgeChannels 对冲请求使用的渠道 id，按发起顺序排列
+	ContextKeyHedgeChannels ContextKey = "hedge_channels"
+	// ContextKeyKeepAliveSize 对冲请求决出胜者前保活 ping 写出的字节数，判断是否已向下游写出内容时不计入
+	ContextKeyKeepAliveSize ContextKey = "keep_alive_size"
+
+	// ContextKeyTokenRateLimitReservation 本次请求在 TPM/TPD 计数器中预占的 token
+	ContextKeyTokenRateLimitReservation ContextKey = "token_rate_limit_reservation"
+
+	// ContextKeyChannelPoolProxy 本次请求从代理池中选出的代理地址
+	ContextKeyChannelPoolProxy ContextKey = "channel_pool_proxy"
+
+	// ContextKeyVirtualModel 请求使用的虚拟模型名称
+	ContextKeyVirtualModel ContextKey = "virtual_model"
+
+	// ContextKeyModelFallbackFrom 发生模型回退时用户请求的原始模型
+	ContextKeyModelFallbackFrom ContextKey = "model_fallback_from"
+
+	// ContextKeyFileChannelId 请求引用了上游文件时固定使用的渠道 id
+	ContextKeyFileChannelId ContextKey = "file_channel_id"
+
+	/* batch related keys */
+	ContextKeyBatchId            ContextKey = "batch_id"
+	ContextKeyBatchDiscountRatio ContextKey = "batch_discount_ratio"
+
+	// ContextKeyResponsesResult 转换渠道生成的 Responses 响应，用于网关侧存储
+	ContextKeyResponsesResult ContextKey = "responses_result"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -54,6 +87,9 @@ const (
 
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
+	// ContextKeyLocalModeration 审核请求使用本地审核，不转发上游
+	ContextKeyLocalModeration ContextKey = "local_moderation"
+
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
 
 	// ContextKeyFileSourcesToCleanup stores file sources that need cleanup when request ends
@@ -0,0 +1,638 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	batchCompletionWindow   = "24h"
+	batchScheduleInterval   = 10 * time.Second
+	batchScheduleQueryLimit = 100
+)
+
+// CreateBatch POST /v1/batches
+func CreateBatch(c *gin.Context) {
+	if !operation_setting.GetBatchSetting().Enabled {
+		RelayNotImplemented(c)
+		return
+	}
+	var req dto.OpenAIBatchCreateRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		fileApiError(c, http.StatusBadRequest, "invalid_request_error", "Invalid request body: "+err.Error())
+		return
+	}
+	if req.InputFileId == "" {
+		fileApiError(c, http.StatusBadRequest, "invalid_request_error", "Missing required parameter: 'input_file_id'")
+		return
+	}
+	if !service.IsBatchEndpointSupported(req.Endpoint) {
+		fileApiError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Unsupported endpoint: '%s'", req.Endpoint))
+		return
+	}
+	if req.CompletionWindow != batchCompletionWindow {
+		fileApiError(c, http.StatusBadRequest, "invalid_request_error", "Invalid completion_window, only '24h' is supported")
+		return
+	}
+	if len(req.Metadata) > 16 {
+		fileApiError(c, http.StatusBadRequest, "invalid_request_error", "Metadata can have at most 16 key-value pairs")
+		return
+	}
+	userId := c.GetInt("id")
+	tokenId := c.GetInt("token_id")
+	inputFile, err := model.GetUserFile(userId, tokenId, req.InputFileId)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to query batch input file: %s", err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to query input file")
+		return
+	}
+	if inputFile == nil {
+		fileApiError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such File object: %s", req.InputFileId))
+		return
+	}
+	if inputFile.Purpose != "batch" {
+		fileApiError(c, http.StatusBadRequest, "invalid_request_error", "The input file must be uploaded with purpose 'batch'")
+		return
+	}
+
+	now := time.Now().Unix()
+	batch := &model.Batch{
+		BatchId:          model.GenerateBatchId(),
+		UserId:           userId,
+		TokenId:          tokenId,
+		Group:            common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+		Endpoint:         req.Endpoint,
+		InputFileId:      req.InputFileId,
+		CompletionWindow: req.CompletionWindow,
+		Status:           dto.OpenAIBatchStatusValidating,
+		CreatedAt:        now,
+		ExpiresAt:        now + 24*3600,
+	}
+	if len(req.Metadata) > 0 {
+		metadata, _ := common.Marshal(req.Metadata)
+		batch.Metadata = string(metadata)
+	}
+	if err := batch.Insert(); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to create batch: %s", err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to create batch")
+		return
+	}
+	wakeupBatchScheduler()
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+// getRequestUserBatch 获取当前令牌下的批次，不存在时直接写入 404 响应
+func getRequestUserBatch(c *gin.Context) *model.Batch {
+	batchId := c.Param("id")
+	batch, err := model.GetUserBatch(c.GetInt("id"), c.GetInt("token_id"), batchId)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to query batch %s: %s", batchId, err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to query batch")
+		return nil
+	}
+	if batch == nil {
+		fileApiError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such Batch object: %s", batchId))
+		return nil
+	}
+	return batch
+}
+
+// RetrieveBatch GET /v1/batches/:id
+func RetrieveBatch(c *gin.Context) {
+	batch := getRequestUserBatch(c)
+	if batch == nil {
+		return
+	}
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+// ListBatches GET /v1/batches
+func ListBatches(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	batches, hasMore, err := model.ListUserBatches(c.GetInt("id"), c.GetInt("token_id"), c.Query("after"), limit)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to list batches: %s", err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to list batches")
+		return
+	}
+	list := dto.OpenAIBatchList{
+		Object:  "list",
+		Data:    make([]dto.OpenAIBatch, 0, len(batches)),
+		HasMore: hasMore,
+	}
+	for _, batch := range batches {
+		list.Data = append(list.Data, batch.ToOpenAIBatch())
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// CancelBatch POST /v1/batches/:id/cancel
+// 批次先进入 cancelling 状态，由执行协程在当前一组请求完成后收尾为 cancelled
+func CancelBatch(c *gin.Context) {
+	batch := getRequestUserBatch(c)
+	if batch == nil {
+		return
+	}
+	if batch.Status != dto.OpenAIBatchStatusValidating && batch.Status != dto.OpenAIBatchStatusInProgress {
+		if batch.Status == dto.OpenAIBatchStatusCancelling {
+			c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+			return
+		}
+		fileApiError(c, http.StatusConflict, "invalid_request_error",
+			fmt.Sprintf("Cannot cancel a batch with status '%s'", batch.Status))
+		return
+	}
+	fromStatus := batch.Status
+	batch.Status = dto.OpenAIBatchStatusCancelling
+	batch.CancellingAt = time.Now().Unix()
+	won, err := batch.UpdateWithStatus(fromStatus)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to cancel batch %s: %s", batch.BatchId, err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to cancel batch")
+		return
+	}
+	if !won {
+		// 状态已被执行协程推进，返回最新状态
+		batch = getRequestUserBatch(c)
+		if batch == nil {
+			return
+		}
+	}
+	wakeupBatchScheduler()
+	c.JSON(http.StatusOK, batch.ToOpenAIBatch())
+}
+
+// ---------------------------------------------------------------------------
+// 批次执行
+// ---------------------------------------------------------------------------
+
+var (
+	batchSchedulerOnce sync.Once
+	batchWakeup        = make(chan struct{}, 1)
+	batchRunning       sync.Map // batch id -> struct{}
+	batchRunningCount  atomic.Int32
+
+	batchEngineOnce sync.Once
+	batchEngine     *gin.Engine
+)
+
+// batchRunContext 注入到批次内部请求的上下文，供 batchContextMiddleware 还原令牌信息
+type batchRunContext struct {
+	batch *model.Batch
+	token *model.Token
+	user  *model.UserBase
+}
+
+type batchRunContextKey struct{}
+
+// StartBatchScheduler 在主节点启动批次调度，定期拉取未完成的批次并在后台执行
+func StartBatchScheduler() {
+	batchSchedulerOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("batch scheduler started: tick=%s", batchScheduleInterval))
+			ticker := time.NewTicker(batchScheduleInterval)
+			defer ticker.Stop()
+			for {
+				scheduleBatches()
+				select {
+				case <-ticker.C:
+				case <-batchWakeup:
+				}
+			}
+		})
+	})
+}
+
+func wakeupBatchScheduler() {
+	select {
+	case batchWakeup <- struct{}{}:
+	default:
+	}
+}
+
+func scheduleBatches() {
+	batches, err := model.GetUnfinishedBatches(batchScheduleQueryLimit)
+	if err != nil {
+		logger.LogWarn(context.Background(), fmt.Sprintf("failed to query unfinished batches: %v", err))
+		return
+	}
+	maxRunning := int32(operation_setting.GetBatchSetting().MaxRunningBatches)
+	if maxRunning <= 0 {
+		maxRunning = 1
+	}
+	for _, batch := range batches {
+		if _, running := batchRunning.Load(batch.Id); running {
+			continue
+		}
+		if batchRunningCount.Load() >= maxRunning {
+			return
+		}
+		batchRunning.Store(batch.Id, struct{}{})
+		batchRunningCount.Add(1)
+		b := batch
+		gopool.Go(func() {
+			defer func() {
+				batchRunning.Delete(b.Id)
+				batchRunningCount.Add(-1)
+				if r := recover(); r != nil {
+					common.SysError(fmt.Sprintf("batch %s panic: %v", b.BatchId, r))
+				}
+			}()
+			runBatch(b)
+		})
+	}
+}
+
+func runBatch(batch *model.Batch) {
+	ctx := context.Background()
+	if batch.Status == dto.OpenAIBatchStatusValidating {
+		if !validateBatch(batch) {
+			return
+		}
+	}
+	switch batch.Status {
+	case dto.OpenAIBatchStatusInProgress:
+		executeBatch(batch)
+	case dto.OpenAIBatchStatusFinalizing:
+		finishBatch(batch, dto.OpenAIBatchStatusFinalizing, dto.OpenAIBatchStatusCompleted, nil)
+	case dto.OpenAIBatchStatusCancelling:
+		finishBatch(batch, dto.OpenAIBatchStatusCancelling, dto.OpenAIBatchStatusCancelled, nil)
+	default:
+		logger.LogDebug(ctx, "batch %s in status %s, skip", batch.BatchId, batch.Status)
+	}
+}
+
+// validateBatch 校验输入文件并进入 in_progress，返回是否可以继续执行
+func validateBatch(batch *model.Batch) bool {
+	ctx := context.Background()
+	lines, lineErrs, err := service.LoadBatchInput(batch)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("failed to load batch %s input: %s", batch.BatchId, err.Error()))
+		lineErrs = []dto.OpenAIBatchError{{Code: "invalid_input_file", Message: "Failed to read the input file."}}
+		if errors.Is(err, os.ErrNotExist) {
+			// 批次只在主节点执行，其他节点上传的本地文件需要通过 FILE_STORE_DIR 共享
+			logger.LogError(ctx, fmt.Sprintf("batch %s input file is not on this node, FILE_STORE_DIR must point to storage shared by all nodes", batch.BatchId))
+		}
+	}
+	if len(lineErrs) > 0 {
+		finishBatch(batch, dto.OpenAIBatchStatusValidating, dto.OpenAIBatchStatusFailed, lineErrs)
+		return false
+	}
+	outputPath, err := common.CreateDiskStoreFile()
+	if err == nil {
+		batch.OutputPath = outputPath
+		batch.ErrorPath, err = common.CreateDiskStoreFile()
+	}
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("failed to create batch %s result files: %s", batch.BatchId, err.Error()))
+		finishBatch(batch, dto.OpenAIBatchStatusValidating, dto.OpenAIBatchStatusFailed,
+			[]dto.OpenAIBatchError{{Code: "server_error", Message: "Failed to create result files."}})
+		return false
+	}
+	batch.TotalRequests = len(lines)
+	batch.Status = dto.OpenAIBatchStatusInProgress
+	batch.InProgressAt = time.Now().Unix()
+	won, err := batch.UpdateWithStatus(dto.OpenAIBatchStatusValidating)
+	if err != nil || !won {
+		// 校验期间被取消，交由下一轮调度收尾
+		_ = os.Remove(batch.OutputPath)
+		_ = os.Remove(batch.ErrorPath)
+		return false
+	}
+	return true
+}
+
+// failBatchLines 将未执行或结果未知的请求以指定错误写入错误文件
+func failBatchLines(batch *model.Batch, lines []dto.BatchRequestLine, code string, message string) error {
+	results := make([]dto.BatchResponseLine, 0, len(lines))
+	for _, line := range lines {
+		results = append(results, dto.BatchResponseLine{
+			Id:       "batch_req_" + common.GetRandomString(24),
+			CustomId: line.CustomId,
+			Error:    &dto.BatchLineError{Code: code, Message: message},
+		})
+	}
+	_, failed, err := service.AppendBatchResults(batch, results)
+	if err != nil {
+		logger.LogError(context.Background(), fmt.Sprintf("failed to write batch %s %s results: %s", batch.BatchId, code, err.Error()))
+		return err
+	}
+	batch.FailedCount += failed
+	batch.ProcessedLines += len(lines)
+	return nil
+}
+
+// expireBatchRemaining 超出完成时间窗口时，未执行的请求以 batch_expired 写入错误文件
+func expireBatchRemaining(batch *model.Batch, remaining []dto.BatchRequestLine) {
+	_ = failBatchLines(batch, remaining, "batch_expired", "This request could not be executed before the completion window expired.")
+}
+
+// recoverInterruptedLines 上次执行中断时已下发但未写入结果的请求可能已经计费，不再重复执行
+func recoverInterruptedLines(batch *model.Batch, lines []dto.BatchRequestLine) error {
+	if batch.DispatchedLines <= batch.ProcessedLines {
+		return nil
+	}
+	end := min(batch.DispatchedLines, len(lines))
+	if err := failBatchLines(batch, lines[batch.ProcessedLines:end], "batch_interrupted",
+		"This request was interrupted and its result is unknown; it has not been retried to avoid duplicate billing."); err != nil {
+		return err
+	}
+	batch.DispatchedLines = batch.ProcessedLines
+	return batch.UpdateProgress()
+}
+
+// executeBatch 按并发上限分组执行请求，每组完成后追加结果并持久化进度
+func executeBatch(batch *model.Batch) {
+	ctx := context.Background()
+	lines, lineErrs, err := service.LoadBatchInput(batch)
+	if err != nil || len(lineErrs) > 0 {
+		logger.LogError(ctx, fmt.Sprintf("failed to reload batch %s input", batch.BatchId))
+		finishBatch(batch, dto.OpenAIBatchStatusInProgress, dto.OpenAIBatchStatusFailed,
+			[]dto.OpenAIBatchError{{Code: "invalid_input_file", Message: "Failed to read the input file."}})
+		return
+	}
+	if err := recoverInterruptedLines(batch, lines); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("failed to recover batch %s interrupted lines: %s", batch.BatchId, err.Error()))
+		return
+	}
+
+	for batch.ProcessedLines < len(lines) {
+		latest, err := model.GetBatchById(batch.Id)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("failed to refresh batch %s: %s", batch.BatchId, err.Error()))
+			return
+		}
+		if latest.Status == dto.OpenAIBatchStatusCancelling {
+			batch.Status = latest.Status
+			batch.CancellingAt = latest.CancellingAt
+			finishBatch(batch, dto.OpenAIBatchStatusCancelling, dto.OpenAIBatchStatusCancelled, nil)
+			return
+		}
+		if latest.Status != dto.OpenAIBatchStatusInProgress {
+			return
+		}
+		if time.Now().Unix() > batch.ExpiresAt {
+			expireBatchRemaining(batch, lines[batch.ProcessedLines:])
+			finishBatch(batch, dto.OpenAIBatchStatusInProgress, dto.OpenAIBatchStatusExpired, nil)
+			return
+		}
+		run, runErr := newBatchRunContext(batch)
+		if runErr != nil {
+			finishBatch(batch, dto.OpenAIBatchStatusInProgress, dto.OpenAIBatchStatusFailed,
+				[]dto.OpenAIBatchError{{Code: "invalid_token", Message: runErr.Error()}})
+			return
+		}
+
+		concurrency := operation_setting.GetBatchSetting().Concurrency
+		if concurrency <= 0 {
+			concurrency = 1
+		}
+		end := batch.ProcessedLines + concurrency
+		if end > len(lines) {
+			end = len(lines)
+		}
+		// 先记录已下发的行再执行，中断后重启不会重复计费
+		batch.DispatchedLines = end
+		if err := batch.UpdateProgress(); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("failed to update batch %s progress: %s", batch.BatchId, err.Error()))
+			return
+		}
+		chunk := lines[batch.ProcessedLines:end]
+		results := make([]dto.BatchResponseLine, len(chunk))
+		var wg sync.WaitGroup
+		for i := range chunk {
+			wg.Add(1)
+			gopool.Go(func() {
+				defer wg.Done()
+				results[i] = executeBatchLine(run, &chunk[i])
+			})
+		}
+		wg.Wait()
+
+		completed, failed, err := service.AppendBatchResults(batch, results)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("failed to write batch %s results: %s", batch.BatchId, err.Error()))
+			finishBatch(batch, dto.OpenAIBatchStatusInProgress, dto.OpenAIBatchStatusFailed,
+				[]dto.OpenAIBatchError{{Code: "server_error", Message: "Failed to write batch results."}})
+			return
+		}
+		batch.CompletedCount += completed
+		batch.FailedCount += failed
+		batch.ProcessedLines = end
+		if err := batch.UpdateProgress(); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("failed to update batch %s progress: %s", batch.BatchId, err.Error()))
+		}
+	}
+
+	batch.Status = dto.OpenAIBatchStatusFinalizing
+	batch.FinalizingAt = time.Now().Unix()
+	won, err := batch.UpdateWithStatus(dto.OpenAIBatchStatusInProgress)
+	if err != nil || !won {
+		// 可能在最后一组执行期间被取消，交由下一轮调度收尾
+		return
+	}
+	finishBatch(batch, dto.OpenAIBatchStatusFinalizing, dto.OpenAIBatchStatusCompleted, nil)
+}
+
+// finishBatch 生成结果文件并将批次推进到终态
+func finishBatch(batch *model.Batch, fromStatus string, toStatus string, batchErrs []dto.OpenAIBatchError) {
+	ctx := context.Background()
+	if err := service.FinalizeBatchFiles(batch); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("failed to finalize batch %s files: %s", batch.BatchId, err.Error()))
+	}
+	now := time.Now().Unix()
+	batch.Status = toStatus
+	switch toStatus {
+	case dto.OpenAIBatchStatusCompleted:
+		batch.CompletedAt = now
+	case dto.OpenAIBatchStatusFailed:
+		batch.FailedAt = now
+	case dto.OpenAIBatchStatusExpired:
+		batch.ExpiredAt = now
+	case dto.OpenAIBatchStatusCancelled:
+		batch.CancelledAt = now
+	}
+	if len(batchErrs) > 0 {
+		errData, _ := common.Marshal(batchErrs)
+		batch.Errors = string(errData)
+	}
+	won, err := batch.UpdateWithStatus(fromStatus)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("failed to update batch %s status: %s", batch.BatchId, err.Error()))
+		return
+	}
+	if !won {
+		logger.LogInfo(ctx, fmt.Sprintf("batch %s already transitioned from %s, skip", batch.BatchId, fromStatus))
+		return
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("batch %s %s: completed=%d, failed=%d, total=%d",
+		batch.BatchId, toStatus, batch.CompletedCount, batch.FailedCount, batch.TotalRequests))
+}
+
+// newBatchRunContext 校验批次所属令牌和用户，令牌失效时批次不再继续执行
+func newBatchRunContext(batch *model.Batch) (*batchRunContext, error) {
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil {
+		return nil, fmt.Errorf("token of this batch is no longer available")
+	}
+	token, err = model.ValidateUserToken(token.Key)
+	if err != nil {
+		return nil, err
+	}
+	user, err := model.GetUserCache(token.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != common.UserStatusEnabled {
+		return nil, fmt.Errorf("user is disabled")
+	}
+	return &batchRunContext{batch: batch, token: token, user: user}, nil
+}
+
+// getBatchEngine 批次内部请求使用的路由，复用分发和 Relay 流程
+func getBatchEngine() *gin.Engine {
+	batchEngineOnce.Do(func() {
+		engine := gin.New()
+		engine.Use(gin.Recovery())
+		engine.Use(middleware.RequestId())
+		engine.Use(middleware.I18n())
+		engine.Use(batchContextMiddleware())
+		engine.Use(middleware.BodyStorageCleanup())
+		engine.Use(middleware.Distribute())
+		engine.POST("/v1/chat/completions", func(c *gin.Context) {
+			Relay(c, types.RelayFormatOpenAI)
+		})
+		engine.POST("/v1/completions", func(c *gin.Context) {
+			Relay(c, types.RelayFormatOpenAI)
+		})
+		engine.POST("/v1/embeddings", func(c *gin.Context) {
+			Relay(c, types.RelayFormatEmbedding)
+		})
+		engine.POST("/v1/responses", func(c *gin.Context) {
+			Relay(c, types.RelayFormatOpenAIResponses)
+		})
+		batchEngine = engine
+	})
+	return batchEngine
+}
+
+// batchContextMiddleware 按批次所属令牌还原 TokenAuth 写入的上下文
+func batchContextMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		run, ok := c.Request.Context().Value(batchRunContextKey{}).(*batchRunContext)
+		if !ok || run == nil {
+			abortWithBatchError(c, "batch context is missing")
+			return
+		}
+		run.user.WriteContext(c)
+		common.SetContextKey(c, constant.ContextKeyUsingGroup, run.batch.Group)
+		if err := middleware.SetupContextForToken(c, run.token); err != nil {
+			abortWithBatchError(c, err.Error())
+			return
+		}
+		common.SetContextKey(c, constant.ContextKeyBatchId, run.batch.BatchId)
+		if discount := operation_setting.GetBatchDiscountRatio(); discount != 1 {
+			common.SetContextKey(c, constant.ContextKeyBatchDiscountRatio, discount)
+		}
+		c.Next()
+	}
+}
+
+func abortWithBatchError(c *gin.Context, message string) {
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": types.OpenAIError{
+			Message: message,
+			Type:    "new_api_error",
+		},
+	})
+	c.Abort()
+}
+
+// executeBatchLine 通过内部路由执行单行请求，计费与普通请求一致（每行一个 BillingSession）
+func executeBatchLine(run *batchRunContext, line *dto.BatchRequestLine) dto.BatchResponseLine {
+	result := dto.BatchResponseLine{
+		Id:       "batch_req_" + common.GetRandomString(24),
+		CustomId: line.CustomId,
+	}
+	var streamCheck struct {
+		Stream bool `json:"stream"`
+	}
+	if err := common.Unmarshal(line.Body, &streamCheck); err == nil && streamCheck.Stream {
+		result.Error = &dto.BatchLineError{Code: "invalid_request", Message: "Streaming is not supported in batch requests."}
+		return result
+	}
+
+	ctx := context.WithValue(context.Background(), batchRunContextKey{}, run)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, line.Url, bytes.NewReader(line.Body))
+	if err != nil {
+		result.Error = &dto.BatchLineError{Code: "invalid_request", Message: err.Error()}
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	w := newBatchResponseWriter()
+	getBatchEngine().ServeHTTP(w, req)
+
+	body := w.body.Bytes()
+	if !common.ValidJson(body) {
+		body, _ = common.Marshal(string(body))
+	}
+	result.Response = &dto.BatchResponse{
+		StatusCode: w.status,
+		RequestId:  w.header.Get(common.RequestIdKey),
+		Body:       body,
+	}
+	return result
+}
+
+// batchResponseWriter 批次内部请求的响应写入器，将响应缓存在内存中
+type batchResponseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func newBatchResponseWriter() *batchResponseWriter {
+	return &batchResponseWriter{header: make(http.Header), status: http.StatusOK}
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *batchResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *batchResponseWriter) Flush() {}
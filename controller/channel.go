@@ -65,6 +65,34 @@ func clearChannelInfo(channel *model.Channel) {
 		channel.ChannelInfo.MultiKeyDisabledReason = nil
 		channel.ChannelInfo.MultiKeyDisabledTime = nil
 	}
+	// mTLS 私钥与渠道密钥一样不返回给前端
+	if channel.OtherSettings != "" {
+		settings := channel.GetOtherSettings()
+		if settings.Transport != nil && settings.Transport.ClientKey != "" {
+			settings.Transport.ClientKey = ""
+			channel.SetOtherSettings(settings)
+		}
+	}
+}
+
+// restoreChannelTransportClientKey 更新渠道时未提交 mTLS 私钥则沿用原有私钥
+func restoreChannelTransportClientKey(channel *model.Channel, originChannel *model.Channel) {
+	if channel.OtherSettings == "" {
+		return
+	}
+	settings := dto.ChannelOtherSettings{}
+	if err := common.UnmarshalJsonStr(channel.OtherSettings, &settings); err != nil {
+		return
+	}
+	if settings.Transport == nil || settings.Transport.ClientCert == "" || settings.Transport.ClientKey != "" {
+		return
+	}
+	originSettings := originChannel.GetOtherSettings()
+	if originSettings.Transport == nil || originSettings.Transport.ClientKey == "" {
+		return
+	}
+	settings.Transport.ClientKey = originSettings.Transport.ClientKey
+	channel.SetOtherSettings(settings)
 }
 
 func GetAllChannels(c *gin.Context) {
@@ -577,6 +605,22 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 	if err := channel.ValidateSettings(); err != nil {
 		return fmt.Errorf("渠道额外设置[channel setting] 格式错误：%s", err.Error())
 	}
+	if proxyPool := channel.GetSetting().ProxyPool; proxyPool != "" {
+		if exists, err := model.ProxyPoolExists(proxyPool); err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("代理池 %s 不存在", proxyPool)
+		}
+	}
+	if channel.OtherSettings != "" {
+		otherSettings := dto.ChannelOtherSettings{}
+		if err := common.UnmarshalJsonStr(channel.OtherSettings, &otherSettings); err != nil {
+			return fmt.Errorf("渠道其他设置格式错误：%s", err.Error())
+		}
+		if err := service.ValidateChannelTransport(otherSettings.Transport); err != nil {
+			return fmt.Errorf("渠道连接设置错误：%s", err.Error())
+		}
+	}
 
 	// 如果是添加操作，检查 channel 和 key 是否为空
 	if isAdd {
@@ -811,6 +855,7 @@ func DeleteChannel(c *gin.Context) {
 		return
 	}
 	model.InitChannelCache()
+	service.EvictChannelHttpClient(id)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -964,6 +1009,9 @@ func DeleteChannelBatch(c *gin.Context) {
 		return
 	}
 	model.InitChannelCache()
+	for _, id := range channelBatch.Ids {
+		service.EvictChannelHttpClient(id)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -986,17 +1034,19 @@ func UpdateChannel(c *gin.Context) {
 		return
 	}
 
-	// 使用统一的校验函数
-	if err := validateChannel(&channel.Channel, false); err != nil {
+	// Preserve existing ChannelInfo to ensure multi-key channels keep correct state even if the client does not send ChannelInfo in the request.
+	originChannel, err := model.GetChannelById(channel.Id, true)
+	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
 		})
 		return
 	}
-	// Preserve existing ChannelInfo to ensure multi-key channels keep correct state even if the client does not send ChannelInfo in the request.
-	originChannel, err := model.GetChannelById(channel.Id, true)
-	if err != nil {
+	restoreChannelTransportClientKey(&channel.Channel, originChannel)
+
+	// 使用统一的校验函数
+	if err := validateChannel(&channel.Channel, false); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -1099,6 +1149,7 @@ func UpdateChannel(c *gin.Context) {
 	}
 	model.InitChannelCache()
 	service.ResetProxyClientCache()
+	service.EvictChannelHttpClient(channel.Id)
 	channel.Key = ""
 	clearChannelInfo(&channel.Channel)
 	c.JSON(http.StatusOK, gin.H{
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelUtilization 返回配置了并发或速率限制的渠道的当前用量
+func GetChannelUtilization(c *gin.Context) {
+	channels, err := model.GetAllChannels(0, 0, true, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	result := make([]*service.ChannelUtilization, 0)
+	for _, channel := range channels {
+		setting := channel.GetSetting()
+		if !setting.HasThroughputLimit() {
+			continue
+		}
+		result = append(result, service.GetChannelUtilization(channel))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    result,
+	})
+}
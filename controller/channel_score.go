@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelScores 返回各渠道近期的延迟、首字时间、错误率统计及自适应选择系数
+func GetChannelScores(c *gin.Context) {
+	modelName := strings.TrimSpace(c.Query("model"))
+	snapshots := service.GetChannelStatsSnapshots()
+	result := make([]*service.ChannelStatsSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		if modelName != "" && snapshot.Model != modelName {
+			continue
+		}
+		if channel, err := model.CacheGetChannel(snapshot.ChannelId); err == nil {
+			snapshot.ChannelName = channel.Name
+		}
+		result = append(result, snapshot)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    result,
+	})
+}
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/vertex"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+const countTokensTestBody = `{"model":"claude-sonnet-4-20250514","max_tokens":1024,"system":"be brief","messages":[{"role":"user","content":"how many tokens is this?"}]}`
+
+// countTokensUpstream 记录上游收到的计数请求
+type countTokensUpstream struct {
+	requests atomic.Int32
+	host     string
+	path     string
+	header   http.Header
+	body     string
+}
+
+func (u *countTokensUpstream) handler(response string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u.requests.Add(1)
+		u.host = r.Host
+		u.path = r.URL.EscapedPath()
+		u.header = r.Header.Clone()
+		body, _ := io.ReadAll(r.Body)
+		u.body = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}
+}
+
+// newTunnelProxy 返回把所有 CONNECT 隧道转发到 upstream 的 HTTP 代理，用于拦截写死域名的 HTTPS 请求
+func newTunnelProxy(t *testing.T, upstream *httptest.Server) *httptest.Server {
+	t.Helper()
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		target, err := net.Dial("tcp", upstream.Listener.Addr().String())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		client, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		_, _ = client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() {
+			_, _ = io.Copy(target, client)
+			target.Close()
+		}()
+		_, _ = io.Copy(client, target)
+		client.Close()
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+// setupCountTokensTest 创建测试用户、令牌和一个指定类型的渠道
+func setupCountTokensTest(t *testing.T, channel *model.Channel) {
+	t.Helper()
+	setupRelayTest(t, "")
+	channel.Status = common.ChannelStatusEnabled
+	channel.Name = "count-tokens"
+	channel.Models = "claude-sonnet-4-20250514"
+	channel.Group = "default"
+	require.NoError(t, model.DB.Create(channel).Error)
+	require.NoError(t, channel.AddAbilities(nil))
+}
+
+func doCountTokensRequest(t *testing.T, body string) int {
+	t.Helper()
+	engine := gin.New()
+	engine.POST("/v1/messages/count_tokens", middleware.TokenAuth(), middleware.Distribute(), ClaudeCountTokens)
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages/count_tokens", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-"+relayTestTokenKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("anthropic-version", "2023-06-01")
+	engine.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var response struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	require.NoError(t, common.UnmarshalJsonStr(recorder.Body.String(), &response))
+	return response.InputTokens
+}
+
+// allowInsecureUpstream 测试上游使用自签名证书
+func allowInsecureUpstream(t *testing.T) {
+	saved := common.TLSInsecureSkipVerify
+	common.TLSInsecureSkipVerify = true
+	t.Cleanup(func() { common.TLSInsecureSkipVerify = saved })
+}
+
+func TestClaudeCountTokensProxiesToAnthropic(t *testing.T) {
+	upstream := &countTokensUpstream{}
+	server := httptest.NewServer(upstream.handler(`{"input_tokens":42}`))
+	t.Cleanup(server.Close)
+	modelMapping := `{"claude-sonnet-4-20250514":"claude-sonnet-4-5-20250929"}`
+	setupCountTokensTest(t, &model.Channel{Type: constant.ChannelTypeAnthropic, Key: "sk-ant-test", BaseURL: &server.URL, ModelMapping: &modelMapping})
+
+	require.Equal(t, 42, doCountTokensRequest(t, countTokensTestBody))
+	require.EqualValues(t, 1, upstream.requests.Load())
+	require.Equal(t, "/v1/messages/count_tokens", upstream.path)
+	require.Equal(t, "sk-ant-test", upstream.header.Get("x-api-key"))
+	// 计数请求使用映射后的模型，且不带 max_tokens 等生成参数
+	require.Contains(t, upstream.body, `"model":"claude-sonnet-4-5-20250929"`)
+	require.Contains(t, upstream.body, `"system":"be brief"`)
+	require.NotContains(t, upstream.body, "max_tokens")
+}
+
+func TestClaudeCountTokensProxiesToAws(t *testing.T) {
+	allowInsecureUpstream(t)
+	upstream := &countTokensUpstream{}
+	server := httptest.NewTLSServer(upstream.handler(`{"inputTokens":17}`))
+	t.Cleanup(server.Close)
+	proxy := newTunnelProxy(t, server)
+	setting := fmt.Sprintf(`{"proxy":%q}`, proxy.URL)
+	setupCountTokensTest(t, &model.Channel{Type: constant.ChannelTypeAws, Key: "AKIDTEST|secret|us-east-1", Setting: &setting})
+
+	require.Equal(t, 17, doCountTokensRequest(t, countTokensTestBody))
+	require.EqualValues(t, 1, upstream.requests.Load())
+	require.Equal(t, "bedrock-runtime.us-east-1.amazonaws.com", upstream.host)
+	require.Equal(t, "/model/anthropic.claude-sonnet-4-20250514-v1%3A0/count-tokens", upstream.path)
+	require.True(t, strings.HasPrefix(upstream.header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDTEST/"))
+
+	// InvokeModel 请求体以 base64 放在 input.invokeModel.body 中
+	var countRequest struct {
+		Input struct {
+			InvokeModel struct {
+				Body []byte `json:"body"`
+			} `json:"invokeModel"`
+		} `json:"input"`
+	}
+	require.NoError(t, common.UnmarshalJsonStr(upstream.body, &countRequest))
+	invokeBody := string(countRequest.Input.InvokeModel.Body)
+	require.Contains(t, invokeBody, `"anthropic_version":"bedrock-2023-05-31"`)
+	require.Contains(t, invokeBody, `"max_tokens":1`)
+	require.Contains(t, invokeBody, "how many tokens is this?")
+}
+
+func TestClaudeCountTokensProxiesToVertex(t *testing.T) {
+	allowInsecureUpstream(t)
+	upstream := &countTokensUpstream{}
+	server := httptest.NewTLSServer(upstream.handler(`{"input_tokens":23}`))
+	t.Cleanup(server.Close)
+	proxy := newTunnelProxy(t, server)
+	setting := fmt.Sprintf(`{"proxy":%q}`, proxy.URL)
+	region := "us-east5"
+	setupCountTokensTest(t, &model.Channel{
+		Type:    constant.ChannelTypeVertexAi,
+		Key:     `{"type":"service_account","project_id":"test-project","client_email":"test@test-project.iam.gserviceaccount.com"}`,
+		Setting: &setting,
+		Other:   region,
+	})
+	var channel model.Channel
+	require.NoError(t, model.DB.Where("name = ?", "count-tokens").First(&channel).Error)
+	// 预置访问令牌，避免向 Google 换取令牌
+	vertex.Cache.SetDefault(fmt.Sprintf("access-token-%d", channel.Id), "ya29.test")
+
+	require.Equal(t, 23, doCountTokensRequest(t, countTokensTestBody))
+	require.EqualValues(t, 1, upstream.requests.Load())
+	require.Equal(t, "us-east5-aiplatform.googleapis.com", upstream.host)
+	require.Equal(t, "/v1/projects/test-project/locations/us-east5/publishers/anthropic/models/count-tokens:rawPredict", upstream.path)
+	require.Equal(t, "Bearer ya29.test", upstream.header.Get("Authorization"))
+	require.Contains(t, upstream.body, `"model":"claude-sonnet-4@20250514"`)
+}
+
+func TestClaudeCountTokensEstimatesLocally(t *testing.T) {
+	upstream := &countTokensUpstream{}
+	server := httptest.NewServer(upstream.handler(`{"input_tokens":1000}`))
+	t.Cleanup(server.Close)
+	setupCountTokensTest(t, &model.Channel{Type: constant.ChannelTypeOpenAI, Key: "sk-test", BaseURL: &server.URL})
+
+	short := doCountTokensRequest(t, countTokensTestBody)
+	long := doCountTokensRequest(t, strings.Replace(countTokensTestBody, "how many tokens is this?", strings.Repeat("how many tokens is this? ", 20), 1))
+	// 其他类型渠道不请求上游，使用本地估算
+	require.Zero(t, upstream.requests.Load())
+	require.Positive(t, short)
+	require.Greater(t, long, short)
+}
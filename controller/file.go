@@ -0,0 +1,300 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func fileApiError(c *gin.Context, status int, errType string, message string) {
+	c.JSON(status, gin.H{
+		"error": types.OpenAIError{
+			Message: message,
+			Type:    errType,
+		},
+	})
+}
+
+func fileApiUpstreamError(c *gin.Context, newAPIError *types.NewAPIError) {
+	logger.LogError(c, fmt.Sprintf("file upstream request failed: %s", newAPIError.Error()))
+	c.JSON(newAPIError.StatusCode, gin.H{
+		"error": newAPIError.ToOpenAIError(),
+	})
+}
+
+// getRequestUserFile 获取当前令牌下的文件，不存在时直接写入 404 响应
+func getRequestUserFile(c *gin.Context) *model.File {
+	fileId := c.Param("id")
+	userId := c.GetInt("id")
+	tokenId := c.GetInt("token_id")
+	file, err := model.GetUserFile(userId, tokenId, fileId)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to query file %s: %s", fileId, err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to query file")
+		return nil
+	}
+	if file == nil {
+		fileApiError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("No such File object: %s", fileId))
+		return nil
+	}
+	return file
+}
+
+// getFileChannel 获取上游文件所在渠道，渠道不可用时直接写入错误响应
+func getFileChannel(c *gin.Context, file *model.File) *model.Channel {
+	channel, err := model.CacheGetChannel(file.ChannelId)
+	if err != nil || channel == nil {
+		fileApiError(c, http.StatusServiceUnavailable, "server_error", "The channel holding this file is no longer available")
+		return nil
+	}
+	if channel.Status != common.ChannelStatusEnabled {
+		fileApiError(c, http.StatusServiceUnavailable, "server_error", "The channel holding this file is disabled")
+		return nil
+	}
+	return channel
+}
+
+// ListFiles GET /v1/files
+func ListFiles(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	files, hasMore, err := model.ListUserFiles(c.GetInt("id"), c.GetInt("token_id"), model.FileListParams{
+		Purpose: c.Query("purpose"),
+		After:   c.Query("after"),
+		Limit:   limit,
+		Order:   c.Query("order"),
+	})
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to list files: %s", err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to list files")
+		return
+	}
+	list := dto.OpenAIFileList{
+		Object:  "list",
+		Data:    make([]dto.OpenAIFile, 0, len(files)),
+		HasMore: hasMore,
+	}
+	for _, file := range files {
+		list.Data = append(list.Data, file.ToOpenAIFile())
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// UploadFile POST /v1/files
+func UploadFile(c *gin.Context) {
+	purpose := c.PostForm("purpose")
+	if purpose == "" {
+		fileApiError(c, http.StatusBadRequest, "invalid_request_error", "Missing required parameter: 'purpose'")
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		fileApiError(c, http.StatusBadRequest, "invalid_request_error", "Missing required parameter: 'file'")
+		return
+	}
+	maxBytes := operation_setting.GetMaxFileSizeBytes()
+	if header.Size > maxBytes {
+		fileApiError(c, http.StatusRequestEntityTooLarge, "invalid_request_error",
+			fmt.Sprintf("File exceeds the maximum allowed size of %d bytes", maxBytes))
+		return
+	}
+	src, err := header.Open()
+	if err != nil {
+		fileApiError(c, http.StatusBadRequest, "invalid_request_error", "Failed to read uploaded file")
+		return
+	}
+	defer src.Close()
+
+	file := &model.File{
+		UserId:   c.GetInt("id"),
+		TokenId:  c.GetInt("token_id"),
+		Filename: header.Filename,
+		Purpose:  purpose,
+	}
+
+	fileSetting := operation_setting.GetFileSetting()
+	if fileSetting.StorageMode == operation_setting.FileStorageModeUpstream {
+		modelName := c.PostForm("model")
+		if modelName == "" {
+			modelName = fileSetting.UpstreamModel
+		}
+		channel, err := service.SelectFileUploadChannel(c, modelName)
+		if err != nil {
+			logger.LogWarn(c, fmt.Sprintf("failed to select file upload channel: %s", err.Error()))
+		}
+		if channel != nil {
+			uploaded, newAPIError := service.UploadFileToChannel(c.Request.Context(), channel, purpose, header.Filename, src)
+			if newAPIError != nil {
+				fileApiUpstreamError(c, newAPIError)
+				return
+			}
+			file.FileId = uploaded.Id
+			file.ChannelId = channel.Id
+			file.Bytes = uploaded.Bytes
+			file.Status = uploaded.Status
+			file.CreatedAt = uploaded.CreatedAt
+			if uploaded.ExpiresAt != nil {
+				file.ExpiresAt = *uploaded.ExpiresAt
+			}
+			if err := file.Insert(); err != nil {
+				logger.LogError(c, fmt.Sprintf("failed to save file record: %s", err.Error()))
+				fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to save file")
+				return
+			}
+			c.JSON(http.StatusOK, file.ToOpenAIFile())
+			return
+		}
+		if !fileSetting.FallbackToLocal {
+			fileApiError(c, http.StatusServiceUnavailable, "server_error",
+				fmt.Sprintf("No available channel supports file uploads for model %s", modelName))
+			return
+		}
+	}
+
+	storagePath, written, err := common.WriteDiskStoreFile(src, maxBytes)
+	if err != nil {
+		if common.IsRequestBodyTooLargeError(err) {
+			fileApiError(c, http.StatusRequestEntityTooLarge, "invalid_request_error",
+				fmt.Sprintf("File exceeds the maximum allowed size of %d bytes", maxBytes))
+			return
+		}
+		logger.LogError(c, fmt.Sprintf("failed to store file: %s", err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to store file")
+		return
+	}
+	file.FileId = model.GenerateFileId()
+	file.StoragePath = storagePath
+	file.Bytes = written
+	file.Status = dto.OpenAIFileStatusProcessed
+	file.CreatedAt = time.Now().Unix()
+	if fileSetting.LocalRetentionDays > 0 {
+		file.ExpiresAt = file.CreatedAt + int64(fileSetting.LocalRetentionDays)*24*3600
+	}
+	if err := file.Insert(); err != nil {
+		_ = os.Remove(storagePath)
+		logger.LogError(c, fmt.Sprintf("failed to save file record: %s", err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to save file")
+		return
+	}
+	c.JSON(http.StatusOK, file.ToOpenAIFile())
+}
+
+// RetrieveFile GET /v1/files/:id
+func RetrieveFile(c *gin.Context) {
+	file := getRequestUserFile(c)
+	if file == nil {
+		return
+	}
+	if !file.IsLocal() {
+		if channel := getFileChannel(c, file); channel != nil {
+			upstream, newAPIError := service.RetrieveChannelFile(c.Request.Context(), channel, file.FileId)
+			if newAPIError != nil {
+				fileApiUpstreamError(c, newAPIError)
+				return
+			}
+			// 同步上游处理状态
+			if upstream.Status != "" && upstream.Status != file.Status {
+				file.Status = upstream.Status
+				if err := file.Update(); err != nil {
+					logger.LogWarn(c, fmt.Sprintf("failed to update file %s status: %s", file.FileId, err.Error()))
+				}
+			}
+			c.JSON(http.StatusOK, upstream)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, file.ToOpenAIFile())
+}
+
+// DeleteFile DELETE /v1/files/:id
+func DeleteFile(c *gin.Context) {
+	file := getRequestUserFile(c)
+	if file == nil {
+		return
+	}
+	if file.IsLocal() {
+		if err := service.RemoveLocalFile(file); err != nil {
+			logger.LogError(c, fmt.Sprintf("failed to delete file %s: %s", file.FileId, err.Error()))
+			fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to delete file")
+			return
+		}
+	} else {
+		// 渠道已不可用时仅删除本地记录
+		channel, err := model.CacheGetChannel(file.ChannelId)
+		if err == nil && channel != nil && channel.Status == common.ChannelStatusEnabled {
+			if newAPIError := service.DeleteChannelFile(c.Request.Context(), channel, file.FileId); newAPIError != nil && newAPIError.StatusCode != http.StatusNotFound {
+				fileApiUpstreamError(c, newAPIError)
+				return
+			}
+		}
+		if err := file.Delete(); err != nil {
+			logger.LogError(c, fmt.Sprintf("failed to delete file %s: %s", file.FileId, err.Error()))
+			fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to delete file")
+			return
+		}
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleted{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
+
+// RetrieveFileContent GET /v1/files/:id/content
+func RetrieveFileContent(c *gin.Context) {
+	file := getRequestUserFile(c)
+	if file == nil {
+		return
+	}
+	if !file.IsLocal() {
+		channel := getFileChannel(c, file)
+		if channel == nil {
+			return
+		}
+		resp, newAPIError := service.OpenChannelFileContent(c.Request.Context(), channel, file.FileId)
+		if newAPIError != nil {
+			fileApiUpstreamError(c, newAPIError)
+			return
+		}
+		defer service.CloseResponseBodyGracefully(resp)
+		contentType := resp.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.Writer.Header().Set("Content-Type", contentType)
+		c.Writer.WriteHeader(http.StatusOK)
+		if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+			logger.LogError(c, fmt.Sprintf("failed to stream file content: %s", err.Error()))
+		}
+		return
+	}
+	f, err := common.OpenDiskStoreFile(file.StoragePath)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to open file %s: %s", file.FileId, err.Error()))
+		fileApiError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("Content of file %s is no longer available", file.FileId))
+		return
+	}
+	defer f.Close()
+	c.Writer.Header().Set("Content-Type", "application/octet-stream")
+	c.Writer.Header().Set("Content-Length", strconv.FormatInt(file.Bytes, 10))
+	c.Writer.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(c.Writer, f); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to stream file content: %s", err.Error()))
+	}
+}
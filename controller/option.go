@@ -205,6 +205,15 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "model_rate_limit_setting.rules":
+		err = operation_setting.ValidateModelRateLimitRules(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	case "console_setting.api_info":
 		err = console_setting.ValidateConsoleSettings(option.Value.(string), "ApiInfo")
 		if err != nil {
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProxyPools 获取代理池列表
+func GetProxyPools(c *gin.Context) {
+	pools, err := model.GetAllProxyPools()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, pools)
+}
+
+// CreateProxyPool 创建代理池
+func CreateProxyPool(c *gin.Context) {
+	var pool model.ProxyPool
+	if err := c.ShouldBindJSON(&pool); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := service.ValidateProxyPool(&pool); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if dup, err := model.IsProxyPoolNameDuplicated(0, pool.Name); err != nil {
+		common.ApiError(c, err)
+		return
+	} else if dup {
+		common.ApiErrorMsg(c, "代理池名称已存在")
+		return
+	}
+	if err := pool.Insert(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	reloadProxyPools()
+	common.ApiSuccess(c, &pool)
+}
+
+// UpdateProxyPool 更新代理池
+func UpdateProxyPool(c *gin.Context) {
+	var pool model.ProxyPool
+	if err := c.ShouldBindJSON(&pool); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if pool.Id == 0 {
+		common.ApiErrorMsg(c, "缺少代理池 ID")
+		return
+	}
+	if err := service.ValidateProxyPool(&pool); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	origin, err := model.GetProxyPoolById(pool.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if origin.Name != pool.Name {
+		if dup, err := model.IsProxyPoolNameDuplicated(pool.Id, pool.Name); err != nil {
+			common.ApiError(c, err)
+			return
+		} else if dup {
+			common.ApiErrorMsg(c, "代理池名称已存在")
+			return
+		}
+		if !checkProxyPoolUnused(c, origin.Name) {
+			return
+		}
+	}
+	if err := pool.Update(); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	reloadProxyPools()
+	common.ApiSuccess(c, &pool)
+}
+
+// DeleteProxyPool 删除代理池，被渠道引用时不允许删除
+func DeleteProxyPool(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pool, err := model.GetProxyPoolById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if !checkProxyPoolUnused(c, pool.Name) {
+		return
+	}
+	if err := model.DeleteProxyPoolById(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	reloadProxyPools()
+	common.ApiSuccess(c, nil)
+}
+
+func checkProxyPoolUnused(c *gin.Context, name string) bool {
+	channelIds, err := model.GetChannelIdsUsingProxyPool(name)
+	if err != nil {
+		common.ApiError(c, err)
+		return false
+	}
+	if len(channelIds) > 0 {
+		common.ApiErrorMsg(c, fmt.Sprintf("代理池正在被渠道使用：%v", channelIds))
+		return false
+	}
+	return true
+}
+
+func reloadProxyPools() {
+	if err := service.ReloadProxyPools(); err != nil {
+		common.SysError("failed to reload proxy pools: " + err.Error())
+	}
+}
@@ -33,7 +33,7 @@ import (
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
 	var err *types.NewAPIError
 	switch info.RelayMode {
-	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits:
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits, relayconstant.RelayModeImagesVariations:
 		err = relay.ImageHelper(c, info)
 	case relayconstant.RelayModeAudioSpeech:
 		fallthrough
@@ -115,17 +115,33 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	newAPIError = service.CheckTokenPolicy(c, relayFormat, request)
+	if newAPIError != nil {
+		return
+	}
+
 	relayInfo, err := relaycommon.GenRelayInfo(c, relayFormat, request, ws)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeGenRelayInfoFailed)
 		return
 	}
 
+	if common.GetContextKeyBool(c, constant.ContextKeyLocalModeration) {
+		newAPIError = relay.LocalModerationHelper(c, relayInfo)
+		return
+	}
+
+	newAPIError = service.CheckModelRateLimit(c, relayInfo)
+	if newAPIError != nil {
+		return
+	}
+
 	needSensitiveCheck := setting.ShouldCheckPromptSensitive()
 	needCountToken := constant.CountToken
+	needModerationCheck := operation_setting.GetModerationSetting().PreCheckEnabled && relayInfo.RelayMode != relayconstant.RelayModeModerations
 	// Avoid building huge CombineText (strings.Join) when token counting and sensitive check are both disabled.
 	var meta *types.TokenCountMeta
-	if needSensitiveCheck || needCountToken {
+	if needSensitiveCheck || needCountToken || needModerationCheck {
 		meta = request.GetTokenCountMeta()
 	} else {
 		meta = fastTokenCountMetaForPricing(request)
@@ -140,6 +156,14 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}
 
+	if needModerationCheck && meta != nil {
+		if flagged, categories := service.CheckModerationText(meta.CombineText); flagged {
+			logger.LogWarn(c, fmt.Sprintf("request flagged by local moderation: %s", strings.Join(categories, ", ")))
+			newAPIError = types.NewErrorWithStatusCode(fmt.Errorf("request flagged by moderation: %s", strings.Join(categories, ", ")), types.ErrorCodeModerationFlagged, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+			return
+		}
+	}
+
 	tokens, err := service.EstimateRequestToken(c, meta, relayInfo)
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeCountTokenFailed)
@@ -148,21 +172,21 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	relayInfo.SetEstimatePromptTokens(tokens)
 
-	priceData, err := helper.ModelPriceHelper(c, relayInfo, tokens, meta)
-	if err != nil {
-		newAPIError = types.NewError(err, types.ErrorCodeModelPriceError)
+	newAPIError = service.ReserveTokenRateLimit(c, relayInfo)
+	if newAPIError != nil {
 		return
 	}
+	defer func() {
+		if newAPIError != nil {
+			service.ReleaseTokenRateLimit(c)
+		}
+	}()
 
 	// common.SetContextKey(c, constant.ContextKeyTokenCountMeta, meta)
 
-	if priceData.FreeModel {
-		logger.LogInfo(c, fmt.Sprintf("模型 %s 免费，跳过预扣费", relayInfo.OriginModelName))
-	} else {
-		newAPIError = service.PreConsumeBilling(c, priceData.QuotaToPreConsume, relayInfo)
-		if newAPIError != nil {
-			return
-		}
+	newAPIError = priceAndPreConsume(c, relayInfo, request, tokens, meta)
+	if newAPIError != nil {
+		return
 	}
 
 	defer func() {
@@ -183,6 +207,84 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		Retry:      common.GetPointer(0),
 	}
 
+	for {
+		newAPIError = relayWithRetry(c, relayFormat, relayInfo, retryParam)
+		if newAPIError == nil {
+			return
+		}
+		// 主模型的渠道都失败或不可用时，切换到回退链上的下一个模型重新计价和选择渠道
+		if relayInfo.HasSendResponse() || responseWritten(c) || !service.ShouldFallbackModel(newAPIError) {
+			break
+		}
+		fallbackModels := service.GetFallbackModels(c, relayInfo.OriginModelName)
+		if len(fallbackModels) == 0 {
+			break
+		}
+		if fallbackErr := switchFallbackModel(c, relayInfo, request, fallbackModels[0], tokens, meta); fallbackErr != nil {
+			newAPIError = fallbackErr
+			break
+		}
+		retryParam = &service.RetryParam{
+			Ctx:        c,
+			TokenGroup: relayInfo.TokenGroup,
+			ModelName:  relayInfo.OriginModelName,
+			Retry:      common.GetPointer(0),
+		}
+	}
+
+	useChannel := c.GetStringSlice("use_channel")
+	if len(useChannel) > 1 {
+		retryLogStr := fmt.Sprintf("重试：%s", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(useChannel)), "->"), "[]"))
+		logger.LogInfo(c, retryLogStr)
+	}
+}
+
+// priceAndPreConsume 按当前模型计价并预扣费
+func priceAndPreConsume(c *gin.Context, relayInfo *relaycommon.RelayInfo, request dto.Request, tokens int, meta *types.TokenCountMeta) *types.NewAPIError {
+	var priceData types.PriceData
+	if imageRequest, ok := request.(*dto.ImageRequest); ok && relayInfo.RelayMode == relayconstant.RelayModeImagesVariations {
+		// 图片变体按张计费
+		priceData = helper.ModelPriceHelperPerImage(c, relayInfo, int(imageRequest.N))
+	} else {
+		var err error
+		priceData, err = helper.ModelPriceHelper(c, relayInfo, tokens, meta)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeModelPriceError)
+		}
+	}
+
+	if priceData.FreeModel {
+		logger.LogInfo(c, fmt.Sprintf("模型 %s 免费，跳过预扣费", relayInfo.OriginModelName))
+		return nil
+	}
+	return service.PreConsumeBilling(c, priceData.QuotaToPreConsume, relayInfo)
+}
+
+// switchFallbackModel 退还当前模型的预扣费和预占的 TPM/TPD，切换到回退模型后重新限流、计价和预扣费
+func switchFallbackModel(c *gin.Context, relayInfo *relaycommon.RelayInfo, request dto.Request, fallbackModel string, tokens int, meta *types.TokenCountMeta) *types.NewAPIError {
+	logger.LogWarn(c, fmt.Sprintf("all channels for model %s failed, falling back to %s", relayInfo.OriginModelName, fallbackModel))
+	if relayInfo.Billing != nil {
+		relayInfo.Billing.Refund(c)
+		relayInfo.Billing = nil
+	}
+	service.ReleaseTokenRateLimit(c)
+	service.MarkModelFallback(c, relayInfo.OriginModelName, fallbackModel)
+	service.ResetAutoGroupSelection(c)
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, fallbackModel)
+	relayInfo.OriginModelName = fallbackModel
+	request.SetModelName(fallbackModel)
+	// 模型限流按回退模型重新计数
+	if newAPIError := service.CheckModelRateLimit(c, relayInfo); newAPIError != nil {
+		return newAPIError
+	}
+	if newAPIError := service.ReserveTokenRateLimit(c, relayInfo); newAPIError != nil {
+		return newAPIError
+	}
+	return priceAndPreConsume(c, relayInfo, request, tokens, meta)
+}
+
+// relayWithRetry 在当前模型的渠道间重试
+func relayWithRetry(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam) (newAPIError *types.NewAPIError) {
 	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
 		channel, channelErr := getChannel(c, relayInfo, retryParam)
 		if channelErr != nil {
@@ -204,35 +306,68 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 		c.Request.Body = io.NopCloser(bodyStorage)
 
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo)
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo)
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo)
-		default:
-			newAPIError = relayHandler(c, relayInfo)
+		releaseChannel, limitErr := service.AcquireChannelLimit(c, channel.Id, relayInfo.GetEstimatePromptTokens())
+		if limitErr != nil {
+			// 渠道限流由本地触发，不计入渠道错误
+			logger.LogWarn(c, limitErr.Error())
+			newAPIError = limitErr
+			if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
+				break
+			}
+			continue
 		}
 
-		if newAPIError == nil {
-			return
+		if hedgeDelay := service.GetHedgeDelay(c); hedgeDelay > 0 && relayFormat != types.RelayFormatOpenAIRealtime {
+			newAPIError = relayHedged(c, relayFormat, relayInfo, retryParam, channel, releaseChannel, hedgeDelay)
+		} else {
+			attemptStart := time.Now()
+			newAPIError = relayAttempt(c, relayFormat, relayInfo)
+			releaseChannel()
+			newAPIError = finishAttempt(c, relayInfo, channel, attemptStart, newAPIError)
 		}
 
-		newAPIError = service.NormalizeViolationFeeError(newAPIError)
-
-		processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+		if newAPIError == nil {
+			return nil
+		}
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
 			break
 		}
 	}
+	return newAPIError
+}
 
-	useChannel := c.GetStringSlice("use_channel")
-	if len(useChannel) > 1 {
-		retryLogStr := fmt.Sprintf("重试：%s", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(useChannel)), "->"), "[]"))
-		logger.LogInfo(c, retryLogStr)
+// relayAttempt 使用上下文中已选中的渠道转发一次请求
+func relayAttempt(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo) *types.NewAPIError {
+	var newAPIError *types.NewAPIError
+	switch relayFormat {
+	case types.RelayFormatOpenAIRealtime:
+		newAPIError = relay.WssHelper(c, relayInfo)
+	case types.RelayFormatClaude:
+		newAPIError = relay.ClaudeHelper(c, relayInfo)
+	case types.RelayFormatGemini:
+		newAPIError = geminiRelayHandler(c, relayInfo)
+	default:
+		newAPIError = relayHandler(c, relayInfo)
 	}
+	// 首个 token 超时导致的失败统一转换为可重试的超时错误
+	if newAPIError != nil {
+		if timeoutErr := relayInfo.FirstTokenTimeoutError(); timeoutErr != nil {
+			return timeoutErr
+		}
+	}
+	return newAPIError
+}
+
+// finishAttempt 记录一次尝试的结果，失败时处理渠道错误
+func finishAttempt(c *gin.Context, relayInfo *relaycommon.RelayInfo, channel *model.Channel, attemptStart time.Time, newAPIError *types.NewAPIError) *types.NewAPIError {
+	recordChannelResult(c, relayInfo, channel.Id, attemptStart, newAPIError)
+	if newAPIError == nil {
+		return nil
+	}
+	newAPIError = service.NormalizeViolationFeeError(newAPIError)
+	processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+	return newAPIError
 }
 
 var upgrader = websocket.Upgrader{
@@ -242,6 +377,32 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// recordChannelResult 记录本次尝试的耗时与结果，供自适应渠道选择、熔断器和 Key 冷却使用
+func recordChannelResult(c *gin.Context, info *relaycommon.RelayInfo, channelId int, attemptStart time.Time, err *types.NewAPIError) {
+	keyIndex := 0
+	isMultiKey := common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey)
+	if isMultiKey {
+		keyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	}
+	if err != nil {
+		if cooldown := service.KeyCooldownDuration(err); cooldown > 0 {
+			logger.LogWarn(c, fmt.Sprintf("channel #%d key #%d rate limited by upstream, cooling down for %s", channelId, keyIndex, cooldown))
+			model.SetChannelKeyCooldown(channelId, common.GetContextKeyString(c, constant.ContextKeyChannelKey), cooldown)
+		}
+		if service.IsChannelFaultError(err) {
+			service.RecordChannelResult(channelId, info.OriginModelName, time.Since(attemptStart), 0, false)
+			service.RecordCircuitResult(channelId, keyIndex, isMultiKey, false, err.Error())
+		}
+		return
+	}
+	var ttft time.Duration
+	if info.IsStream && info.HasSendResponse() && info.FirstResponseTime.After(attemptStart) {
+		ttft = info.FirstResponseTime.Sub(attemptStart)
+	}
+	service.RecordChannelResult(channelId, info.OriginModelName, time.Since(attemptStart), ttft, true)
+	service.RecordCircuitResult(channelId, keyIndex, isMultiKey, true, "")
+}
+
 func addUsedChannel(c *gin.Context, channelId int) {
 	useChannel := c.GetStringSlice("use_channel")
 	useChannel = append(useChannel, fmt.Sprintf("%d", channelId))
@@ -276,7 +437,8 @@ func fastTokenCountMetaForPricing(request dto.Request) *types.TokenCountMeta {
 }
 
 func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service.RetryParam) (*model.Channel, *types.NewAPIError) {
-	if info.ChannelMeta == nil {
+	// 首次尝试直接使用分发阶段选中的渠道
+	if info.ChannelMeta == nil && len(c.GetStringSlice("use_channel")) == 0 {
 		autoBan := c.GetBool("auto_ban")
 		autoBanInt := 1
 		if !autoBan {
@@ -314,6 +476,10 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	if service.ShouldSkipRetryAfterChannelAffinityFailure(c) {
 		return false
 	}
+	// 引用了上游文件的请求只能发往文件所在渠道
+	if common.GetContextKeyInt(c, constant.ContextKeyFileChannelId) > 0 {
+		return false
+	}
 	if types.IsChannelError(openaiErr) {
 		return true
 	}
@@ -326,6 +492,10 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	// 首个 token 超时且尚未向下游写出时可以换渠道重试
+	if openaiErr.GetErrorCode() == types.ErrorCodeFirstTokenTimeout {
+		return !responseWritten(c)
+	}
 	code := openaiErr.StatusCode
 	if code >= 200 && code < 300 {
 		return false
@@ -336,14 +506,27 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return operation_setting.ShouldRetryByStatusCode(code)
 }
 
+// responseWritten 是否已向下游写出内容，对冲请求决出胜者前的保活 ping 不计入
+func responseWritten(c *gin.Context) bool {
+	if !c.Writer.Written() {
+		return false
+	}
+	keepAliveSize, ok := common.GetContextKeyType[int](c, constant.ContextKeyKeepAliveSize)
+	return !ok || c.Writer.Size() > keepAliveSize
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
 	if service.ShouldDisableChannel(channelError.ChannelType, err) && channelError.AutoBan {
-		gopool.Go(func() {
-			service.DisableChannel(channelError, err.ErrorWithStatusCode())
-		})
+		if service.ShouldCircuitReplaceAutoBan() {
+			service.TripCircuit(channelError.ChannelId, common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex), channelError.IsMultiKey, err.ErrorWithStatusCode())
+		} else {
+			gopool.Go(func() {
+				service.DisableChannel(channelError, err.ErrorWithStatusCode())
+			})
+		}
 	}
 
 	if constant.ErrorLogEnabled && types.IsRecordErrorLog(err) {
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const fallbackTestBody = `{"model":"gpt-4o-mini","messages":[{"role":"user","content":"hi"}]}`
+
+// setupFallbackTest 主模型 gpt-4o-mini 的渠道始终返回 500，回退模型 gpt-4o 的渠道正常返回
+func setupFallbackTest(t *testing.T) *atomic.Int32 {
+	t.Helper()
+	fallbackSetting := operation_setting.GetModelFallbackSetting()
+	saved := *fallbackSetting
+	fallbackSetting.Enabled = true
+	fallbackSetting.Chains = map[string][]string{"gpt-4o-mini": {"gpt-4o"}}
+	t.Cleanup(func() { *fallbackSetting = saved })
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error":{"message":"upstream down","type":"server_error"}}`)
+	}))
+	t.Cleanup(failing.Close)
+	var fallbackRequests atomic.Int32
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fallbackRequests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"from fallback"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1000,"completion_tokens":2,"total_tokens":1002}}`)
+	}))
+	t.Cleanup(healthy.Close)
+
+	setupRelayTest(t, "", failing.URL)
+	priority := int64(0)
+	channel := &model.Channel{
+		Type:     constant.ChannelTypeOpenAI,
+		Key:      "sk-upstream-fallback",
+		Status:   common.ChannelStatusEnabled,
+		Name:     "upstream-fallback",
+		BaseURL:  &healthy.URL,
+		Models:   "gpt-4o",
+		Group:    "default",
+		Priority: &priority,
+	}
+	if err := model.DB.Create(channel).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := channel.AddAbilities(nil); err != nil {
+		t.Fatal(err)
+	}
+	return &fallbackRequests
+}
+
+func TestRelayFallbackChecksModelRateLimit(t *testing.T) {
+	fallbackRequests := setupFallbackTest(t)
+	setting := operation_setting.GetModelRateLimitSetting()
+	saved := *setting
+	setting.Enabled = true
+	setting.Rules = []operation_setting.ModelRateLimitRule{{Model: "gpt-4o", DurationSeconds: 3600, UserLimit: 1}}
+	operation_setting.CompileModelRateLimitRules()
+	t.Cleanup(func() {
+		*setting = saved
+		operation_setting.CompileModelRateLimitRules()
+	})
+
+	recorder := doRelayTestRequest(newRelayTestEngine(), fallbackTestBody)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "from fallback") {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	// 回退模型同样受模型限流规则约束
+	recorder = doRelayTestRequest(newRelayTestEngine(), fallbackTestBody)
+	if recorder.Code != http.StatusTooManyRequests || !strings.Contains(recorder.Body.String(), "gpt-4o") {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if fallbackRequests.Load() != 1 {
+		t.Fatalf("fallback requests = %d, want 1", fallbackRequests.Load())
+	}
+}
+
+func TestRelayFallbackMovesTokenRateLimitReservation(t *testing.T) {
+	fallbackRequests := setupFallbackTest(t)
+	setting := operation_setting.GetTokenRateLimitSetting()
+	saved := *setting
+	setting.Enabled = true
+	// 约 300 token 的提示，主模型的 TPD 只够预占一次，回退模型的 TPD 只够一次请求的实际用量
+	setting.ModelLimits = map[string]operation_setting.TokenRateLimit{
+		"gpt-4o-mini": {TPD: 500},
+		"gpt-4o":      {TPD: 1000},
+	}
+	savedCountToken := constant.CountToken
+	constant.CountToken = true
+	t.Cleanup(func() {
+		*setting = saved
+		constant.CountToken = savedCountToken
+	})
+	body := strings.Replace(fallbackTestBody, `"hi"`, fmt.Sprintf("%q", strings.Repeat("hello ", 300)), 1)
+
+	recorder := doRelayTestRequest(newRelayTestEngine(), body)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "from fallback") {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	// 主模型的预占已归还，第二次请求在回退时因回退模型的 TPD 用完被拒绝
+	recorder = doRelayTestRequest(newRelayTestEngine(), body)
+	if recorder.Code != http.StatusTooManyRequests || !strings.Contains(recorder.Body.String(), "gpt-4o TPD") {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if fallbackRequests.Load() != 1 {
+		t.Fatalf("fallback requests = %d, want 1", fallbackRequests.Load())
+	}
+}
@@ -0,0 +1,428 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+var errHedgeLost = errors.New("hedged request lost the race")
+
+// hedgeResponseWriter 对冲请求中每个尝试使用的响应写入器，只有胜出的尝试能写到下游。
+// 胜出前设置的响应头暂存在本地，首次写出时再复制到下游
+type hedgeResponseWriter struct {
+	gin.ResponseWriter
+	attempt   *relaycommon.HedgeAttempt
+	keepAlive *hedgeKeepAlive
+	header    http.Header
+	claimed   bool
+}
+
+func (w *hedgeResponseWriter) claim() bool {
+	if w.claimed {
+		return true
+	}
+	if !w.attempt.Claim() {
+		return false
+	}
+	// 胜出的尝试接管下游前停止竞速期间的保活
+	if w.keepAlive != nil {
+		w.keepAlive.stop()
+	}
+	dst := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.claimed = true
+	return true
+}
+
+func (w *hedgeResponseWriter) Header() http.Header {
+	if w.claimed {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *hedgeResponseWriter) WriteHeader(code int) {
+	if w.claim() {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *hedgeResponseWriter) WriteHeaderNow() {
+	if w.claim() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *hedgeResponseWriter) Write(data []byte) (int, error) {
+	if !w.claim() {
+		return 0, errHedgeLost
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *hedgeResponseWriter) WriteString(s string) (int, error) {
+	if !w.claim() {
+		return 0, errHedgeLost
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *hedgeResponseWriter) Flush() {
+	if w.claimed {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *hedgeResponseWriter) Written() bool {
+	return w.claimed && w.ResponseWriter.Written()
+}
+
+func (w *hedgeResponseWriter) Status() int {
+	if w.claimed {
+		return w.ResponseWriter.Status()
+	}
+	return http.StatusOK
+}
+
+func (w *hedgeResponseWriter) Size() int {
+	if w.claimed {
+		return w.ResponseWriter.Size()
+	}
+	return -1
+}
+
+func (w *hedgeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("hijack is not supported for hedged requests")
+}
+
+// hedgeBilling 对冲请求中只有胜出的尝试可以结算，退款由外层统一处理
+type hedgeBilling struct {
+	relaycommon.BillingSettler
+	attempt *relaycommon.HedgeAttempt
+}
+
+func (b *hedgeBilling) Settle(actualQuota int) error {
+	if !b.attempt.Claim() {
+		return nil
+	}
+	return b.BillingSettler.Settle(actualQuota)
+}
+
+func (b *hedgeBilling) Refund(c *gin.Context) {}
+
+// hedgeKeepAlive 决出胜者前由外层向下游发送 ping 保活，胜出的尝试首次写出前停止，
+// 之后由胜出尝试自身的流处理负责保活
+type hedgeKeepAlive struct {
+	mu      sync.Mutex
+	stopped bool
+	done    chan struct{}
+}
+
+func startHedgeKeepAlive(c *gin.Context, race *relaycommon.HedgeRace, interval time.Duration) *hedgeKeepAlive {
+	k := &hedgeKeepAlive{done: make(chan struct{})}
+	if interval <= 0 {
+		interval = helper.DefaultPingInterval
+	}
+	gopool.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !k.ping(c, race) {
+					return
+				}
+			case <-k.done:
+				return
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	})
+	return k
+}
+
+func (k *hedgeKeepAlive) ping(c *gin.Context, race *relaycommon.HedgeRace) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.stopped || race.Decided() {
+		return false
+	}
+	helper.SetEventStreamHeaders(c)
+	if err := helper.PingData(c); err != nil {
+		logger.LogError(c, "hedged request ping error: "+err.Error())
+		return false
+	}
+	// 记录保活写出的字节数，两个尝试都失败时仍可以重试或回退模型
+	common.SetContextKey(c, constant.ContextKeyKeepAliveSize, c.Writer.Size())
+	return true
+}
+
+// stop 停止保活并等待正在发送的 ping 完成
+func (k *hedgeKeepAlive) stop() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.stopped {
+		k.stopped = true
+		close(k.done)
+	}
+}
+
+// hedgeAttempt 对冲请求中的一次尝试，使用独立的上下文、请求体和 RelayInfo
+type hedgeAttempt struct {
+	hedge   *relaycommon.HedgeAttempt
+	ctx     *gin.Context
+	info    *relaycommon.RelayInfo
+	channel *model.Channel
+	release func()
+	cancel  context.CancelFunc
+	start   time.Time
+	err     *types.NewAPIError
+	// 在其他尝试胜出后才结束，视为被取消
+	cancelled bool
+}
+
+func cloneRelayInfoForHedge(info *relaycommon.RelayInfo) *relaycommon.RelayInfo {
+	cloned := *info
+	cloned.RequestConversionChain = slices.Clone(info.RequestConversionChain)
+	if info.ClaudeConvertInfo != nil {
+		claudeConvertInfo := *info.ClaudeConvertInfo
+		cloned.ClaudeConvertInfo = &claudeConvertInfo
+	}
+	if info.ResponsesUsageInfo != nil {
+		responsesUsageInfo := *info.ResponsesUsageInfo
+		cloned.ResponsesUsageInfo = &responsesUsageInfo
+	}
+	return &cloned
+}
+
+func newHedgeAttempt(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, race *relaycommon.HedgeRace, keepAlive *hedgeKeepAlive, index int32, channel *model.Channel, release func()) (*hedgeAttempt, *types.NewAPIError) {
+	bodyStorage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	body, err := bodyStorage.Bytes()
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	storage, err := common.CreateBodyStorage(body)
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	hedge := &relaycommon.HedgeAttempt{Race: race, Index: index}
+	// 决出胜者时立即取消落败的尝试，不必等胜出的尝试结束
+	race.Register(index, cancel)
+	attemptCtx := c.Copy()
+	attemptCtx.Request = c.Request.Clone(ctx)
+	attemptCtx.Request.Body = io.NopCloser(storage)
+	attemptCtx.Set(common.KeyBodyStorage, storage)
+	attemptCtx.Writer = &hedgeResponseWriter{ResponseWriter: c.Writer, attempt: hedge, keepAlive: keepAlive, header: http.Header{}}
+
+	info := cloneRelayInfoForHedge(relayInfo)
+	info.Hedge = hedge
+	if relayInfo.Billing != nil {
+		info.Billing = &hedgeBilling{BillingSettler: relayInfo.Billing, attempt: hedge}
+	}
+	if index > 1 {
+		// 各尝试并发修改请求，后发起的尝试使用重新解析的请求
+		request, err := helper.GetAndValidateRequest(attemptCtx, relayFormat)
+		if err != nil {
+			cancel()
+			common.CleanupBodyStorage(attemptCtx)
+			return nil, types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+		}
+		// 重新解析的请求同样需要按令牌策略限制参数
+		if policyErr := service.CheckTokenPolicy(attemptCtx, relayFormat, request); policyErr != nil {
+			cancel()
+			common.CleanupBodyStorage(attemptCtx)
+			return nil, policyErr
+		}
+		request.SetModelName(relayInfo.OriginModelName)
+		info.Request = request
+	}
+	return &hedgeAttempt{
+		hedge:   hedge,
+		ctx:     attemptCtx,
+		info:    info,
+		channel: channel,
+		release: release,
+		cancel:  cancel,
+	}, nil
+}
+
+func (a *hedgeAttempt) run(relayFormat types.RelayFormat, results chan<- *hedgeAttempt) {
+	a.start = time.Now()
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				a.err = types.NewError(fmt.Errorf("hedged request panic: %v", r), types.ErrorCodeDoRequestFailed)
+			}
+			a.release()
+			results <- a
+		}()
+		a.err = relayAttempt(a.ctx, relayFormat, a.info)
+	}()
+}
+
+// pickHedgeChannel 为对冲请求选择一个与首个渠道不同且未饱和的渠道。
+// 选择渠道会修改上下文和 RelayInfo，因此在副本上进行，避免影响仍在运行的首个尝试
+func pickHedgeChannel(c *gin.Context, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam, primaryChannelId int) (*gin.Context, *relaycommon.RelayInfo, *model.Channel) {
+	for i := 0; i < 3; i++ {
+		hedgeCtx := c.Copy()
+		hedgeCtx.Writer = c.Writer
+		hedgeInfo := cloneRelayInfoForHedge(relayInfo)
+		channel, err := getChannel(hedgeCtx, hedgeInfo, &service.RetryParam{
+			Ctx:        hedgeCtx,
+			TokenGroup: retryParam.TokenGroup,
+			ModelName:  retryParam.ModelName,
+			Retry:      common.GetPointer(retryParam.GetRetry() + i),
+		})
+		if err == nil && channel.Id != primaryChannelId && !service.IsChannelSaturated(channel) {
+			return hedgeCtx, hedgeInfo, channel
+		}
+	}
+	return nil, nil, nil
+}
+
+// relayHedged 首个渠道在 delay 内未返回首字节时，向第二个渠道发起同样的请求，
+// 先返回首字节的尝试胜出并转发给下游，另一个尝试被取消且不计费
+func relayHedged(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, retryParam *service.RetryParam, channel *model.Channel, release func(), delay time.Duration) *types.NewAPIError {
+	race := &relaycommon.HedgeRace{}
+	results := make(chan *hedgeAttempt, 2)
+	var keepAlive *hedgeKeepAlive
+	// Gemini 原生格式不发送自定义 ping
+	if generalSettings := operation_setting.GetGeneralSetting(); relayInfo.IsStream && generalSettings.PingIntervalEnabled && !relayInfo.DisablePing && relayFormat != types.RelayFormatGemini {
+		keepAlive = startHedgeKeepAlive(c, race, time.Duration(generalSettings.PingIntervalSeconds)*time.Second)
+		defer keepAlive.stop()
+	}
+	primary, newAPIError := newHedgeAttempt(c, relayFormat, relayInfo, race, keepAlive, 1, channel, release)
+	if newAPIError != nil {
+		release()
+		return newAPIError
+	}
+	attempts := []*hedgeAttempt{primary}
+	primary.run(relayFormat, results)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var winner, last *hedgeAttempt
+	for running := 1; running > 0; {
+		select {
+		case <-timer.C:
+			if race.Decided() {
+				continue
+			}
+			hedgeCtx, hedgeInfo, hedgeChannel := pickHedgeChannel(c, relayInfo, retryParam, primary.channel.Id)
+			if hedgeChannel == nil {
+				logger.LogInfo(c, "no other channel available for hedged request")
+				continue
+			}
+			hedgeRelease, limitErr := service.AcquireChannelLimit(c, hedgeChannel.Id, relayInfo.GetEstimatePromptTokens())
+			if limitErr != nil {
+				continue
+			}
+			hedge, hedgeErr := newHedgeAttempt(hedgeCtx, relayFormat, hedgeInfo, race, keepAlive, 2, hedgeChannel, hedgeRelease)
+			if hedgeErr != nil {
+				hedgeRelease()
+				logger.LogError(c, "failed to start hedged request: "+hedgeErr.Error())
+				continue
+			}
+			addUsedChannel(c, hedgeChannel.Id)
+			hedgeChannels := []int{primary.channel.Id, hedgeChannel.Id}
+			common.SetContextKey(c, constant.ContextKeyHedgeChannels, hedgeChannels)
+			for _, a := range []*hedgeAttempt{primary, hedge} {
+				common.SetContextKey(a.ctx, constant.ContextKeyHedgeChannels, hedgeChannels)
+				a.ctx.Set("use_channel", c.GetStringSlice("use_channel"))
+			}
+			logger.LogInfo(c, fmt.Sprintf("channel #%d has not responded in %s, hedging with channel #%d", primary.channel.Id, delay, hedgeChannel.Id))
+			attempts = append(attempts, hedge)
+			hedge.run(relayFormat, results)
+			running++
+		case a := <-results:
+			running--
+			last = a
+			// 胜出时其他尝试已在 Claim 中被取消
+			if a.hedge.IsWinner() || (a.err == nil && a.hedge.Claim()) {
+				winner = a
+			} else if race.Decided() {
+				a.cancelled = true
+			}
+		}
+	}
+
+	final := winner
+	if final == nil {
+		final = last
+	}
+	for _, a := range attempts {
+		a.cancel()
+		common.CleanupBodyStorage(a.ctx)
+		if a.cancelled {
+			logger.LogInfo(c, fmt.Sprintf("hedged request on channel #%d cancelled, channel #%d answered first", a.channel.Id, winner.channel.Id))
+			recordHedgeCancelledLog(a, winner)
+			continue
+		}
+		a.err = finishAttempt(a.ctx, a.info, a.channel, a.start, a.err)
+	}
+
+	// 将最终尝试的上下文和 RelayInfo 写回，供后续重试、日志和模型回退使用
+	for k, v := range final.ctx.Keys {
+		if k == "use_channel" || k == common.KeyBodyStorage || k == string(constant.ContextKeyKeepAliveSize) {
+			continue
+		}
+		c.Set(k, v)
+	}
+	billing := relayInfo.Billing
+	*relayInfo = *final.info
+	relayInfo.Billing = billing
+	relayInfo.Hedge = nil
+	return final.err
+}
+
+// recordHedgeCancelledLog 为被取消的尝试记录一条不计费的消费日志，便于排查对冲请求
+func recordHedgeCancelledLog(a *hedgeAttempt, winner *hedgeAttempt) {
+	other := map[string]any{
+		"hedge_cancelled":         true,
+		"hedge_index":             a.hedge.Index,
+		"hedge_winner_channel_id": winner.channel.Id,
+		"channel_name":            a.channel.Name,
+		"channel_type":            a.channel.Type,
+	}
+	model.RecordConsumeLog(a.ctx, a.info.UserId, model.RecordConsumeLogParams{
+		ChannelId:      a.channel.Id,
+		ModelName:      a.info.OriginModelName,
+		TokenName:      a.ctx.GetString("token_name"),
+		Quota:          0,
+		Content:        fmt.Sprintf("Hedged request cancelled, channel #%d answered first", winner.channel.Id),
+		TokenId:        a.info.TokenId,
+		UseTimeSeconds: int(time.Since(a.start).Seconds()),
+		IsStream:       a.info.IsStream,
+		Group:          a.info.UsingGroup,
+		Other:          other,
+	})
+}
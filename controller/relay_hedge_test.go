@@ -0,0 +1,265 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeBillingSettler struct {
+	settled []int
+	refunds int
+}
+
+func (s *fakeBillingSettler) Settle(actualQuota int) error {
+	s.settled = append(s.settled, actualQuota)
+	return nil
+}
+
+func (s *fakeBillingSettler) Refund(c *gin.Context) { s.refunds++ }
+
+func (s *fakeBillingSettler) NeedsRefund() bool { return len(s.settled) == 0 }
+
+func (s *fakeBillingSettler) GetPreConsumedQuota() int { return 0 }
+
+func TestHedgeResponseWriterSeparatesHeaders(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	race := &relaycommon.HedgeRace{}
+	primary := &hedgeResponseWriter{ResponseWriter: c.Writer, attempt: &relaycommon.HedgeAttempt{Race: race, Index: 1}, header: http.Header{}}
+	hedge := &hedgeResponseWriter{ResponseWriter: c.Writer, attempt: &relaycommon.HedgeAttempt{Race: race, Index: 2}, header: http.Header{}}
+
+	primary.Header().Set("X-Attempt", "primary")
+	hedge.Header().Set("X-Attempt", "hedge")
+	if recorder.Header().Get("X-Attempt") != "" {
+		t.Fatal("headers leaked to the client before the race was decided")
+	}
+
+	if _, err := hedge.WriteString("from hedge"); err != nil {
+		t.Fatal(err)
+	}
+	primary.WriteHeader(http.StatusInternalServerError)
+	if _, err := primary.Write([]byte("from primary")); !errors.Is(err, errHedgeLost) {
+		t.Fatalf("loser write error = %v, want errHedgeLost", err)
+	}
+	primary.Header().Set("X-Attempt", "primary-late")
+
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "from hedge" {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if got := recorder.Header().Get("X-Attempt"); got != "hedge" {
+		t.Fatalf("X-Attempt = %q, want hedge", got)
+	}
+	if primary.Written() || !hedge.Written() {
+		t.Fatalf("primary written = %t, hedge written = %t", primary.Written(), hedge.Written())
+	}
+}
+
+func TestHedgeBillingSettlesOnlyWinner(t *testing.T) {
+	settler := &fakeBillingSettler{}
+	race := &relaycommon.HedgeRace{}
+	primary := &hedgeBilling{BillingSettler: settler, attempt: &relaycommon.HedgeAttempt{Race: race, Index: 1}}
+	hedge := &hedgeBilling{BillingSettler: settler, attempt: &relaycommon.HedgeAttempt{Race: race, Index: 2}}
+
+	if !hedge.attempt.Claim() {
+		t.Fatal("hedge attempt failed to claim the race")
+	}
+	if err := primary.Settle(100); err != nil {
+		t.Fatal(err)
+	}
+	if err := hedge.Settle(42); err != nil {
+		t.Fatal(err)
+	}
+	// 退款由外层统一处理，尝试自身不能退款
+	primary.Refund(nil)
+	hedge.Refund(nil)
+
+	if len(settler.settled) != 1 || settler.settled[0] != 42 {
+		t.Fatalf("settled = %v, want only the winner's quota", settler.settled)
+	}
+	if settler.refunds != 0 {
+		t.Fatalf("refunds = %d, want 0", settler.refunds)
+	}
+}
+
+// setupHedgeTest 开启对冲并为测试令牌设置对冲延迟
+func setupHedgeTest(t *testing.T, channelSetting string, delayMs int, baseURLs ...string) []int {
+	t.Helper()
+	setting := operation_setting.GetHedgingSetting()
+	saved := *setting
+	setting.Enabled = true
+	setting.MinDelayMs = 0
+	t.Cleanup(func() { *setting = saved })
+	ids := setupRelayTest(t, channelSetting, baseURLs...)
+	if err := model.DB.Model(&model.Token{}).Where("user_id = ?", 1).Update("hedge_delay_ms", delayMs).Error; err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestRelayHedgedStreamsFastestChannel(t *testing.T) {
+	var slowRequests, fastRequests atomic.Int32
+	slow := newStreamUpstream(t, "from slow", 3*time.Second, &slowRequests)
+	fast := newStreamUpstream(t, "from fast", 0, &fastRequests)
+	ids := setupHedgeTest(t, "", 100, slow.URL, fast.URL)
+
+	start := time.Now()
+	recorder := doRelayTestRequest(newRelayTestEngine(), `{"model":"gpt-4o-mini","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || !strings.Contains(body, "from fast") || strings.Contains(body, "from slow") {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, body)
+	}
+	if strings.Count(body, "data: [DONE]") != 1 {
+		t.Fatalf("expected a single stream in the response: %s", body)
+	}
+	if slowRequests.Load() != 1 || fastRequests.Load() != 1 {
+		t.Fatalf("slow requests = %d, fast requests = %d", slowRequests.Load(), fastRequests.Load())
+	}
+	// 落败的尝试被取消，不会等到慢渠道返回
+	if elapsed := time.Since(start); elapsed >= 3*time.Second {
+		t.Fatalf("hedged request took %s, loser was not cancelled", elapsed)
+	}
+
+	var logs []model.Log
+	if err := model.LOG_DB.Where("type = ?", model.LogTypeConsume).Order("id").Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("consume logs = %d, want one for each attempt", len(logs))
+	}
+	var winnerLog, loserLog *model.Log
+	for i := range logs {
+		switch logs[i].ChannelId {
+		case ids[0]:
+			loserLog = &logs[i]
+		case ids[1]:
+			winnerLog = &logs[i]
+		}
+	}
+	if winnerLog == nil || loserLog == nil {
+		t.Fatalf("unexpected consume logs: %+v", logs)
+	}
+	if winnerLog.Quota <= 0 || loserLog.Quota != 0 {
+		t.Fatalf("winner quota = %d, loser quota = %d, want only the winner billed", winnerLog.Quota, loserLog.Quota)
+	}
+	if !strings.Contains(loserLog.Content, "cancelled") {
+		t.Fatalf("loser log content = %q", loserLog.Content)
+	}
+
+	// 预扣费退还后只扣除胜出尝试的额度
+	user, err := model.GetUserById(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UsedQuota != winnerLog.Quota {
+		t.Fatalf("used quota = %d, want %d", user.UsedQuota, winnerLog.Quota)
+	}
+}
+
+func TestRelayHedgedKeepAlive(t *testing.T) {
+	general := operation_setting.GetGeneralSetting()
+	saved := *general
+	general.PingIntervalEnabled = true
+	general.PingIntervalSeconds = 1
+	t.Cleanup(func() { *general = saved })
+
+	var stalledRequests, lateRequests atomic.Int32
+	stalled := newStreamUpstream(t, "from stalled", -1, &stalledRequests)
+	late := newStreamUpstream(t, "from late", 1500*time.Millisecond, &lateRequests)
+	setupHedgeTest(t, "", 100, stalled.URL, late.URL)
+
+	recorder := doRelayTestRequest(newRelayTestEngine(), `{"model":"gpt-4o-mini","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || !strings.Contains(body, "from late") || strings.Contains(body, "from stalled") {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, body)
+	}
+	// 决出胜者前的 ping 写在胜出尝试的内容之前
+	ping := strings.Index(body, ": PING")
+	if ping < 0 || ping > strings.Index(body, "from late") {
+		t.Fatalf("expected a keepalive ping before the winner's data: %s", body)
+	}
+	if stalledRequests.Load() != 1 || lateRequests.Load() != 1 {
+		t.Fatalf("stalled requests = %d, late requests = %d", stalledRequests.Load(), lateRequests.Load())
+	}
+}
+
+func TestRelayHedgedCancelsLoserWhenRaceIsDecided(t *testing.T) {
+	loserCancelled := make(chan time.Time, 1)
+	loser := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		loserCancelled <- time.Now()
+	}))
+	t.Cleanup(loser.Close)
+	var winnerFinished atomic.Int64
+	winner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4o-mini\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"from winner\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		// 胜出的流持续一段时间，落败的尝试必须在此之前被取消
+		time.Sleep(time.Second)
+		winnerFinished.Store(time.Now().UnixNano())
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(winner.Close)
+	setupHedgeTest(t, "", 100, loser.URL, winner.URL)
+
+	recorder := doRelayTestRequest(newRelayTestEngine(), `{"model":"gpt-4o-mini","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "from winner") {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+	select {
+	case cancelledAt := <-loserCancelled:
+		if cancelledAt.UnixNano() >= winnerFinished.Load() {
+			t.Fatalf("loser cancelled at %s, after the winner finished", cancelledAt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("loser upstream was never cancelled")
+	}
+}
+
+func TestRelayHedgedRetriesAfterKeepAlive(t *testing.T) {
+	general := operation_setting.GetGeneralSetting()
+	saved := *general
+	general.PingIntervalEnabled = true
+	general.PingIntervalSeconds = 1
+	t.Cleanup(func() { *general = saved })
+	savedRetryTimes := common.RetryTimes
+	common.RetryTimes = 1
+	t.Cleanup(func() { common.RetryTimes = savedRetryTimes })
+
+	var stalledRequests, healthyRequests atomic.Int32
+	first := newStreamUpstream(t, "from stalled", -1, &stalledRequests)
+	second := newStreamUpstream(t, "from stalled", -1, &stalledRequests)
+	healthy := newStreamUpstream(t, "from healthy", 0, &healthyRequests)
+	setupHedgeTest(t, `{"first_token_timeout_seconds":2}`, 100, first.URL, second.URL, healthy.URL)
+
+	recorder := doRelayTestRequest(newRelayTestEngine(), `{"model":"gpt-4o-mini","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	// 两个对冲尝试都超时后，保活 ping 不能阻止换渠道重试
+	body := recorder.Body.String()
+	if !strings.Contains(body, ": PING") || !strings.Contains(body, "from healthy") || strings.Contains(body, "from stalled") {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, body)
+	}
+	if healthyRequests.Load() != 1 {
+		t.Fatalf("healthy requests = %d, want 1", healthyRequests.Load())
+	}
+}
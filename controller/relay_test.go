@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const relayTestTokenKey = "relaytest0000000000000000000000000000000000000"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	dir, err := os.MkdirTemp("", "relay-test")
+	if err != nil {
+		panic("failed to create temp dir: " + err.Error())
+	}
+	defer os.RemoveAll(dir)
+	common.SQLitePath = filepath.Join(dir, "relay-test.db") + "?_busy_timeout=5000"
+	common.IsMasterNode = true
+	common.RedisEnabled = false
+	common.MemoryCacheEnabled = false
+	common.BatchUpdateEnabled = false
+	common.LogConsumeEnabled = true
+	constant.StreamingTimeout = 300
+	ratio_setting.InitRatioSettings()
+	if err := model.InitDB(); err != nil {
+		panic("failed to init test db: " + err.Error())
+	}
+	if err := model.InitLogDB(); err != nil {
+		panic("failed to init test log db: " + err.Error())
+	}
+	service.InitHttpClient()
+
+	code := m.Run()
+	_ = model.CloseDB()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setupRelayTest 创建测试用户、令牌和指向 baseURLs 的 OpenAI 渠道，渠道优先级按顺序递减
+func setupRelayTest(t *testing.T, channelSetting string, baseURLs ...string) []int {
+	t.Helper()
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM users")
+		model.DB.Exec("DELETE FROM tokens")
+		model.DB.Exec("DELETE FROM channels")
+		model.DB.Exec("DELETE FROM abilities")
+		model.DB.Exec("DELETE FROM logs")
+	})
+	user := &model.User{Id: 1, Username: "relay-test", Password: "x", Role: common.RoleCommonUser, Status: common.UserStatusEnabled, Quota: 100000000, Group: "default", AffCode: "relay-test"}
+	if err := model.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	token := &model.Token{UserId: 1, Key: relayTestTokenKey, Status: common.TokenStatusEnabled, Name: "relay-test", ExpiredTime: -1, UnlimitedQuota: true}
+	if err := model.DB.Create(token).Error; err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, 0, len(baseURLs))
+	for i, baseURL := range baseURLs {
+		priority := int64(len(baseURLs) - i)
+		channel := &model.Channel{
+			Type:     constant.ChannelTypeOpenAI,
+			Key:      fmt.Sprintf("sk-upstream-%d", i),
+			Status:   common.ChannelStatusEnabled,
+			Name:     fmt.Sprintf("upstream-%d", i),
+			BaseURL:  &baseURL,
+			Models:   "gpt-4o-mini",
+			Group:    "default",
+			Priority: &priority,
+			Setting:  &channelSetting,
+		}
+		if err := model.DB.Create(channel).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := channel.AddAbilities(nil); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, channel.Id)
+	}
+	return ids
+}
+
+func newRelayTestEngine() *gin.Engine {
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", middleware.TokenAuth(), middleware.Distribute(), func(c *gin.Context) {
+		Relay(c, types.RelayFormatOpenAI)
+	})
+	return engine
+}
+
+func doRelayTestRequest(engine *gin.Engine, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-"+relayTestTokenKey)
+	req.Header.Set("Content-Type", "application/json")
+	engine.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// newStreamUpstream 返回按 delay 延迟后输出 content 的流式上游，delay 为负数时只返回响应头并一直等待
+func newStreamUpstream(t *testing.T, content string, delay time.Duration, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		if delay < 0 {
+			<-r.Context().Done()
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprintf(w, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4o-mini\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", content)
+		fmt.Fprint(w, "data: {\"id\":\"chatcmpl-1\",\"object\":\"chat.completion.chunk\",\"model\":\"gpt-4o-mini\",\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":2,\"total_tokens\":7}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRelayRetriesAfterFirstTokenTimeout(t *testing.T) {
+	savedRetryTimes := common.RetryTimes
+	common.RetryTimes = 2
+	defer func() { common.RetryTimes = savedRetryTimes }()
+
+	var stalledRequests, healthyRequests atomic.Int32
+	stalled := newStreamUpstream(t, "from stalled", -1, &stalledRequests)
+	healthy := newStreamUpstream(t, "from healthy", 0, &healthyRequests)
+	setupRelayTest(t, `{"first_token_timeout_seconds":1}`, stalled.URL, healthy.URL)
+
+	recorder := doRelayTestRequest(newRelayTestEngine(), `{"model":"gpt-4o-mini","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	body := recorder.Body.String()
+	if recorder.Code != http.StatusOK || !strings.Contains(body, "from healthy") {
+		t.Fatalf("unexpected response %d: %s", recorder.Code, body)
+	}
+	if stalledRequests.Load() != 1 || healthyRequests.Load() != 1 {
+		t.Fatalf("stalled requests = %d, healthy requests = %d, want exactly one retry", stalledRequests.Load(), healthyRequests.Load())
+	}
+	// 超时的尝试不能向下游写出任何内容
+	if strings.Count(body, "data: [DONE]") != 1 || strings.Contains(body, "from stalled") {
+		t.Fatalf("timed out attempt wrote to the client: %s", body)
+	}
+}
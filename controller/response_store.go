@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getRequestStoredResponse 获取当前令牌下网关侧存储的响应，不存在时直接写入 404 响应
+func getRequestStoredResponse(c *gin.Context) *model.StoredResponse {
+	responseId := c.Param("id")
+	stored, err := model.GetUserStoredResponse(c.GetInt("id"), c.GetInt("token_id"), responseId)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to query response %s: %s", responseId, err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to query response")
+		return nil
+	}
+	if stored == nil {
+		fileApiError(c, http.StatusNotFound, "invalid_request_error", fmt.Sprintf("Response with id '%s' not found.", responseId))
+		return nil
+	}
+	return stored
+}
+
+// RetrieveResponse GET /v1/responses/:id
+func RetrieveResponse(c *gin.Context) {
+	stored := getRequestStoredResponse(c)
+	if stored == nil {
+		return
+	}
+	c.Data(http.StatusOK, "application/json", []byte(stored.Response))
+}
+
+// DeleteResponse DELETE /v1/responses/:id
+func DeleteResponse(c *gin.Context) {
+	stored := getRequestStoredResponse(c)
+	if stored == nil {
+		return
+	}
+	if err := stored.Delete(); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to delete response %s: %s", stored.ResponseId, err.Error()))
+		fileApiError(c, http.StatusInternalServerError, "server_error", "Failed to delete response")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":      stored.ResponseId,
+		"object":  "response",
+		"deleted": true,
+	})
+}
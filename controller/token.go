@@ -3,10 +3,12 @@ package controller
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
@@ -131,12 +133,30 @@ func GetTokenUsage(c *gin.Context) {
 			"total_available":      token.RemainQuota,
 			"unlimited_quota":      token.UnlimitedQuota,
 			"model_limits":         token.GetModelLimitsMap(),
+			"model_limit_rules":    token.GetModelLimits(),
 			"model_limits_enabled": token.ModelLimitsEnabled,
 			"expires_at":           expiredAt,
+			"budget":               buildTokenBudgetUsage(token),
 		},
 	})
 }
 
+// buildTokenBudgetUsage 返回令牌当前周期的预算用量，未配置周期预算时返回 nil
+func buildTokenBudgetUsage(token *model.Token) gin.H {
+	if !token.HasBudget() {
+		return nil
+	}
+	now := common.GetTimestamp()
+	used := token.GetBudgetUsed(now)
+	return gin.H{
+		"period":    token.BudgetPeriod,
+		"granted":   token.BudgetQuota,
+		"used":      used,
+		"available": max(token.BudgetQuota-used, 0),
+		"reset_at":  token.GetBudgetResetTime(now),
+	}
+}
+
 func AddToken(c *gin.Context) {
 	token := model.Token{}
 	err := c.ShouldBindJSON(&token)
@@ -160,6 +180,30 @@ func AddToken(c *gin.Context) {
 			return
 		}
 	}
+	if err := validateTokenModelFallbacks(token.ModelFallbacks); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if token.HedgeDelayMs < 0 {
+		common.ApiError(c, fmt.Errorf("对冲延迟不能为负数"))
+		return
+	}
+	if token.TPM < 0 || token.TPD < 0 {
+		common.ApiError(c, fmt.Errorf("令牌 TPM 和 TPD 限制不能为负数"))
+		return
+	}
+	if err := validateTokenBudget(&token); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateTokenPolicy(token.Policy); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, err := model.ParseTokenModelLimits(token.ModelLimits, true); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	// 检查用户令牌数量是否已达上限
 	maxTokens := operation_setting.GetMaxUserTokens()
 	count, err := model.CountUserTokens(c.GetInt("id"))
@@ -194,6 +238,13 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		ModelFallbacks:     token.ModelFallbacks,
+		HedgeDelayMs:       token.HedgeDelayMs,
+		TPM:                token.TPM,
+		TPD:                token.TPD,
+		BudgetPeriod:       token.BudgetPeriod,
+		BudgetQuota:        token.BudgetQuota,
+		Policy:             token.Policy,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -246,6 +297,30 @@ func UpdateToken(c *gin.Context) {
 			return
 		}
 	}
+	if err := validateTokenModelFallbacks(token.ModelFallbacks); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if token.HedgeDelayMs < 0 {
+		common.ApiError(c, fmt.Errorf("对冲延迟不能为负数"))
+		return
+	}
+	if token.TPM < 0 || token.TPD < 0 {
+		common.ApiError(c, fmt.Errorf("令牌 TPM 和 TPD 限制不能为负数"))
+		return
+	}
+	if err := validateTokenBudget(&token); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err := validateTokenPolicy(token.Policy); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if _, err := model.ParseTokenModelLimits(token.ModelLimits, true); err != nil {
+		common.ApiError(c, err)
+		return
+	}
 	cleanToken, err := model.GetTokenByIds(token.Id, userId)
 	if err != nil {
 		common.ApiError(c, err)
@@ -274,6 +349,16 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.ModelFallbacks = token.ModelFallbacks
+		cleanToken.HedgeDelayMs = token.HedgeDelayMs
+		cleanToken.TPM = token.TPM
+		cleanToken.TPD = token.TPD
+		cleanToken.Policy = token.Policy
+		// 预算配置单独按条件更新，变化后重新开始计算周期
+		if err := model.UpdateTokenBudget(cleanToken, token.BudgetPeriod, token.BudgetQuota); err != nil {
+			common.ApiError(c, err)
+			return
+		}
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -309,3 +394,44 @@ func DeleteTokenBatch(c *gin.Context) {
 		"data":    count,
 	})
 }
+
+// validateTokenBudget 校验令牌周期预算额度与周期
+func validateTokenBudget(token *model.Token) error {
+	if token.BudgetQuota < 0 {
+		return fmt.Errorf("令牌周期预算不能为负数")
+	}
+	if token.BudgetQuota > 0 && !model.IsValidTokenBudgetPeriod(token.BudgetPeriod) {
+		return fmt.Errorf("令牌预算周期必须为 daily、weekly 或 monthly")
+	}
+	return nil
+}
+
+// validateTokenPolicy 校验令牌请求策略的 JSON 格式与取值范围
+func validateTokenPolicy(policy string) error {
+	if strings.TrimSpace(policy) == "" {
+		return nil
+	}
+	var tokenPolicy dto.TokenPolicy
+	if err := common.UnmarshalJsonStr(policy, &tokenPolicy); err != nil {
+		return fmt.Errorf("令牌请求策略格式错误: %s", err.Error())
+	}
+	if tokenPolicy.MaxOutputTokens < 0 {
+		return fmt.Errorf("最大输出 token 数不能为负数")
+	}
+	if tokenPolicy.MaxReasoningEffort != "" && !slices.Contains(dto.ReasoningEffortLevels, tokenPolicy.MaxReasoningEffort) {
+		return fmt.Errorf("reasoning_effort 上限必须为 %s 之一", strings.Join(dto.ReasoningEffortLevels, "、"))
+	}
+	return nil
+}
+
+// validateTokenModelFallbacks 校验令牌模型回退链为 {"模型": ["回退模型", ...]} 格式
+func validateTokenModelFallbacks(fallbacks string) error {
+	if strings.TrimSpace(fallbacks) == "" {
+		return nil
+	}
+	var chains map[string][]string
+	if err := common.UnmarshalJsonStr(fallbacks, &chains); err != nil {
+		return fmt.Errorf("模型回退链格式错误: %s", err.Error())
+	}
+	return nil
+}
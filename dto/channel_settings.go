@@ -4,9 +4,22 @@ type ChannelSettings struct {
 	ForceFormat            bool   `json:"force_format,omitempty"`
 	ThinkingToContent      bool   `json:"thinking_to_content,omitempty"`
 	Proxy                  string `json:"proxy"`
+	ProxyPool              string `json:"proxy_pool,omitempty"` // 引用的代理池名称，设置后优先于 Proxy
 	PassThroughBodyEnabled bool   `json:"pass_through_body_enabled,omitempty"`
 	SystemPrompt           string `json:"system_prompt,omitempty"`
 	SystemPromptOverride   bool   `json:"system_prompt_override,omitempty"`
+	MaxConcurrency         int    `json:"max_concurrency,omitempty"`       // 最大并发请求数，0 表示不限制
+	RPM                    int    `json:"rpm,omitempty"`                   // 每分钟最大请求数，0 表示不限制
+	TPM                    int    `json:"tpm,omitempty"`                   // 每分钟最大 token 数，0 表示不限制
+	MaxQueueSize           int    `json:"max_queue_size,omitempty"`        // 达到限制时最多排队等待的请求数，0 表示不排队
+	QueueTimeoutSeconds    int    `json:"queue_timeout_seconds,omitempty"` // 排队等待的超时时间（秒）
+	// 流式请求等待首个 token 的超时时间（秒），0 表示使用模型或全局配置
+	FirstTokenTimeoutSeconds int `json:"first_token_timeout_seconds,omitempty"`
+}
+
+// HasThroughputLimit 是否配置了并发或速率限制
+func (s *ChannelSettings) HasThroughputLimit() bool {
+	return s.MaxConcurrency > 0 || s.RPM > 0 || s.TPM > 0
 }
 
 type VertexKeyType string
@@ -32,6 +45,29 @@ type ChannelOtherSettings struct {
 	DisableStore          bool          `json:"disable_store,omitempty"`           // 是否禁用 store 透传（默认允许透传，禁用后可能导致 Codex 无法使用）
 	AllowSafetyIdentifier bool          `json:"allow_safety_identifier,omitempty"` // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
 	AwsKeyType            AwsKeyType    `json:"aws_key_type,omitempty"`
+	// 渠道级别的上游连接设置，为空时使用全局 HTTP 客户端
+	Transport *ChannelTransportSettings `json:"transport,omitempty"`
+}
+
+// ChannelTransportSettings 渠道上游连接设置，超时单位均为秒，0 表示使用全局配置
+type ChannelTransportSettings struct {
+	ConnectTimeoutSeconds        int    `json:"connect_timeout_seconds,omitempty"`         // 建立连接（含 TLS 握手）的超时时间
+	ResponseHeaderTimeoutSeconds int    `json:"response_header_timeout_seconds,omitempty"` // 发送请求后等待响应头的超时时间
+	IdleStreamTimeoutSeconds     int    `json:"idle_stream_timeout_seconds,omitempty"`     // 流式响应两次数据之间的最长间隔
+	MaxIdleConns                 int    `json:"max_idle_conns,omitempty"`                  // 最大空闲连接数
+	DisableHTTP2                 bool   `json:"disable_http2,omitempty"`                   // 禁用 HTTP/2
+	CACert                       string `json:"ca_cert,omitempty"`                         // 自定义 CA 证书（PEM）
+	ClientCert                   string `json:"client_cert,omitempty"`                     // mTLS 客户端证书（PEM）
+	ClientKey                    string `json:"client_key,omitempty"`                      // mTLS 客户端私钥（PEM）
+}
+
+// HasClientProfile 是否配置了需要独立 HTTP 客户端的设置
+func (s *ChannelTransportSettings) HasClientProfile() bool {
+	if s == nil {
+		return false
+	}
+	return s.ConnectTimeoutSeconds > 0 || s.ResponseHeaderTimeoutSeconds > 0 || s.MaxIdleConns > 0 ||
+		s.DisableHTTP2 || s.CACert != "" || s.ClientCert != "" || s.ClientKey != ""
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
@@ -235,6 +235,7 @@ func (g *GeminiInlineData) UnmarshalJSON(data []byte) error {
 }
 
 type FunctionCall struct {
+	ID           string `json:"id,omitempty"`
 	FunctionName string `json:"name"`
 	Arguments    any    `json:"args"`
 }
@@ -466,6 +467,38 @@ type GeminiPromptTokensDetails struct {
 	TokenCount int    `json:"tokenCount"`
 }
 
+// GeminiCountTokensRequest models/{model}:countTokens 的请求体，contents 与 generateContentRequest 二选一
+type GeminiCountTokensRequest struct {
+	Contents               []GeminiChatContent `json:"contents,omitempty"`
+	GenerateContentRequest *GeminiChatRequest  `json:"generateContentRequest,omitempty"`
+}
+
+// ToGeminiChatRequest 统一为 GeminiChatRequest，用于本地估算及 Vertex 请求
+func (r *GeminiCountTokensRequest) ToGeminiChatRequest() *GeminiChatRequest {
+	if r.GenerateContentRequest != nil {
+		return r.GenerateContentRequest
+	}
+	return &GeminiChatRequest{Contents: r.Contents}
+}
+
+func (r *GeminiCountTokensRequest) IsStream(c *gin.Context) bool {
+	return false
+}
+
+func (r *GeminiCountTokensRequest) GetTokenCountMeta() *types.TokenCountMeta {
+	return r.ToGeminiChatRequest().GetTokenCountMeta()
+}
+
+func (r *GeminiCountTokensRequest) SetModelName(modelName string) {
+	// 模型名位于请求路径中，请求体无需设置
+}
+
+type GeminiCountTokensResponse struct {
+	TotalTokens             int                         `json:"totalTokens"`
+	CachedContentTokenCount int                         `json:"cachedContentTokenCount,omitempty"`
+	PromptTokensDetails     []GeminiPromptTokensDetails `json:"promptTokensDetails,omitempty"`
+}
+
 // Imagen related structs
 type GeminiImageRequest struct {
 	Instances  []GeminiImageInstance `json:"instances"`
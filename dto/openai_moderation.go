@@ -0,0 +1,31 @@
+package dto
+
+// OpenAIModerationResponse /v1/moderations 响应
+type OpenAIModerationResponse struct {
+	ID      string                   `json:"id"`
+	Model   string                   `json:"model"`
+	Results []OpenAIModerationResult `json:"results"`
+}
+
+type OpenAIModerationResult struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// OpenAIModerationCategories OpenAI 审核接口返回的标准类别
+var OpenAIModerationCategories = []string{
+	"harassment",
+	"harassment/threatening",
+	"hate",
+	"hate/threatening",
+	"illicit",
+	"illicit/violent",
+	"self-harm",
+	"self-harm/intent",
+	"self-harm/instructions",
+	"sexual",
+	"sexual/minors",
+	"violence",
+	"violence/graphic",
+}
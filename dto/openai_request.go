@@ -1016,3 +1016,89 @@ func (r *OpenAIResponsesRequest) ParseInput() []MediaInput {
 
 	return mediaInputs
 }
+
+// ResponsesInputItem Responses API input 数组中的条目，
+// 覆盖 message、function_call、function_call_output、reasoning 几类
+type ResponsesInputItem struct {
+	Type             string                          `json:"type,omitempty"`
+	Id               string                          `json:"id,omitempty"`
+	Role             string                          `json:"role,omitempty"`
+	Content          json.RawMessage                 `json:"content,omitempty"`
+	CallId           string                          `json:"call_id,omitempty"`
+	Name             string                          `json:"name,omitempty"`
+	Arguments        string                          `json:"arguments,omitempty"`
+	Output           json.RawMessage                 `json:"output,omitempty"`
+	Summary          []ResponsesReasoningSummaryPart `json:"summary,omitempty"`
+	EncryptedContent string                          `json:"encrypted_content,omitempty"`
+}
+
+// ResponsesInputContent message 条目的内容片段
+type ResponsesInputContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageUrl any    `json:"image_url,omitempty"`
+	FileId   string `json:"file_id,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	FileUrl  string `json:"file_url,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// GetImageUrl image_url 可能是字符串或带 url 字段的对象
+func (c *ResponsesInputContent) GetImageUrl() string {
+	switch v := c.ImageUrl.(type) {
+	case string:
+		return v
+	case map[string]any:
+		url, _ := v["url"].(string)
+		return url
+	}
+	return ""
+}
+
+// ParseInputItems 将 input 解析为条目列表，字符串 input 视为一条 user 消息
+func (r *OpenAIResponsesRequest) ParseInputItems() ([]ResponsesInputItem, error) {
+	switch common.GetJsonType(r.Input) {
+	case "string":
+		return []ResponsesInputItem{{Type: "message", Role: "user", Content: r.Input}}, nil
+	case "array":
+		var items []ResponsesInputItem
+		if err := common.Unmarshal(r.Input, &items); err != nil {
+			return nil, err
+		}
+		for i := range items {
+			// 省略 type 的条目为 message
+			if items[i].Type == "" && items[i].Role != "" {
+				items[i].Type = "message"
+			}
+		}
+		return items, nil
+	}
+	return nil, nil
+}
+
+// GetInstructions instructions 字段的字符串值
+func (r *OpenAIResponsesRequest) GetInstructions() string {
+	if common.GetJsonType(r.Instructions) != "string" {
+		return ""
+	}
+	var instructions string
+	_ = common.Unmarshal(r.Instructions, &instructions)
+	return instructions
+}
+
+// ParseResponsesContent 解析 message 内容或 function_call_output 的 output，
+// 字符串内容转为单个文本片段，textType 为其片段类型
+func ParseResponsesContent(content json.RawMessage, textType string) []ResponsesInputContent {
+	switch common.GetJsonType(content) {
+	case "string":
+		var text string
+		_ = common.Unmarshal(content, &text)
+		return []ResponsesInputContent{{Type: textType, Text: text}}
+	case "array":
+		var parts []ResponsesInputContent
+		_ = common.Unmarshal(content, &parts)
+		return parts
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package dto
+
+// TokenPolicy 令牌的请求策略，限制请求参数和可用功能，未配置的字段不限制
+type TokenPolicy struct {
+	// 最大输出 token 数上限，max_tokens、max_completion_tokens、max_output_tokens 和 maxOutputTokens
+	// 超过上限时降低到上限，未设置时使用上限
+	MaxOutputTokens int  `json:"max_output_tokens,omitempty"`
+	ForbidStream    bool `json:"forbid_stream,omitempty"`
+	// 禁止函数调用等工具，不包括联网搜索
+	ForbidTools      bool `json:"forbid_tools,omitempty"`
+	ForbidWebSearch  bool `json:"forbid_web_search,omitempty"`
+	ForbidImageInput bool `json:"forbid_image_input,omitempty"`
+	// reasoning_effort 上限，更高的取值以及 Claude、Gemini 的思考预算会被降低到该值，
+	// 模型名称后缀（如 -high、-thinking）超过上限时拒绝请求
+	MaxReasoningEffort string `json:"max_reasoning_effort,omitempty"`
+	// 允许的接口类型：openai、claude、gemini、openai_responses、openai_responses_compaction、
+	// openai_audio、openai_image、openai_realtime、rerank、embedding，为空时不限制
+	AllowedEndpoints []string `json:"allowed_endpoints,omitempty"`
+}
+
+// ReasoningEffortLevels reasoning_effort 取值从低到高排列
+var ReasoningEffortLevels = []string{"none", "minimal", "low", "medium", "high", "xhigh"}
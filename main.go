@@ -113,6 +113,18 @@ func main() {
 	// Subscription quota reset task (daily/weekly/monthly/custom)
 	service.StartSubscriptionQuotaResetTask()
 
+	// Expired local files (/v1/files) cleanup task
+	service.StartFileCleanupTask()
+
+	// Expired gateway-side stored responses cleanup task
+	service.StartResponseStoreCleanupTask()
+
+	// Proxy pool loading and health check task
+	service.StartProxyPoolHealthCheckTask()
+
+	// Batch API (/v1/batches) scheduler
+	controller.StartBatchScheduler()
+
 	// Wire task polling adaptor factory (breaks service -> relay import cycle)
 	service.GetTaskAdaptorFunc = func(platform constant.TaskPlatform) service.TaskPollingAdaptor {
 		a := relay.GetTaskAdaptor(platform)
@@ -383,12 +383,22 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	if token.ModelLimitsEnabled {
 		c.Set("token_model_limit_enabled", true)
-		c.Set("token_model_limit", token.GetModelLimitsMap())
+		c.Set("token_model_limit", token.GetModelLimitRules())
 	} else {
 		c.Set("token_model_limit_enabled", false)
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenHedgeDelayMs, token.HedgeDelayMs)
+	common.SetContextKey(c, constant.ContextKeyTokenTPM, token.TPM)
+	common.SetContextKey(c, constant.ContextKeyTokenTPD, token.TPD)
+	common.SetContextKey(c, constant.ContextKeyTokenHasBudget, token.HasBudget())
+	if policy := token.GetPolicy(); policy != nil {
+		common.SetContextKey(c, constant.ContextKeyTokenPolicy, policy)
+	}
+	if fallbacks := token.GetModelFallbacks(); len(fallbacks) > 0 {
+		common.SetContextKey(c, constant.ContextKeyTokenModelFallbacks, fallbacks)
+	}
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
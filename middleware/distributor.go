@@ -51,24 +51,21 @@ func Distribute() func(c *gin.Context) {
 				abortWithOpenAiMessage(c, http.StatusForbidden, i18n.T(c, i18n.MsgDistributorChannelDisabled))
 				return
 			}
+			if modelRequest.Model != "" && !resolveVirtualModel(c, modelRequest) {
+				return
+			}
 		} else {
 			// Select a channel for the user
 			// check token model mapping
 			modelLimitEnable := common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled)
 			if modelLimitEnable {
-				s, ok := common.GetContextKey(c, constant.ContextKeyTokenModelLimit)
-				if !ok {
+				tokenModelLimits, ok := common.GetContextKeyType[*model.TokenModelLimits](c, constant.ContextKeyTokenModelLimit)
+				if !ok || tokenModelLimits.IsEmpty() {
 					// token model limit is empty, all models are not allowed
 					abortWithOpenAiMessage(c, http.StatusForbidden, i18n.T(c, i18n.MsgDistributorTokenNoModelAccess))
 					return
 				}
-				var tokenModelLimit map[string]bool
-				tokenModelLimit, ok = s.(map[string]bool)
-				if !ok {
-					tokenModelLimit = map[string]bool{}
-				}
-				matchName := ratio_setting.FormatMatchingModelName(modelRequest.Model) // match gpts & thinking-*
-				if _, ok := tokenModelLimit[matchName]; !ok {
+				if !tokenModelLimits.IsAllowed(modelRequest.Model) {
 					abortWithOpenAiMessage(c, http.StatusForbidden, i18n.T(c, i18n.MsgDistributorTokenModelForbidden, map[string]any{"Model": modelRequest.Model}))
 					return
 				}
@@ -99,25 +96,60 @@ func Distribute() func(c *gin.Context) {
 					}
 				}
 
-				if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found {
-					preferred, err := model.CacheGetChannel(preferredChannelID)
-					if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled {
-						if usingGroup == "auto" {
-							userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
-							autoGroups := service.GetUserAutoGroup(userGroup)
-							for _, g := range autoGroups {
-								if model.IsChannelEnabledForGroupModel(g, modelRequest.Model, preferred.Id) {
-									selectGroup = g
-									common.SetContextKey(c, constant.ContextKeyAutoGroup, g)
-									channel = preferred
-									service.MarkChannelAffinityUsed(c, g, preferred.Id)
-									break
+				if !resolveVirtualModel(c, modelRequest) {
+					return
+				}
+				usingGroup = common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+
+				// 引用了上游文件的请求固定到文件所在渠道
+				if fileChannelId := service.GetFilePinnedChannel(c); fileChannelId > 0 {
+					pinned, err := model.CacheGetChannel(fileChannelId)
+					if err != nil || pinned == nil || pinned.Status != common.ChannelStatusEnabled {
+						abortWithOpenAiMessage(c, http.StatusServiceUnavailable, "The channel holding the referenced file is not available")
+						return
+					}
+					// 文件所在渠道必须在当前分组下提供该模型，auto 分组需解析出实际分组
+					if usingGroup == "auto" {
+						userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+						for _, g := range service.GetUserAutoGroup(userGroup) {
+							if model.IsChannelEnabledForGroupModel(g, modelRequest.Model, pinned.Id) {
+								selectGroup = g
+								common.SetContextKey(c, constant.ContextKeyAutoGroup, g)
+								break
+							}
+						}
+					} else if model.IsChannelEnabledForGroupModel(usingGroup, modelRequest.Model, pinned.Id) {
+						selectGroup = usingGroup
+					}
+					if selectGroup == "" {
+						abortWithOpenAiMessage(c, http.StatusServiceUnavailable, fmt.Sprintf("The channel holding the referenced file does not serve model %s in group %s", modelRequest.Model, usingGroup), types.ErrorCodeModelNotFound)
+						return
+					}
+					common.SetContextKey(c, constant.ContextKeyFileChannelId, fileChannelId)
+					channel = pinned
+				}
+
+				if channel == nil {
+					if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found {
+						preferred, err := model.CacheGetChannel(preferredChannelID)
+						if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled && service.IsChannelSupportRequest(c, preferred) {
+							if usingGroup == "auto" {
+								userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+								autoGroups := service.GetUserAutoGroup(userGroup)
+								for _, g := range autoGroups {
+									if model.IsChannelEnabledForGroupModel(g, modelRequest.Model, preferred.Id) {
+										selectGroup = g
+										common.SetContextKey(c, constant.ContextKeyAutoGroup, g)
+										channel = preferred
+										service.MarkChannelAffinityUsed(c, g, preferred.Id)
+										break
+									}
 								}
+							} else if model.IsChannelEnabledForGroupModel(usingGroup, modelRequest.Model, preferred.Id) {
+								channel = preferred
+								selectGroup = usingGroup
+								service.MarkChannelAffinityUsed(c, usingGroup, preferred.Id)
 							}
-						} else if model.IsChannelEnabledForGroupModel(usingGroup, modelRequest.Model, preferred.Id) {
-							channel = preferred
-							selectGroup = usingGroup
-							service.MarkChannelAffinityUsed(c, usingGroup, preferred.Id)
 						}
 					}
 				}
@@ -129,7 +161,29 @@ func Distribute() func(c *gin.Context) {
 						TokenGroup: usingGroup,
 						Retry:      common.GetPointer(0),
 					})
-					if err != nil {
+					if err != nil || channel == nil {
+						// 主模型没有可用渠道时尝试回退链上的模型
+						for _, fallbackModel := range service.GetFallbackModels(c, modelRequest.Model) {
+							service.ResetAutoGroupSelection(c)
+							fallbackChannel, fallbackGroup, fallbackErr := service.CacheGetRandomSatisfiedChannel(&service.RetryParam{
+								Ctx:        c,
+								ModelName:  fallbackModel,
+								TokenGroup: usingGroup,
+								Retry:      common.GetPointer(0),
+							})
+							if fallbackErr == nil && fallbackChannel != nil {
+								service.MarkModelFallback(c, modelRequest.Model, fallbackModel)
+								channel, selectGroup, err = fallbackChannel, fallbackGroup, nil
+								modelRequest.Model = fallbackModel
+								break
+							}
+						}
+					}
+					if (err != nil || channel == nil) && strings.HasPrefix(c.Request.URL.Path, "/v1/moderations") && service.ShouldFallbackToLocalModeration(c) {
+						// 没有可用的审核渠道，回退到本地审核
+						common.SetContextKey(c, constant.ContextKeyLocalModeration, true)
+						channel, err = nil, nil
+					} else if err != nil {
 						showGroup := usingGroup
 						if usingGroup == "auto" {
 							showGroup = fmt.Sprintf("auto(%s)", selectGroup)
@@ -142,8 +196,7 @@ func Distribute() func(c *gin.Context) {
 						//}
 						abortWithOpenAiMessage(c, http.StatusServiceUnavailable, message, types.ErrorCodeModelNotFound)
 						return
-					}
-					if channel == nil {
+					} else if channel == nil {
 						abortWithOpenAiMessage(c, http.StatusServiceUnavailable, i18n.T(c, i18n.MsgDistributorNoAvailableChannel, map[string]any{"Group": usingGroup, "Model": modelRequest.Model}), types.ErrorCodeModelNotFound)
 						return
 					}
@@ -159,6 +212,31 @@ func Distribute() func(c *gin.Context) {
 	}
 }
 
+// resolveVirtualModel 将虚拟模型解析为实际模型和分组，后续的渠道选择和计费都使用解析后的模型；
+// 解析失败或令牌不允许使用解析后的模型时中止请求并返回 false
+func resolveVirtualModel(c *gin.Context, modelRequest *ModelRequest) bool {
+	virtualRule, err := service.ResolveVirtualModel(c, modelRequest.Model)
+	if err != nil {
+		abortWithOpenAiMessage(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if virtualRule == nil {
+		return true
+	}
+	if common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		tokenModelLimits, ok := common.GetContextKeyType[*model.TokenModelLimits](c, constant.ContextKeyTokenModelLimit)
+		if !ok || !tokenModelLimits.IsAllowed(virtualRule.Model) {
+			abortWithOpenAiMessage(c, http.StatusForbidden, i18n.T(c, i18n.MsgDistributorTokenModelForbidden, map[string]any{"Model": virtualRule.Model}))
+			return false
+		}
+	}
+	modelRequest.Model = virtualRule.Model
+	if virtualRule.Group != "" {
+		common.SetContextKey(c, constant.ContextKeyUsingGroup, virtualRule.Group)
+	}
+	return true
+}
+
 // getModelFromRequest 从请求中读取模型信息
 // 根据 Content-Type 自动处理：
 // - application/json
@@ -280,6 +358,10 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 		if modelRequest.Model == "" {
 			modelRequest.Model = "text-moderation-stable"
 		}
+		if service.ShouldUseLocalModeration(c) {
+			common.SetContextKey(c, constant.ContextKeyLocalModeration, true)
+			shouldSelectChannel = false
+		}
 	}
 	if strings.HasSuffix(c.Request.URL.Path, "embeddings") {
 		if modelRequest.Model == "" {
@@ -288,7 +370,7 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/generations") {
 		modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e")
-	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") {
+	} else if strings.HasPrefix(c.Request.URL.Path, "/v1/images/edits") || strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
 		//modelRequest.Model = common.GetStringIfEmpty(c.PostForm("model"), "gpt-image-1")
 		contentType := c.ContentType()
 		if slices.Contains([]string{gin.MIMEPOSTForm, gin.MIMEMultipartPOSTForm}, contentType) {
@@ -297,6 +379,10 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 				modelRequest.Model = req.Model
 			}
 		}
+		// 图片变体接口的 model 为可选参数，与 OpenAI 保持一致默认使用 dall-e-2
+		if strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
+			modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "dall-e-2")
+		}
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/audio") {
 		relayMode := relayconstant.RelayModeAudioSpeech
@@ -357,7 +443,7 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	common.SetContextKey(c, constant.ContextKeyChannelModelMapping, channel.GetModelMapping())
 	common.SetContextKey(c, constant.ContextKeyChannelStatusCodeMapping, channel.GetStatusCodeMapping())
 
-	key, index, newAPIError := channel.GetNextEnabledKey()
+	key, index, newAPIError := service.GetNextCircuitAllowedKey(channel)
 	if newAPIError != nil {
 		return newAPIError
 	}
@@ -0,0 +1,200 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"gorm.io/gorm"
+)
+
+// Batch 通过 /v1/batches 创建的批量任务，由网关自身在后台逐行执行
+// 输出与错误结果在执行过程中追加写入本地存储文件（OutputPath / ErrorPath），
+// ProcessedLines 记录已写入结果的输入行数，服务重启后从该位置继续执行；
+// DispatchedLines 在执行（计费）前记录，重启时介于两者之间的行不会重复执行。
+type Batch struct {
+	Id               int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	BatchId          string `json:"batch_id" gorm:"type:varchar(64);uniqueIndex"`
+	UserId           int    `json:"user_id" gorm:"index:idx_batch_user_token"`
+	TokenId          int    `json:"token_id" gorm:"index:idx_batch_user_token"`
+	Group            string `json:"group" gorm:"type:varchar(64)"`
+	Endpoint         string `json:"endpoint" gorm:"type:varchar(64)"`
+	InputFileId      string `json:"input_file_id" gorm:"type:varchar(128)"`
+	CompletionWindow string `json:"completion_window" gorm:"type:varchar(16)"`
+	Status           string `json:"status" gorm:"type:varchar(20);index"`
+	OutputFileId     string `json:"output_file_id" gorm:"type:varchar(128)"`
+	ErrorFileId      string `json:"error_file_id" gorm:"type:varchar(128)"`
+	OutputPath       string `json:"-" gorm:"type:varchar(512)"`
+	ErrorPath        string `json:"-" gorm:"type:varchar(512)"`
+	TotalRequests    int    `json:"total_requests"`
+	CompletedCount   int    `json:"completed_count"`
+	FailedCount      int    `json:"failed_count"`
+	ProcessedLines   int    `json:"processed_lines"`
+	DispatchedLines  int    `json:"dispatched_lines"`
+	Metadata         string `json:"metadata" gorm:"type:text"`
+	Errors           string `json:"errors" gorm:"type:text"` // 批次级错误（JSON 数组）
+	CreatedAt        int64  `json:"created_at" gorm:"bigint;index"`
+	InProgressAt     int64  `json:"in_progress_at" gorm:"bigint"`
+	ExpiresAt        int64  `json:"expires_at" gorm:"bigint"`
+	FinalizingAt     int64  `json:"finalizing_at" gorm:"bigint"`
+	CompletedAt      int64  `json:"completed_at" gorm:"bigint"`
+	FailedAt         int64  `json:"failed_at" gorm:"bigint"`
+	ExpiredAt        int64  `json:"expired_at" gorm:"bigint"`
+	CancellingAt     int64  `json:"cancelling_at" gorm:"bigint"`
+	CancelledAt      int64  `json:"cancelled_at" gorm:"bigint"`
+}
+
+func (Batch) TableName() string {
+	return "batches"
+}
+
+// GenerateBatchId 生成 batch_xxxx 格式 ID
+func GenerateBatchId() string {
+	key, _ := common.GenerateRandomCharsKey(24)
+	return "batch_" + key
+}
+
+// IsFinished 批次是否已到达终态
+func (b *Batch) IsFinished() bool {
+	switch b.Status {
+	case dto.OpenAIBatchStatusCompleted, dto.OpenAIBatchStatusFailed,
+		dto.OpenAIBatchStatusExpired, dto.OpenAIBatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func optionalTime(t int64) *int64 {
+	if t == 0 {
+		return nil
+	}
+	return &t
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func (b *Batch) ToOpenAIBatch() dto.OpenAIBatch {
+	batch := dto.OpenAIBatch{
+		Id:               b.BatchId,
+		Object:           "batch",
+		Endpoint:         b.Endpoint,
+		InputFileId:      b.InputFileId,
+		CompletionWindow: b.CompletionWindow,
+		Status:           b.Status,
+		OutputFileId:     optionalString(b.OutputFileId),
+		ErrorFileId:      optionalString(b.ErrorFileId),
+		CreatedAt:        b.CreatedAt,
+		InProgressAt:     optionalTime(b.InProgressAt),
+		ExpiresAt:        optionalTime(b.ExpiresAt),
+		FinalizingAt:     optionalTime(b.FinalizingAt),
+		CompletedAt:      optionalTime(b.CompletedAt),
+		FailedAt:         optionalTime(b.FailedAt),
+		ExpiredAt:        optionalTime(b.ExpiredAt),
+		CancellingAt:     optionalTime(b.CancellingAt),
+		CancelledAt:      optionalTime(b.CancelledAt),
+		RequestCounts: dto.OpenAIBatchRequestCounts{
+			Total:     b.TotalRequests,
+			Completed: b.CompletedCount,
+			Failed:    b.FailedCount,
+		},
+	}
+	if b.Metadata != "" {
+		_ = common.UnmarshalJsonStr(b.Metadata, &batch.Metadata)
+	}
+	if b.Errors != "" {
+		var errs []dto.OpenAIBatchError
+		if err := common.UnmarshalJsonStr(b.Errors, &errs); err == nil && len(errs) > 0 {
+			batch.Errors = &dto.OpenAIBatchErrors{Object: "list", Data: errs}
+		}
+	}
+	return batch
+}
+
+func (b *Batch) Insert() error {
+	if b.CreatedAt == 0 {
+		b.CreatedAt = time.Now().Unix()
+	}
+	return DB.Create(b).Error
+}
+
+// UpdateWithStatus 以 fromStatus 为条件更新批次（CAS），返回是否更新成功
+func (b *Batch) UpdateWithStatus(fromStatus string) (bool, error) {
+	result := DB.Model(b).Where("status = ?", fromStatus).Select("*").Updates(b)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateProgress 更新执行进度，不修改状态，避免覆盖并发的取消操作
+func (b *Batch) UpdateProgress() error {
+	return DB.Model(b).Select("completed_count", "failed_count", "processed_lines", "dispatched_lines").Updates(b).Error
+}
+
+// GetUserBatch 获取用户令牌下的批次，不存在时返回 (nil, nil)
+func GetUserBatch(userId int, tokenId int, batchId string) (*Batch, error) {
+	var batch Batch
+	err := DB.Where("batch_id = ? AND user_id = ? AND token_id = ?", batchId, userId, tokenId).First(&batch).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// GetBatchById 按主键获取批次
+func GetBatchById(id int) (*Batch, error) {
+	var batch Batch
+	err := DB.First(&batch, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// ListUserBatches 按令牌列出批次（按创建时间倒序），多取一条用于判断 has_more
+func ListUserBatches(userId int, tokenId int, after string, limit int) ([]*Batch, bool, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	query := DB.Where("user_id = ? AND token_id = ?", userId, tokenId)
+	if after != "" {
+		afterBatch, err := GetUserBatch(userId, tokenId, after)
+		if err != nil {
+			return nil, false, err
+		}
+		if afterBatch != nil {
+			query = query.Where("id < ?", afterBatch.Id)
+		}
+	}
+	var batches []*Batch
+	err := query.Order("id desc").Limit(limit + 1).Find(&batches).Error
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(batches) > limit
+	if hasMore {
+		batches = batches[:limit]
+	}
+	return batches, hasMore, nil
+}
+
+// GetUnfinishedBatches 获取待执行或执行中的批次
+func GetUnfinishedBatches(limit int) ([]*Batch, error) {
+	var batches []*Batch
+	err := DB.Where("status IN ?", []string{
+		dto.OpenAIBatchStatusValidating,
+		dto.OpenAIBatchStatusInProgress,
+		dto.OpenAIBatchStatusFinalizing,
+		dto.OpenAIBatchStatusCancelling,
+	}).Order("id asc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
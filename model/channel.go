@@ -114,6 +114,7 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		// No keys available, return error, should disable the channel
 		return "", 0, types.NewError(errors.New("no keys available"), types.ErrorCodeChannelNoAvailableKey)
 	}
+	keyCooldowns := getChannelKeyCooldowns(channel.Id, keys)
 
 	lock := GetChannelPollingLock(channel.Id)
 	lock.Lock()
@@ -144,11 +145,27 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 	if len(enabledIdx) == 0 {
 		return "", 0, types.NewError(errors.New("no enabled keys"), types.ErrorCodeChannelNoAvailableKey)
 	}
+	// 跳过被上游限流、仍在冷却中的 Key；全部冷却时不跳过
+	isAvailable := func(idx int) bool {
+		return getStatus(idx) == common.ChannelStatusEnabled && keyCooldowns[idx] <= 0
+	}
+	availableIdx := make([]int, 0, len(enabledIdx))
+	for _, idx := range enabledIdx {
+		if isAvailable(idx) {
+			availableIdx = append(availableIdx, idx)
+		}
+	}
+	if len(availableIdx) == 0 {
+		availableIdx = enabledIdx
+		isAvailable = func(idx int) bool {
+			return getStatus(idx) == common.ChannelStatusEnabled
+		}
+	}
 
 	switch channel.ChannelInfo.MultiKeyMode {
 	case constant.MultiKeyModeRandom:
 		// Randomly pick one enabled key
-		selectedIdx := enabledIdx[rand.Intn(len(enabledIdx))]
+		selectedIdx := availableIdx[rand.Intn(len(availableIdx))]
 		return keys[selectedIdx], selectedIdx, nil
 	case constant.MultiKeyModePolling:
 		// Use channel-specific lock to ensure thread-safe polling
@@ -175,17 +192,17 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		}
 		for i := 0; i < len(keys); i++ {
 			idx := (start + i) % len(keys)
-			if getStatus(idx) == common.ChannelStatusEnabled {
+			if isAvailable(idx) {
 				// update polling index for next call (point to the next position)
 				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % len(keys)
 				return keys[idx], idx, nil
 			}
 		}
 		// Fallback – should not happen, but return first enabled key
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		return keys[availableIdx[0]], availableIdx[0], nil
 	default:
 		// Unknown mode, default to first enabled key (or original key string)
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		return keys[availableIdx[0]], availableIdx[0], nil
 	}
 }
 
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+)
+
+const channelKeyCooldownRedisPrefix = "new-api:key_cooldown:v1:"
+
+// channelKeyCooldowns 未启用 Redis 时被上游限流的 Key 的冷却截止时间（Unix 毫秒），按渠道和 Key 的哈希索引
+var channelKeyCooldowns sync.Map
+
+// channelKeyCooldownId 用渠道 ID 和 Key 的哈希标识一个 Key，Key 增删或重排后冷却状态不会错位
+func channelKeyCooldownId(channelId int, key string) string {
+	return fmt.Sprintf("%d:%s", channelId, hex.EncodeToString(common.Sha256Raw([]byte(key)))[:16])
+}
+
+func channelKeyCooldownEnabledRedis() bool {
+	return common.RedisEnabled && common.RDB != nil
+}
+
+// SetChannelKeyCooldown 设置 Key 的冷却时间，已有更晚的截止时间时保留
+func SetChannelKeyCooldown(channelId int, key string, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	id := channelKeyCooldownId(channelId, key)
+	until := time.Now().Add(duration)
+	if channelKeyCooldownEnabledRedis() {
+		err := limiter.SetKeyCooldown(context.Background(), common.RDB, channelKeyCooldownRedisPrefix+id, until)
+		if err == nil {
+			return
+		}
+		common.SysError(fmt.Sprintf("failed to set key cooldown for channel #%d: %s", channelId, err.Error()))
+	}
+	untilMilli := until.UnixMilli()
+	for {
+		v, loaded := channelKeyCooldowns.LoadOrStore(id, untilMilli)
+		if !loaded || v.(int64) >= untilMilli || channelKeyCooldowns.CompareAndSwap(id, v, untilMilli) {
+			return
+		}
+	}
+}
+
+// GetChannelKeyCooldown 返回 Key 的剩余冷却时间，未冷却返回 0
+func GetChannelKeyCooldown(channelId int, key string) time.Duration {
+	return getChannelKeyCooldowns(channelId, []string{key})[0]
+}
+
+// getChannelKeyCooldowns 批量返回同一渠道多个 Key 的剩余冷却时间，启用 Redis 时使用一次 MGET
+func getChannelKeyCooldowns(channelId int, keys []string) []time.Duration {
+	remaining := make([]time.Duration, len(keys))
+	if len(keys) == 0 {
+		return remaining
+	}
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = channelKeyCooldownId(channelId, key)
+	}
+	if channelKeyCooldownEnabledRedis() {
+		redisKeys := make([]string, len(ids))
+		for i, id := range ids {
+			redisKeys[i] = channelKeyCooldownRedisPrefix + id
+		}
+		values, err := common.RDB.MGet(context.Background(), redisKeys...).Result()
+		if err == nil {
+			for i, v := range values {
+				s, ok := v.(string)
+				if !ok {
+					continue
+				}
+				if until, err := strconv.ParseInt(s, 10, 64); err == nil {
+					remaining[i] = max(time.Until(time.UnixMilli(until)), 0)
+				}
+			}
+			return remaining
+		}
+		common.SysError(fmt.Sprintf("failed to get key cooldowns for channel #%d: %s", channelId, err.Error()))
+	}
+	for i, id := range ids {
+		v, ok := channelKeyCooldowns.Load(id)
+		if !ok {
+			continue
+		}
+		left := time.Until(time.UnixMilli(v.(int64)))
+		if left <= 0 {
+			channelKeyCooldowns.CompareAndDelete(id, v)
+			continue
+		}
+		remaining[i] = left
+	}
+	return remaining
+}
+
+// IsCoolingDown 渠道是否处于冷却中：单 Key 渠道看该 Key，多 Key 渠道要求所有启用的 Key 都在冷却
+func (channel *Channel) IsCoolingDown() bool {
+	if !channel.ChannelInfo.IsMultiKey {
+		return GetChannelKeyCooldown(channel.Id, channel.Key) > 0
+	}
+	if channel.ChannelInfo.MultiKeySize == 0 {
+		return false
+	}
+	keys := channel.GetKeys()
+	enabledKeys := make([]string, 0, len(keys))
+	for i, key := range keys {
+		if status, ok := channel.ChannelInfo.MultiKeyStatusList[i]; ok && status != common.ChannelStatusEnabled {
+			continue
+		}
+		enabledKeys = append(enabledKeys, key)
+	}
+	if len(enabledKeys) == 0 {
+		return false
+	}
+	for _, remaining := range getChannelKeyCooldowns(channel.Id, enabledKeys) {
+		if remaining <= 0 {
+			return false
+		}
+	}
+	return true
+}
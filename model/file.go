@@ -0,0 +1,156 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"gorm.io/gorm"
+)
+
+// File 通过 /v1/files 上传的文件
+// ChannelId 为 0 表示文件保存在本地磁盘（StoragePath），否则表示文件已透传到该渠道，
+// FileId 即上游返回的文件 id，后续引用该 file_id 的请求会固定到此渠道。
+type File struct {
+	Id          int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	FileId      string `json:"file_id" gorm:"type:varchar(128);uniqueIndex"`
+	UserId      int    `json:"user_id" gorm:"index:idx_file_user_token"`
+	TokenId     int    `json:"token_id" gorm:"index:idx_file_user_token"`
+	ChannelId   int    `json:"channel_id" gorm:"index"`
+	Filename    string `json:"filename" gorm:"type:varchar(255)"`
+	Purpose     string `json:"purpose" gorm:"type:varchar(32);index"`
+	Bytes       int64  `json:"bytes" gorm:"bigint"`
+	Status      string `json:"status" gorm:"type:varchar(20)"`
+	StoragePath string `json:"-" gorm:"type:varchar(512)"` // 本地存储路径，仅本地文件有效
+	CreatedAt   int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt   int64  `json:"expires_at" gorm:"bigint;default:0"` // 0 表示不过期
+}
+
+func (File) TableName() string {
+	return "files"
+}
+
+// IsLocal 文件是否保存在本地存储
+func (f *File) IsLocal() bool {
+	return f.ChannelId == 0
+}
+
+func (f *File) ToOpenAIFile() dto.OpenAIFile {
+	file := dto.OpenAIFile{
+		Id:        f.FileId,
+		Object:    "file",
+		Bytes:     f.Bytes,
+		CreatedAt: f.CreatedAt,
+		Filename:  f.Filename,
+		Purpose:   f.Purpose,
+		Status:    f.Status,
+	}
+	if f.ExpiresAt > 0 {
+		expiresAt := f.ExpiresAt
+		file.ExpiresAt = &expiresAt
+	}
+	return file
+}
+
+// GenerateFileId 生成本地文件的 file-xxxx 格式 ID
+func GenerateFileId() string {
+	key, _ := common.GenerateRandomCharsKey(24)
+	return "file-" + key
+}
+
+func (f *File) Insert() error {
+	if f.CreatedAt == 0 {
+		f.CreatedAt = time.Now().Unix()
+	}
+	return DB.Create(f).Error
+}
+
+func (f *File) Update() error {
+	return DB.Model(f).Select("status", "bytes", "filename").Updates(f).Error
+}
+
+func (f *File) Delete() error {
+	return DB.Delete(f).Error
+}
+
+// GetUserFile 获取用户令牌下的文件，不存在时返回 (nil, nil)
+func GetUserFile(userId int, tokenId int, fileId string) (*File, error) {
+	if fileId == "" {
+		return nil, errors.New("file id is empty")
+	}
+	var file File
+	err := DB.Where("file_id = ? AND user_id = ? AND token_id = ?", fileId, userId, tokenId).First(&file).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &file, nil
+}
+
+// GetUserFilesByIds 批量获取用户令牌下的文件
+func GetUserFilesByIds(userId int, tokenId int, fileIds []string) ([]*File, error) {
+	var files []*File
+	if len(fileIds) == 0 {
+		return files, nil
+	}
+	err := DB.Where("file_id IN ? AND user_id = ? AND token_id = ?", fileIds, userId, tokenId).Find(&files).Error
+	return files, err
+}
+
+// FileListParams 文件列表查询参数，与 OpenAI list files 参数一致
+type FileListParams struct {
+	Purpose string
+	After   string
+	Limit   int
+	Order   string // asc / desc
+}
+
+// ListUserFiles 按令牌列出文件，多取一条用于判断 has_more
+func ListUserFiles(userId int, tokenId int, params FileListParams) ([]*File, bool, error) {
+	limit := params.Limit
+	if limit <= 0 || limit > 10000 {
+		limit = 10000
+	}
+	query := DB.Where("user_id = ? AND token_id = ?", userId, tokenId)
+	if params.Purpose != "" {
+		query = query.Where("purpose = ?", params.Purpose)
+	}
+	asc := params.Order == "asc"
+	if params.After != "" {
+		after, err := GetUserFile(userId, tokenId, params.After)
+		if err != nil {
+			return nil, false, err
+		}
+		if after != nil {
+			if asc {
+				query = query.Where("id > ?", after.Id)
+			} else {
+				query = query.Where("id < ?", after.Id)
+			}
+		}
+	}
+	order := "id desc"
+	if asc {
+		order = "id asc"
+	}
+	var files []*File
+	err := query.Order(order).Limit(limit + 1).Find(&files).Error
+	if err != nil {
+		return nil, false, err
+	}
+	hasMore := len(files) > limit
+	if hasMore {
+		files = files[:limit]
+	}
+	return files, hasMore, nil
+}
+
+// GetExpiredLocalFiles 获取已过期的本地文件，用于后台清理
+func GetExpiredLocalFiles(now int64, limit int) ([]*File, error) {
+	var files []*File
+	err := DB.Where("channel_id = 0 AND expires_at > 0 AND expires_at < ?", now).Limit(limit).Find(&files).Error
+	return files, err
+}
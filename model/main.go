@@ -250,6 +250,7 @@ func InitLogDB() (err error) {
 func migrateDB() error {
 	// Migrate price_amount column from float/double to decimal for existing tables
 	migrateSubscriptionPlanPriceAmount()
+	migrateTokenModelLimitsToText()
 
 	err := DB.AutoMigrate(
 		&Channel{},
@@ -276,6 +277,10 @@ func migrateDB() error {
 		&SubscriptionPreConsumeRecord{},
 		&CustomOAuthProvider{},
 		&UserOAuthBinding{},
+		&File{},
+		&StoredResponse{},
+		&Batch{},
+		&ProxyPool{},
 	)
 	if err != nil {
 		return err
@@ -324,6 +329,10 @@ func migrateDBFast() error {
 		{&SubscriptionPreConsumeRecord{}, "SubscriptionPreConsumeRecord"},
 		{&CustomOAuthProvider{}, "CustomOAuthProvider"},
 		{&UserOAuthBinding{}, "UserOAuthBinding"},
+		{&File{}, "File"},
+		{&StoredResponse{}, "StoredResponse"},
+		{&Batch{}, "Batch"},
+		{&ProxyPool{}, "ProxyPool"},
 	}
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
@@ -502,6 +511,37 @@ func migrateSubscriptionPlanPriceAmount() {
 	}
 }
 
+// migrateTokenModelLimitsToText 将 tokens.model_limits 从 varchar(1024) 改为 text，取消模型规则的长度限制
+func migrateTokenModelLimitsToText() {
+	// SQLite 不限制 varchar 长度
+	if common.UsingSQLite || !DB.Migrator().HasTable(&Token{}) {
+		return
+	}
+	var alterSQL string
+	if common.UsingPostgreSQL {
+		var dataType string
+		DB.Raw(`SELECT data_type FROM information_schema.columns WHERE table_name = 'tokens' AND column_name = 'model_limits'`).Scan(&dataType)
+		if dataType == "" || dataType == "text" {
+			return
+		}
+		alterSQL = `ALTER TABLE tokens ALTER COLUMN model_limits TYPE text, ALTER COLUMN model_limits DROP DEFAULT`
+	} else if common.UsingMySQL {
+		var dataType string
+		DB.Raw(`SELECT DATA_TYPE FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = 'tokens' AND column_name = 'model_limits'`).Scan(&dataType)
+		if dataType == "" || strings.HasSuffix(strings.ToLower(dataType), "text") {
+			return
+		}
+		alterSQL = "ALTER TABLE tokens MODIFY COLUMN model_limits text"
+	} else {
+		return
+	}
+	if err := DB.Exec(alterSQL).Error; err != nil {
+		common.SysLog(fmt.Sprintf("Warning: failed to migrate tokens.model_limits to text: %v", err))
+	} else {
+		common.SysLog("Successfully migrated tokens.model_limits to text")
+	}
+}
+
 func closeDB(db *gorm.DB) error {
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -489,6 +489,9 @@ func handleConfigUpdate(key, value string) bool {
 		// 同步磁盘缓存配置到 common 包
 		performance_setting.UpdateAndSync()
 	}
+	if configName == "model_rate_limit_setting" {
+		operation_setting.CompileModelRateLimitRules()
+	}
 
 	return true // 已处理
 }
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ProxyPool 可被渠道引用的代理池，Proxies 为 JSON 数组格式的代理地址列表，
+// Strategy 为代理轮换策略：round_robin、random 或 sticky（按渠道密钥固定代理）
+type ProxyPool struct {
+	Id             int    `json:"id"`
+	Name           string `json:"name" gorm:"size:64;not null;uniqueIndex"`
+	Strategy       string `json:"strategy" gorm:"size:32;not null;default:'round_robin'"`
+	Proxies        string `json:"proxies" gorm:"type:text"`
+	HealthCheckURL string `json:"health_check_url" gorm:"type:varchar(255)"` // 为空时使用默认地址
+	Description    string `json:"description,omitempty" gorm:"type:varchar(255)"`
+	CreatedTime    int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime    int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// GetProxies 解析代理地址列表
+func (p *ProxyPool) GetProxies() []string {
+	var proxies []string
+	if p.Proxies == "" {
+		return proxies
+	}
+	if err := common.UnmarshalJsonStr(p.Proxies, &proxies); err != nil {
+		common.SysLog("failed to unmarshal proxies of proxy pool " + p.Name + ": " + err.Error())
+	}
+	return proxies
+}
+
+func (p *ProxyPool) Insert() error {
+	now := common.GetTimestamp()
+	p.CreatedTime = now
+	p.UpdatedTime = now
+	return DB.Create(p).Error
+}
+
+func (p *ProxyPool) Update() error {
+	p.UpdatedTime = common.GetTimestamp()
+	return DB.Model(p).Select("name", "strategy", "proxies", "health_check_url", "description", "updated_time").Updates(p).Error
+}
+
+// IsProxyPoolNameDuplicated 检查代理池名称是否重复（排除自身 ID）
+func IsProxyPoolNameDuplicated(id int, name string) (bool, error) {
+	var cnt int64
+	err := DB.Model(&ProxyPool{}).Where("name = ? AND id <> ?", name, id).Count(&cnt).Error
+	return cnt > 0, err
+}
+
+func GetProxyPoolById(id int) (*ProxyPool, error) {
+	var pool ProxyPool
+	if err := DB.First(&pool, id).Error; err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+func GetAllProxyPools() ([]*ProxyPool, error) {
+	var pools []*ProxyPool
+	if err := DB.Order("id ASC").Find(&pools).Error; err != nil {
+		return nil, err
+	}
+	return pools, nil
+}
+
+func DeleteProxyPoolById(id int) error {
+	return DB.Delete(&ProxyPool{}, id).Error
+}
+
+func ProxyPoolExists(name string) (bool, error) {
+	var cnt int64
+	err := DB.Model(&ProxyPool{}).Where("name = ?", name).Count(&cnt).Error
+	return cnt > 0, err
+}
+
+// GetChannelIdsUsingProxyPool 返回引用了指定代理池的渠道 id
+func GetChannelIdsUsingProxyPool(name string) ([]int, error) {
+	var channels []*Channel
+	if err := DB.Select("id", "setting").Where("setting LIKE ?", "%proxy_pool%").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0)
+	for _, channel := range channels {
+		if channel.Setting == nil {
+			continue
+		}
+		var setting dto.ChannelSettings
+		if err := common.UnmarshalJsonStr(*channel.Setting, &setting); err == nil && setting.ProxyPool == name {
+			ids = append(ids, channel.Id)
+		}
+	}
+	return ids, nil
+}
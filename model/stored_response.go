@@ -0,0 +1,115 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// StoredResponse 网关侧保存的 Responses 结果，供转换渠道实现 previous_response_id
+// Input 为本轮请求新增的 input 条目（JSON 数组），ParentResponseId 指向展开时使用的上一轮响应，
+// 完整历史沿 ParentResponseId 逐轮拼接 Input 和 Response 中的 output 条目得到。
+// 旧版本保存的记录 Input 为完整历史且 ParentResponseId 为空，作为链的起点。
+type StoredResponse struct {
+	Id                 int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	ResponseId         string `json:"response_id" gorm:"type:varchar(128);uniqueIndex"`
+	UserId             int    `json:"user_id" gorm:"index:idx_stored_response_user_token"`
+	TokenId            int    `json:"token_id" gorm:"index:idx_stored_response_user_token"`
+	PreviousResponseId string `json:"previous_response_id" gorm:"type:varchar(128)"`
+	ParentResponseId   string `json:"parent_response_id" gorm:"type:varchar(128)"`
+	Model              string `json:"model" gorm:"type:varchar(255)"`
+	Input              string `json:"input" gorm:"type:text"`
+	Response           string `json:"response" gorm:"type:text"`
+	CreatedAt          int64  `json:"created_at" gorm:"bigint;index"`
+	ExpiresAt          int64  `json:"expires_at" gorm:"bigint;index;default:0"` // 0 表示不过期
+}
+
+func (StoredResponse) TableName() string {
+	return "stored_responses"
+}
+
+func (r *StoredResponse) Insert() error {
+	if r.CreatedAt == 0 {
+		r.CreatedAt = time.Now().Unix()
+	}
+	return DB.Create(r).Error
+}
+
+func (r *StoredResponse) Delete() error {
+	return DB.Delete(r).Error
+}
+
+// GetUserStoredResponse 获取用户令牌下未过期的响应，不存在时返回 (nil, nil)
+func GetUserStoredResponse(userId int, tokenId int, responseId string) (*StoredResponse, error) {
+	if responseId == "" {
+		return nil, errors.New("response id is empty")
+	}
+	var response StoredResponse
+	err := DB.Where("response_id = ? AND user_id = ? AND token_id = ?", responseId, userId, tokenId).
+		Where("expires_at = 0 OR expires_at > ?", common.GetTimestamp()).
+		First(&response).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ErrStoredResponseChainBroken 响应链中间的某一轮已被删除或过期
+var ErrStoredResponseChainBroken = errors.New("stored response chain is broken")
+
+// GetUserStoredResponseChain 从 responseId 沿 ParentResponseId 向前查找响应链，按从早到晚的顺序返回；
+// responseId 不存在时返回 (nil, nil)
+func GetUserStoredResponseChain(userId int, tokenId int, responseId string, maxDepth int) ([]*StoredResponse, error) {
+	chain := make([]*StoredResponse, 0)
+	for id := responseId; id != ""; {
+		if len(chain) >= maxDepth {
+			return nil, fmt.Errorf("response chain of %s exceeds %d turns", responseId, maxDepth)
+		}
+		response, err := GetUserStoredResponse(userId, tokenId, id)
+		if err != nil {
+			return nil, err
+		}
+		if response == nil {
+			if len(chain) == 0 {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("%w: response %s not found", ErrStoredResponseChainBroken, id)
+		}
+		chain = append(chain, response)
+		id = response.ParentResponseId
+	}
+	slices.Reverse(chain)
+	return chain, nil
+}
+
+// ExtendStoredResponsesExpiry 将响应链上较早过期的响应延长到 expiresAt，expiresAt 为 0 表示不过期
+func ExtendStoredResponsesExpiry(ids []int, expiresAt int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := DB.Model(&StoredResponse{}).Where("id IN ? AND expires_at > 0", ids)
+	if expiresAt > 0 {
+		query = query.Where("expires_at < ?", expiresAt)
+	}
+	return query.Update("expires_at", expiresAt).Error
+}
+
+// DeleteExpiredStoredResponses 删除已过期的响应，返回删除条数
+func DeleteExpiredStoredResponses(now int64, limit int) (int64, error) {
+	var ids []int
+	if err := DB.Model(&StoredResponse{}).Where("expires_at > 0 AND expires_at < ?", now).Limit(limit).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := DB.Where("id IN ?", ids).Delete(&StoredResponse{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUserStoredResponseChain(t *testing.T) {
+	truncateTables(t)
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	for _, r := range []*StoredResponse{
+		{ResponseId: "resp_1", UserId: 1, TokenId: 1, Input: `["a"]`, ExpiresAt: expiresAt},
+		{ResponseId: "resp_2", UserId: 1, TokenId: 1, ParentResponseId: "resp_1", Input: `["b"]`, ExpiresAt: expiresAt},
+		{ResponseId: "resp_3", UserId: 1, TokenId: 1, ParentResponseId: "resp_2", Input: `["c"]`},
+	} {
+		require.NoError(t, r.Insert())
+	}
+
+	chain, err := GetUserStoredResponseChain(1, 1, "resp_3", 10)
+	require.NoError(t, err)
+	require.Len(t, chain, 3)
+	assert.Equal(t, []string{"resp_1", "resp_2", "resp_3"}, []string{chain[0].ResponseId, chain[1].ResponseId, chain[2].ResponseId})
+
+	_, err = GetUserStoredResponseChain(1, 1, "resp_3", 2)
+	assert.Error(t, err)
+
+	chain, err = GetUserStoredResponseChain(2, 1, "resp_3", 10)
+	require.NoError(t, err)
+	assert.Nil(t, chain)
+
+	// 延长链上响应的过期时间，0 表示不过期
+	require.NoError(t, ExtendStoredResponsesExpiry([]int{storedResponseId(t, "resp_1"), storedResponseId(t, "resp_2")}, 0))
+	first, err := GetUserStoredResponse(1, 1, "resp_1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), first.ExpiresAt)
+
+	require.NoError(t, first.Delete())
+	_, err = GetUserStoredResponseChain(1, 1, "resp_3", 10)
+	assert.ErrorIs(t, err, ErrStoredResponseChainBroken)
+}
+
+func storedResponseId(t *testing.T, responseId string) int {
+	t.Helper()
+	r, err := GetUserStoredResponse(1, 1, responseId)
+	require.NoError(t, err)
+	return r.Id
+}
@@ -309,12 +309,17 @@ func calcNextResetTime(base time.Time, plan *SubscriptionPlan, endUnix int64) in
 	if plan == nil {
 		return 0
 	}
-	period := NormalizeResetPeriod(plan.QuotaResetPeriod)
-	if period == SubscriptionResetNever {
+	next := calcNextPeriodResetTime(base, plan.QuotaResetPeriod, plan.QuotaResetCustomSeconds)
+	if endUnix > 0 && next > endUnix {
 		return 0
 	}
+	return next
+}
+
+// calcNextPeriodResetTime 计算 base 之后的下一个重置时间，不重置时返回 0
+func calcNextPeriodResetTime(base time.Time, period string, customSeconds int64) int64 {
 	var next time.Time
-	switch period {
+	switch NormalizeResetPeriod(period) {
 	case SubscriptionResetDaily:
 		next = time.Date(base.Year(), base.Month(), base.Day(), 0, 0, 0, 0, base.Location()).
 			AddDate(0, 0, 1)
@@ -333,16 +338,13 @@ func calcNextResetTime(base time.Time, plan *SubscriptionPlan, endUnix int64) in
 		next = time.Date(base.Year(), base.Month(), 1, 0, 0, 0, 0, base.Location()).
 			AddDate(0, 1, 0)
 	case SubscriptionResetCustom:
-		if plan.QuotaResetCustomSeconds <= 0 {
+		if customSeconds <= 0 {
 			return 0
 		}
-		next = base.Add(time.Duration(plan.QuotaResetCustomSeconds) * time.Second)
+		next = base.Add(time.Duration(customSeconds) * time.Second)
 	default:
 		return 0
 	}
-	if endUnix > 0 && next.Unix() > endUnix {
-		return 0
-	}
 	return next.Unix()
 }
 
@@ -3,9 +3,13 @@ package model
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/bytedance/gopkg/util/gopool"
 	"gorm.io/gorm"
@@ -23,11 +27,21 @@ type Token struct {
 	RemainQuota        int            `json:"remain_quota" gorm:"default:0"`
 	UnlimitedQuota     bool           `json:"unlimited_quota"`
 	ModelLimitsEnabled bool           `json:"model_limits_enabled"`
-	ModelLimits        string         `json:"model_limits" gorm:"type:varchar(1024);default:''"`
+	ModelLimits        string         `json:"model_limits" gorm:"type:text"` // 逗号分隔的模型规则，见 TokenModelLimits
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
 	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
+	ModelFallbacks     string         `json:"model_fallbacks" gorm:"type:text"`
+	HedgeDelayMs       int            `json:"hedge_delay_ms" gorm:"default:0"`                  // 对冲延迟（毫秒），0 表示不对冲
+	TPM                int            `json:"tpm" gorm:"default:0"`                             // 每分钟最多消耗的 token 数，0 表示不限制
+	TPD                int            `json:"tpd" gorm:"default:0"`                             // 每天最多消耗的 token 数，0 表示不限制
+	BudgetPeriod       string         `json:"budget_period" gorm:"type:varchar(16);default:''"` // 周期预算的重置周期：daily、weekly 或 monthly
+	BudgetQuota        int            `json:"budget_quota" gorm:"default:0"`                    // 每个周期最多消耗的额度，0 表示不限制
+	BudgetUsed         int            `json:"budget_used" gorm:"default:0"`                     // 当前周期已消耗的额度
+	BudgetStartTime    int64          `json:"budget_start_time" gorm:"bigint;default:0"`        // 当前周期开始时间
+	BudgetResetTime    int64          `json:"budget_reset_time" gorm:"bigint;default:0"`        // 下次重置时间，0 表示尚未开始计算周期
+	Policy             string         `json:"policy" gorm:"type:text"`                          // 请求策略，JSON 格式，见 dto.TokenPolicy
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -283,7 +297,8 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry", "model_fallbacks", "hedge_delay_ms", "tpm", "tpd",
+		"policy").Updates(token).Error
 	return err
 }
 
@@ -322,21 +337,171 @@ func (token *Token) IsModelLimitsEnabled() bool {
 }
 
 func (token *Token) GetModelLimits() []string {
-	if token.ModelLimits == "" {
-		return []string{}
+	return splitTokenModelLimits(token.ModelLimits)
+}
+
+// GetModelLimitRules 返回令牌解析后的可用模型规则，按令牌缓存，规则变化后重新解析
+func (token *Token) GetModelLimitRules() *TokenModelLimits {
+	if v, ok := tokenModelLimitsCache.Load(token.Id); ok {
+		if entry := v.(*tokenModelLimitsCacheEntry); entry.limits == token.ModelLimits {
+			return entry.parsed
+		}
+	}
+	limits, _ := ParseTokenModelLimits(token.ModelLimits, false)
+	if token.Id != 0 {
+		tokenModelLimitsCache.Store(token.Id, &tokenModelLimitsCacheEntry{limits: token.ModelLimits, parsed: limits})
 	}
-	return strings.Split(token.ModelLimits, ",")
+	return limits
 }
 
+// GetModelLimitsMap 返回允许的精确模型名称集合，通配符、正则和禁止规则见 GetModelLimits
 func (token *Token) GetModelLimitsMap() map[string]bool {
-	limits := token.GetModelLimits()
 	limitsMap := make(map[string]bool)
-	for _, limit := range limits {
-		limitsMap[limit] = true
+	for _, name := range token.GetModelLimitRules().GetAllowedModels(nil) {
+		limitsMap[name] = true
 	}
 	return limitsMap
 }
 
+// GetModelFallbacks 解析令牌配置的模型回退链，JSON 格式如 {"gpt-4o":["gpt-4.1"]}，格式错误时忽略
+func (token *Token) GetModelFallbacks() map[string][]string {
+	if strings.TrimSpace(token.ModelFallbacks) == "" {
+		return nil
+	}
+	var fallbacks map[string][]string
+	if err := common.UnmarshalJsonStr(token.ModelFallbacks, &fallbacks); err != nil {
+		return nil
+	}
+	return fallbacks
+}
+
+// GetPolicy 解析令牌的请求策略，未配置或格式错误时返回 nil
+func (token *Token) GetPolicy() *dto.TokenPolicy {
+	if strings.TrimSpace(token.Policy) == "" {
+		return nil
+	}
+	var policy dto.TokenPolicy
+	if err := common.UnmarshalJsonStr(token.Policy, &policy); err != nil {
+		return nil
+	}
+	return &policy
+}
+
+// HasBudget 令牌是否配置了周期预算
+func (token *Token) HasBudget() bool {
+	return token.BudgetQuota > 0 && IsValidTokenBudgetPeriod(token.BudgetPeriod)
+}
+
+// IsValidTokenBudgetPeriod 令牌周期预算支持按天、周、月重置
+func IsValidTokenBudgetPeriod(period string) bool {
+	switch period {
+	case SubscriptionResetDaily, SubscriptionResetWeekly, SubscriptionResetMonthly:
+		return true
+	}
+	return false
+}
+
+// GetBudgetUsed 返回当前周期已消耗的额度，周期已过但尚未重置时返回 0
+func (token *Token) GetBudgetUsed(now int64) int {
+	if token.BudgetResetTime > 0 && token.BudgetResetTime <= now {
+		return 0
+	}
+	return token.BudgetUsed
+}
+
+// GetBudgetResetTime 返回当前周期的重置时间，周期已过或尚未开始计算时按当前时间推算
+func (token *Token) GetBudgetResetTime(now int64) int64 {
+	if token.BudgetResetTime > now {
+		return token.BudgetResetTime
+	}
+	return calcNextPeriodResetTime(time.Unix(now, 0), token.BudgetPeriod, 0)
+}
+
+// UpdateTokenBudget 预算周期或额度变化时更新预算配置并重新开始计算周期，
+// 按原配置条件更新，未变化时不会覆盖并发请求累加的已用预算
+func UpdateTokenBudget(token *Token, period string, quota int) error {
+	if token.BudgetPeriod == period && token.BudgetQuota == quota {
+		return nil
+	}
+	result := DB.Model(&Token{}).Where("id = ? AND budget_period = ? AND budget_quota = ?", token.Id, token.BudgetPeriod, token.BudgetQuota).Updates(
+		map[string]interface{}{
+			"budget_period":     period,
+			"budget_quota":      quota,
+			"budget_used":       0,
+			"budget_start_time": 0,
+			"budget_reset_time": 0,
+		},
+	)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("令牌预算配置已被修改，请刷新后重试")
+	}
+	token.BudgetPeriod = period
+	token.BudgetQuota = quota
+	token.BudgetUsed = 0
+	token.BudgetStartTime = 0
+	token.BudgetResetTime = 0
+	return nil
+}
+
+// RefreshTokenBudget 首次使用或周期到期时清零令牌的已用预算并推进重置时间，
+// 按原重置时间条件更新，并发请求只会重置一次
+func RefreshTokenBudget(token *Token, now int64) error {
+	if !token.HasBudget() || token.BudgetResetTime > now {
+		return nil
+	}
+	start := now
+	next := calcNextPeriodResetTime(time.Unix(now, 0), token.BudgetPeriod, 0)
+	if token.BudgetResetTime > 0 {
+		start = token.BudgetResetTime
+		next = calcNextPeriodResetTime(time.Unix(start, 0), token.BudgetPeriod, 0)
+		for next > 0 && next <= now {
+			start = next
+			next = calcNextPeriodResetTime(time.Unix(start, 0), token.BudgetPeriod, 0)
+		}
+	}
+	result := DB.Model(&Token{}).Where("id = ? AND budget_reset_time = ?", token.Id, token.BudgetResetTime).Updates(
+		map[string]interface{}{
+			"budget_used":       0,
+			"budget_start_time": start,
+			"budget_reset_time": next,
+		},
+	)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 已被其他请求重置
+		var fresh Token
+		if err := DB.Select("budget_used", "budget_start_time", "budget_reset_time").First(&fresh, token.Id).Error; err != nil {
+			return err
+		}
+		token.BudgetUsed, start, next = fresh.BudgetUsed, fresh.BudgetStartTime, fresh.BudgetResetTime
+	} else {
+		token.BudgetUsed = 0
+	}
+	token.BudgetStartTime = start
+	token.BudgetResetTime = next
+	if common.RedisEnabled {
+		key := token.Key
+		budgetUsed := token.BudgetUsed
+		gopool.Go(func() {
+			for field, value := range map[string]int64{
+				constant.TokenFieldBudgetUsed:      int64(budgetUsed),
+				constant.TokenFieldBudgetStartTime: start,
+				constant.TokenFieldBudgetResetTime: next,
+			} {
+				if err := cacheSetTokenField(key, field, strconv.FormatInt(value, 10)); err != nil {
+					common.SysLog("failed to update token budget cache: " + err.Error())
+				}
+			}
+		})
+	}
+	return nil
+}
+
 func DisableModelLimits(tokenId int) error {
 	token, err := GetTokenById(tokenId)
 	if err != nil {
@@ -384,6 +549,7 @@ func increaseTokenQuota(id int, quota int) (err error) {
 		map[string]interface{}{
 			"remain_quota":  gorm.Expr("remain_quota + ?", quota),
 			"used_quota":    gorm.Expr("used_quota - ?", quota),
+			"budget_used":   gorm.Expr("CASE WHEN budget_used > ? THEN budget_used - ? ELSE 0 END", quota, quota),
 			"accessed_time": common.GetTimestamp(),
 		},
 	).Error
@@ -414,6 +580,7 @@ func decreaseTokenQuota(id int, quota int) (err error) {
 		map[string]interface{}{
 			"remain_quota":  gorm.Expr("remain_quota - ?", quota),
 			"used_quota":    gorm.Expr("used_quota + ?", quota),
+			"budget_used":   gorm.Expr("budget_used + ?", quota),
 			"accessed_time": common.GetTimestamp(),
 		},
 	).Error
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshTokenBudget(t *testing.T) {
+	truncateTables(t)
+	token := &Token{UserId: 1, Key: "budget-token", Name: "budget", BudgetPeriod: SubscriptionResetDaily, BudgetQuota: 100}
+	require.NoError(t, token.Insert())
+
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.Local).Unix()
+	require.NoError(t, RefreshTokenBudget(token, now))
+	assert.Equal(t, now, token.BudgetStartTime)
+	assert.Equal(t, time.Date(2026, 3, 11, 0, 0, 0, 0, time.Local).Unix(), token.BudgetResetTime)
+
+	require.NoError(t, decreaseTokenQuota(token.Id, 60))
+	require.NoError(t, increaseTokenQuota(token.Id, 80))
+	fresh, err := GetTokenById(token.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 0, fresh.BudgetUsed, "refund must not make budget negative")
+	require.NoError(t, decreaseTokenQuota(token.Id, 70))
+
+	// 周期内不重置
+	fresh, err = GetTokenById(token.Id)
+	require.NoError(t, err)
+	require.NoError(t, RefreshTokenBudget(fresh, now+3600))
+	assert.Equal(t, 70, fresh.BudgetUsed)
+
+	// 跨越多个周期后对齐到当天零点
+	later := time.Date(2026, 3, 13, 8, 0, 0, 0, time.Local).Unix()
+	stale := *fresh
+	assert.Equal(t, 0, fresh.GetBudgetUsed(later))
+	require.NoError(t, RefreshTokenBudget(fresh, later))
+	assert.Equal(t, 0, fresh.BudgetUsed)
+	assert.Equal(t, time.Date(2026, 3, 13, 0, 0, 0, 0, time.Local).Unix(), fresh.BudgetStartTime)
+	assert.Equal(t, time.Date(2026, 3, 14, 0, 0, 0, 0, time.Local).Unix(), fresh.BudgetResetTime)
+
+	// 并发请求使用过期的令牌副本时不会重复重置
+	require.NoError(t, decreaseTokenQuota(token.Id, 10))
+	require.NoError(t, RefreshTokenBudget(&stale, later))
+	assert.Equal(t, 10, stale.BudgetUsed)
+	assert.Equal(t, fresh.BudgetResetTime, stale.BudgetResetTime)
+}
+
+func TestUpdateTokenKeepsConcurrentBudgetUsage(t *testing.T) {
+	truncateTables(t)
+	token := &Token{UserId: 1, Key: "budget-update-token", Name: "budget", BudgetPeriod: SubscriptionResetDaily, BudgetQuota: 100}
+	require.NoError(t, token.Insert())
+
+	// 编辑令牌时使用的副本不包含并发请求累加的用量
+	stale, err := GetTokenById(token.Id)
+	require.NoError(t, err)
+	require.NoError(t, decreaseTokenQuota(token.Id, 30))
+	stale.Name = "renamed"
+	require.NoError(t, UpdateTokenBudget(stale, SubscriptionResetDaily, 100))
+	require.NoError(t, stale.Update())
+	fresh, err := GetTokenById(token.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", fresh.Name)
+	assert.Equal(t, 30, fresh.BudgetUsed)
+
+	// 预算配置变化后重新开始计算周期
+	require.NoError(t, UpdateTokenBudget(stale, SubscriptionResetWeekly, 200))
+	fresh, err = GetTokenById(token.Id)
+	require.NoError(t, err)
+	assert.Equal(t, SubscriptionResetWeekly, fresh.BudgetPeriod)
+	assert.Equal(t, 200, fresh.BudgetQuota)
+	assert.Equal(t, 0, fresh.BudgetUsed)
+
+	// 按过期的预算配置更新时不会覆盖
+	outdated := &Token{Id: token.Id, BudgetPeriod: SubscriptionResetDaily, BudgetQuota: 100}
+	assert.Error(t, UpdateTokenBudget(outdated, SubscriptionResetMonthly, 300))
+}
@@ -33,7 +33,7 @@ func cacheIncrTokenQuota(key string, increment int64) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return common.RedisHIncrBy(fmt.Sprintf("token:%s", key), constant.TokenFieldBudgetUsed, -increment)
 }
 
 func cacheDecrTokenQuota(key string, decrement int64) error {
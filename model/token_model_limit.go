@@ -0,0 +1,180 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+)
+
+// TokenModelLimits 令牌的可用模型规则，每条规则可以是精确的模型名称、含 * 的通配符（如 gpt-4o*）
+// 或 /.../ 包裹的正则表达式，! 前缀表示禁止。配置了允许规则时只能使用匹配的模型，
+// 只有禁止规则时可以使用其他所有模型，禁止规则优先
+type TokenModelLimits struct {
+	allowExact    map[string]bool
+	allowPatterns []*regexp.Regexp
+	denyExact     map[string]bool
+	denyPatterns  []*regexp.Regexp
+	// 存在无效的禁止规则，此时拒绝所有模型
+	denyAll bool
+	// 允许的精确模型名称，保持配置顺序
+	allowModels []string
+}
+
+// tokenModelLimitsCacheEntry 令牌解析后的模型规则，规则字符串变化后重新解析
+type tokenModelLimitsCacheEntry struct {
+	limits string
+	parsed *TokenModelLimits
+}
+
+var tokenModelLimitsCache sync.Map // map[int]*tokenModelLimitsCacheEntry
+
+// ParseTokenModelLimits 解析逗号或换行分隔的模型规则，strict 为 false 时记录并跳过无效的正则：
+// 无效的允许规则不匹配任何模型，无效的禁止规则拒绝所有模型
+func ParseTokenModelLimits(limits string, strict bool) (*TokenModelLimits, error) {
+	l := &TokenModelLimits{allowExact: map[string]bool{}, denyExact: map[string]bool{}}
+	for _, rule := range splitTokenModelLimits(limits) {
+		deny := strings.HasPrefix(rule, "!")
+		if deny {
+			rule = strings.TrimSpace(rule[1:])
+			if rule == "" {
+				continue
+			}
+		}
+		var pattern string
+		if len(rule) > 2 && strings.HasPrefix(rule, "/") && strings.HasSuffix(rule, "/") {
+			pattern = rule[1 : len(rule)-1]
+		} else if strings.Contains(rule, "*") {
+			pattern = "^" + strings.ReplaceAll(regexp.QuoteMeta(rule), `\*`, ".*") + "$"
+		}
+		if pattern == "" {
+			if deny {
+				l.denyExact[rule] = true
+			} else if !l.allowExact[rule] {
+				l.allowExact[rule] = true
+				l.allowModels = append(l.allowModels, rule)
+			}
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			if strict {
+				return nil, fmt.Errorf("invalid model limit %s: %w", rule, err)
+			}
+			common.SysError(fmt.Sprintf("invalid token model limit %s: %s", rule, err.Error()))
+			if deny {
+				l.denyAll = true
+			}
+			continue
+		}
+		if deny {
+			l.denyPatterns = append(l.denyPatterns, re)
+		} else {
+			l.allowPatterns = append(l.allowPatterns, re)
+		}
+	}
+	return l, nil
+}
+
+// splitTokenModelLimits 按逗号或换行拆分规则，/.../ 包裹的正则中的逗号不作为分隔符
+func splitTokenModelLimits(limits string) []string {
+	rules := make([]string, 0)
+	var rule strings.Builder
+	flush := func() {
+		if r := strings.TrimSpace(rule.String()); r != "" {
+			rules = append(rules, r)
+		}
+		rule.Reset()
+	}
+	inRegex := false
+	for i := 0; i < len(limits); i++ {
+		ch := limits[i]
+		if ch == '\n' {
+			inRegex = false
+			flush()
+			continue
+		}
+		if inRegex {
+			rule.WriteByte(ch)
+			if ch == '\\' && i+1 < len(limits) && limits[i+1] != '\n' {
+				i++
+				rule.WriteByte(limits[i])
+			} else if ch == '/' {
+				inRegex = false
+			}
+			continue
+		}
+		if ch == ',' {
+			flush()
+			continue
+		}
+		if ch == '/' {
+			if prefix := strings.TrimSpace(rule.String()); prefix == "" || prefix == "!" {
+				inRegex = true
+			}
+		}
+		rule.WriteByte(ch)
+	}
+	flush()
+	return rules
+}
+
+// IsEmpty 没有任何有效规则，此时不允许使用任何模型
+func (l *TokenModelLimits) IsEmpty() bool {
+	return !l.denyAll && len(l.allowExact) == 0 && len(l.allowPatterns) == 0 && len(l.denyExact) == 0 && len(l.denyPatterns) == 0
+}
+
+func (l *TokenModelLimits) hasAllowRules() bool {
+	return len(l.allowExact) > 0 || len(l.allowPatterns) > 0
+}
+
+func (l *TokenModelLimits) match(exact map[string]bool, patterns []*regexp.Regexp, names []string) bool {
+	for _, name := range names {
+		if exact[name] {
+			return true
+		}
+		for _, re := range patterns {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsAllowed 模型是否可以使用，同时匹配原始名称和归一化后的名称（gpts、thinking 等）
+func (l *TokenModelLimits) IsAllowed(modelName string) bool {
+	if l == nil || l.IsEmpty() || l.denyAll {
+		return false
+	}
+	names := []string{modelName}
+	if matchName := ratio_setting.FormatMatchingModelName(modelName); matchName != modelName {
+		names = append(names, matchName)
+	}
+	if l.match(l.denyExact, l.denyPatterns, names) {
+		return false
+	}
+	return !l.hasAllowRules() || l.match(l.allowExact, l.allowPatterns, names)
+}
+
+// GetAllowedModels 返回实际可用的模型：允许的精确模型名称，加上 candidates 中被通配符或正则允许的模型
+func (l *TokenModelLimits) GetAllowedModels(candidates []string) []string {
+	models := make([]string, 0, len(l.allowModels))
+	seen := make(map[string]bool)
+	for _, name := range l.allowModels {
+		if l.IsAllowed(name) {
+			models = append(models, name)
+			seen[name] = true
+		}
+	}
+	for _, name := range candidates {
+		if !seen[name] && l.IsAllowed(name) {
+			models = append(models, name)
+			seen[name] = true
+		}
+	}
+	return models
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenModelLimits(t *testing.T) {
+	limits, err := ParseTokenModelLimits("gpt-4o*, claude-*-sonnet-*,!gpt-4o-audio-preview\n/^o[13]-mini$/,text-embedding-3-small", true)
+	require.NoError(t, err)
+
+	for _, name := range []string{"gpt-4o", "gpt-4o-mini", "claude-3-7-sonnet-20250219", "o1-mini", "o3-mini", "text-embedding-3-small"} {
+		assert.True(t, limits.IsAllowed(name), name)
+	}
+	for _, name := range []string{"gpt-4o-audio-preview", "gpt-4.1", "claude-sonnet-4", "o1-mini-2024", "o1-pro"} {
+		assert.False(t, limits.IsAllowed(name), name)
+	}
+	assert.Equal(t, []string{"text-embedding-3-small", "gpt-4o-mini", "o3-mini"},
+		limits.GetAllowedModels([]string{"gpt-4o-mini", "gpt-4o-audio-preview", "o3-mini", "o1-pro", "text-embedding-3-small"}))
+
+	// 只有禁止规则时允许其他模型
+	denyOnly, err := ParseTokenModelLimits("!o1-pro", true)
+	require.NoError(t, err)
+	assert.True(t, denyOnly.IsAllowed("gpt-4o"))
+	assert.False(t, denyOnly.IsAllowed("o1-pro"))
+
+	empty, err := ParseTokenModelLimits("", true)
+	require.NoError(t, err)
+	assert.True(t, empty.IsEmpty())
+	assert.False(t, empty.IsAllowed("gpt-4o"))
+
+	_, err = ParseTokenModelLimits("/gpt-(4o/", true)
+	assert.Error(t, err)
+	lenient, err := ParseTokenModelLimits("/gpt-(4o/,gpt-4.1", false)
+	require.NoError(t, err)
+	assert.True(t, lenient.IsAllowed("gpt-4.1"))
+
+	// 无效的禁止规则拒绝所有模型
+	invalidDeny, err := ParseTokenModelLimits("gpt-4.1,!/o1-(pro/", false)
+	require.NoError(t, err)
+	assert.False(t, invalidDeny.IsAllowed("gpt-4.1"))
+	assert.False(t, invalidDeny.IsAllowed("o1-pro"))
+}
+
+func TestSplitTokenModelLimits(t *testing.T) {
+	assert.Equal(t, []string{"/^gpt-4o-(mini|audio){1,2}$/", "!/^o[1,3]-pro$/", "gpt-4.1", "claude-*"},
+		splitTokenModelLimits("/^gpt-4o-(mini|audio){1,2}$/, !/^o[1,3]-pro$/,gpt-4.1\nclaude-*,"))
+	assert.Equal(t, []string{`/a\/b,c/`, "d"}, splitTokenModelLimits(`/a\/b,c/,d`))
+
+	limits, err := ParseTokenModelLimits("/^o[1,3]-mini$/", true)
+	require.NoError(t, err)
+	assert.True(t, limits.IsAllowed("o3-mini"))
+	assert.False(t, limits.IsAllowed("o2-mini"))
+}
+
+func TestTokenModelLimitRulesCache(t *testing.T) {
+	token := &Token{Id: 900401, ModelLimits: "gpt-4o"}
+	first := token.GetModelLimitRules()
+	assert.Same(t, first, token.GetModelLimitRules())
+	assert.Equal(t, map[string]bool{"gpt-4o": true}, token.GetModelLimitsMap())
+
+	token.ModelLimits = "gpt-4.1,!gpt-4o"
+	updated := token.GetModelLimitRules()
+	assert.NotSame(t, first, updated)
+	assert.True(t, updated.IsAllowed("gpt-4.1"))
+	assert.False(t, updated.IsAllowed("gpt-4o"))
+}
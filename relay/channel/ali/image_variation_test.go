@@ -0,0 +1,44 @@
+package ali
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/constant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertImageVariationRequest(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", "cat.png")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("\x89PNG\r\n\x1a\nimage"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/variations", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	adaptor := &Adaptor{}
+	info := &relaycommon.RelayInfo{RelayMode: constant.RelayModeImagesVariations, OriginModelName: "qwen-image-edit"}
+	converted, err := adaptor.ConvertImageRequest(c, info, dto.ImageRequest{Model: "qwen-image-edit", N: 1})
+	require.NoError(t, err)
+
+	// 参考图转为 data URL，缺少提示词时使用默认提示词
+	aliRequest := converted.(*AliImageRequest)
+	require.Equal(t, "qwen-image-edit", aliRequest.Model)
+	content := aliRequest.Input.(AliImageInput).Messages[0].Content.([]AliMediaContent)
+	require.Len(t, content, 2)
+	require.True(t, strings.HasPrefix(content[0].Image, "data:image/png;base64,"))
+	require.Equal(t, imageVariationPrompt, content[1].Text)
+}
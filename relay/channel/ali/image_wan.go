@@ -38,6 +38,9 @@ func oaiFormEdit2WanxImageEdit(c *gin.Context, info *relaycommon.RelayInfo, requ
 	return &imageRequest, nil
 }
 
+// imageVariationPrompt 图片变体（/v1/images/variations）请求的默认提示词
+const imageVariationPrompt = "Generate a variation of this image that keeps its main subject, composition and style."
+
 func isOldWanModel(modelName string) bool {
 	return strings.Contains(modelName, "wan") && !strings.Contains(modelName, "wan2.6")
 }
@@ -1,6 +1,7 @@
 package channel
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	common2 "github.com/QuantumNous/new-api/common"
+	appconstant "github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
@@ -472,23 +474,34 @@ func DoRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 	return doRequest(c, req, info)
 }
 func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http.Response, error) {
-	var client *http.Client
-	var err error
-	if info.ChannelSetting.Proxy != "" {
-		client, err = service.NewProxyHttpClient(info.ChannelSetting.Proxy)
+	proxyURL := info.ChannelSetting.Proxy
+	poolProxy := ""
+	if info.ChannelSetting.ProxyPool != "" {
+		var err error
+		poolProxy, err = service.SelectPoolProxy(info.ChannelSetting.ProxyPool, info.ApiKey)
 		if err != nil {
-			return nil, fmt.Errorf("new proxy http client failed: %w", err)
+			return nil, fmt.Errorf("select proxy from pool failed: %w", err)
 		}
-	} else {
-		client = service.GetHttpClient()
+		proxyURL = poolProxy
+	}
+	// 重试到其他渠道时覆盖上一次使用的代理
+	common2.SetContextKey(c, appconstant.ContextKeyChannelPoolProxy, poolProxy)
+	client, err := service.GetChannelHttpClient(info.ChannelId, proxyURL, info.ChannelOtherSettings.Transport)
+	if err != nil {
+		return nil, fmt.Errorf("new proxy http client failed: %w", err)
+	}
+
+	if info.Hedge != nil {
+		// 对冲请求失败的一方需要能够被取消
+		req = req.WithContext(c.Request.Context())
 	}
 
 	var stopPinger context.CancelFunc
 	if info.IsStream {
 		helper.SetEventStreamHeaders(c)
-		// 处理流式请求的 ping 保活
+		// 处理流式请求的 ping 保活，对冲请求决出胜者前由外层统一保活
 		generalSettings := operation_setting.GetGeneralSetting()
-		if generalSettings.PingIntervalEnabled && !info.DisablePing {
+		if generalSettings.PingIntervalEnabled && !info.DisablePing && info.Hedge == nil {
 			pingInterval := time.Duration(generalSettings.PingIntervalSeconds) * time.Second
 			stopPinger = startPingKeepAlive(c, pingInterval)
 			// 使用defer确保在任何情况下都能停止ping goroutine
@@ -503,7 +516,30 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
+	// 流式请求在首个 token 超时前未收到响应头时取消请求
+	stopFirstTokenTimer := func() bool { return true }
+	if info.IsStream && info.ChannelMeta != nil {
+		if firstTokenTimeout := info.FirstTokenTimeout(); firstTokenTimeout > 0 {
+			ctx, cancel := context.WithCancel(req.Context())
+			stopFirstTokenTimer = time.AfterFunc(max(firstTokenTimeout-time.Since(info.AttemptStartTime), 0), cancel).Stop
+			req = req.WithContext(ctx)
+		}
+	}
+
 	resp, err := client.Do(req)
+	var claimErr error
+	if err == nil && resp != nil && info.Hedge != nil && resp.StatusCode < http.StatusBadRequest {
+		// 对冲请求等待首字节时同样受首个 token 超时限制
+		claimErr = claimHedge(info.Hedge, resp)
+	}
+	if !stopFirstTokenTimer() {
+		info.FirstTokenTimedOut = true
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		logger.LogError(c, "first token timeout while waiting for response headers")
+		return nil, types.NewErrorWithStatusCode(errors.New("first token timeout"), types.ErrorCodeFirstTokenTimeout, http.StatusGatewayTimeout)
+	}
 	if err != nil {
 		logger.LogError(c, "do request failed: "+err.Error())
 		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed, types.ErrOptionWithHideErrMsg("upstream error: do request failed"))
@@ -511,12 +547,33 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 	if resp == nil {
 		return nil, errors.New("resp is nil")
 	}
+	if claimErr != nil {
+		return nil, types.NewError(claimErr, types.ErrorCodeDoRequestFailed, types.ErrOptionWithSkipRetry())
+	}
 
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
 	return resp, nil
 }
 
+// claimHedge 等待上游返回首字节后争夺对冲请求的胜出权，落败时关闭响应
+func claimHedge(hedge *common.HedgeAttempt, resp *http.Response) error {
+	reader := bufio.NewReader(resp.Body)
+	if _, err := reader.Peek(1); err != nil && err != io.EOF {
+		_ = resp.Body.Close()
+		return fmt.Errorf("read first byte failed: %w", err)
+	}
+	if !hedge.Claim() {
+		_ = resp.Body.Close()
+		return errors.New("hedged request lost the race")
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}
+	return nil
+}
+
 func DoTaskApiRequest(a TaskAdaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Response, error) {
 	fullRequestURL, err := a.BuildRequestURL(info)
 	if err != nil {
@@ -0,0 +1,119 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/gin-gonic/gin"
+)
+
+// bedrockruntime SDK 当前版本未提供 CountTokens，这里直接请求 REST 接口
+type awsCountTokensRequest struct {
+	Input awsCountTokensInput `json:"input"`
+}
+
+type awsCountTokensInput struct {
+	InvokeModel awsCountTokensInvokeModel `json:"invokeModel"`
+}
+
+type awsCountTokensInvokeModel struct {
+	// InvokeModel 的请求体，序列化为 base64
+	Body []byte `json:"body"`
+}
+
+type awsCountTokensResponse struct {
+	InputTokens int `json:"inputTokens"`
+}
+
+// CountTokens 调用 Bedrock count-tokens 接口计算 Claude 模型的输入 token
+func CountTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeCountTokensRequest) (int, *types.NewAPIError) {
+	awsSecret := strings.Split(info.ApiKey, "|")
+	var region string
+	switch len(awsSecret) {
+	case 2:
+		region = awsSecret[1]
+	case 3:
+		region = awsSecret[2]
+	default:
+		return 0, types.NewError(fmt.Errorf("invalid aws secret key"), types.ErrorCodeChannelAwsClientError, types.ErrOptionWithSkipRetry())
+	}
+
+	countBody, err := common.Marshal(request)
+	if err != nil {
+		return 0, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	awsClaudeReq, err := formatRequest(bytes.NewReader(countBody), c.Request.Header)
+	if err != nil {
+		return 0, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	// InvokeModel 要求 max_tokens，且开启思考时必须大于 budget_tokens，不影响输入 token 计数
+	awsClaudeReq.MaxTokens = 1
+	if request.Thinking != nil && request.Thinking.BudgetTokens != nil {
+		awsClaudeReq.MaxTokens = uint(*request.Thinking.BudgetTokens) + 1
+	}
+	invokeBody, err := common.Marshal(awsClaudeReq)
+	if err != nil {
+		return 0, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	body, err := common.Marshal(awsCountTokensRequest{
+		Input: awsCountTokensInput{InvokeModel: awsCountTokensInvokeModel{Body: invokeBody}},
+	})
+	if err != nil {
+		return 0, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+
+	// 与 SDK 一致，模型 ID 中的冒号需要转义
+	modelId := strings.ReplaceAll(url.PathEscape(getAwsModelID(info.UpstreamModelName)), ":", "%3A")
+	requestURL := fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/count-tokens", region, modelId)
+	req, err := http.NewRequest(http.MethodPost, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, types.NewError(err, types.ErrorCodeDoRequestFailed)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if len(awsSecret) == 2 {
+		req.Header.Set("Authorization", "Bearer "+awsSecret[0])
+	} else {
+		payloadHash := sha256.Sum256(body)
+		credentials := aws.Credentials{AccessKeyID: awsSecret[0], SecretAccessKey: awsSecret[1]}
+		if err := v4.NewSigner().SignHTTP(context.Background(), credentials, req, hex.EncodeToString(payloadHash[:]), "bedrock", region, time.Now()); err != nil {
+			return 0, types.NewError(err, types.ErrorCodeChannelAwsClientError)
+		}
+	}
+
+	resp, err := channel.DoRequest(c, req, info)
+	if err != nil {
+		return 0, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, service.RelayErrorHandler(c.Request.Context(), resp, false)
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var countResponse awsCountTokensResponse
+	if err := common.Unmarshal(responseBody, &countResponse); err != nil {
+		return 0, types.NewOpenAIError(fmt.Errorf("unmarshal count tokens response failed: %w", err), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	return countResponse.InputTokens, nil
+}
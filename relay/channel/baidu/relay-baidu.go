@@ -134,6 +134,9 @@ func baiduStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.
 		}
 		return true
 	})
+	if timeoutErr := info.FirstTokenTimeoutError(); timeoutErr != nil {
+		return timeoutErr, nil
+	}
 	service.CloseResponseBodyGracefully(resp)
 	return nil, usage
 }
@@ -0,0 +1,46 @@
+package claude
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CountTokens 调用上游 count_tokens 接口，a 为实际发起请求的适配器（Anthropic 或 Vertex Claude）
+func CountTokens(a channel.Adaptor, c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeCountTokensRequest) (int, *types.NewAPIError) {
+	body, err := common.Marshal(request)
+	if err != nil {
+		return 0, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	if common.DebugEnabled {
+		println("count tokens request body: ", string(body))
+	}
+	resp, err := channel.DoApiRequest(a, c, info, bytes.NewReader(body))
+	if err != nil {
+		return 0, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, service.RelayErrorHandler(c.Request.Context(), resp, false)
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var countResponse dto.ClaudeCountTokensResponse
+	if err := common.Unmarshal(responseBody, &countResponse); err != nil {
+		return 0, types.NewOpenAIError(fmt.Errorf("unmarshal count tokens response failed: %w", err), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	return countResponse.InputTokens, nil
+}
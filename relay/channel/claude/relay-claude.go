@@ -544,6 +544,10 @@ type ClaudeResponseInfo struct {
 	ResponseText strings.Builder
 	Usage        *dto.Usage
 	Done         bool
+
+	// 转换为 Gemini 流式响应时，暂存尚未结束的 tool_use 及其参数
+	geminiToolCall      *dto.FunctionCall
+	geminiToolArguments strings.Builder
 }
 
 func buildMessageDeltaPatchUsage(claudeResponse *dto.ClaudeResponse, claudeInfo *ClaudeResponseInfo) *dto.ClaudeUsage {
@@ -727,6 +731,16 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 			return nil
 		}
 
+		err = helper.ObjectData(c, response)
+		if err != nil {
+			logger.LogError(c, "send_stream_response_failed: "+err.Error())
+		}
+	} else if info.RelayFormat == types.RelayFormatGemini {
+		FormatClaudeResponseInfo(&claudeResponse, nil, claudeInfo)
+		response := StreamResponseClaude2Gemini(&claudeResponse, claudeInfo)
+		if response == nil {
+			return nil
+		}
 		err = helper.ObjectData(c, response)
 		if err != nil {
 			logger.LogError(c, "send_stream_response_failed: "+err.Error())
@@ -776,6 +790,9 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 		}
 		return true
 	})
+	if timeoutErr := info.FirstTokenTimeoutError(); timeoutErr != nil {
+		return nil, timeoutErr
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -817,6 +834,13 @@ func HandleClaudeResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		}
 	case types.RelayFormatClaude:
 		responseData = data
+	case types.RelayFormatGemini:
+		geminiResponse := ResponseClaude2Gemini(&claudeResponse)
+		geminiResponse.UsageMetadata = geminiUsageMetadata(claudeInfo.Usage)
+		responseData, err = common.Marshal(geminiResponse)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
 	}
 
 	if claudeResponse.Usage != nil && claudeResponse.Usage.ServerToolUse != nil && claudeResponse.Usage.ServerToolUse.WebSearchRequests > 0 {
@@ -0,0 +1,538 @@
+package claude
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/reasonmap"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Claude 与 Gemini 的思考内容互转约定：
+//   - thinking 块 <-> thought 片段，签名保留在 thoughtSignature 上；流式响应中签名单独作为一个 thought 片段下发
+//   - redacted_thinking 块 <-> 仅带签名的 thought 片段，签名加前缀以区分
+const geminiRedactedThinkingPrefix = "redacted_thinking:"
+
+// Claude 开启思考时 budget_tokens 的最小值
+const claudeMinThinkingBudget = 1024
+
+// RequestGemini2ClaudeMessage 将 Gemini generateContent 请求直接转换为 Claude Messages 请求，保留思考签名、工具调用 ID 与图片
+func RequestGemini2ClaudeMessage(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (*dto.ClaudeRequest, error) {
+	config := request.GenerationConfig
+	claudeRequest := &dto.ClaudeRequest{
+		Model:         info.UpstreamModelName,
+		MaxTokens:     config.MaxOutputTokens,
+		Temperature:   config.Temperature,
+		TopP:          config.TopP,
+		TopK:          int(config.TopK),
+		StopSequences: config.StopSequences,
+		Stream:        info.IsStream,
+	}
+	if claudeRequest.MaxTokens == 0 {
+		claudeRequest.MaxTokens = uint(model_setting.GetClaudeSettings().GetDefaultMaxTokens(claudeRequest.Model))
+	}
+
+	if thinkingConfig := config.ThinkingConfig; thinkingConfig != nil {
+		budgetTokens := -1
+		if thinkingConfig.ThinkingBudget != nil {
+			budgetTokens = *thinkingConfig.ThinkingBudget
+		}
+		if budgetTokens != 0 && (thinkingConfig.IncludeThoughts || budgetTokens > 0 || thinkingConfig.ThinkingLevel != "") {
+			// 动态预算按 max_tokens 比例分配
+			if budgetTokens < 0 {
+				budgetTokens = int(float64(claudeRequest.MaxTokens) * model_setting.GetClaudeSettings().ThinkingAdapterBudgetTokensPercentage)
+			}
+			if budgetTokens < claudeMinThinkingBudget {
+				budgetTokens = claudeMinThinkingBudget
+			}
+			// max_tokens 必须大于 budget_tokens
+			if claudeRequest.MaxTokens <= uint(budgetTokens) {
+				claudeRequest.MaxTokens = uint(budgetTokens) + claudeRequest.MaxTokens
+			}
+			claudeRequest.Thinking = &dto.Thinking{
+				Type:         "enabled",
+				BudgetTokens: common.GetPointer(budgetTokens),
+			}
+			claudeRequest.TopP = 0
+			claudeRequest.TopK = 0
+			claudeRequest.Temperature = common.GetPointer[float64](1.0)
+		}
+	}
+
+	if request.SystemInstructions != nil {
+		var systemMessages []dto.ClaudeMediaMessage
+		for _, part := range request.SystemInstructions.Parts {
+			if part.Text == "" {
+				continue
+			}
+			systemMessage := dto.ClaudeMediaMessage{Type: dto.ContentTypeText}
+			systemMessage.SetText(part.Text)
+			systemMessages = append(systemMessages, systemMessage)
+		}
+		if len(systemMessages) > 0 {
+			claudeRequest.System = systemMessages
+		}
+	}
+
+	tools, err := geminiTools2Claude(request.GetTools())
+	if err != nil {
+		return nil, err
+	}
+	if len(tools) > 0 {
+		claudeRequest.Tools = tools
+		if request.ToolConfig != nil && request.ToolConfig.FunctionCallingConfig != nil {
+			claudeRequest.ToolChoice = geminiFunctionCallingConfig2Claude(request.ToolConfig.FunctionCallingConfig)
+		}
+	}
+
+	messages := make([]dto.ClaudeMessage, 0, len(request.Contents))
+	toolUseIDs := make(map[string][]string)
+	for _, content := range request.Contents {
+		role := "user"
+		if content.Role == "model" {
+			role = "assistant"
+		}
+		blocks, err := geminiParts2ClaudeContent(content.Parts, toolUseIDs)
+		if err != nil {
+			return nil, err
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		// Claude 要求角色交替出现，合并相邻的同角色消息
+		if last := len(messages) - 1; last >= 0 && messages[last].Role == role {
+			messages[last].Content = append(messages[last].Content.([]dto.ClaudeMediaMessage), blocks...)
+			continue
+		}
+		messages = append(messages, dto.ClaudeMessage{Role: role, Content: blocks})
+	}
+	for i := range messages {
+		if messages[i].Role == "user" {
+			messages[i].Content = moveToolResultsFirst(messages[i].Content.([]dto.ClaudeMediaMessage))
+		}
+	}
+	if len(messages) > 0 && messages[0].Role != "user" {
+		messages = append([]dto.ClaudeMessage{{
+			Role: "user",
+			Content: []dto.ClaudeMediaMessage{{
+				Type: dto.ContentTypeText,
+				Text: common.GetPointer[string]("..."),
+			}},
+		}}, messages...)
+	}
+	claudeRequest.Messages = messages
+	return claudeRequest, nil
+}
+
+func geminiTools2Claude(tools []dto.GeminiChatTool) ([]any, error) {
+	claudeTools := make([]any, 0, len(tools))
+	for _, tool := range tools {
+		if tool.GoogleSearch != nil || tool.GoogleSearchRetrieval != nil {
+			claudeTools = append(claudeTools, &dto.ClaudeWebSearchTool{
+				Type: "web_search_20250305",
+				Name: "web_search",
+			})
+		}
+		if tool.FunctionDeclarations == nil {
+			continue
+		}
+		declarations, err := common.Any2Type[[]map[string]any](tool.FunctionDeclarations)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gemini function declarations: %w", err)
+		}
+		for _, declaration := range declarations {
+			claudeTool := &dto.Tool{
+				Name:        common.Interface2String(declaration["name"]),
+				Description: common.Interface2String(declaration["description"]),
+			}
+			schema, ok := declaration["parametersJsonSchema"].(map[string]any)
+			if !ok {
+				schema, _ = declaration["parameters"].(map[string]any)
+			}
+			if schema == nil {
+				schema = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			claudeTool.InputSchema = lowerSchemaTypes(schema).(map[string]any)
+			claudeTools = append(claudeTools, claudeTool)
+		}
+	}
+	return claudeTools, nil
+}
+
+// lowerSchemaTypes Gemini Schema 的类型为大写（如 OBJECT、STRING），转为 JSON Schema 的小写形式
+func lowerSchemaTypes(schema any) any {
+	switch v := schema.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, value := range v {
+			if typeName, ok := value.(string); ok && key == "type" {
+				result[key] = strings.ToLower(typeName)
+				continue
+			}
+			result[key] = lowerSchemaTypes(value)
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, value := range v {
+			result[i] = lowerSchemaTypes(value)
+		}
+		return result
+	default:
+		return schema
+	}
+}
+
+func geminiFunctionCallingConfig2Claude(config *dto.FunctionCallingConfig) *dto.ClaudeToolChoice {
+	switch strings.ToUpper(string(config.Mode)) {
+	case "ANY":
+		if len(config.AllowedFunctionNames) == 1 {
+			return &dto.ClaudeToolChoice{Type: "tool", Name: config.AllowedFunctionNames[0]}
+		}
+		return &dto.ClaudeToolChoice{Type: "any"}
+	case "NONE":
+		return &dto.ClaudeToolChoice{Type: "none"}
+	case "AUTO", "VALIDATED":
+		return &dto.ClaudeToolChoice{Type: "auto"}
+	}
+	return nil
+}
+
+// geminiParts2ClaudeContent 转换单条 Gemini 消息，toolUseIDs 按函数名记录尚未响应的工具调用 ID
+func geminiParts2ClaudeContent(parts []dto.GeminiPart, toolUseIDs map[string][]string) ([]dto.ClaudeMediaMessage, error) {
+	blocks := make([]dto.ClaudeMediaMessage, 0, len(parts))
+	// 流式响应的思考内容会被客户端分片回传，累积到携带签名的片段为止
+	var thinking strings.Builder
+	for i := range parts {
+		part := &parts[i]
+		if part.Thought {
+			thinking.WriteString(part.Text)
+			signature := geminiThoughtSignature(part.ThoughtSignature)
+			if signature == "" {
+				continue
+			}
+			if data, ok := strings.CutPrefix(signature, geminiRedactedThinkingPrefix); ok {
+				blocks = append(blocks, dto.ClaudeMediaMessage{Type: "redacted_thinking", Data: data})
+			} else {
+				blocks = append(blocks, dto.ClaudeMediaMessage{
+					Type:      "thinking",
+					Thinking:  common.GetPointer(thinking.String()),
+					Signature: signature,
+				})
+			}
+			thinking.Reset()
+			continue
+		}
+		// 没有签名的思考内容无法通过 Claude 校验，直接丢弃
+		thinking.Reset()
+
+		switch {
+		case part.FunctionCall != nil:
+			id := part.FunctionCall.ID
+			if id == "" {
+				id = "toolu_" + common.GetUUID()
+			}
+			name := part.FunctionCall.FunctionName
+			toolUseIDs[name] = append(toolUseIDs[name], id)
+			input := part.FunctionCall.Arguments
+			if input == nil {
+				input = map[string]any{}
+			}
+			blocks = append(blocks, dto.ClaudeMediaMessage{
+				Type:  "tool_use",
+				Id:    id,
+				Name:  name,
+				Input: input,
+			})
+		case part.FunctionResponse != nil:
+			blocks = append(blocks, geminiFunctionResponse2Claude(part.FunctionResponse, toolUseIDs))
+		case part.InlineData != nil:
+			block, err := geminiInlineData2Claude(part.InlineData)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, *block)
+		case part.FileData != nil:
+			block, err := geminiFileData2Claude(part.FileData)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, *block)
+		case part.ExecutableCode != nil:
+			text := "```" + part.ExecutableCode.Language + "\n" + part.ExecutableCode.Code + "\n```"
+			blocks = append(blocks, dto.ClaudeMediaMessage{Type: dto.ContentTypeText, Text: common.GetPointer(text)})
+		case part.CodeExecutionResult != nil:
+			text := "```output\n" + part.CodeExecutionResult.Output + "\n```"
+			blocks = append(blocks, dto.ClaudeMediaMessage{Type: dto.ContentTypeText, Text: common.GetPointer(text)})
+		case part.Text != "":
+			blocks = append(blocks, dto.ClaudeMediaMessage{Type: dto.ContentTypeText, Text: common.GetPointer(part.Text)})
+		}
+	}
+	return blocks, nil
+}
+
+func geminiFunctionResponse2Claude(response *dto.GeminiFunctionResponse, toolUseIDs map[string][]string) dto.ClaudeMediaMessage {
+	id := geminiThoughtSignature(response.ID)
+	pending := toolUseIDs[response.Name]
+	if id == "" && len(pending) > 0 {
+		id = pending[0]
+	}
+	for i, pendingID := range pending {
+		if pendingID == id {
+			toolUseIDs[response.Name] = append(pending[:i:i], pending[i+1:]...)
+			break
+		}
+	}
+	if id == "" {
+		id = "toolu_" + common.GetUUID()
+	}
+
+	block := dto.ClaudeMediaMessage{Type: "tool_result", ToolUseId: id}
+	// 仅包含 output / error 等单个字段时直接取其内容
+	var content any = response.Response
+	if len(response.Response) == 1 {
+		for key, value := range response.Response {
+			switch key {
+			case "output", "content", "result":
+				content = value
+			case "error":
+				content = value
+				block.IsError = true
+			}
+		}
+	}
+	if text, ok := content.(string); ok {
+		block.Content = text
+	} else {
+		data, _ := common.Marshal(content)
+		block.Content = string(data)
+	}
+	return block
+}
+
+func geminiInlineData2Claude(data *dto.GeminiInlineData) (*dto.ClaudeMediaMessage, error) {
+	mimeType := strings.ToLower(data.MimeType)
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return &dto.ClaudeMediaMessage{
+			Type:   "image",
+			Source: &dto.ClaudeMessageSource{Type: "base64", MediaType: mimeType, Data: data.Data},
+		}, nil
+	case mimeType == "application/pdf":
+		return &dto.ClaudeMediaMessage{
+			Type:   "document",
+			Source: &dto.ClaudeMessageSource{Type: "base64", MediaType: mimeType, Data: data.Data},
+		}, nil
+	case mimeType == "text/plain":
+		text, err := base64.StdEncoding.DecodeString(data.Data)
+		if err != nil {
+			return nil, fmt.Errorf("decode text/plain inline data failed: %w", err)
+		}
+		return &dto.ClaudeMediaMessage{
+			Type:   "document",
+			Source: &dto.ClaudeMessageSource{Type: "text", MediaType: mimeType, Data: string(text)},
+		}, nil
+	}
+	return nil, fmt.Errorf("mime type is not supported by Claude: '%s'", data.MimeType)
+}
+
+func geminiFileData2Claude(data *dto.GeminiFileData) (*dto.ClaudeMediaMessage, error) {
+	mimeType := strings.ToLower(data.MimeType)
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return &dto.ClaudeMediaMessage{
+			Type:   "image",
+			Source: &dto.ClaudeMessageSource{Type: "url", Url: data.FileUri},
+		}, nil
+	case mimeType == "application/pdf":
+		return &dto.ClaudeMediaMessage{
+			Type:   "document",
+			Source: &dto.ClaudeMessageSource{Type: "url", Url: data.FileUri},
+		}, nil
+	}
+	return nil, fmt.Errorf("mime type is not supported by Claude: '%s', url: '%s'", data.MimeType, data.FileUri)
+}
+
+// moveToolResultsFirst Claude 要求 tool_result 位于用户消息的最前面
+func moveToolResultsFirst(blocks []dto.ClaudeMediaMessage) []dto.ClaudeMediaMessage {
+	sorted := make([]dto.ClaudeMediaMessage, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Type == "tool_result" {
+			sorted = append(sorted, block)
+		}
+	}
+	for _, block := range blocks {
+		if block.Type != "tool_result" {
+			sorted = append(sorted, block)
+		}
+	}
+	return sorted
+}
+
+// geminiThoughtSignature 解析 JSON 字符串形式的字段（thoughtSignature、functionResponse.id）
+func geminiThoughtSignature(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := common.Unmarshal(raw, &s); err != nil {
+		return ""
+	}
+	return s
+}
+
+func claudeBlock2GeminiPart(block *dto.ClaudeMediaMessage) *dto.GeminiPart {
+	switch block.Type {
+	case dto.ContentTypeText:
+		if block.GetText() == "" {
+			return nil
+		}
+		return &dto.GeminiPart{Text: block.GetText()}
+	case "thinking":
+		part := &dto.GeminiPart{Thought: true}
+		if block.Thinking != nil {
+			part.Text = *block.Thinking
+		}
+		if block.Signature != "" {
+			part.ThoughtSignature = json.RawMessage(strconv.Quote(block.Signature))
+		}
+		if part.Text == "" && len(part.ThoughtSignature) == 0 {
+			return nil
+		}
+		return part
+	case "redacted_thinking":
+		return &dto.GeminiPart{
+			Thought:          true,
+			ThoughtSignature: json.RawMessage(strconv.Quote(geminiRedactedThinkingPrefix + block.Data)),
+		}
+	case "tool_use":
+		args := block.Input
+		if args == nil {
+			args = map[string]any{}
+		}
+		return &dto.GeminiPart{
+			FunctionCall: &dto.FunctionCall{
+				ID:           block.Id,
+				FunctionName: block.Name,
+				Arguments:    args,
+			},
+		}
+	}
+	return nil
+}
+
+// geminiUsageMetadata 由 Claude 语义的用量生成 Gemini usageMetadata，promptTokenCount 包含缓存部分
+func geminiUsageMetadata(usage *dto.Usage) dto.GeminiUsageMetadata {
+	if usage == nil {
+		return dto.GeminiUsageMetadata{}
+	}
+	promptTokens := usage.PromptTokens + usage.PromptTokensDetails.CachedTokens + usage.PromptTokensDetails.CachedCreationTokens
+	return dto.GeminiUsageMetadata{
+		PromptTokenCount:        promptTokens,
+		CandidatesTokenCount:    usage.CompletionTokens,
+		TotalTokenCount:         promptTokens + usage.CompletionTokens,
+		CachedContentTokenCount: usage.PromptTokensDetails.CachedTokens,
+	}
+}
+
+func geminiCandidateResponse(parts []dto.GeminiPart, finishReason *string) *dto.GeminiChatResponse {
+	return &dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{{
+			Content: dto.GeminiChatContent{
+				Role:  "model",
+				Parts: parts,
+			},
+			FinishReason: finishReason,
+		}},
+	}
+}
+
+func ResponseClaude2Gemini(claudeResponse *dto.ClaudeResponse) *dto.GeminiChatResponse {
+	parts := make([]dto.GeminiPart, 0, len(claudeResponse.Content))
+	for i := range claudeResponse.Content {
+		if part := claudeBlock2GeminiPart(&claudeResponse.Content[i]); part != nil {
+			parts = append(parts, *part)
+		}
+	}
+	finishReason := reasonmap.ClaudeStopReasonToGeminiFinishReason(claudeResponse.StopReason)
+	return geminiCandidateResponse(parts, &finishReason)
+}
+
+// StreamResponseClaude2Gemini 将单个 Claude 流式事件转换为 Gemini 流式片段，无需输出时返回 nil
+func StreamResponseClaude2Gemini(claudeResponse *dto.ClaudeResponse, claudeInfo *ClaudeResponseInfo) *dto.GeminiChatResponse {
+	var part *dto.GeminiPart
+	var finishReason *string
+	switch claudeResponse.Type {
+	case "content_block_start":
+		if claudeResponse.ContentBlock == nil {
+			return nil
+		}
+		if claudeResponse.ContentBlock.Type == "tool_use" {
+			// 参数通过 input_json_delta 分片下发，在 content_block_stop 时输出完整的函数调用
+			claudeInfo.geminiToolCall = &dto.FunctionCall{
+				ID:           claudeResponse.ContentBlock.Id,
+				FunctionName: claudeResponse.ContentBlock.Name,
+			}
+			claudeInfo.geminiToolArguments.Reset()
+			return nil
+		}
+		part = claudeBlock2GeminiPart(claudeResponse.ContentBlock)
+	case "content_block_delta":
+		delta := claudeResponse.Delta
+		if delta == nil {
+			return nil
+		}
+		switch delta.Type {
+		case "text_delta":
+			if delta.GetText() != "" {
+				part = &dto.GeminiPart{Text: delta.GetText()}
+			}
+		case "thinking_delta":
+			if delta.Thinking != nil && *delta.Thinking != "" {
+				part = &dto.GeminiPart{Text: *delta.Thinking, Thought: true}
+			}
+		case "signature_delta":
+			part = &dto.GeminiPart{Thought: true, ThoughtSignature: json.RawMessage(strconv.Quote(delta.Signature))}
+		case "input_json_delta":
+			if delta.PartialJson != nil {
+				claudeInfo.geminiToolArguments.WriteString(*delta.PartialJson)
+			}
+			return nil
+		}
+	case "content_block_stop":
+		if claudeInfo.geminiToolCall == nil {
+			return nil
+		}
+		var arguments any = map[string]any{}
+		if claudeInfo.geminiToolArguments.Len() > 0 {
+			if err := common.UnmarshalJsonStr(claudeInfo.geminiToolArguments.String(), &arguments); err != nil {
+				common.SysLog("error unmarshalling tool_use input: " + err.Error())
+			}
+		}
+		claudeInfo.geminiToolCall.Arguments = arguments
+		part = &dto.GeminiPart{FunctionCall: claudeInfo.geminiToolCall}
+		claudeInfo.geminiToolCall = nil
+	case "message_delta":
+		if claudeResponse.Delta != nil && claudeResponse.Delta.StopReason != nil {
+			finishReason = common.GetPointer(reasonmap.ClaudeStopReasonToGeminiFinishReason(*claudeResponse.Delta.StopReason))
+		}
+	}
+	if part == nil && finishReason == nil {
+		return nil
+	}
+	parts := make([]dto.GeminiPart, 0, 1)
+	if part != nil {
+		parts = append(parts, *part)
+	}
+	geminiResponse := geminiCandidateResponse(parts, finishReason)
+	geminiResponse.UsageMetadata = geminiUsageMetadata(claudeInfo.Usage)
+	return geminiResponse
+}
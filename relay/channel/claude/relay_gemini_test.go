@@ -0,0 +1,67 @@
+package claude
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestGemini2ClaudeMessageThinkingAndTools(t *testing.T) {
+	request := &dto.GeminiChatRequest{
+		Contents: []dto.GeminiChatContent{
+			{Role: "model", Parts: []dto.GeminiPart{
+				{Text: "a", Thought: true},
+				{Text: "b", Thought: true, ThoughtSignature: json.RawMessage(`"SIG"`)},
+				{Thought: true, ThoughtSignature: json.RawMessage(`"redacted_thinking:DATA"`)},
+				{FunctionCall: &dto.FunctionCall{ID: "toolu_1", FunctionName: "get_weather", Arguments: map[string]any{"city": "Paris"}}},
+			}},
+			{Role: "user", Parts: []dto.GeminiPart{
+				{Text: "next"},
+				{FunctionResponse: &dto.GeminiFunctionResponse{Name: "get_weather", Response: map[string]any{"error": "boom"}}},
+			}},
+		},
+	}
+	claudeRequest, err := RequestGemini2ClaudeMessage(nil, &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{}}, request)
+	require.NoError(t, err)
+	require.Len(t, claudeRequest.Messages, 3)
+	require.Equal(t, "user", claudeRequest.Messages[0].Role)
+
+	assistant := claudeRequest.Messages[1].Content.([]dto.ClaudeMediaMessage)
+	require.Len(t, assistant, 3)
+	require.Equal(t, "thinking", assistant[0].Type)
+	require.Equal(t, "ab", *assistant[0].Thinking)
+	require.Equal(t, "SIG", assistant[0].Signature)
+	require.Equal(t, "redacted_thinking", assistant[1].Type)
+	require.Equal(t, "DATA", assistant[1].Data)
+	require.Equal(t, "toolu_1", assistant[2].Id)
+
+	user := claudeRequest.Messages[2].Content.([]dto.ClaudeMediaMessage)
+	require.Equal(t, "tool_result", user[0].Type)
+	require.Equal(t, "toolu_1", user[0].ToolUseId)
+	require.True(t, user[0].IsError)
+	require.Equal(t, "boom", user[0].Content)
+}
+
+func TestResponseClaude2GeminiRoundTrip(t *testing.T) {
+	claudeResponse := &dto.ClaudeResponse{
+		StopReason: "max_tokens",
+		Content: []dto.ClaudeMediaMessage{
+			{Type: "thinking", Thinking: new(string), Signature: "SIG"},
+			{Type: "redacted_thinking", Data: "DATA"},
+			{Type: "tool_use", Id: "toolu_1", Name: "get_weather", Input: map[string]any{"city": "Paris"}},
+		},
+	}
+	geminiResponse := ResponseClaude2Gemini(claudeResponse)
+	require.Equal(t, "MAX_TOKENS", *geminiResponse.Candidates[0].FinishReason)
+
+	parts := geminiResponse.Candidates[0].Content.Parts
+	require.Len(t, parts, 3)
+	blocks, err := geminiParts2ClaudeContent(parts, map[string][]string{})
+	require.NoError(t, err)
+	require.Equal(t, "SIG", blocks[0].Signature)
+	require.Equal(t, "DATA", blocks[1].Data)
+	require.Equal(t, "toolu_1", blocks[2].Id)
+}
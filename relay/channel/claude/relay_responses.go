@@ -0,0 +1,538 @@
+package claude
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reasoning.effort 对应的 thinking budget_tokens，与 Chat Completions 的 reasoning_effort 保持一致
+var responsesReasoningBudgetTokens = map[string]int{
+	"low":    1280,
+	"medium": 2048,
+	"high":   4096,
+}
+
+// RequestOpenAIResponses2ClaudeMessage 将 Responses API 请求转换为 Claude Messages 请求
+func RequestOpenAIResponses2ClaudeMessage(c *gin.Context, request dto.OpenAIResponsesRequest) (*dto.ClaudeRequest, error) {
+	if request.PreviousResponseID != "" {
+		return nil, helper.NewPreviousResponseNotFoundError(request.PreviousResponseID)
+	}
+	claudeRequest := dto.ClaudeRequest{
+		Model:       request.Model,
+		MaxTokens:   request.MaxOutputTokens,
+		Temperature: request.Temperature,
+		Stream:      request.Stream,
+	}
+	if request.TopP != nil {
+		claudeRequest.TopP = *request.TopP
+	}
+	if claudeRequest.MaxTokens == 0 {
+		claudeRequest.MaxTokens = uint(model_setting.GetClaudeSettings().GetDefaultMaxTokens(request.Model))
+	}
+
+	tools, err := responsesTools2ClaudeTools(request.Tools)
+	if err != nil {
+		return nil, err
+	}
+	if len(tools) > 0 {
+		claudeRequest.Tools = tools
+	}
+	if toolChoice := responsesToolChoice2Claude(request.ToolChoice, request.ParallelToolCalls); toolChoice != nil {
+		claudeRequest.ToolChoice = toolChoice
+	}
+
+	if request.Reasoning != nil {
+		if budgetTokens, ok := responsesReasoningBudgetTokens[request.Reasoning.Effort]; ok {
+			// max_tokens 必须大于 budget_tokens
+			if claudeRequest.MaxTokens <= uint(budgetTokens) {
+				claudeRequest.MaxTokens = uint(budgetTokens) + claudeRequest.MaxTokens
+			}
+			claudeRequest.Thinking = &dto.Thinking{
+				Type:         "enabled",
+				BudgetTokens: common.GetPointer[int](budgetTokens),
+			}
+			// https://docs.anthropic.com/en/docs/build-with-claude/extended-thinking#important-considerations-when-using-extended-thinking
+			claudeRequest.TopP = 0
+			claudeRequest.Temperature = common.GetPointer[float64](1.0)
+		}
+	}
+
+	var systemMessages []dto.ClaudeMediaMessage
+	if instructions := request.GetInstructions(); instructions != "" {
+		systemMessages = append(systemMessages, dto.ClaudeMediaMessage{
+			Type: "text",
+			Text: common.GetPointer[string](instructions),
+		})
+	}
+
+	items, err := request.ParseInputItems()
+	if err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+	claudeMessages := make([]dto.ClaudeMessage, 0, len(items))
+	// Claude 要求 user/assistant 交替出现，相同角色的相邻条目合并为一条消息
+	appendBlocks := func(role string, blocks ...dto.ClaudeMediaMessage) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(claudeMessages); n > 0 && claudeMessages[n-1].Role == role {
+			claudeMessages[n-1].Content = append(claudeMessages[n-1].Content.([]dto.ClaudeMediaMessage), blocks...)
+			return
+		}
+		claudeMessages = append(claudeMessages, dto.ClaudeMessage{Role: role, Content: blocks})
+	}
+
+	for _, item := range items {
+		switch item.Type {
+		case "message":
+			switch item.Role {
+			case "system", "developer":
+				for _, part := range dto.ParseResponsesContent(item.Content, "input_text") {
+					if part.Text != "" {
+						systemMessages = append(systemMessages, dto.ClaudeMediaMessage{
+							Type: "text",
+							Text: common.GetPointer[string](part.Text),
+						})
+					}
+				}
+			case "user", "assistant":
+				textType := "input_text"
+				if item.Role == "assistant" {
+					textType = "output_text"
+				}
+				blocks, err := responsesContent2ClaudeBlocks(c, dto.ParseResponsesContent(item.Content, textType))
+				if err != nil {
+					return nil, err
+				}
+				appendBlocks(item.Role, blocks...)
+			}
+		case "function_call":
+			input := make(map[string]any)
+			if strings.TrimSpace(item.Arguments) != "" {
+				if err := common.UnmarshalJsonStr(item.Arguments, &input); err != nil {
+					return nil, fmt.Errorf("invalid arguments of function call %s: %w", item.CallId, err)
+				}
+			}
+			appendBlocks("assistant", dto.ClaudeMediaMessage{
+				Type:  "tool_use",
+				Id:    item.CallId,
+				Name:  item.Name,
+				Input: input,
+			})
+		case "function_call_output":
+			toolResult := dto.ClaudeMediaMessage{
+				Type:      "tool_result",
+				ToolUseId: item.CallId,
+			}
+			if common.GetJsonType(item.Output) == "string" {
+				var output string
+				_ = common.Unmarshal(item.Output, &output)
+				toolResult.Content = output
+			} else {
+				blocks, err := responsesContent2ClaudeBlocks(c, dto.ParseResponsesContent(item.Output, "input_text"))
+				if err != nil {
+					return nil, err
+				}
+				toolResult.Content = blocks
+			}
+			appendBlocks("user", toolResult)
+		case "reasoning":
+			// 只有来自 Claude 的推理条目带有签名（encrypted_content），其余无法回传
+			if item.EncryptedContent == "" {
+				continue
+			}
+			if len(item.Summary) == 0 {
+				appendBlocks("assistant", dto.ClaudeMediaMessage{
+					Type: "redacted_thinking",
+					Data: item.EncryptedContent,
+				})
+				continue
+			}
+			summaries := make([]string, 0, len(item.Summary))
+			for _, summary := range item.Summary {
+				summaries = append(summaries, summary.Text)
+			}
+			appendBlocks("assistant", dto.ClaudeMediaMessage{
+				Type:      "thinking",
+				Thinking:  common.GetPointer[string](strings.Join(summaries, "\n\n")),
+				Signature: item.EncryptedContent,
+			})
+		}
+	}
+
+	// 第一条消息必须为 user
+	if len(claudeMessages) == 0 || claudeMessages[0].Role != "user" {
+		claudeMessages = append([]dto.ClaudeMessage{{
+			Role: "user",
+			Content: []dto.ClaudeMediaMessage{{
+				Type: "text",
+				Text: common.GetPointer[string]("..."),
+			}},
+		}}, claudeMessages...)
+	}
+
+	if len(systemMessages) > 0 {
+		claudeRequest.System = systemMessages
+	}
+	claudeRequest.Messages = claudeMessages
+	return &claudeRequest, nil
+}
+
+func responsesContent2ClaudeBlocks(c *gin.Context, parts []dto.ResponsesInputContent) ([]dto.ClaudeMediaMessage, error) {
+	blocks := make([]dto.ClaudeMediaMessage, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			// Claude 不接受空文本块
+			if part.Text == "" {
+				continue
+			}
+			blocks = append(blocks, dto.ClaudeMediaMessage{
+				Type: "text",
+				Text: common.GetPointer[string](part.Text),
+			})
+		case "input_image":
+			imageUrl := part.GetImageUrl()
+			if imageUrl == "" {
+				return nil, fmt.Errorf("input_image without image_url is not supported for Claude")
+			}
+			var source *types.FileSource
+			if strings.HasPrefix(imageUrl, "http") {
+				source = types.NewURLFileSource(imageUrl)
+			} else {
+				source = types.NewBase64FileSource(imageUrl, "")
+			}
+			base64Data, mimeType, err := service.GetBase64Data(c, source, "formatting image for Claude")
+			if err != nil {
+				return nil, fmt.Errorf("get file data failed: %s", err.Error())
+			}
+			blocks = append(blocks, dto.ClaudeMediaMessage{
+				Type: "image",
+				Source: &dto.ClaudeMessageSource{
+					Type:      "base64",
+					MediaType: mimeType,
+					Data:      base64Data,
+				},
+			})
+		case "input_file":
+			if part.FileUrl != "" {
+				blocks = append(blocks, dto.ClaudeMediaMessage{
+					Type: "document",
+					Source: &dto.ClaudeMessageSource{
+						Type: "url",
+						Url:  part.FileUrl,
+					},
+				})
+				continue
+			}
+			if part.FileData == "" {
+				return nil, fmt.Errorf("input_file without file_data or file_url is not supported for Claude")
+			}
+			base64Data, mimeType, err := service.GetBase64Data(c, types.NewBase64FileSource(part.FileData, ""), "formatting file for Claude")
+			if err != nil {
+				return nil, fmt.Errorf("get file data failed: %s", err.Error())
+			}
+			blocks = append(blocks, dto.ClaudeMediaMessage{
+				Type: "document",
+				Source: &dto.ClaudeMessageSource{
+					Type:      "base64",
+					MediaType: mimeType,
+					Data:      base64Data,
+				},
+			})
+		}
+	}
+	return blocks, nil
+}
+
+func responsesTools2ClaudeTools(toolsRaw []byte) ([]any, error) {
+	if len(toolsRaw) == 0 {
+		return nil, nil
+	}
+	var tools []map[string]any
+	if err := common.Unmarshal(toolsRaw, &tools); err != nil {
+		return nil, fmt.Errorf("invalid tools: %w", err)
+	}
+	claudeTools := make([]any, 0, len(tools))
+	for _, tool := range tools {
+		switch common.Interface2String(tool["type"]) {
+		case "function":
+			claudeTool := dto.Tool{
+				Name:        common.Interface2String(tool["name"]),
+				Description: common.Interface2String(tool["description"]),
+			}
+			if params, ok := tool["parameters"].(map[string]any); ok {
+				claudeTool.InputSchema = params
+			} else {
+				claudeTool.InputSchema = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			claudeTools = append(claudeTools, &claudeTool)
+		case dto.BuildInToolWebSearchPreview, "web_search":
+			claudeTools = append(claudeTools, &dto.ClaudeWebSearchTool{
+				Type: "web_search_20250305",
+				Name: "web_search",
+			})
+		}
+	}
+	return claudeTools, nil
+}
+
+// responsesToolChoice2Claude Responses 的 {"type":"function","name":...} 转为 Chat 格式后复用 mapToolChoice
+func responsesToolChoice2Claude(toolChoiceRaw []byte, parallelToolCallsRaw []byte) *dto.ClaudeToolChoice {
+	var toolChoice any
+	if len(toolChoiceRaw) > 0 {
+		_ = common.Unmarshal(toolChoiceRaw, &toolChoice)
+	}
+	if m, ok := toolChoice.(map[string]any); ok {
+		if name := common.Interface2String(m["name"]); name != "" {
+			toolChoice = map[string]any{"function": map[string]any{"name": name}}
+		}
+	}
+	var parallelToolCalls *bool
+	if common.GetJsonType(parallelToolCallsRaw) == "boolean" {
+		var parallel bool
+		_ = common.Unmarshal(parallelToolCallsRaw, &parallel)
+		parallelToolCalls = &parallel
+	}
+	if toolChoice == nil && parallelToolCalls == nil {
+		return nil
+	}
+	return mapToolChoice(toolChoice, parallelToolCalls)
+}
+
+// claudeUsage2Usage 转换为内部 usage，input_tokens 不包含缓存 tokens（Claude 计费语义）
+func claudeUsage2Usage(claudeUsage *dto.ClaudeUsage) *dto.Usage {
+	usage := &dto.Usage{}
+	if claudeUsage == nil {
+		return usage
+	}
+	usage.PromptTokens = claudeUsage.InputTokens
+	usage.CompletionTokens = claudeUsage.OutputTokens
+	usage.TotalTokens = claudeUsage.InputTokens + claudeUsage.OutputTokens
+	usage.PromptTokensDetails.CachedTokens = claudeUsage.CacheReadInputTokens
+	usage.PromptTokensDetails.CachedCreationTokens = claudeUsage.CacheCreationInputTokens
+	usage.ClaudeCacheCreation5mTokens = claudeUsage.GetCacheCreation5mTokens()
+	usage.ClaudeCacheCreation1hTokens = claudeUsage.GetCacheCreation1hTokens()
+	return usage
+}
+
+// buildResponsesUsage Claude 的 input_tokens 不含缓存部分，返回给客户端时加上缓存读取和写入的 tokens
+func buildResponsesUsage(usage *dto.Usage) *dto.Usage {
+	return service.BuildResponsesUsage(usage, usage.PromptTokens+usage.PromptTokensDetails.CachedTokens+usage.PromptTokensDetails.CachedCreationTokens)
+}
+
+func responsesIncompleteReason(stopReason string) string {
+	if stopReason == "max_tokens" {
+		return "max_output_tokens"
+	}
+	return ""
+}
+
+// ResponseClaude2OpenAIResponses 将 Claude 非流式响应转换为 Responses API 响应，不包含 usage
+func ResponseClaude2OpenAIResponses(claudeResponse *dto.ClaudeResponse, id string, createdAt int64) *dto.OpenAIResponsesResponse {
+	response := &dto.OpenAIResponsesResponse{
+		ID:        id,
+		Object:    "response",
+		CreatedAt: int(createdAt),
+		Status:    helper.ResponsesStatusCompleted,
+		Model:     claudeResponse.Model,
+		Output:    make([]dto.ResponsesOutput, 0, len(claudeResponse.Content)),
+	}
+	for _, block := range claudeResponse.Content {
+		switch block.Type {
+		case "text":
+			response.Output = append(response.Output, dto.ResponsesOutput{
+				Type:   "message",
+				ID:     "msg_" + common.GetUUID(),
+				Status: helper.ResponsesStatusCompleted,
+				Role:   "assistant",
+				Content: []dto.ResponsesOutputContent{{
+					Type:        "output_text",
+					Text:        block.GetText(),
+					Annotations: []interface{}{},
+				}},
+			})
+		case "thinking":
+			output := dto.ResponsesOutput{
+				Type:             "reasoning",
+				ID:               "rs_" + common.GetUUID(),
+				Status:           helper.ResponsesStatusCompleted,
+				Summary:          []dto.ResponsesReasoningSummaryPart{},
+				EncryptedContent: block.Signature,
+			}
+			if block.Thinking != nil && *block.Thinking != "" {
+				output.Summary = append(output.Summary, dto.ResponsesReasoningSummaryPart{Type: "summary_text", Text: *block.Thinking})
+			}
+			response.Output = append(response.Output, output)
+		case "redacted_thinking":
+			response.Output = append(response.Output, dto.ResponsesOutput{
+				Type:             "reasoning",
+				ID:               "rs_" + common.GetUUID(),
+				Status:           helper.ResponsesStatusCompleted,
+				Summary:          []dto.ResponsesReasoningSummaryPart{},
+				EncryptedContent: block.Data,
+			})
+		case "tool_use":
+			arguments := "{}"
+			if block.Input != nil {
+				if data, err := common.Marshal(block.Input); err == nil {
+					arguments = string(data)
+				}
+			}
+			response.Output = append(response.Output, dto.ResponsesOutput{
+				Type:      "function_call",
+				ID:        "fc_" + common.GetUUID(),
+				Status:    helper.ResponsesStatusCompleted,
+				CallId:    block.Id,
+				Name:      block.Name,
+				Arguments: arguments,
+			})
+		}
+	}
+	if reason := responsesIncompleteReason(claudeResponse.StopReason); reason != "" {
+		response.Status = helper.ResponsesStatusIncomplete
+		response.IncompleteDetails = &dto.IncompleteDetails{Reason: reason}
+	}
+	return response
+}
+
+func ClaudeResponsesHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	if common.DebugEnabled {
+		println("responseBody: ", string(responseBody))
+	}
+	var claudeResponse dto.ClaudeResponse
+	if err := common.Unmarshal(responseBody, &claudeResponse); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	if claudeError := claudeResponse.GetClaudeError(); claudeError != nil && claudeError.Type != "" {
+		return nil, types.WithClaudeError(*claudeError, http.StatusInternalServerError)
+	}
+	maybeMarkClaudeRefusal(c, claudeResponse.StopReason)
+	if claudeResponse.Usage != nil && claudeResponse.Usage.ServerToolUse != nil && claudeResponse.Usage.ServerToolUse.WebSearchRequests > 0 {
+		c.Set("claude_web_search_requests", claudeResponse.Usage.ServerToolUse.WebSearchRequests)
+	}
+
+	usage := claudeUsage2Usage(claudeResponse.Usage)
+	responsesResponse := ResponseClaude2OpenAIResponses(&claudeResponse, helper.GetResponsesID(c), common.GetTimestamp())
+	responsesResponse.Usage = buildResponsesUsage(usage)
+	responseData, err := common.Marshal(responsesResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, responseData)
+	helper.SetResponsesResult(c, responsesResponse)
+	return usage, nil
+}
+
+func ClaudeResponsesStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
+	claudeInfo := &ClaudeResponseInfo{
+		ResponseId:   helper.GetResponsesID(c),
+		Created:      common.GetTimestamp(),
+		Model:        info.UpstreamModelName,
+		ResponseText: strings.Builder{},
+		Usage:        &dto.Usage{},
+	}
+	writer := helper.NewResponsesStreamWriter(c, claudeInfo.ResponseId, claudeInfo.Created, info.UpstreamModelName)
+	var stopReason string
+	var newAPIError *types.NewAPIError
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		var claudeResponse dto.ClaudeResponse
+		if err := common.UnmarshalJsonStr(data, &claudeResponse); err != nil {
+			common.SysLog("error unmarshalling stream response: " + err.Error())
+			newAPIError = types.NewError(err, types.ErrorCodeBadResponseBody)
+			return false
+		}
+		if claudeError := claudeResponse.GetClaudeError(); claudeError != nil && claudeError.Type != "" {
+			newAPIError = types.WithClaudeError(*claudeError, http.StatusInternalServerError)
+			return false
+		}
+		FormatClaudeResponseInfo(&claudeResponse, nil, claudeInfo)
+
+		switch claudeResponse.Type {
+		case "message_start":
+			if claudeResponse.Message != nil {
+				writer.SetModel(claudeResponse.Message.Model)
+			}
+			writer.Start()
+		case "content_block_start":
+			block := claudeResponse.ContentBlock
+			if block == nil {
+				break
+			}
+			switch block.Type {
+			case "text":
+				writer.AppendText(block.GetText())
+			case "thinking":
+				thinking := ""
+				if block.Thinking != nil {
+					thinking = *block.Thinking
+				}
+				writer.AppendReasoning(thinking)
+			case "redacted_thinking":
+				writer.SetReasoningEncryptedContent(block.Data)
+			case "tool_use":
+				writer.StartFunctionCall(block.Id, block.Name)
+			}
+		case "content_block_delta":
+			delta := claudeResponse.Delta
+			if delta == nil {
+				break
+			}
+			switch delta.Type {
+			case "text_delta":
+				writer.AppendText(delta.GetText())
+			case "thinking_delta":
+				if delta.Thinking != nil {
+					writer.AppendReasoning(*delta.Thinking)
+				}
+			case "signature_delta":
+				writer.SetReasoningEncryptedContent(delta.Signature)
+			case "input_json_delta":
+				if delta.PartialJson != nil {
+					writer.AppendArguments(*delta.PartialJson)
+				}
+			}
+		case "content_block_stop":
+			writer.CloseItem()
+		case "message_delta":
+			if claudeResponse.Delta != nil && claudeResponse.Delta.StopReason != nil {
+				stopReason = *claudeResponse.Delta.StopReason
+				maybeMarkClaudeRefusal(c, stopReason)
+			}
+		}
+		return true
+	})
+	if timeoutErr := info.FirstTokenTimeoutError(); timeoutErr != nil {
+		return nil, timeoutErr
+	}
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+
+	if claudeInfo.Usage.CompletionTokens == 0 || !claudeInfo.Done {
+		if common.DebugEnabled {
+			common.SysLog("claude response usage is not complete, maybe upstream error")
+		}
+		claudeInfo.Usage = service.ResponseText2Usage(c, claudeInfo.ResponseText.String(), info.UpstreamModelName, claudeInfo.Usage.PromptTokens)
+	}
+	writer.Complete(buildResponsesUsage(claudeInfo.Usage), responsesIncompleteReason(stopReason))
+	return claudeInfo.Usage, nil
+}
@@ -0,0 +1,117 @@
+package claude
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func TestRequestOpenAIResponses2ClaudeMessage(t *testing.T) {
+	request := dto.OpenAIResponsesRequest{
+		Model:           "claude-sonnet-4",
+		Instructions:    json.RawMessage(`"be brief"`),
+		MaxOutputTokens: 1000,
+		Reasoning:       &dto.Reasoning{Effort: "medium"},
+		Tools:           json.RawMessage(`[{"type":"function","name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}]`),
+		ToolChoice:      json.RawMessage(`{"type":"function","name":"get_weather"}`),
+		Input: json.RawMessage(`[
+			{"role":"user","content":"weather in Paris?"},
+			{"type":"reasoning","summary":[{"type":"summary_text","text":"need tool"}],"encrypted_content":"sig"},
+			{"type":"reasoning","summary":[{"type":"summary_text","text":"unsigned"}]},
+			{"type":"function_call","call_id":"toolu_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"},
+			{"type":"function_call_output","call_id":"toolu_1","output":"sunny"},
+			{"type":"message","role":"user","content":[{"type":"input_text","text":"thanks"}]}
+		]`),
+	}
+
+	claudeRequest, err := RequestOpenAIResponses2ClaudeMessage(nil, request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claudeRequest.Thinking == nil || claudeRequest.Thinking.GetBudgetTokens() != 2048 {
+		t.Fatalf("thinking = %+v, want budget 2048", claudeRequest.Thinking)
+	}
+	if claudeRequest.MaxTokens <= 2048 {
+		t.Errorf("MaxTokens = %d, want > budget", claudeRequest.MaxTokens)
+	}
+	if toolChoice, ok := claudeRequest.ToolChoice.(*dto.ClaudeToolChoice); !ok || toolChoice.Type != "tool" || toolChoice.Name != "get_weather" {
+		t.Errorf("ToolChoice = %+v", claudeRequest.ToolChoice)
+	}
+	if system, ok := claudeRequest.System.([]dto.ClaudeMediaMessage); !ok || len(system) != 1 || system[0].GetText() != "be brief" {
+		t.Errorf("System = %+v", claudeRequest.System)
+	}
+
+	if len(claudeRequest.Messages) != 3 {
+		t.Fatalf("len(Messages) = %d, want 3", len(claudeRequest.Messages))
+	}
+	assistant := claudeRequest.Messages[1].Content.([]dto.ClaudeMediaMessage)
+	if claudeRequest.Messages[1].Role != "assistant" || len(assistant) != 2 {
+		t.Fatalf("assistant message = %+v", claudeRequest.Messages[1])
+	}
+	if assistant[0].Type != "thinking" || assistant[0].Signature != "sig" || *assistant[0].Thinking != "need tool" {
+		t.Errorf("thinking block = %+v", assistant[0])
+	}
+	if assistant[1].Type != "tool_use" || assistant[1].Id != "toolu_1" || assistant[1].Input.(map[string]any)["city"] != "Paris" {
+		t.Errorf("tool_use block = %+v", assistant[1])
+	}
+	user := claudeRequest.Messages[2].Content.([]dto.ClaudeMediaMessage)
+	if len(user) != 2 || user[0].Type != "tool_result" || user[0].Content != "sunny" || user[1].GetText() != "thanks" {
+		t.Errorf("user message = %+v", user)
+	}
+}
+
+func TestResponseClaude2OpenAIResponses(t *testing.T) {
+	thinking := "let me think"
+	text := "done"
+	claudeResponse := &dto.ClaudeResponse{
+		Model:      "claude-sonnet-4",
+		StopReason: "max_tokens",
+		Content: []dto.ClaudeMediaMessage{
+			{Type: "thinking", Thinking: &thinking, Signature: "sig"},
+			{Type: "redacted_thinking", Data: "opaque"},
+			{Type: "tool_use", Id: "toolu_1", Name: "get_weather", Input: map[string]any{"city": "Paris"}},
+			{Type: "text", Text: &text},
+		},
+	}
+
+	response := ResponseClaude2OpenAIResponses(claudeResponse, "resp_1", 1)
+	if response.Status != "incomplete" || response.IncompleteDetails == nil || response.IncompleteDetails.Reason != "max_output_tokens" {
+		t.Errorf("status = %s, incomplete_details = %+v", response.Status, response.IncompleteDetails)
+	}
+	if len(response.Output) != 4 {
+		t.Fatalf("len(Output) = %d, want 4", len(response.Output))
+	}
+	if out := response.Output[0]; out.Type != "reasoning" || out.EncryptedContent != "sig" || len(out.Summary) != 1 || out.Summary[0].Text != thinking {
+		t.Errorf("reasoning output = %+v", out)
+	}
+	if out := response.Output[1]; out.Type != "reasoning" || out.EncryptedContent != "opaque" || len(out.Summary) != 0 {
+		t.Errorf("redacted reasoning output = %+v", out)
+	}
+	if out := response.Output[2]; out.Type != "function_call" || out.CallId != "toolu_1" || out.Arguments != `{"city":"Paris"}` {
+		t.Errorf("function_call output = %+v", out)
+	}
+	if out := response.Output[3]; out.Type != "message" || out.Content[0].Text != text {
+		t.Errorf("message output = %+v", out)
+	}
+}
+
+func TestBuildResponsesUsage(t *testing.T) {
+	usage := claudeUsage2Usage(&dto.ClaudeUsage{
+		InputTokens:              100,
+		OutputTokens:             20,
+		CacheReadInputTokens:     30,
+		CacheCreationInputTokens: 50,
+	})
+	if usage.PromptTokens != 100 {
+		t.Errorf("PromptTokens = %d, want 100", usage.PromptTokens)
+	}
+
+	responsesUsage := buildResponsesUsage(usage)
+	if responsesUsage.InputTokens != 180 || responsesUsage.OutputTokens != 20 || responsesUsage.TotalTokens != 200 {
+		t.Errorf("usage = %+v", responsesUsage)
+	}
+	if responsesUsage.InputTokensDetails.CachedTokens != 30 {
+		t.Errorf("CachedTokens = %d, want 30", responsesUsage.InputTokensDetails.CachedTokens)
+	}
+}
@@ -0,0 +1,68 @@
+package gemini
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// generateContentRequestWithModel Gemini API 要求 generateContentRequest 中携带 model
+type generateContentRequestWithModel struct {
+	Model string `json:"model"`
+	*dto.GeminiChatRequest
+}
+
+// CountTokens 调用 Gemini API 的 countTokens 接口
+func CountTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiCountTokensRequest) (*dto.GeminiCountTokensResponse, *types.NewAPIError) {
+	adaptor := &Adaptor{}
+	adaptor.Init(info)
+	var body any = request
+	if request.GenerateContentRequest != nil {
+		body = map[string]any{
+			"generateContentRequest": generateContentRequestWithModel{
+				Model:             "models/" + info.UpstreamModelName,
+				GeminiChatRequest: request.GenerateContentRequest,
+			},
+		}
+	}
+	return DoCountTokensRequest(adaptor, c, info, body)
+}
+
+// DoCountTokensRequest 发送 countTokens 请求，a 为实际发起请求的适配器（Gemini 或 Vertex）
+func DoCountTokensRequest(a channel.Adaptor, c *gin.Context, info *relaycommon.RelayInfo, body any) (*dto.GeminiCountTokensResponse, *types.NewAPIError) {
+	jsonData, err := common.Marshal(body)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	if common.DebugEnabled {
+		println("count tokens request body: ", string(jsonData))
+	}
+	resp, err := channel.DoApiRequest(a, c, info, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, service.RelayErrorHandler(c.Request.Context(), resp, false)
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var countResponse dto.GeminiCountTokensResponse
+	if err := common.Unmarshal(responseBody, &countResponse); err != nil {
+		return nil, types.NewOpenAIError(fmt.Errorf("unmarshal count tokens response failed: %w", err), types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	return &countResponse, nil
+}
@@ -0,0 +1,691 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/relay/reasonmap"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Claude 与 Gemini 的思考签名互转约定：
+//   - 带文本的 thinking 块 <-> thought 片段，签名保留在该片段上
+//   - 文本为空的 thinking 块 <-> 后一个非 thought 片段上的签名（如文本、函数调用）
+
+// RequestClaude2Gemini 将 Claude Messages 请求直接转换为 Gemini 请求，保留思考签名、工具调用与图片
+func RequestClaude2Gemini(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (*dto.GeminiChatRequest, error) {
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: make([]dto.GeminiChatContent, 0, len(request.Messages)),
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			Temperature:     request.Temperature,
+			TopP:            request.TopP,
+			TopK:            float64(request.TopK),
+			MaxOutputTokens: request.MaxTokens,
+		},
+	}
+	if model_setting.IsGeminiModelSupportImagine(info.UpstreamModelName) {
+		geminiRequest.GenerationConfig.ResponseModalities = []string{
+			"TEXT",
+			"IMAGE",
+		}
+	}
+	if stopSequences := request.StopSequences; len(stopSequences) > 0 {
+		// Gemini supports up to 5 stop sequences
+		if len(stopSequences) > 5 {
+			stopSequences = stopSequences[:5]
+		}
+		geminiRequest.GenerationConfig.StopSequences = stopSequences
+	}
+
+	if request.Thinking != nil {
+		switch request.Thinking.Type {
+		case "enabled":
+			thinkingConfig := &dto.GeminiThinkingConfig{IncludeThoughts: true}
+			if budget := request.Thinking.GetBudgetTokens(); budget > 0 {
+				thinkingConfig.ThinkingBudget = common.GetPointer(clampThinkingBudget(info.UpstreamModelName, budget))
+			}
+			geminiRequest.GenerationConfig.ThinkingConfig = thinkingConfig
+		case "adaptive":
+			geminiRequest.GenerationConfig.ThinkingConfig = &dto.GeminiThinkingConfig{
+				IncludeThoughts: true,
+				ThinkingBudget:  common.GetPointer(-1),
+			}
+		case "disabled":
+			// 2.5 Pro 不支持关闭思考
+			if !isNew25ProModel(info.UpstreamModelName) {
+				geminiRequest.GenerationConfig.ThinkingConfig = &dto.GeminiThinkingConfig{
+					ThinkingBudget: common.GetPointer(0),
+				}
+			}
+		}
+	}
+	if geminiRequest.GenerationConfig.ThinkingConfig == nil {
+		ThinkingAdaptor(geminiRequest, info)
+	}
+
+	safetySettings := make([]dto.GeminiChatSafetySettings, 0, len(SafetySettingList))
+	for _, category := range SafetySettingList {
+		safetySettings = append(safetySettings, dto.GeminiChatSafetySettings{
+			Category:  category,
+			Threshold: model_setting.GetGeminiSafetySetting(category),
+		})
+	}
+	geminiRequest.SafetySettings = safetySettings
+
+	if len(request.OutputFormat) > 0 {
+		var outputFormat struct {
+			Type   string `json:"type"`
+			Schema any    `json:"schema"`
+		}
+		if err := common.Unmarshal(request.OutputFormat, &outputFormat); err == nil && outputFormat.Type == "json_schema" {
+			geminiRequest.GenerationConfig.ResponseMimeType = "application/json"
+			if outputFormat.Schema != nil {
+				geminiRequest.GenerationConfig.ResponseSchema = removeAdditionalPropertiesWithDepth(outputFormat.Schema, 0)
+			}
+		}
+	}
+
+	if request.Tools != nil {
+		if err := convertClaudeTools2Gemini(request, geminiRequest); err != nil {
+			return nil, err
+		}
+	}
+
+	var systemTexts []string
+	if request.IsStringSystem() {
+		if system := request.GetStringSystem(); system != "" {
+			systemTexts = append(systemTexts, system)
+		}
+	} else {
+		for _, system := range request.ParseSystem() {
+			if system.Type == dto.ContentTypeText && system.GetText() != "" {
+				systemTexts = append(systemTexts, system.GetText())
+			}
+		}
+	}
+	if len(systemTexts) > 0 {
+		geminiRequest.SystemInstructions = &dto.GeminiChatContent{
+			Parts: []dto.GeminiPart{{Text: strings.Join(systemTexts, "\n")}},
+		}
+	}
+
+	attachBypassSignature := model_setting.GetGeminiSettings().FunctionCallThoughtSignatureEnabled
+	toolNames := make(map[string]string)
+	for _, message := range request.Messages {
+		content := dto.GeminiChatContent{Role: "user"}
+		if message.Role == "assistant" {
+			content.Role = "model"
+		}
+		if message.IsStringContent() {
+			if text := message.GetStringContent(); text != "" {
+				content.Parts = append(content.Parts, dto.GeminiPart{Text: text})
+			}
+		} else {
+			blocks, err := message.ParseContent()
+			if err != nil {
+				return nil, fmt.Errorf("parse claude message content failed: %w", err)
+			}
+			parts, err := convertClaudeContent2GeminiParts(c, blocks, toolNames)
+			if err != nil {
+				return nil, err
+			}
+			content.Parts = parts
+		}
+		if len(content.Parts) == 0 {
+			continue
+		}
+		if content.Role == "model" && attachBypassSignature {
+			attachFunctionCallBypassSignature(content.Parts)
+		}
+		geminiRequest.Contents = append(geminiRequest.Contents, content)
+	}
+	return geminiRequest, nil
+}
+
+func convertClaudeTools2Gemini(request *dto.ClaudeRequest, geminiRequest *dto.GeminiChatRequest) error {
+	tools, err := common.Any2Type[[]map[string]any](request.Tools)
+	if err != nil {
+		return fmt.Errorf("invalid claude tools: %w", err)
+	}
+	functions := make([]dto.FunctionRequest, 0, len(tools))
+	googleSearch := false
+	for _, tool := range tools {
+		toolType := common.Interface2String(tool["type"])
+		if strings.HasPrefix(toolType, "web_search") {
+			googleSearch = true
+			continue
+		}
+		// 其余 Claude 内置工具（bash、computer 等）在 Gemini 中没有对应实现
+		if toolType != "" && toolType != "custom" {
+			continue
+		}
+		function := dto.FunctionRequest{
+			Name:        common.Interface2String(tool["name"]),
+			Description: common.Interface2String(tool["description"]),
+		}
+		if schema, ok := tool["input_schema"].(map[string]any); ok {
+			if properties, ok := schema["properties"].(map[string]any); ok && len(properties) > 0 {
+				function.Parameters = cleanFunctionParameters(schema)
+			}
+		}
+		functions = append(functions, function)
+	}
+
+	geminiTools := geminiRequest.GetTools()
+	if googleSearch {
+		geminiTools = append(geminiTools, dto.GeminiChatTool{
+			GoogleSearch: make(map[string]string),
+		})
+	}
+	if len(functions) > 0 {
+		geminiTools = append(geminiTools, dto.GeminiChatTool{
+			FunctionDeclarations: functions,
+		})
+	}
+	if len(geminiTools) > 0 {
+		geminiRequest.SetTools(geminiTools)
+	}
+
+	if request.ToolChoice != nil {
+		toolChoice, err := common.Any2Type[dto.ClaudeToolChoice](request.ToolChoice)
+		if err == nil {
+			config := &dto.ToolConfig{FunctionCallingConfig: &dto.FunctionCallingConfig{}}
+			switch toolChoice.Type {
+			case "any":
+				config.FunctionCallingConfig.Mode = "ANY"
+			case "tool":
+				config.FunctionCallingConfig.Mode = "ANY"
+				config.FunctionCallingConfig.AllowedFunctionNames = []string{toolChoice.Name}
+			case "none":
+				config.FunctionCallingConfig.Mode = "NONE"
+			default:
+				config.FunctionCallingConfig.Mode = "AUTO"
+			}
+			geminiRequest.ToolConfig = config
+		}
+	}
+	return nil
+}
+
+func convertClaudeContent2GeminiParts(c *gin.Context, blocks []dto.ClaudeMediaMessage, toolNames map[string]string) ([]dto.GeminiPart, error) {
+	parts := make([]dto.GeminiPart, 0, len(blocks))
+	pendingSignature := ""
+	appendPart := func(part dto.GeminiPart) {
+		if pendingSignature != "" && !part.Thought {
+			part.ThoughtSignature = json.RawMessage(strconv.Quote(pendingSignature))
+			pendingSignature = ""
+		}
+		parts = append(parts, part)
+	}
+
+	for _, block := range blocks {
+		switch block.Type {
+		case dto.ContentTypeText:
+			if block.GetText() != "" {
+				appendPart(dto.GeminiPart{Text: block.GetText()})
+			}
+		case "thinking":
+			thinking := ""
+			if block.Thinking != nil {
+				thinking = *block.Thinking
+			}
+			if thinking == "" {
+				if block.Signature != "" {
+					pendingSignature = block.Signature
+				}
+				continue
+			}
+			part := dto.GeminiPart{Text: thinking, Thought: true}
+			if block.Signature != "" {
+				part.ThoughtSignature = json.RawMessage(strconv.Quote(block.Signature))
+			}
+			appendPart(part)
+		case "redacted_thinking":
+			// Claude 加密思考内容对 Gemini 无意义
+			continue
+		case "image", "document":
+			if block.Source == nil {
+				continue
+			}
+			part, err := claudeSource2GeminiPart(c, block.Source)
+			if err != nil {
+				return nil, err
+			}
+			appendPart(*part)
+		case "tool_use":
+			args := block.Input
+			if args == nil {
+				args = map[string]any{}
+			}
+			toolNames[block.Id] = block.Name
+			appendPart(dto.GeminiPart{
+				FunctionCall: &dto.FunctionCall{
+					FunctionName: block.Name,
+					Arguments:    args,
+				},
+			})
+		case "tool_result":
+			resultParts, err := claudeToolResult2GeminiParts(c, block, toolNames[block.ToolUseId])
+			if err != nil {
+				return nil, err
+			}
+			for _, part := range resultParts {
+				appendPart(part)
+			}
+		}
+	}
+
+	// 签名后没有其他片段时，放回最后一个未携带签名的片段
+	if pendingSignature != "" {
+		for i := len(parts) - 1; i >= 0; i-- {
+			if !parts[i].Thought && len(parts[i].ThoughtSignature) == 0 {
+				parts[i].ThoughtSignature = json.RawMessage(strconv.Quote(pendingSignature))
+				break
+			}
+		}
+	}
+	return parts, nil
+}
+
+func claudeToolResult2GeminiParts(c *gin.Context, block dto.ClaudeMediaMessage, name string) ([]dto.GeminiPart, error) {
+	var texts []string
+	var mediaParts []dto.GeminiPart
+	if block.IsStringContent() {
+		texts = append(texts, block.GetStringContent())
+	} else if block.Content != nil {
+		for _, item := range block.ParseMediaContent() {
+			switch item.Type {
+			case dto.ContentTypeText:
+				texts = append(texts, item.GetText())
+			case "image", "document":
+				if item.Source == nil {
+					continue
+				}
+				part, err := claudeSource2GeminiPart(c, item.Source)
+				if err != nil {
+					return nil, err
+				}
+				mediaParts = append(mediaParts, *part)
+			}
+		}
+	}
+
+	key := "output"
+	if block.IsError {
+		key = "error"
+	}
+	parts := []dto.GeminiPart{{
+		FunctionResponse: &dto.GeminiFunctionResponse{
+			Name:     name,
+			Response: map[string]interface{}{key: strings.Join(texts, "\n")},
+		},
+	}}
+	return append(parts, mediaParts...), nil
+}
+
+func claudeSource2GeminiPart(c *gin.Context, source *dto.ClaudeMessageSource) (*dto.GeminiPart, error) {
+	var fileSource *types.FileSource
+	switch source.Type {
+	case "text":
+		return &dto.GeminiPart{Text: common.Interface2String(source.Data)}, nil
+	case "url":
+		fileSource = types.NewURLFileSource(source.Url)
+	case "base64":
+		fileSource = types.NewBase64FileSource(common.Interface2String(source.Data), source.MediaType)
+	default:
+		return nil, fmt.Errorf("unsupported claude source type for Gemini: '%s'", source.Type)
+	}
+	base64Data, mimeType, err := service.GetBase64Data(c, fileSource, "formatting file for Gemini")
+	if err != nil {
+		return nil, fmt.Errorf("get file data from '%s' failed: %w", fileSource.GetIdentifier(), err)
+	}
+	if _, ok := geminiSupportedMimeTypes[strings.ToLower(mimeType)]; !ok {
+		return nil, fmt.Errorf("mime type is not supported by Gemini: '%s', url: '%s', supported types are: %v", mimeType, fileSource.GetIdentifier(), getSupportedMimeTypesList())
+	}
+	return &dto.GeminiPart{
+		InlineData: &dto.GeminiInlineData{
+			MimeType: mimeType,
+			Data:     base64Data,
+		},
+	}, nil
+}
+
+// attachFunctionCallBypassSignature 历史中的函数调用没有签名时（如来自其他模型），填充跳过校验的签名
+func attachFunctionCallBypassSignature(parts []dto.GeminiPart) {
+	for i := range parts {
+		if len(parts[i].ThoughtSignature) > 0 {
+			return
+		}
+		if hasFunctionCallContent(parts[i].FunctionCall) {
+			parts[i].ThoughtSignature = json.RawMessage(strconv.Quote(thoughtSignatureBypassValue))
+			return
+		}
+	}
+}
+
+func thoughtSignatureString(signature json.RawMessage) string {
+	if len(signature) == 0 {
+		return ""
+	}
+	var s string
+	if err := common.Unmarshal(signature, &s); err != nil {
+		return ""
+	}
+	return s
+}
+
+func claudeToolUseID(call *dto.FunctionCall) string {
+	if call.ID != "" {
+		return call.ID
+	}
+	return "toolu_" + common.GetUUID()
+}
+
+func claudeToolUseInput(call *dto.FunctionCall) any {
+	if call.Arguments == nil {
+		return map[string]any{}
+	}
+	return call.Arguments
+}
+
+// claudeImageBlock Gemini 生成的图片转为 Claude 图片块，非图片媒体返回 nil
+func claudeImageBlock(data *dto.GeminiInlineData) *dto.ClaudeMediaMessage {
+	if !strings.HasPrefix(data.MimeType, "image/") {
+		return nil
+	}
+	return &dto.ClaudeMediaMessage{
+		Type: "image",
+		Source: &dto.ClaudeMessageSource{
+			Type:      "base64",
+			MediaType: data.MimeType,
+			Data:      data.Data,
+		},
+	}
+}
+
+func claudeStopReason(finishReason string, hasToolUse bool) string {
+	if hasToolUse {
+		return "tool_use"
+	}
+	return reasonmap.GeminiFinishReasonToClaudeStopReason(finishReason)
+}
+
+// geminiUsage2Claude 将用量转为 Claude 语义：input_tokens 不含缓存命中部分，与 Claude 计费保持一致
+func geminiUsage2Claude(usage *dto.Usage) *dto.ClaudeUsage {
+	cachedTokens := usage.PromptTokensDetails.CachedTokens
+	if cachedTokens > usage.PromptTokens {
+		cachedTokens = usage.PromptTokens
+	}
+	usage.PromptTokens -= cachedTokens
+	usage.PromptTokensDetails.CachedTokens = cachedTokens
+	return &dto.ClaudeUsage{
+		InputTokens:          usage.PromptTokens,
+		CacheReadInputTokens: cachedTokens,
+		OutputTokens:         usage.CompletionTokens,
+	}
+}
+
+func ResponseGemini2Claude(geminiResponse *dto.GeminiChatResponse, id string, model string) *dto.ClaudeResponse {
+	claudeResponse := &dto.ClaudeResponse{
+		Id:      id,
+		Type:    "message",
+		Role:    "assistant",
+		Model:   model,
+		Content: make([]dto.ClaudeMediaMessage, 0),
+	}
+	if len(geminiResponse.Candidates) == 0 {
+		claudeResponse.StopReason = claudeStopReason("", false)
+		return claudeResponse
+	}
+	candidate := geminiResponse.Candidates[0]
+
+	hasToolUse := false
+	for i := range candidate.Content.Parts {
+		part := &candidate.Content.Parts[i]
+		signature := thoughtSignatureString(part.ThoughtSignature)
+		if part.Thought {
+			claudeResponse.Content = append(claudeResponse.Content, dto.ClaudeMediaMessage{
+				Type:      "thinking",
+				Thinking:  common.GetPointer(part.Text),
+				Signature: signature,
+			})
+			continue
+		}
+		if signature != "" {
+			claudeResponse.Content = append(claudeResponse.Content, dto.ClaudeMediaMessage{
+				Type:      "thinking",
+				Thinking:  common.GetPointer(""),
+				Signature: signature,
+			})
+		}
+		if part.FunctionCall != nil {
+			hasToolUse = true
+			claudeResponse.Content = append(claudeResponse.Content, dto.ClaudeMediaMessage{
+				Type:  "tool_use",
+				Id:    claudeToolUseID(part.FunctionCall),
+				Name:  part.FunctionCall.FunctionName,
+				Input: claudeToolUseInput(part.FunctionCall),
+			})
+			continue
+		}
+		if part.InlineData != nil {
+			if block := claudeImageBlock(part.InlineData); block != nil {
+				claudeResponse.Content = append(claudeResponse.Content, *block)
+				continue
+			}
+		}
+		text := geminiPartText(part)
+		if text == "" {
+			continue
+		}
+		if last := len(claudeResponse.Content) - 1; last >= 0 && claudeResponse.Content[last].Type == dto.ContentTypeText {
+			claudeResponse.Content[last].SetText(claudeResponse.Content[last].GetText() + text)
+			continue
+		}
+		block := dto.ClaudeMediaMessage{Type: dto.ContentTypeText}
+		block.SetText(text)
+		claudeResponse.Content = append(claudeResponse.Content, block)
+	}
+
+	finishReason := ""
+	if candidate.FinishReason != nil {
+		finishReason = *candidate.FinishReason
+	}
+	claudeResponse.StopReason = claudeStopReason(finishReason, hasToolUse)
+	return claudeResponse
+}
+
+func GeminiClaudeHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	if common.DebugEnabled {
+		println(string(responseBody))
+	}
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if len(geminiResponse.Candidates) == 0 {
+		usage := geminiEmptyCandidatesHandler(c, info, &geminiResponse)
+		geminiUsage2Claude(usage)
+		return usage, nil
+	}
+
+	usage := &dto.Usage{
+		PromptTokens: geminiResponse.UsageMetadata.PromptTokenCount,
+		TotalTokens:  geminiResponse.UsageMetadata.TotalTokenCount,
+	}
+	usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
+	usage.CompletionTokenDetails.ReasoningTokens = geminiResponse.UsageMetadata.ThoughtsTokenCount
+	usage.PromptTokensDetails.CachedTokens = geminiResponse.UsageMetadata.CachedContentTokenCount
+
+	claudeResponse := ResponseGemini2Claude(&geminiResponse, "msg_"+common.GetUUID(), info.UpstreamModelName)
+	claudeResponse.Usage = geminiUsage2Claude(usage)
+	responseBody, err = common.Marshal(claudeResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, responseBody)
+	return usage, nil
+}
+
+// geminiClaudeStreamWriter 按 Claude SSE 的内容块状态机输出 Gemini 流式片段
+type geminiClaudeStreamWriter struct {
+	c          *gin.Context
+	started    bool
+	index      int
+	openBlock  string
+	hasToolUse bool
+}
+
+func (w *geminiClaudeStreamWriter) send(resp dto.ClaudeResponse) {
+	_ = helper.ClaudeData(w.c, resp)
+}
+
+func (w *geminiClaudeStreamWriter) start(id string, model string, inputTokens int) {
+	if w.started {
+		return
+	}
+	w.started = true
+	message := &dto.ClaudeMediaMessage{
+		Id:    id,
+		Type:  "message",
+		Role:  "assistant",
+		Model: model,
+		Usage: &dto.ClaudeUsage{InputTokens: inputTokens},
+	}
+	message.SetContent(make([]any, 0))
+	w.send(dto.ClaudeResponse{Type: "message_start", Message: message})
+}
+
+func (w *geminiClaudeStreamWriter) startBlock(block dto.ClaudeMediaMessage) {
+	w.closeBlock()
+	w.send(dto.ClaudeResponse{Type: "content_block_start", Index: common.GetPointer(w.index), ContentBlock: &block})
+	w.openBlock = block.Type
+}
+
+func (w *geminiClaudeStreamWriter) delta(delta dto.ClaudeMediaMessage) {
+	w.send(dto.ClaudeResponse{Type: "content_block_delta", Index: common.GetPointer(w.index), Delta: &delta})
+}
+
+func (w *geminiClaudeStreamWriter) closeBlock() {
+	if w.openBlock == "" {
+		return
+	}
+	w.send(dto.ClaudeResponse{Type: "content_block_stop", Index: common.GetPointer(w.index)})
+	w.index++
+	w.openBlock = ""
+}
+
+func (w *geminiClaudeStreamWriter) writeSignature(signature string) {
+	w.delta(dto.ClaudeMediaMessage{Type: "signature_delta", Signature: signature})
+	w.closeBlock()
+}
+
+func (w *geminiClaudeStreamWriter) writePart(part *dto.GeminiPart) {
+	signature := thoughtSignatureString(part.ThoughtSignature)
+	if part.Thought {
+		if w.openBlock != "thinking" {
+			w.startBlock(dto.ClaudeMediaMessage{Type: "thinking", Thinking: common.GetPointer("")})
+		}
+		if part.Text != "" {
+			w.delta(dto.ClaudeMediaMessage{Type: "thinking_delta", Thinking: common.GetPointer(part.Text)})
+		}
+		if signature != "" {
+			w.writeSignature(signature)
+		}
+		return
+	}
+	if signature != "" {
+		w.startBlock(dto.ClaudeMediaMessage{Type: "thinking", Thinking: common.GetPointer("")})
+		w.writeSignature(signature)
+	}
+
+	if part.FunctionCall != nil {
+		// Gemini 每个片段都包含完整的函数调用
+		w.hasToolUse = true
+		arguments, _ := common.Marshal(claudeToolUseInput(part.FunctionCall))
+		w.startBlock(dto.ClaudeMediaMessage{
+			Type:  "tool_use",
+			Id:    claudeToolUseID(part.FunctionCall),
+			Name:  part.FunctionCall.FunctionName,
+			Input: map[string]any{},
+		})
+		w.delta(dto.ClaudeMediaMessage{Type: "input_json_delta", PartialJson: common.GetPointer(string(arguments))})
+		w.closeBlock()
+		return
+	}
+	if part.InlineData != nil {
+		if block := claudeImageBlock(part.InlineData); block != nil {
+			w.startBlock(*block)
+			w.closeBlock()
+			return
+		}
+	}
+	text := geminiPartText(part)
+	if text == "" {
+		return
+	}
+	if w.openBlock != dto.ContentTypeText {
+		block := dto.ClaudeMediaMessage{Type: dto.ContentTypeText}
+		block.SetText("")
+		w.startBlock(block)
+	}
+	w.delta(dto.ClaudeMediaMessage{Type: "text_delta", Text: common.GetPointer(text)})
+}
+
+func GeminiClaudeStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	writer := &geminiClaudeStreamWriter{c: c}
+	id := "msg_" + common.GetUUID()
+	finishReason := ""
+
+	usage, err := geminiStreamHandler(c, info, resp, func(data string, geminiResponse *dto.GeminiChatResponse) bool {
+		inputTokens := info.GetEstimatePromptTokens()
+		if metadata := geminiResponse.UsageMetadata; metadata.PromptTokenCount > 0 {
+			inputTokens = metadata.PromptTokenCount - metadata.CachedContentTokenCount
+		}
+		writer.start(id, info.UpstreamModelName, inputTokens)
+		if len(geminiResponse.Candidates) == 0 {
+			return true
+		}
+		candidate := geminiResponse.Candidates[0]
+		for i := range candidate.Content.Parts {
+			writer.writePart(&candidate.Content.Parts[i])
+		}
+		if candidate.FinishReason != nil {
+			finishReason = *candidate.FinishReason
+		}
+		return true
+	})
+	if err != nil {
+		return usage, err
+	}
+
+	// 上游没有返回任何数据时也输出完整的消息结构
+	writer.start(id, info.UpstreamModelName, info.GetEstimatePromptTokens())
+	writer.closeBlock()
+	writer.send(dto.ClaudeResponse{
+		Type:  "message_delta",
+		Usage: geminiUsage2Claude(usage),
+		Delta: &dto.ClaudeMediaMessage{
+			StopReason: common.GetPointer(claudeStopReason(finishReason, writer.hasToolUse)),
+		},
+	})
+	writer.send(dto.ClaudeResponse{Type: "message_stop"})
+	return usage, nil
+}
@@ -0,0 +1,222 @@
+package gemini
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestClaude2GeminiToolsThinkingAndSystem(t *testing.T) {
+	var request dto.ClaudeRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "claude-sonnet-4",
+		"max_tokens": 4096,
+		"system": [{"type": "text", "text": "be brief"}, {"type": "text", "text": "answer in French"}],
+		"thinking": {"type": "enabled", "budget_tokens": 2048},
+		"tools": [
+			{"name": "get_weather", "description": "weather", "input_schema": {"type": "object", "properties": {"city": {"type": "string"}}}},
+			{"type": "web_search_20250305", "name": "web_search"},
+			{"type": "bash_20250124", "name": "bash"}
+		],
+		"tool_choice": {"type": "tool", "name": "get_weather"},
+		"messages": [
+			{"role": "user", "content": "weather in Paris?"},
+			{"role": "assistant", "content": [
+				{"type": "thinking", "thinking": "need the tool", "signature": "SIG1"},
+				{"type": "thinking", "thinking": "", "signature": "SIG2"},
+				{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}
+			]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "is_error": true, "content": [{"type": "text", "text": "boom"}]}
+			]}
+		]
+	}`, &request))
+
+	geminiRequest, err := RequestClaude2Gemini(nil, newResponsesTestInfo(), &request)
+	require.NoError(t, err)
+
+	// 多段 system 合并为 systemInstruction
+	require.Equal(t, "be brief\nanswer in French", geminiRequest.SystemInstructions.Parts[0].Text)
+
+	// 思考预算按模型上限裁剪并包含思考内容
+	require.True(t, geminiRequest.GenerationConfig.ThinkingConfig.IncludeThoughts)
+	require.Equal(t, 2048, *geminiRequest.GenerationConfig.ThinkingConfig.ThinkingBudget)
+
+	// web_search 转为 googleSearch，其他内置工具被忽略
+	tools := geminiRequest.GetTools()
+	require.Len(t, tools, 2)
+	require.NotNil(t, tools[0].GoogleSearch)
+	functions, err := common.Marshal(tools[1].FunctionDeclarations)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"name":"get_weather","description":"weather","parameters":{"type":"OBJECT","properties":{"city":{"type":"STRING"}}}}]`, string(functions))
+	require.Equal(t, dto.FunctionCallingConfigMode("ANY"), geminiRequest.ToolConfig.FunctionCallingConfig.Mode)
+	require.Equal(t, []string{"get_weather"}, geminiRequest.ToolConfig.FunctionCallingConfig.AllowedFunctionNames)
+
+	require.Len(t, geminiRequest.Contents, 3)
+	model := geminiRequest.Contents[1]
+	require.Equal(t, "model", model.Role)
+	require.Len(t, model.Parts, 2)
+	require.True(t, model.Parts[0].Thought)
+	require.Equal(t, "need the tool", model.Parts[0].Text)
+	require.Equal(t, "SIG1", thoughtSignatureString(model.Parts[0].ThoughtSignature))
+	// 空的 thinking 块的签名放到后一个函数调用片段上
+	require.Equal(t, "get_weather", model.Parts[1].FunctionCall.FunctionName)
+	require.Equal(t, "SIG2", thoughtSignatureString(model.Parts[1].ThoughtSignature))
+
+	// tool_result 按 tool_use_id 找回函数名，错误结果放在 error 字段
+	result := geminiRequest.Contents[2].Parts[0].FunctionResponse
+	require.Equal(t, "get_weather", result.Name)
+	require.Equal(t, map[string]interface{}{"error": "boom"}, result.Response)
+}
+
+func TestRequestClaude2GeminiStringSystemAndDisabledThinking(t *testing.T) {
+	var request dto.ClaudeRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "claude-sonnet-4",
+		"max_tokens": 1024,
+		"system": "you are helpful",
+		"thinking": {"type": "disabled"},
+		"messages": [{"role": "user", "content": "hi"}]
+	}`, &request))
+	geminiRequest, err := RequestClaude2Gemini(nil, newResponsesTestInfo(), &request)
+	require.NoError(t, err)
+	require.Equal(t, "you are helpful", geminiRequest.SystemInstructions.Parts[0].Text)
+	require.Equal(t, 0, *geminiRequest.GenerationConfig.ThinkingConfig.ThinkingBudget)
+	require.Len(t, geminiRequest.Contents, 1)
+	require.Equal(t, "hi", geminiRequest.Contents[0].Parts[0].Text)
+}
+
+func TestResponseGemini2ClaudeThinkingAndToolUse(t *testing.T) {
+	finishReason := "STOP"
+	geminiResponse := &dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{{
+			FinishReason: &finishReason,
+			Content: dto.GeminiChatContent{Role: "model", Parts: []dto.GeminiPart{
+				{Text: "let me check", Thought: true, ThoughtSignature: json.RawMessage(`"SIG1"`)},
+				{Text: "Checking"},
+				{Text: " now"},
+				{FunctionCall: &dto.FunctionCall{FunctionName: "get_weather", Arguments: map[string]any{"city": "Paris"}}, ThoughtSignature: json.RawMessage(`"SIG2"`)},
+			}},
+		}},
+	}
+	claudeResponse := ResponseGemini2Claude(geminiResponse, "msg_1", "gemini-2.5-flash")
+	require.Equal(t, "tool_use", claudeResponse.StopReason)
+	require.Len(t, claudeResponse.Content, 4)
+
+	require.Equal(t, "thinking", claudeResponse.Content[0].Type)
+	require.Equal(t, "let me check", *claudeResponse.Content[0].Thinking)
+	require.Equal(t, "SIG1", claudeResponse.Content[0].Signature)
+	// 相邻文本合并为一个块
+	require.Equal(t, dto.ContentTypeText, claudeResponse.Content[1].Type)
+	require.Equal(t, "Checking now", claudeResponse.Content[1].GetText())
+	// 函数调用上的签名转为空的 thinking 块
+	require.Equal(t, "thinking", claudeResponse.Content[2].Type)
+	require.Empty(t, *claudeResponse.Content[2].Thinking)
+	require.Equal(t, "SIG2", claudeResponse.Content[2].Signature)
+	require.Equal(t, "tool_use", claudeResponse.Content[3].Type)
+	require.Equal(t, "get_weather", claudeResponse.Content[3].Name)
+	require.True(t, strings.HasPrefix(claudeResponse.Content[3].Id, "toolu_"))
+
+	// 再转换回 Gemini 请求时签名回到原来的片段上
+	assistant := dto.ClaudeMessage{Role: "assistant"}
+	assistant.SetContent(claudeResponse.Content)
+	request := &dto.ClaudeRequest{Model: "claude-sonnet-4", MaxTokens: 1024, Messages: []dto.ClaudeMessage{assistant}}
+	geminiRequest, err := RequestClaude2Gemini(nil, newResponsesTestInfo(), request)
+	require.NoError(t, err)
+	parts := geminiRequest.Contents[0].Parts
+	require.Len(t, parts, 3)
+	require.Equal(t, "SIG1", thoughtSignatureString(parts[0].ThoughtSignature))
+	require.Empty(t, parts[1].ThoughtSignature)
+	require.Equal(t, "get_weather", parts[2].FunctionCall.FunctionName)
+	require.Equal(t, "SIG2", thoughtSignatureString(parts[2].ThoughtSignature))
+
+	maxTokens := "MAX_TOKENS"
+	claudeResponse = ResponseGemini2Claude(&dto.GeminiChatResponse{Candidates: []dto.GeminiChatCandidate{{
+		FinishReason: &maxTokens,
+		Content:      dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: "cut"}}},
+	}}}, "msg_2", "gemini-2.5-flash")
+	require.Equal(t, "max_tokens", claudeResponse.StopReason)
+}
+
+func TestGeminiClaudeStreamHandler(t *testing.T) {
+	savedTimeout := constant.StreamingTimeout
+	constant.StreamingTimeout = 30
+	t.Cleanup(func() { constant.StreamingTimeout = savedTimeout })
+
+	chunks := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"plan","thought":true,"thoughtSignature":"SIG"}]}}],"usageMetadata":{"promptTokenCount":7,"cachedContentTokenCount":2}}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hel"}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"lo"},{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":7,"cachedContentTokenCount":2,"candidatesTokenCount":4,"totalTokenCount":11}}`,
+	}
+	var body strings.Builder
+	for _, chunk := range chunks {
+		body.WriteString("data: " + chunk + "\n\n")
+	}
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body.String()))}
+
+	info := newResponsesTestInfo()
+	info.IsStream = true
+	_, apiErr := GeminiClaudeStreamHandler(c, info, resp)
+	require.Nil(t, apiErr)
+
+	var events []dto.ClaudeResponse
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var event dto.ClaudeResponse
+		require.NoError(t, common.UnmarshalJsonStr(data, &event))
+		events = append(events, event)
+	}
+	var eventTypes []string
+	for _, event := range events {
+		eventType := event.Type
+		if event.Delta != nil && event.Delta.Type != "" {
+			eventType += ":" + event.Delta.Type
+		}
+		if event.ContentBlock != nil {
+			eventType += ":" + event.ContentBlock.Type
+		}
+		eventTypes = append(eventTypes, eventType)
+	}
+	require.Equal(t, []string{
+		"message_start",
+		"content_block_start:thinking",
+		"content_block_delta:thinking_delta",
+		"content_block_delta:signature_delta",
+		"content_block_stop",
+		"content_block_start:text",
+		"content_block_delta:text_delta",
+		"content_block_delta:text_delta",
+		"content_block_stop",
+		"content_block_start:tool_use",
+		"content_block_delta:input_json_delta",
+		"content_block_stop",
+		"message_delta",
+		"message_stop",
+	}, eventTypes)
+
+	// input_tokens 不含缓存命中部分
+	require.Equal(t, 5, events[0].Message.Usage.InputTokens)
+	require.Equal(t, "SIG", events[3].Delta.Signature)
+	require.JSONEq(t, `{"city":"Paris"}`, *events[10].Delta.PartialJson)
+	messageDelta := events[12]
+	require.Equal(t, "tool_use", *messageDelta.Delta.StopReason)
+	require.Equal(t, 5, messageDelta.Usage.InputTokens)
+	require.Equal(t, 2, messageDelta.Usage.CacheReadInputTokens)
+	require.Equal(t, 4, messageDelta.Usage.OutputTokens)
+}
@@ -0,0 +1,282 @@
+package gemini
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/service/openaicompat"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestOpenAIResponses2Gemini 复用 Chat 转换逻辑，再补充 Responses 特有的推理强度和内置搜索工具
+func RequestOpenAIResponses2Gemini(c *gin.Context, info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest) (*dto.GeminiChatRequest, error) {
+	if request.PreviousResponseID != "" {
+		return nil, helper.NewPreviousResponseNotFoundError(request.PreviousResponseID)
+	}
+	textRequest, err := openaicompat.ResponsesRequestToChatCompletionsRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Tools) > 0 {
+		var tools []map[string]any
+		if err := common.Unmarshal(request.Tools, &tools); err == nil {
+			for _, tool := range tools {
+				switch common.Interface2String(tool["type"]) {
+				case "web_search", "web_search_preview":
+					// 与 Chat 渠道约定一致，名为 googleSearch 的函数会转为 Gemini 内置搜索
+					textRequest.Tools = append(textRequest.Tools, dto.ToolCallRequest{
+						Type:     "function",
+						Function: dto.FunctionRequest{Name: "googleSearch"},
+					})
+				}
+			}
+		}
+	}
+
+	geminiRequest, err := CovertOpenAI2Gemini(c, *textRequest, info)
+	if err != nil {
+		return nil, err
+	}
+	if effort := textRequest.ReasoningEffort; effort != "" && geminiRequest.GenerationConfig.ThinkingConfig == nil {
+		if effort == "none" {
+			geminiRequest.GenerationConfig.ThinkingConfig = &dto.GeminiThinkingConfig{
+				ThinkingBudget: common.GetPointer(0),
+			}
+		} else {
+			geminiRequest.GenerationConfig.ThinkingConfig = &dto.GeminiThinkingConfig{
+				IncludeThoughts: true,
+				ThinkingBudget:  common.GetPointer(clampThinkingBudgetByEffort(info.UpstreamModelName, effort)),
+			}
+		}
+	}
+	return geminiRequest, nil
+}
+
+func responsesIncompleteReason(finishReason *string) string {
+	if finishReason == nil {
+		return ""
+	}
+	switch *finishReason {
+	case "STOP", "":
+		return ""
+	case "MAX_TOKENS":
+		return "max_output_tokens"
+	default:
+		return "content_filter"
+	}
+}
+
+// geminiPartText 非思考、非函数调用片段的文本表示，与 Chat 转换保持一致
+func geminiPartText(part *dto.GeminiPart) string {
+	if part.InlineData != nil {
+		if strings.HasPrefix(part.InlineData.MimeType, "image") {
+			return "![image](data:" + part.InlineData.MimeType + ";base64," + part.InlineData.Data + ")"
+		}
+		return fmt.Sprintf("[media](data:%s;base64,%s)", part.InlineData.MimeType, part.InlineData.Data)
+	}
+	if part.ExecutableCode != nil {
+		return "```" + part.ExecutableCode.Language + "\n" + part.ExecutableCode.Code + "\n```"
+	}
+	if part.CodeExecutionResult != nil {
+		return "```output\n" + part.CodeExecutionResult.Output + "\n```"
+	}
+	return part.Text
+}
+
+// ResponseGemini2OpenAIResponses 将 Gemini 非流式响应转换为 Responses API 响应，不包含 usage，
+// 思考片段及其 thoughtSignature 转为 reasoning 条目
+func ResponseGemini2OpenAIResponses(geminiResponse *dto.GeminiChatResponse, id string, createdAt int64, model string) (*dto.OpenAIResponsesResponse, error) {
+	response := &dto.OpenAIResponsesResponse{
+		ID:        id,
+		Object:    "response",
+		CreatedAt: int(createdAt),
+		Status:    helper.ResponsesStatusCompleted,
+		Model:     model,
+		Output:    make([]dto.ResponsesOutput, 0),
+	}
+	if len(geminiResponse.Candidates) == 0 {
+		return response, nil
+	}
+	candidate := geminiResponse.Candidates[0]
+
+	var texts []string
+	appendReasoning := func(text string, signature string) {
+		item := dto.ResponsesOutput{
+			Type:             "reasoning",
+			ID:               "rs_" + common.GetUUID(),
+			Status:           helper.ResponsesStatusCompleted,
+			Summary:          []dto.ResponsesReasoningSummaryPart{},
+			EncryptedContent: signature,
+		}
+		if text != "" {
+			item.Summary = append(item.Summary, dto.ResponsesReasoningSummaryPart{Type: "summary_text", Text: text})
+		}
+		response.Output = append(response.Output, item)
+	}
+	flushText := func() {
+		if len(texts) == 0 {
+			return
+		}
+		response.Output = append(response.Output, dto.ResponsesOutput{
+			Type:   "message",
+			ID:     "msg_" + common.GetUUID(),
+			Status: helper.ResponsesStatusCompleted,
+			Role:   "assistant",
+			Content: []dto.ResponsesOutputContent{{
+				Type:        "output_text",
+				Text:        strings.Join(texts, "\n"),
+				Annotations: []interface{}{},
+			}},
+		})
+		texts = nil
+	}
+	for i := range candidate.Content.Parts {
+		part := &candidate.Content.Parts[i]
+		signature := thoughtSignatureString(part.ThoughtSignature)
+		if part.Thought {
+			flushText()
+			appendReasoning(part.Text, signature)
+			continue
+		}
+		if signature != "" {
+			// 非思考片段上的签名单独作为 reasoning 条目，回传时需要原样带回
+			flushText()
+			appendReasoning("", signature)
+		}
+		if part.FunctionCall != nil {
+			flushText()
+			arguments, err := common.Marshal(part.FunctionCall.Arguments)
+			if err != nil {
+				return nil, err
+			}
+			response.Output = append(response.Output, dto.ResponsesOutput{
+				Type:      "function_call",
+				ID:        "fc_" + common.GetUUID(),
+				Status:    helper.ResponsesStatusCompleted,
+				CallId:    fmt.Sprintf("call_%s", common.GetUUID()),
+				Name:      part.FunctionCall.FunctionName,
+				Arguments: string(arguments),
+			})
+			continue
+		}
+		// 过滤掉空行
+		if text := geminiPartText(part); text != "" && text != "\n" {
+			texts = append(texts, text)
+		}
+	}
+	flushText()
+
+	if reason := responsesIncompleteReason(candidate.FinishReason); reason != "" {
+		response.Status = helper.ResponsesStatusIncomplete
+		response.IncompleteDetails = &dto.IncompleteDetails{Reason: reason}
+	}
+	return response, nil
+}
+
+func GeminiResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	if common.DebugEnabled {
+		println(string(responseBody))
+	}
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if len(geminiResponse.Candidates) == 0 {
+		return geminiEmptyCandidatesHandler(c, info, &geminiResponse), nil
+	}
+
+	usage := dto.Usage{
+		PromptTokens: geminiResponse.UsageMetadata.PromptTokenCount,
+		TotalTokens:  geminiResponse.UsageMetadata.TotalTokenCount,
+	}
+	usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
+	usage.CompletionTokenDetails.ReasoningTokens = geminiResponse.UsageMetadata.ThoughtsTokenCount
+	usage.PromptTokensDetails.CachedTokens = geminiResponse.UsageMetadata.CachedContentTokenCount
+	for _, detail := range geminiResponse.UsageMetadata.PromptTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.PromptTokensDetails.AudioTokens = detail.TokenCount
+		} else if detail.Modality == "TEXT" {
+			usage.PromptTokensDetails.TextTokens = detail.TokenCount
+		}
+	}
+
+	responsesResponse, err := ResponseGemini2OpenAIResponses(&geminiResponse, helper.GetResponsesID(c), common.GetTimestamp(), info.UpstreamModelName)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	responsesResponse.Usage = service.BuildResponsesUsage(&usage, usage.PromptTokens)
+	responseBody, err = common.Marshal(responsesResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, responseBody)
+	helper.SetResponsesResult(c, responsesResponse)
+	return &usage, nil
+}
+
+func GeminiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	writer := helper.NewResponsesStreamWriter(c, helper.GetResponsesID(c), common.GetTimestamp(), info.UpstreamModelName)
+	var finishReason *string
+
+	usage, err := geminiStreamHandler(c, info, resp, func(data string, geminiResponse *dto.GeminiChatResponse) bool {
+		writer.Start()
+		if len(geminiResponse.Candidates) == 0 {
+			return true
+		}
+		candidate := geminiResponse.Candidates[0]
+		for i := range candidate.Content.Parts {
+			part := &candidate.Content.Parts[i]
+			signature := thoughtSignatureString(part.ThoughtSignature)
+			if part.Thought {
+				writer.AppendReasoning(part.Text)
+				if signature != "" {
+					writer.SetReasoningEncryptedContent(signature)
+				}
+				continue
+			}
+			if signature != "" {
+				writer.SetReasoningEncryptedContent(signature)
+			}
+			if part.FunctionCall != nil {
+				// Gemini 每个片段都包含完整的函数调用
+				arguments, err := common.Marshal(part.FunctionCall.Arguments)
+				if err != nil {
+					logger.LogError(c, "error marshalling function call arguments: "+err.Error())
+					return false
+				}
+				writer.StartFunctionCall(fmt.Sprintf("call_%s", common.GetUUID()), part.FunctionCall.FunctionName)
+				writer.AppendArguments(string(arguments))
+				writer.CloseItem()
+				continue
+			}
+			if text := geminiPartText(part); text != "" {
+				writer.AppendText(text)
+			}
+		}
+		if candidate.FinishReason != nil {
+			finishReason = candidate.FinishReason
+		}
+		return true
+	})
+	if err != nil {
+		return usage, err
+	}
+
+	writer.Complete(service.BuildResponsesUsage(usage, usage.PromptTokens), responsesIncompleteReason(finishReason))
+	return usage, nil
+}
@@ -0,0 +1,189 @@
+package gemini
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newResponsesTestInfo() *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		ChannelMeta: &relaycommon.ChannelMeta{ChannelType: constant.ChannelTypeGemini, UpstreamModelName: "gemini-2.5-flash"},
+	}
+}
+
+func TestRequestOpenAIResponses2GeminiWebSearch(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	request := &dto.OpenAIResponsesRequest{
+		Model: "gemini-2.5-flash",
+		Input: json.RawMessage(`"what's new today?"`),
+		Tools: json.RawMessage(`[{"type":"web_search_preview"}]`),
+	}
+	geminiRequest, err := RequestOpenAIResponses2Gemini(c, newResponsesTestInfo(), request)
+	require.NoError(t, err)
+
+	var tools []map[string]any
+	require.NoError(t, common.Unmarshal(geminiRequest.Tools, &tools))
+	require.Len(t, tools, 1)
+	require.Contains(t, tools[0], "googleSearch")
+	require.NotContains(t, tools[0], "functionDeclarations")
+}
+
+func TestRequestOpenAIResponses2GeminiReasoningEffort(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	request := &dto.OpenAIResponsesRequest{
+		Model:     "gemini-2.5-flash",
+		Input:     json.RawMessage(`"hi"`),
+		Reasoning: &dto.Reasoning{Effort: "low"},
+	}
+	geminiRequest, err := RequestOpenAIResponses2Gemini(c, newResponsesTestInfo(), request)
+	require.NoError(t, err)
+	thinkingConfig := geminiRequest.GenerationConfig.ThinkingConfig
+	require.NotNil(t, thinkingConfig)
+	require.True(t, thinkingConfig.IncludeThoughts)
+	require.Equal(t, clampThinkingBudgetByEffort("gemini-2.5-flash", "low"), *thinkingConfig.ThinkingBudget)
+
+	// none 关闭思考
+	request.Reasoning = &dto.Reasoning{Effort: "none"}
+	geminiRequest, err = RequestOpenAIResponses2Gemini(c, newResponsesTestInfo(), request)
+	require.NoError(t, err)
+	require.Equal(t, 0, *geminiRequest.GenerationConfig.ThinkingConfig.ThinkingBudget)
+	require.False(t, geminiRequest.GenerationConfig.ThinkingConfig.IncludeThoughts)
+}
+
+func TestRequestOpenAIResponses2GeminiRejectsPreviousResponse(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	_, err := RequestOpenAIResponses2Gemini(c, newResponsesTestInfo(), &dto.OpenAIResponsesRequest{Model: "gemini-2.5-flash", PreviousResponseID: "resp_1"})
+	require.Error(t, err)
+}
+
+func TestResponseGemini2OpenAIResponsesReasoning(t *testing.T) {
+	finishReason := "STOP"
+	geminiResponse := &dto.GeminiChatResponse{
+		Candidates: []dto.GeminiChatCandidate{{
+			FinishReason: &finishReason,
+			Content: dto.GeminiChatContent{Role: "model", Parts: []dto.GeminiPart{
+				{Text: "thinking about it", Thought: true, ThoughtSignature: json.RawMessage(`"SIG1"`)},
+				{Text: "hello"},
+				{FunctionCall: &dto.FunctionCall{FunctionName: "get_weather", Arguments: map[string]any{"city": "Paris"}}, ThoughtSignature: json.RawMessage(`"SIG2"`)},
+			}},
+		}},
+	}
+	response, err := ResponseGemini2OpenAIResponses(geminiResponse, "resp_1", 100, "gemini-2.5-flash")
+	require.NoError(t, err)
+	require.Equal(t, helper.ResponsesStatusCompleted, response.Status)
+	require.Nil(t, response.IncompleteDetails)
+	require.Len(t, response.Output, 4)
+
+	// 思考片段转为带签名的 reasoning 条目
+	require.Equal(t, "reasoning", response.Output[0].Type)
+	require.Equal(t, "SIG1", response.Output[0].EncryptedContent)
+	require.Equal(t, "thinking about it", response.Output[0].Summary[0].Text)
+	require.Equal(t, "message", response.Output[1].Type)
+	require.Equal(t, "hello", response.Output[1].Content[0].Text)
+	// 函数调用上的签名单独作为 reasoning 条目放在调用之前
+	require.Equal(t, "reasoning", response.Output[2].Type)
+	require.Equal(t, "SIG2", response.Output[2].EncryptedContent)
+	require.Empty(t, response.Output[2].Summary)
+	require.Equal(t, "function_call", response.Output[3].Type)
+	require.Equal(t, "get_weather", response.Output[3].Name)
+	require.JSONEq(t, `{"city":"Paris"}`, response.Output[3].Arguments)
+}
+
+func TestResponsesIncompleteReason(t *testing.T) {
+	for finishReason, want := range map[string]string{
+		"STOP":       "",
+		"":           "",
+		"MAX_TOKENS": "max_output_tokens",
+		"SAFETY":     "content_filter",
+		"RECITATION": "content_filter",
+	} {
+		require.Equal(t, want, responsesIncompleteReason(&finishReason), finishReason)
+	}
+	require.Empty(t, responsesIncompleteReason(nil))
+
+	finishReason := "MAX_TOKENS"
+	response, err := ResponseGemini2OpenAIResponses(&dto.GeminiChatResponse{Candidates: []dto.GeminiChatCandidate{{
+		FinishReason: &finishReason,
+		Content:      dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: "cut"}}},
+	}}}, "resp_1", 100, "gemini-2.5-flash")
+	require.NoError(t, err)
+	require.Equal(t, helper.ResponsesStatusIncomplete, response.Status)
+	require.Equal(t, "max_output_tokens", response.IncompleteDetails.Reason)
+}
+
+func TestGeminiResponsesStreamHandlerEventOrder(t *testing.T) {
+	savedTimeout := constant.StreamingTimeout
+	constant.StreamingTimeout = 30
+	t.Cleanup(func() { constant.StreamingTimeout = savedTimeout })
+
+	chunks := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"plan","thought":true}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"","thoughtSignature":"SIG"},{"text":"Hel"}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"lo"}]},"finishReason":"MAX_TOKENS"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":2,"totalTokenCount":5}}`,
+	}
+	var body strings.Builder
+	for _, chunk := range chunks {
+		body.WriteString("data: " + chunk + "\n\n")
+	}
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body.String()))}
+
+	info := newResponsesTestInfo()
+	info.IsStream = true
+	usage, apiErr := GeminiResponsesStreamHandler(c, info, resp)
+	require.Nil(t, apiErr)
+	require.Equal(t, 3, usage.PromptTokens)
+
+	var eventTypes []string
+	var completed dto.ResponsesStreamResponse
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var event dto.ResponsesStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(data, &event))
+		eventTypes = append(eventTypes, event.Type)
+		completed = event
+	}
+	require.Equal(t, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added",
+		"response.reasoning_summary_part.added",
+		"response.reasoning_summary_text.delta",
+		"response.reasoning_summary_text.done",
+		"response.reasoning_summary_part.done",
+		"response.output_item.done",
+		"response.output_item.added",
+		"response.content_part.added",
+		"response.output_text.delta",
+		"response.output_text.delta",
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.incomplete",
+	}, eventTypes)
+
+	// 思考签名写入 reasoning 条目，截断原因映射为 max_output_tokens
+	require.Equal(t, helper.ResponsesStatusIncomplete, completed.Response.Status)
+	require.Equal(t, "max_output_tokens", completed.Response.IncompleteDetails.Reason)
+	require.Equal(t, "reasoning", completed.Response.Output[0].Type)
+	require.Equal(t, "SIG", completed.Response.Output[0].EncryptedContent)
+	require.Equal(t, "Hello", completed.Response.Output[1].Content[0].Text)
+}
@@ -499,6 +499,9 @@ func OaiResponsesToChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo
 
 		return true
 	})
+	if timeoutErr := info.FirstTokenTimeoutError(); timeoutErr != nil {
+		return nil, timeoutErr
+	}
 
 	if streamErr != nil {
 		return nil, streamErr
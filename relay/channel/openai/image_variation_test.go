@@ -0,0 +1,50 @@
+package openai
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertImageVariationRequest(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("model", "dall-e-2"))
+	require.NoError(t, writer.WriteField("n", "2"))
+	part, err := writer.CreateFormFile("image", "cat.png")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("\x89PNG\r\n\x1a\nimage"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/variations", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	adaptor := &Adaptor{}
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeImagesVariations}
+	converted, err := adaptor.ConvertImageRequest(c, info, dto.ImageRequest{Model: "gpt-image-variation", N: 2})
+	require.NoError(t, err)
+
+	// 图片变体原样透传表单，模型名替换为映射后的模型
+	_, params, err := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	form, err := multipart.NewReader(converted.(io.Reader), params["boundary"]).ReadForm(1 << 20)
+	require.NoError(t, err)
+	require.Equal(t, []string{"gpt-image-variation"}, form.Value["model"])
+	require.Equal(t, []string{"2"}, form.Value["n"])
+	require.Len(t, form.File["image"], 1)
+	require.Equal(t, "cat.png", form.File["image"][0].Filename)
+	require.Equal(t, "image/png", form.File["image"][0].Header.Get("Content-Type"))
+}
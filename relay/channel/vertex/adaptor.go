@@ -53,7 +53,14 @@ type Adaptor struct {
 }
 
 func (a *Adaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (any, error) {
-	// Vertex AI does not support functionResponse.id; keep it stripped here for consistency.
+	if a.RequestMode == RequestModeClaude {
+		claudeRequest, err := claude.RequestGemini2ClaudeMessage(c, info, request)
+		if err != nil {
+			return nil, err
+		}
+		return a.ConvertClaudeRequest(c, info, claudeRequest)
+	}
+	// Vertex AI does not support functionCall.id / functionResponse.id; keep them stripped here for consistency.
 	if model_setting.GetGeminiSettings().RemoveFunctionResponseIdEnabled {
 		removeFunctionResponseID(request)
 	}
@@ -73,6 +80,9 @@ func removeFunctionResponseID(request *dto.GeminiChatRequest) {
 			}
 			for j := range request.Contents[i].Parts {
 				part := &request.Contents[i].Parts[j]
+				if part.FunctionCall != nil {
+					part.FunctionCall.ID = ""
+				}
 				if part.FunctionResponse == nil {
 					continue
 				}
@@ -91,6 +101,17 @@ func removeFunctionResponseID(request *dto.GeminiChatRequest) {
 }
 
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
+	if a.RequestMode == RequestModeGemini {
+		geminiRequest, err := gemini.RequestClaude2Gemini(c, info, request)
+		if err != nil {
+			return nil, err
+		}
+		if model_setting.GetGeminiSettings().RemoveFunctionResponseIdEnabled {
+			removeFunctionResponseID(geminiRequest)
+		}
+		c.Set("request_model", request.Model)
+		return geminiRequest, nil
+	}
 	if v, ok := claudeModelMap[info.UpstreamModelName]; ok {
 		c.Set("request_model", v)
 	} else {
@@ -230,8 +251,14 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		if strings.HasPrefix(info.UpstreamModelName, "imagen") {
 			suffix = "predict"
 		}
+		if info.RelayMode == constant.RelayModeGeminiCountTokens {
+			suffix = "countTokens"
+		}
 		return a.getRequestUrl(info, info.UpstreamModelName, suffix)
 	} else if a.RequestMode == RequestModeClaude {
+		if info.RelayMode == constant.RelayModeClaudeCountTokens {
+			return a.getRequestUrl(info, "count-tokens", "rawPredict")
+		}
 		if info.IsStream {
 			suffix = "streamRawPredict?alt=sse"
 		} else {
@@ -356,8 +383,25 @@ func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.Rela
 }
 
 func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
-	// TODO implement me
-	return nil, errors.New("not implemented")
+	switch a.RequestMode {
+	case RequestModeClaude:
+		claudeReq, err := claude.RequestOpenAIResponses2ClaudeMessage(c, request)
+		if err != nil {
+			return nil, err
+		}
+		vertexClaudeReq := copyRequest(claudeReq, anthropicVersion)
+		c.Set("request_model", claudeReq.Model)
+		info.UpstreamModelName = claudeReq.Model
+		return vertexClaudeReq, nil
+	case RequestModeGemini:
+		geminiRequest, err := gemini.RequestOpenAIResponses2Gemini(c, info, &request)
+		if err != nil {
+			return nil, err
+		}
+		c.Set("request_model", request.Model)
+		return geminiRequest, nil
+	}
+	return nil, errors.New("responses api is not supported for this model")
 }
 
 func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
@@ -373,6 +417,10 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		case RequestModeGemini:
 			if info.RelayMode == constant.RelayModeGemini {
 				return gemini.GeminiTextGenerationStreamHandler(c, info, resp)
+			} else if info.RelayMode == constant.RelayModeResponses {
+				return gemini.GeminiResponsesStreamHandler(c, info, resp)
+			} else if info.RelayFormat == types.RelayFormatClaude {
+				return gemini.GeminiClaudeStreamHandler(c, info, resp)
 			} else {
 				return gemini.GeminiChatStreamHandler(c, info, resp)
 			}
@@ -386,6 +434,10 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		case RequestModeGemini:
 			if info.RelayMode == constant.RelayModeGemini {
 				return gemini.GeminiTextGenerationHandler(c, info, resp)
+			} else if info.RelayMode == constant.RelayModeResponses {
+				return gemini.GeminiResponsesHandler(c, info, resp)
+			} else if info.RelayFormat == types.RelayFormatClaude {
+				return gemini.GeminiClaudeHandler(c, info, resp)
 			} else {
 				if strings.HasPrefix(info.UpstreamModelName, "imagen") {
 					return gemini.GeminiImageHandler(c, info, resp)
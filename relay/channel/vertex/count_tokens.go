@@ -0,0 +1,55 @@
+package vertex
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel/claude"
+	"github.com/QuantumNous/new-api/relay/channel/gemini"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CountClaudeTokens 通过 Vertex 的 count-tokens:rawPredict 计算 Claude 模型的输入 token
+func CountClaudeTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeCountTokensRequest) (int, *types.NewAPIError) {
+	adaptor := &Adaptor{}
+	adaptor.Init(info)
+	if adaptor.RequestMode != RequestModeClaude {
+		return 0, types.NewError(fmt.Errorf("model %s is not a claude model", info.UpstreamModelName), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	// 请求体中的 model 需使用 Vertex 上的模型版本名
+	if v, ok := claudeModelMap[info.UpstreamModelName]; ok {
+		request.Model = v
+	}
+	return claude.CountTokens(adaptor, c, info, request)
+}
+
+// vertexCountTokensRequest Vertex 的 countTokens 不支持 generateContentRequest，需要展开为顶层字段
+type vertexCountTokensRequest struct {
+	Contents          []dto.GeminiChatContent         `json:"contents"`
+	SystemInstruction *dto.GeminiChatContent          `json:"systemInstruction,omitempty"`
+	Tools             json.RawMessage                 `json:"tools,omitempty"`
+	GenerationConfig  *dto.GeminiChatGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// CountGeminiTokens 通过 Vertex 的 countTokens 计算 Gemini 模型的输入 token
+func CountGeminiTokens(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiCountTokensRequest) (*dto.GeminiCountTokensResponse, *types.NewAPIError) {
+	adaptor := &Adaptor{}
+	adaptor.Init(info)
+	if adaptor.RequestMode != RequestModeGemini {
+		return nil, types.NewError(fmt.Errorf("model %s is not a gemini model", info.UpstreamModelName), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	chatRequest := request.ToGeminiChatRequest()
+	body := vertexCountTokensRequest{
+		Contents:          chatRequest.Contents,
+		SystemInstruction: chatRequest.SystemInstructions,
+		Tools:             chatRequest.Tools,
+	}
+	if request.GenerateContentRequest != nil {
+		body.GenerationConfig = &chatRequest.GenerationConfig
+	}
+	return gemini.DoCountTokensRequest(adaptor, c, info, body)
+}
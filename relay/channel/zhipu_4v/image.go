@@ -1,8 +1,12 @@
 package zhipu_4v
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
@@ -17,12 +21,76 @@ import (
 type zhipuImageRequest struct {
 	Model            string `json:"model"`
 	Prompt           string `json:"prompt"`
+	ImageUrl         string `json:"image_url,omitempty"`
 	Quality          string `json:"quality,omitempty"`
 	Size             string `json:"size,omitempty"`
 	WatermarkEnabled *bool  `json:"watermark_enabled,omitempty"`
 	UserID           string `json:"user_id,omitempty"`
 }
 
+// imageVariationPrompt 图片变体（/v1/images/variations）请求的默认提示词
+const imageVariationPrompt = "Generate a variation of this image that keeps its main subject, composition and style."
+
+// oaiVariation2ZhipuImageRequest 将图片变体请求转换为以参考图生成的智谱图片请求，
+// 表单上传的图片转为 data URL，JSON 请求直接使用 image 字段中的地址
+func oaiVariation2ZhipuImageRequest(c *gin.Context, request dto.ImageRequest) (*zhipuImageRequest, error) {
+	if request.N > 1 {
+		return nil, errors.New("zhipu image models only return one image per request")
+	}
+	imageUrl, err := getVariationImageUrl(c, request)
+	if err != nil {
+		return nil, err
+	}
+	zhipuRequest := &zhipuImageRequest{
+		Model:    request.Model,
+		Prompt:   request.Prompt,
+		ImageUrl: imageUrl,
+		Quality:  request.Quality,
+		Size:     request.Size,
+	}
+	if zhipuRequest.Prompt == "" {
+		zhipuRequest.Prompt = imageVariationPrompt
+	}
+	if request.Watermark != nil {
+		zhipuRequest.WatermarkEnabled = request.Watermark
+	}
+	return zhipuRequest, nil
+}
+
+func getVariationImageUrl(c *gin.Context, request dto.ImageRequest) (string, error) {
+	if strings.Contains(c.Request.Header.Get("Content-Type"), "multipart/form-data") {
+		if c.Request.MultipartForm == nil {
+			if _, err := c.MultipartForm(); err != nil {
+				return "", fmt.Errorf("failed to parse image variation form request: %w", err)
+			}
+		}
+		files := c.Request.MultipartForm.File["image"]
+		if len(files) == 0 {
+			return "", errors.New("image is required")
+		}
+		file, err := files[0].Open()
+		if err != nil {
+			return "", errors.New("failed to open image file")
+		}
+		defer file.Close()
+		imageData, err := io.ReadAll(file)
+		if err != nil {
+			return "", errors.New("failed to read image file")
+		}
+		return fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(imageData), base64.StdEncoding.EncodeToString(imageData)), nil
+	}
+	var imageUrl string
+	if len(request.Image) > 0 {
+		if err := common.Unmarshal(request.Image, &imageUrl); err != nil {
+			return "", fmt.Errorf("invalid image: %w", err)
+		}
+	}
+	if imageUrl == "" {
+		return "", errors.New("image is required")
+	}
+	return imageUrl, nil
+}
+
 type zhipuImageResponse struct {
 	Created       *int64            `json:"created,omitempty"`
 	Data          []zhipuImageData  `json:"data,omitempty"`
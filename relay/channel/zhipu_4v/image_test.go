@@ -0,0 +1,65 @@
+package zhipu_4v
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertImageVariationRequest(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", "cat.png")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("\x89PNG\r\n\x1a\nimage"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/variations", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	adaptor := &Adaptor{}
+	info := &relaycommon.RelayInfo{RelayMode: relayconstant.RelayModeImagesVariations, ChannelMeta: &relaycommon.ChannelMeta{ChannelBaseUrl: "https://open.bigmodel.cn"}}
+	converted, err := adaptor.ConvertImageRequest(c, info, dto.ImageRequest{Model: "cogview-4", N: 1, Size: "1024x1024"})
+	require.NoError(t, err)
+
+	zhipuRequest := converted.(*zhipuImageRequest)
+	require.Equal(t, "cogview-4", zhipuRequest.Model)
+	require.Equal(t, imageVariationPrompt, zhipuRequest.Prompt)
+	require.Equal(t, "1024x1024", zhipuRequest.Size)
+	require.True(t, strings.HasPrefix(zhipuRequest.ImageUrl, "data:image/png;base64,"))
+
+	url, err := adaptor.GetRequestURL(info)
+	require.NoError(t, err)
+	require.Equal(t, "https://open.bigmodel.cn/api/paas/v4/images/generations", url)
+
+	// 智谱每次只返回一张图片，按张计费时不能请求多张
+	_, err = adaptor.ConvertImageRequest(c, info, dto.ImageRequest{Model: "cogview-4", N: 2})
+	require.Error(t, err)
+}
+
+func TestConvertImageVariationJSONRequest(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/images/variations", strings.NewReader(`{}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	request := dto.ImageRequest{Model: "cogview-4", Prompt: "in winter", Image: []byte(`"https://example.com/cat.png"`)}
+	zhipuRequest, err := oaiVariation2ZhipuImageRequest(c, request)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/cat.png", zhipuRequest.ImageUrl)
+	require.Equal(t, "in winter", zhipuRequest.Prompt)
+
+	_, err = oaiVariation2ZhipuImageRequest(c, dto.ImageRequest{Model: "cogview-4"})
+	require.Error(t, err)
+}
@@ -0,0 +1,63 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel/aws"
+	"github.com/QuantumNous/new-api/relay/channel/claude"
+	"github.com/QuantumNous/new-api/relay/channel/vertex"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClaudeCountTokensHelper 计算 /v1/messages/count_tokens 的输入 token，不计费。
+// Anthropic、Bedrock 与 Vertex Claude 渠道转发到上游，其余渠道使用本地估算
+func ClaudeCountTokensHelper(c *gin.Context, info *relaycommon.RelayInfo) (int, *types.NewAPIError) {
+	info.InitChannelMeta(c)
+
+	claudeReq, ok := info.Request.(*dto.ClaudeRequest)
+	if !ok {
+		return 0, types.NewErrorWithStatusCode(fmt.Errorf("invalid request type, expected *dto.ClaudeRequest, got %T", info.Request), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	request, err := common.DeepCopy(claudeReq)
+	if err != nil {
+		return 0, types.NewError(fmt.Errorf("failed to copy request to ClaudeRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	err = helper.ModelMappedHelper(c, info, request)
+	if err != nil {
+		return 0, types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
+	}
+
+	switch info.ChannelType {
+	case constant.ChannelTypeAnthropic:
+		return claude.CountTokens(&claude.Adaptor{}, c, info, request.ToCountTokensRequest())
+	case constant.ChannelTypeAws:
+		return aws.CountTokens(c, info, request.ToCountTokensRequest())
+	case constant.ChannelTypeVertexAi:
+		if strings.HasPrefix(info.UpstreamModelName, "claude") {
+			return vertex.CountClaudeTokens(c, info, request.ToCountTokensRequest())
+		}
+	}
+
+	meta := request.GetTokenCountMeta()
+	if constant.CountToken {
+		tokens, err := service.EstimateRequestToken(c, meta, info)
+		if err != nil {
+			return 0, types.NewError(err, types.ErrorCodeCountTokenFailed, types.ErrOptionWithSkipRetry())
+		}
+		return tokens, nil
+	}
+	// 全局关闭 token 统计时仍需返回结果，退化为仅统计文本
+	return service.CountTextToken(meta.CombineText, info.UpstreamModelName), nil
+}
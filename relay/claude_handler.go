@@ -184,6 +184,9 @@ func ClaudeHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 	}
 
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	if timeoutErr := info.FirstTokenTimeoutError(); timeoutErr != nil {
+		return timeoutErr
+	}
 	//log.Printf("usage: %v", usage)
 	if newAPIError != nil {
 		// reset status code 重置状态码
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func TestFirstTokenTimeout(t *testing.T) {
+	setting := operation_setting.GetFirstTokenTimeoutSetting()
+	saved := *setting
+	defer func() { *setting = saved }()
+	setting.DefaultSeconds = 10
+	setting.ModelSeconds = map[string]int{"gpt-4o": 3}
+
+	info := &RelayInfo{OriginModelName: "gpt-4o-mini"}
+	if got := info.FirstTokenTimeout(); got != 10*time.Second {
+		t.Fatalf("expected default timeout, got %s", got)
+	}
+	info.OriginModelName = "gpt-4o"
+	if got := info.FirstTokenTimeout(); got != 3*time.Second {
+		t.Fatalf("expected model timeout, got %s", got)
+	}
+
+	// 渠道设置优先于模型配置
+	info.ChannelMeta = &ChannelMeta{ChannelSetting: dto.ChannelSettings{FirstTokenTimeoutSeconds: 1}}
+	if got := info.FirstTokenTimeout(); got != time.Second {
+		t.Fatalf("expected channel timeout, got %s", got)
+	}
+
+	if info.FirstTokenTimeoutError() != nil {
+		t.Fatal("unexpected timeout error before timing out")
+	}
+	info.FirstTokenTimedOut = true
+	if err := info.FirstTokenTimeoutError(); err == nil || err.GetErrorCode() != types.ErrorCodeFirstTokenTimeout {
+		t.Fatalf("unexpected timeout error: %v", err)
+	}
+}
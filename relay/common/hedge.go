@@ -0,0 +1,63 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// HedgeRace 对冲请求的竞速状态，最先收到上游首字节或最先向下游写出的尝试胜出
+type HedgeRace struct {
+	winner  atomic.Int32
+	mu      sync.Mutex
+	cancels map[int32]context.CancelFunc
+}
+
+// Decided 是否已有尝试胜出
+func (r *HedgeRace) Decided() bool {
+	return r.winner.Load() != 0
+}
+
+// Register 登记尝试的取消函数，决出胜者时取消其他尝试；已决出胜者时直接取消落败的尝试
+func (r *HedgeRace) Register(index int32, cancel context.CancelFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if winner := r.winner.Load(); winner != 0 && winner != index {
+		cancel()
+		return
+	}
+	if r.cancels == nil {
+		r.cancels = make(map[int32]context.CancelFunc)
+	}
+	r.cancels[index] = cancel
+}
+
+func (r *HedgeRace) cancelLosers(winner int32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for index, cancel := range r.cancels {
+		if index != winner {
+			cancel()
+		}
+	}
+}
+
+// HedgeAttempt 对冲请求中的一次尝试，Index 从 1 开始
+type HedgeAttempt struct {
+	Race  *HedgeRace
+	Index int32
+}
+
+// Claim 尝试成为胜出者，已经胜出时也返回 true；胜出时立即取消其他尝试
+func (a *HedgeAttempt) Claim() bool {
+	if a.Race.winner.CompareAndSwap(0, a.Index) {
+		a.Race.cancelLosers(a.Index)
+		return true
+	}
+	return a.Race.winner.Load() == a.Index
+}
+
+// IsWinner 是否为胜出的尝试
+func (a *HedgeAttempt) IsWinner() bool {
+	return a.Race.winner.Load() == a.Index
+}
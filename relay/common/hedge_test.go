@@ -0,0 +1,38 @@
+package common
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestHedgeAttemptClaim(t *testing.T) {
+	race := &HedgeRace{}
+	attempts := []*HedgeAttempt{{Race: race, Index: 1}, {Race: race, Index: 2}}
+
+	var wg sync.WaitGroup
+	wins := make([]bool, len(attempts))
+	for i, attempt := range attempts {
+		wg.Add(1)
+		go func(i int, attempt *HedgeAttempt) {
+			defer wg.Done()
+			wins[i] = attempt.Claim()
+		}(i, attempt)
+	}
+	wg.Wait()
+
+	if wins[0] == wins[1] {
+		t.Fatalf("exactly one attempt should win, got %v", wins)
+	}
+	if !race.Decided() {
+		t.Fatal("race should be decided")
+	}
+	for i, attempt := range attempts {
+		if attempt.IsWinner() != wins[i] {
+			t.Fatalf("attempt %d winner state mismatch", i+1)
+		}
+		// 胜出后再次争夺结果不变
+		if attempt.Claim() != wins[i] {
+			t.Fatalf("attempt %d claim should be stable", i+1)
+		}
+	}
+}
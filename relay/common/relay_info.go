@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -74,7 +76,9 @@ type ChannelMeta struct {
 	ChannelOtherSettings dto.ChannelOtherSettings
 	UpstreamModelName    string
 	IsModelMapped        bool
-	SupportStreamOptions bool // 是否支持流式选项
+	SupportStreamOptions bool      // 是否支持流式选项
+	AttemptStartTime     time.Time // 本次尝试开始的时间
+	FirstTokenTimedOut   bool      // 本次尝试等待首个 token 超时
 }
 
 type TokenCountMeta struct {
@@ -144,6 +148,8 @@ type RelayInfo struct {
 	SubscriptionAmountUsedAfterPreConsume int64
 	IsClaudeBetaQuery                     bool // /v1/messages?beta=true
 	IsChannelTest                         bool // channel test request
+	// Hedge 对冲请求中的本次尝试，未启用对冲时为 nil
+	Hedge *HedgeAttempt
 
 	PriceData types.PriceData
 
@@ -185,6 +191,7 @@ func (info *RelayInfo) InitChannelMeta(c *gin.Context) {
 		UpstreamModelName:    common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
 		IsModelMapped:        false,
 		SupportStreamOptions: false,
+		AttemptStartTime:     time.Now(),
 	}
 
 	if channelType == constant.ChannelTypeAzure {
@@ -607,6 +614,34 @@ func (info *RelayInfo) SetFirstResponseTime() {
 	}
 }
 
+// FirstTokenTimeout 返回本次尝试等待首个 token 的超时时间，渠道设置优先于按模型的配置，0 表示不限制
+func (info *RelayInfo) FirstTokenTimeout() time.Duration {
+	seconds := 0
+	if info.ChannelMeta != nil {
+		seconds = info.ChannelSetting.FirstTokenTimeoutSeconds
+	}
+	if seconds <= 0 {
+		seconds = operation_setting.GetFirstTokenTimeoutSetting().GetModelSeconds(info.OriginModelName)
+	}
+	return time.Duration(max(seconds, 0)) * time.Second
+}
+
+// FirstTokenTimeoutError 本次尝试等待首个 token 超时且尚未向下游写出时返回可重试的错误
+func (info *RelayInfo) FirstTokenTimeoutError() *types.NewAPIError {
+	if info.ChannelMeta == nil || !info.FirstTokenTimedOut {
+		return nil
+	}
+	return types.NewErrorWithStatusCode(fmt.Errorf("channel #%d did not return the first token in time", info.ChannelId), types.ErrorCodeFirstTokenTimeout, http.StatusGatewayTimeout)
+}
+
+// GetFirstTokenLatency 返回本次尝试从发起到收到首个响应的耗时，尚未收到响应时返回 0
+func (info *RelayInfo) GetFirstTokenLatency() time.Duration {
+	if info.ChannelMeta == nil || !info.HasSendResponse() || !info.FirstResponseTime.After(info.AttemptStartTime) {
+		return 0
+	}
+	return info.FirstResponseTime.Sub(info.AttemptStartTime)
+}
+
 func (info *RelayInfo) HasSendResponse() bool {
 	return info.FirstResponseTime.After(info.StartTime)
 }
@@ -203,6 +203,10 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 	}
 
 	usage, newApiErr := adaptor.DoResponse(c, httpResp, info)
+	if timeoutErr := info.FirstTokenTimeoutError(); timeoutErr != nil {
+		// 首个 token 超时且尚未向下游写出，交由上层重试其他渠道
+		return timeoutErr
+	}
 	if newApiErr != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
@@ -428,6 +432,8 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	if err := service.SettleBilling(ctx, relayInfo, quota); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
+	service.AdjustChannelTokenUsage(relayInfo, usage.PromptTokens+usage.CompletionTokens)
+	service.AdjustTokenRateLimitUsage(ctx, usage.PromptTokens+usage.CompletionTokens)
 
 	logModel := modelName
 	if strings.HasPrefix(logModel, "gpt-4-gizmo") {
@@ -0,0 +1,63 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel/gemini"
+	"github.com/QuantumNous/new-api/relay/channel/vertex"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GeminiCountTokensHelper 处理 models/{model}:countTokens，不计费。
+// Gemini 与 Vertex Gemini 渠道转发到上游，其余渠道使用本地估算
+func GeminiCountTokensHelper(c *gin.Context, info *relaycommon.RelayInfo) (*dto.GeminiCountTokensResponse, *types.NewAPIError) {
+	info.InitChannelMeta(c)
+
+	countReq, ok := info.Request.(*dto.GeminiCountTokensRequest)
+	if !ok {
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("invalid request type, expected *dto.GeminiCountTokensRequest, got %T", info.Request), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	request, err := common.DeepCopy(countReq)
+	if err != nil {
+		return nil, types.NewError(fmt.Errorf("failed to copy request to GeminiCountTokensRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	err = helper.ModelMappedHelper(c, info, request)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
+	}
+
+	switch info.ChannelType {
+	case constant.ChannelTypeGemini:
+		return gemini.CountTokens(c, info, request)
+	case constant.ChannelTypeVertexAi:
+		adaptor := &vertex.Adaptor{}
+		adaptor.Init(info)
+		if adaptor.RequestMode == vertex.RequestModeGemini {
+			return vertex.CountGeminiTokens(c, info, request)
+		}
+	}
+
+	meta := request.GetTokenCountMeta()
+	var tokens int
+	if constant.CountToken {
+		tokens, err = service.EstimateRequestToken(c, meta, info)
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeCountTokenFailed, types.ErrOptionWithSkipRetry())
+		}
+	} else {
+		// 全局关闭 token 统计时仍需返回结果，退化为仅统计文本
+		tokens = service.CountTextToken(meta.CombineText, info.UpstreamModelName)
+	}
+	return &dto.GeminiCountTokensResponse{TotalTokens: tokens}, nil
+}
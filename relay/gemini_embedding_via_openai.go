@@ -0,0 +1,103 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/service/openaicompat"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isGeminiNativeEmbeddingChannel 渠道是否支持 Gemini 原生 embedContent / batchEmbedContents 接口
+func isGeminiNativeEmbeddingChannel(channelType int) bool {
+	return channelType == constant.ChannelTypeGemini || channelType == constant.ChannelTypeVertexAi
+}
+
+// geminiEmbeddingViaOpenAI 将 Gemini embedContent / batchEmbedContents 请求转为 OpenAI embeddings 发往 OpenAI 兼容渠道，
+// 再把结果还原为 Gemini 响应格式
+func geminiEmbeddingViaOpenAI(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, requests []*dto.GeminiEmbeddingRequest, isBatch bool) *types.NewAPIError {
+	embeddingRequest, err := openaicompat.GeminiEmbeddingRequestsToOpenAI(requests, info.UpstreamModelName)
+	if err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+
+	info.RelayMode = relayconstant.RelayModeEmbeddings
+	info.RequestURLPath = "/v1/embeddings"
+	info.IsGeminiBatchEmbedding = false
+
+	convertedRequest, err := adaptor.ConvertEmbeddingRequest(c, info, *embeddingRequest)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	relaycommon.AppendRequestConversionFromRequest(info, convertedRequest)
+	jsonData, err := common.Marshal(convertedRequest)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	if len(info.ParamOverride) > 0 {
+		jsonData, err = relaycommon.ApplyParamOverride(jsonData, info.ParamOverride, relaycommon.BuildParamOverrideContext(info))
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+		}
+	}
+	logger.LogDebug(c, fmt.Sprintf("converted gemini embedding request body: %s", string(jsonData)))
+
+	statusCodeMappingStr := c.GetString("status_code_mapping")
+	resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	httpResp, ok := resp.(*http.Response)
+	if !ok || httpResp == nil {
+		return types.NewOpenAIError(fmt.Errorf("invalid response type %T", resp), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		newAPIError := service.RelayErrorHandler(c.Request.Context(), httpResp, false)
+		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
+		return newAPIError
+	}
+	defer service.CloseResponseBodyGracefully(httpResp)
+
+	responseBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	var openAIResponse dto.OpenAIEmbeddingResponse
+	if err := common.Unmarshal(responseBody, &openAIResponse); err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	geminiResponse, err := openaicompat.OpenAIEmbeddingResponseToGemini(&openAIResponse, len(requests))
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+
+	var out any = geminiResponse
+	if !isBatch {
+		out = dto.GeminiEmbeddingResponse{Embedding: *geminiResponse.Embeddings[0]}
+	}
+	responseBody, err = common.Marshal(out)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, httpResp, responseBody)
+
+	usage := &openAIResponse.Usage
+	if usage.PromptTokens == 0 {
+		usage = service.ResponseText2Usage(c, "", info.UpstreamModelName, info.GetEstimatePromptTokens())
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	postConsumeQuota(c, info, usage)
+	return nil
+}
@@ -189,6 +189,9 @@ func GeminiHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *typ
 	}
 
 	usage, openaiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
+	if timeoutErr := info.FirstTokenTimeoutError(); timeoutErr != nil {
+		return timeoutErr
+	}
 	if openaiErr != nil {
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
 		return openaiErr
@@ -207,6 +210,7 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 	var req dto.Request
 	var err error
 	var inputTexts []string
+	var embeddingRequests []*dto.GeminiEmbeddingRequest
 
 	if isBatch {
 		batchRequest := &dto.GeminiBatchEmbeddingRequest{}
@@ -215,6 +219,7 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 			return types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
 		}
 		req = batchRequest
+		embeddingRequests = batchRequest.Requests
 		for _, r := range batchRequest.Requests {
 			for _, part := range r.Content.Parts {
 				if part.Text != "" {
@@ -229,6 +234,7 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 			return types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
 		}
 		req = singleRequest
+		embeddingRequests = []*dto.GeminiEmbeddingRequest{singleRequest}
 		for _, part := range singleRequest.Content.Parts {
 			if part.Text != "" {
 				inputTexts = append(inputTexts, part.Text)
@@ -241,14 +247,19 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
-	req.SetModelName("models/" + info.UpstreamModelName)
-
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
 	}
 	adaptor.Init(info)
 
+	// 只有 Gemini 与 Vertex 渠道提供 Gemini 原生接口，其余渠道（OpenAI、Azure、OpenRouter、自定义等）转换为 embeddings 请求
+	if !isGeminiNativeEmbeddingChannel(info.ChannelType) {
+		return geminiEmbeddingViaOpenAI(c, info, adaptor, embeddingRequests, isBatch)
+	}
+
+	req.SetModelName("models/" + info.UpstreamModelName)
+
 	var requestBody io.Reader
 	jsonData, err := common.Marshal(req)
 	if err != nil {
@@ -288,6 +299,9 @@ func GeminiEmbeddingHandler(c *gin.Context, info *relaycommon.RelayInfo) (newAPI
 	}
 
 	usage, openaiErr := adaptor.DoResponse(c, resp.(*http.Response), info)
+	if timeoutErr := info.FirstTokenTimeoutError(); timeoutErr != nil {
+		return timeoutErr
+	}
 	if openaiErr != nil {
 		service.ResetStatusCode(openaiErr, statusCodeMappingStr)
 		return openaiErr
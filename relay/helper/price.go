@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
@@ -42,6 +43,11 @@ func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) types.
 		groupRatioInfo.GroupRatio = ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	}
 
+	// batch discount
+	if discount, ok := common.GetContextKeyType[float64](ctx, constant.ContextKeyBatchDiscountRatio); ok && discount > 0 {
+		groupRatioInfo.GroupRatio *= discount
+	}
+
 	return groupRatioInfo
 }
 
@@ -173,6 +179,19 @@ func ModelPriceHelperPerCall(c *gin.Context, info *relaycommon.RelayInfo) types.
 	return priceData
 }
 
+// ModelPriceHelperPerImage 按张计费的 PriceHelper（图片变体），单价取自 ModelPriceHelperPerCall
+func ModelPriceHelperPerImage(c *gin.Context, info *relaycommon.RelayInfo, n int) types.PriceData {
+	priceData := ModelPriceHelperPerCall(c, info)
+	priceData.UsePrice = true
+	if n > 1 {
+		priceData.Quota *= n
+		priceData.AddOtherRatio("n", float64(n))
+	}
+	priceData.QuotaToPreConsume = priceData.Quota
+	info.PriceData = priceData
+	return priceData
+}
+
 func ContainPriceOrRatio(modelName string) bool {
 	_, ok := ratio_setting.GetModelPrice(modelName, false)
 	if ok {
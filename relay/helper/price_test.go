@@ -0,0 +1,30 @@
+package helper
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelPriceHelperPerImage(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	info := &relaycommon.RelayInfo{OriginModelName: "dall-e-2", UsingGroup: "default"}
+	perCall := ModelPriceHelperPerCall(c, info)
+	require.Positive(t, perCall.Quota)
+
+	// 按张计费，预扣费为单价乘以张数
+	priceData := ModelPriceHelperPerImage(c, info, 3)
+	require.True(t, priceData.UsePrice)
+	require.Equal(t, perCall.Quota*3, priceData.Quota)
+	require.Equal(t, priceData.Quota, priceData.QuotaToPreConsume)
+	require.Equal(t, 3.0, priceData.OtherRatios["n"])
+	require.Equal(t, priceData, info.PriceData)
+
+	single := ModelPriceHelperPerImage(c, info, 1)
+	require.Equal(t, perCall.Quota, single.Quota)
+	require.NotContains(t, single.OtherRatios, "n")
+}
@@ -0,0 +1,295 @@
+package helper
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ResponsesStatusInProgress = "in_progress"
+	ResponsesStatusCompleted  = "completed"
+	ResponsesStatusIncomplete = "incomplete"
+)
+
+// ResponsesStreamWriter 将其他格式的上游流式响应改写为 Responses API SSE 事件，
+// 同一时刻只有一个输出条目处于打开状态，切换条目类型时自动结束上一个条目
+type ResponsesStreamWriter struct {
+	c        *gin.Context
+	response *dto.OpenAIResponsesResponse
+	current  *dto.ResponsesOutput
+	buffer   strings.Builder // 当前条目已输出的文本或参数
+	text     strings.Builder // 全部输出文本，用于上游缺少 usage 时估算
+	started  bool
+}
+
+func GetResponsesID(c *gin.Context) string {
+	logID := c.GetString(common.RequestIdKey)
+	return "resp_" + logID
+}
+
+func NewResponsesStreamWriter(c *gin.Context, id string, createdAt int64, model string) *ResponsesStreamWriter {
+	return &ResponsesStreamWriter{
+		c: c,
+		response: &dto.OpenAIResponsesResponse{
+			ID:        id,
+			Object:    "response",
+			CreatedAt: int(createdAt),
+			Status:    ResponsesStatusInProgress,
+			Model:     model,
+			Output:    make([]dto.ResponsesOutput, 0),
+		},
+	}
+}
+
+// NewPreviousResponseNotFoundError 转换渠道没有上游存储，previous_response_id 在网关侧也不存在时返回
+func NewPreviousResponseNotFoundError(id string) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(
+		fmt.Errorf("previous response with id '%s' not found", id),
+		types.ErrorCodeInvalidRequest,
+		http.StatusNotFound,
+		types.ErrOptionWithSkipRetry(),
+	)
+}
+
+// SetResponsesResult 记录转换得到的完整 Responses 响应，供网关侧存储使用
+func SetResponsesResult(c *gin.Context, response *dto.OpenAIResponsesResponse) {
+	common.SetContextKey(c, constant.ContextKeyResponsesResult, response)
+}
+
+func (w *ResponsesStreamWriter) SetModel(model string) {
+	if model != "" {
+		w.response.Model = model
+	}
+}
+
+func (w *ResponsesStreamWriter) Response() *dto.OpenAIResponsesResponse {
+	return w.response
+}
+
+func (w *ResponsesStreamWriter) OutputText() string {
+	return w.text.String()
+}
+
+func (w *ResponsesStreamWriter) send(event dto.ResponsesStreamResponse) {
+	data, err := common.Marshal(event)
+	if err != nil {
+		common.SysError("error marshalling responses stream event: " + err.Error())
+		return
+	}
+	ResponseChunkData(w.c, event, string(data))
+}
+
+// snapshot 用于 created/in_progress 事件，避免后续修改影响已发送的数据
+func (w *ResponsesStreamWriter) snapshot() *dto.OpenAIResponsesResponse {
+	resp := *w.response
+	resp.Output = append(make([]dto.ResponsesOutput, 0, len(w.response.Output)), w.response.Output...)
+	return &resp
+}
+
+// Start 发送 response.created 和 response.in_progress，重复调用无效
+func (w *ResponsesStreamWriter) Start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.send(dto.ResponsesStreamResponse{Type: "response.created", Response: w.snapshot()})
+	w.send(dto.ResponsesStreamResponse{Type: "response.in_progress", Response: w.snapshot()})
+}
+
+func (w *ResponsesStreamWriter) outputIndex() *int {
+	return common.GetPointer(len(w.response.Output))
+}
+
+func (w *ResponsesStreamWriter) openItem(item *dto.ResponsesOutput) {
+	w.Start()
+	w.CloseItem()
+	item.Status = ResponsesStatusInProgress
+	w.current = item
+	w.buffer.Reset()
+	w.send(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemAdded, OutputIndex: w.outputIndex(), Item: item})
+}
+
+// AppendText 输出一段消息文本
+func (w *ResponsesStreamWriter) AppendText(delta string) {
+	if w.current == nil || w.current.Type != "message" {
+		w.openItem(&dto.ResponsesOutput{
+			Type:    "message",
+			ID:      "msg_" + common.GetUUID(),
+			Role:    "assistant",
+			Content: []dto.ResponsesOutputContent{},
+		})
+		w.send(dto.ResponsesStreamResponse{
+			Type:         "response.content_part.added",
+			ItemID:       w.current.ID,
+			OutputIndex:  w.outputIndex(),
+			ContentIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesReasoningSummaryPart{Type: "output_text"},
+		})
+	}
+	if delta == "" {
+		return
+	}
+	w.buffer.WriteString(delta)
+	w.text.WriteString(delta)
+	w.send(dto.ResponsesStreamResponse{
+		Type:         "response.output_text.delta",
+		ItemID:       w.current.ID,
+		OutputIndex:  w.outputIndex(),
+		ContentIndex: common.GetPointer(0),
+		Delta:        delta,
+	})
+}
+
+// AppendReasoning 输出一段推理摘要文本
+func (w *ResponsesStreamWriter) AppendReasoning(delta string) {
+	if w.current == nil || w.current.Type != "reasoning" {
+		w.openItem(&dto.ResponsesOutput{
+			Type:    "reasoning",
+			ID:      "rs_" + common.GetUUID(),
+			Summary: []dto.ResponsesReasoningSummaryPart{},
+		})
+		w.send(dto.ResponsesStreamResponse{
+			Type:         "response.reasoning_summary_part.added",
+			ItemID:       w.current.ID,
+			OutputIndex:  w.outputIndex(),
+			SummaryIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesReasoningSummaryPart{Type: "summary_text"},
+		})
+	}
+	if delta == "" {
+		return
+	}
+	w.buffer.WriteString(delta)
+	w.text.WriteString(delta)
+	w.send(dto.ResponsesStreamResponse{
+		Type:         "response.reasoning_summary_text.delta",
+		ItemID:       w.current.ID,
+		OutputIndex:  w.outputIndex(),
+		SummaryIndex: common.GetPointer(0),
+		Delta:        delta,
+	})
+}
+
+// SetReasoningEncryptedContent 设置当前推理条目的 encrypted_content，不存在推理条目时新建一个
+func (w *ResponsesStreamWriter) SetReasoningEncryptedContent(content string) {
+	if w.current == nil || w.current.Type != "reasoning" {
+		w.openItem(&dto.ResponsesOutput{
+			Type:    "reasoning",
+			ID:      "rs_" + common.GetUUID(),
+			Summary: []dto.ResponsesReasoningSummaryPart{},
+		})
+	}
+	w.current.EncryptedContent += content
+}
+
+// StartFunctionCall 开始一个函数调用条目
+func (w *ResponsesStreamWriter) StartFunctionCall(callId string, name string) {
+	w.openItem(&dto.ResponsesOutput{
+		Type:   "function_call",
+		ID:     "fc_" + common.GetUUID(),
+		CallId: callId,
+		Name:   name,
+	})
+}
+
+// AppendArguments 输出当前函数调用的一段参数
+func (w *ResponsesStreamWriter) AppendArguments(delta string) {
+	if w.current == nil || w.current.Type != "function_call" || delta == "" {
+		return
+	}
+	w.buffer.WriteString(delta)
+	w.text.WriteString(delta)
+	w.send(dto.ResponsesStreamResponse{
+		Type:        "response.function_call_arguments.delta",
+		ItemID:      w.current.ID,
+		OutputIndex: w.outputIndex(),
+		Delta:       delta,
+	})
+}
+
+// CloseItem 结束当前条目并发送对应的 done 事件
+func (w *ResponsesStreamWriter) CloseItem() {
+	item := w.current
+	if item == nil {
+		return
+	}
+	w.current = nil
+	content := w.buffer.String()
+	w.buffer.Reset()
+	outputIndex := w.outputIndex()
+
+	switch item.Type {
+	case "message":
+		w.send(dto.ResponsesStreamResponse{
+			Type:         "response.output_text.done",
+			ItemID:       item.ID,
+			OutputIndex:  outputIndex,
+			ContentIndex: common.GetPointer(0),
+			Text:         content,
+		})
+		w.send(dto.ResponsesStreamResponse{
+			Type:         "response.content_part.done",
+			ItemID:       item.ID,
+			OutputIndex:  outputIndex,
+			ContentIndex: common.GetPointer(0),
+			Part:         &dto.ResponsesReasoningSummaryPart{Type: "output_text", Text: content},
+		})
+		item.Content = []dto.ResponsesOutputContent{{Type: "output_text", Text: content, Annotations: []interface{}{}}}
+	case "reasoning":
+		if content != "" {
+			w.send(dto.ResponsesStreamResponse{
+				Type:         "response.reasoning_summary_text.done",
+				ItemID:       item.ID,
+				OutputIndex:  outputIndex,
+				SummaryIndex: common.GetPointer(0),
+				Text:         content,
+			})
+			w.send(dto.ResponsesStreamResponse{
+				Type:         "response.reasoning_summary_part.done",
+				ItemID:       item.ID,
+				OutputIndex:  outputIndex,
+				SummaryIndex: common.GetPointer(0),
+				Part:         &dto.ResponsesReasoningSummaryPart{Type: "summary_text", Text: content},
+			})
+			item.Summary = []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: content}}
+		}
+	case "function_call":
+		if content == "" {
+			content = "{}"
+		}
+		w.send(dto.ResponsesStreamResponse{
+			Type:        "response.function_call_arguments.done",
+			ItemID:      item.ID,
+			OutputIndex: outputIndex,
+			Arguments:   content,
+		})
+		item.Arguments = content
+	}
+	item.Status = ResponsesStatusCompleted
+	w.send(dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: outputIndex, Item: item})
+	w.response.Output = append(w.response.Output, *item)
+}
+
+// Complete 结束响应，incompleteReason 非空时以 response.incomplete 结束
+func (w *ResponsesStreamWriter) Complete(usage *dto.Usage, incompleteReason string) {
+	w.Start()
+	w.CloseItem()
+	w.response.Usage = usage
+	eventType := "response.completed"
+	w.response.Status = ResponsesStatusCompleted
+	if incompleteReason != "" {
+		eventType = "response.incomplete"
+		w.response.Status = ResponsesStatusIncomplete
+		w.response.IncompleteDetails = &dto.IncompleteDetails{Reason: incompleteReason}
+	}
+	w.send(dto.ResponsesStreamResponse{Type: eventType, Response: w.response})
+	SetResponsesResult(w.c, w.response)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
@@ -48,6 +49,11 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 	}()
 
 	streamingTimeout := time.Duration(constant.StreamingTimeout) * time.Second
+	if info.ChannelMeta != nil {
+		if transport := info.ChannelOtherSettings.Transport; transport != nil && transport.IdleStreamTimeoutSeconds > 0 {
+			streamingTimeout = time.Duration(transport.IdleStreamTimeoutSeconds) * time.Second
+		}
+	}
 
 	var (
 		stopChan   = make(chan bool, 3) // 增加缓冲区避免阻塞
@@ -56,6 +62,8 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		pingTicker *time.Ticker
 		writeMutex sync.Mutex     // Mutex to protect concurrent writes
 		wg         sync.WaitGroup // 用于等待所有 goroutine 退出
+		// 是否已收到上游数据
+		receivedData atomic.Bool
 	)
 
 	generalSettings := operation_setting.GetGeneralSetting()
@@ -141,6 +149,10 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 					gopool.Go(func() {
 						writeMutex.Lock()
 						defer writeMutex.Unlock()
+						if info.FirstTokenTimedOut {
+							done <- nil
+							return
+						}
 						done <- PingData(c)
 					})
 
@@ -217,6 +229,7 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			data = data[5:]
 			data = strings.TrimLeft(data, " ")
 			data = strings.TrimSuffix(data, "\r")
+			receivedData.Store(true)
 			if !strings.HasPrefix(data, "[DONE]") {
 				info.SetFirstResponseTime()
 				info.ReceivedResponseCount++
@@ -225,6 +238,11 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 				gopool.Go(func() {
 					writeMutex.Lock()
 					defer writeMutex.Unlock()
+					// 首个 token 超时后已决定重试，不再向下游写出
+					if info.FirstTokenTimedOut {
+						done <- false
+						return
+					}
 					done <- dataHandler(data)
 				})
 
@@ -257,16 +275,41 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		}
 	})
 
+	// 首个 token 超时从本次尝试发起时开始计算
+	var firstTokenTimeoutChan <-chan time.Time
+	if firstTokenTimeout := info.FirstTokenTimeout(); firstTokenTimeout > 0 && info.ChannelMeta != nil {
+		firstTokenTimer := time.NewTimer(max(firstTokenTimeout-time.Since(info.AttemptStartTime), 0))
+		defer firstTokenTimer.Stop()
+		firstTokenTimeoutChan = firstTokenTimer.C
+	}
+
 	// 主循环等待完成或超时
-	select {
-	case <-ticker.C:
-		// 超时处理逻辑
-		logger.LogError(c, "streaming timeout")
-	case <-stopChan:
-		// 正常结束
-		logger.LogInfo(c, "streaming finished")
-	case <-c.Request.Context().Done():
-		// 客户端断开连接
-		logger.LogInfo(c, "client disconnected")
+	for {
+		select {
+		case <-firstTokenTimeoutChan:
+			firstTokenTimeoutChan = nil
+			// 与写出使用同一把锁判断并关闭响应体，避免判断后又有数据写出
+			writeMutex.Lock()
+			// 已经收到数据或已向下游写出时无法重试，继续等待
+			if receivedData.Load() || c.Writer.Written() {
+				writeMutex.Unlock()
+				continue
+			}
+			info.FirstTokenTimedOut = true
+			// 关闭响应体让扫描协程立即退出
+			_ = resp.Body.Close()
+			writeMutex.Unlock()
+			logger.LogError(c, "first token timeout")
+		case <-ticker.C:
+			// 超时处理逻辑
+			logger.LogError(c, "streaming timeout")
+		case <-stopChan:
+			// 正常结束
+			logger.LogInfo(c, "streaming finished")
+		case <-c.Request.Context().Done():
+			// 客户端断开连接
+			logger.LogInfo(c, "client disconnected")
+		}
+		return
 	}
 }
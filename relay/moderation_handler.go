@@ -0,0 +1,55 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocalModerationHelper 使用本地审核引擎响应 /v1/moderations 请求，不转发上游也不计费
+func LocalModerationHelper(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
+	request, ok := info.Request.(*dto.GeneralOpenAIRequest)
+	if !ok {
+		return types.NewErrorWithStatusCode(fmt.Errorf("invalid request type, expected dto.GeneralOpenAIRequest, got %T", info.Request), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	inputs := moderationInputs(request.Input)
+	if len(inputs) == 0 {
+		return types.NewErrorWithStatusCode(errors.New("input is required"), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	c.JSON(http.StatusOK, service.BuildLocalModerationResponse(request.Model, inputs))
+	return nil
+}
+
+// moderationInputs 字符串或字符串数组按条审核；多模态数组合并其中的文本作为一条输入
+func moderationInputs(input any) []string {
+	switch v := input.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		texts := make([]string, 0, len(v))
+		var parts []string
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				texts = append(texts, item)
+			case map[string]any:
+				if text, ok := item["text"].(string); ok && item["type"] == "text" {
+					parts = append(parts, text)
+				}
+			}
+		}
+		if len(parts) > 0 {
+			texts = append(texts, strings.Join(parts, "\n"))
+		}
+		return texts
+	}
+	return nil
+}
@@ -39,3 +39,27 @@ func OpenAIFinishReasonToClaudeStopReason(finishReason string) string {
 		return finishReason
 	}
 }
+
+func GeminiFinishReasonToClaudeStopReason(finishReason string) string {
+	switch strings.ToUpper(finishReason) {
+	case "", "STOP":
+		return "end_turn"
+	case "MAX_TOKENS":
+		return "max_tokens"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "refusal"
+	default:
+		return "end_turn"
+	}
+}
+
+func ClaudeStopReasonToGeminiFinishReason(stopReason string) string {
+	switch strings.ToLower(stopReason) {
+	case "max_tokens":
+		return "MAX_TOKENS"
+	case "refusal":
+		return "SAFETY"
+	default:
+		return "STOP"
+	}
+}
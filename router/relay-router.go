@@ -1,6 +1,8 @@
 package router
 
 import (
+	"strings"
+
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
@@ -74,6 +76,24 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatOpenAIRealtime)
 		})
 	}
+	{
+		// file routes（不经过渠道分发，按令牌隔离）
+		relayV1Router.GET("/files", controller.ListFiles)
+		relayV1Router.POST("/files", controller.UploadFile)
+		relayV1Router.GET("/files/:id", controller.RetrieveFile)
+		relayV1Router.DELETE("/files/:id", controller.DeleteFile)
+		relayV1Router.GET("/files/:id/content", controller.RetrieveFileContent)
+
+		// batch routes（由网关在后台逐行执行）
+		relayV1Router.POST("/batches", controller.CreateBatch)
+		relayV1Router.GET("/batches", controller.ListBatches)
+		relayV1Router.GET("/batches/:id", controller.RetrieveBatch)
+		relayV1Router.POST("/batches/:id/cancel", controller.CancelBatch)
+
+		// 网关侧存储的 responses（转换渠道的 previous_response_id）
+		relayV1Router.GET("/responses/:id", controller.RetrieveResponse)
+		relayV1Router.DELETE("/responses/:id", controller.DeleteResponse)
+	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
@@ -83,6 +103,7 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/messages", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatClaude)
 		})
+		httpRouter.POST("/messages/count_tokens", controller.ClaudeCountTokens)
 
 		// chat related routes
 		httpRouter.POST("/completions", func(c *gin.Context) {
@@ -110,6 +131,9 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/images/edits", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatOpenAIImage)
 		})
+		httpRouter.POST("/images/variations", func(c *gin.Context) {
+			controller.Relay(c, types.RelayFormatOpenAIImage)
+		})
 
 		// embedding related routes
 		httpRouter.POST("/embeddings", func(c *gin.Context) {
@@ -137,6 +161,10 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatGemini)
 		})
 		httpRouter.POST("/models/*path", func(c *gin.Context) {
+			if strings.HasSuffix(c.Request.URL.Path, ":countTokens") {
+				controller.GeminiCountTokens(c)
+				return
+			}
 			controller.Relay(c, types.RelayFormatGemini)
 		})
 
@@ -146,12 +174,6 @@ func SetRelayRouter(router *gin.Engine) {
 		})
 
 		// not implemented
-		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
 		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
@@ -186,6 +208,10 @@ func SetRelayRouter(router *gin.Engine) {
 	{
 		// Gemini API 路径格式: /v1beta/models/{model_name}:{action}
 		relayGeminiRouter.POST("/models/*path", func(c *gin.Context) {
+			if strings.HasSuffix(c.Request.URL.Path, ":countTokens") {
+				controller.GeminiCountTokens(c)
+				return
+			}
 			controller.Relay(c, types.RelayFormatGemini)
 		})
 	}
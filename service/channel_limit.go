@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	channelLimitRedisPrefix = "new-api:channel_limit:v1"
+	// 并发计数的过期时间，防止节点异常退出后计数无法归还
+	channelConcurrencyTTL    = 10 * time.Minute
+	channelLimitPollInterval = 100 * time.Millisecond
+)
+
+// ChannelUtilization 渠道当前的并发数、本分钟请求数和 token 数
+type ChannelUtilization struct {
+	ChannelId      int    `json:"channel_id"`
+	ChannelName    string `json:"channel_name"`
+	Concurrency    int64  `json:"concurrency"`
+	MaxConcurrency int    `json:"max_concurrency"`
+	Requests       int64  `json:"rpm"`
+	RPM            int    `json:"rpm_limit"`
+	Tokens         int64  `json:"tpm"`
+	TPM            int    `json:"tpm_limit"`
+	Queued         int64  `json:"queued"`
+	MaxQueueSize   int    `json:"max_queue_size"`
+}
+
+// memoryChannelCounter 未启用 Redis 时的计数，请求数和 token 数按自然分钟计
+type memoryChannelCounter struct {
+	mu          sync.Mutex
+	concurrency int64
+	minute      int64
+	requests    int64
+	tokens      int64
+}
+
+var (
+	memoryChannelCounters sync.Map // map[int]*memoryChannelCounter
+	channelQueueLengths   sync.Map // map[int]*atomic.Int64，排队数只在本节点统计
+)
+
+func channelLimitRedisOn() bool {
+	return common.RedisEnabled && common.RDB != nil
+}
+
+func getMemoryChannelCounter(channelId int) *memoryChannelCounter {
+	v, _ := memoryChannelCounters.LoadOrStore(channelId, &memoryChannelCounter{})
+	counter := v.(*memoryChannelCounter)
+	counter.mu.Lock()
+	if minute := time.Now().Unix() / 60; counter.minute != minute {
+		counter.minute = minute
+		counter.requests = 0
+		counter.tokens = 0
+	}
+	counter.mu.Unlock()
+	return counter
+}
+
+func channelLimitRedisKey(kind string, channelId int) string {
+	if kind == "conc" {
+		return fmt.Sprintf("%s:conc:%d", channelLimitRedisPrefix, channelId)
+	}
+	return fmt.Sprintf("%s:%s:%d:%d", channelLimitRedisPrefix, kind, channelId, time.Now().Unix()/60)
+}
+
+// incrChannelCounter 增加计数并返回增加后的值，kind 为 conc / rpm / tpm
+func incrChannelCounter(channelId int, kind string, delta int64) (int64, error) {
+	if channelLimitRedisOn() {
+		ctx := context.Background()
+		key := channelLimitRedisKey(kind, channelId)
+		n, err := common.RDB.IncrBy(ctx, key, delta).Result()
+		if err != nil {
+			return 0, err
+		}
+		ttl := 2 * time.Minute
+		if kind == "conc" {
+			ttl = channelConcurrencyTTL
+		}
+		common.RDB.Expire(ctx, key, ttl)
+		return n, nil
+	}
+	counter := getMemoryChannelCounter(channelId)
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	switch kind {
+	case "conc":
+		counter.concurrency += delta
+		return counter.concurrency, nil
+	case "rpm":
+		counter.requests += delta
+		return counter.requests, nil
+	default:
+		counter.tokens += delta
+		return counter.tokens, nil
+	}
+}
+
+func getChannelCounters(channelId int) (concurrency, requests, tokens int64) {
+	if channelLimitRedisOn() {
+		values, err := common.RDB.MGet(context.Background(),
+			channelLimitRedisKey("conc", channelId),
+			channelLimitRedisKey("rpm", channelId),
+			channelLimitRedisKey("tpm", channelId),
+		).Result()
+		if err != nil {
+			return 0, 0, 0
+		}
+		parse := func(v any) int64 {
+			s, _ := v.(string)
+			n, _ := strconv.ParseInt(s, 10, 64)
+			return n
+		}
+		return parse(values[0]), parse(values[1]), parse(values[2])
+	}
+	counter := getMemoryChannelCounter(channelId)
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	return counter.concurrency, counter.requests, counter.tokens
+}
+
+func getChannelQueueLength(channelId int) *atomic.Int64 {
+	v, _ := channelQueueLengths.LoadOrStore(channelId, &atomic.Int64{})
+	return v.(*atomic.Int64)
+}
+
+// IsChannelSaturated 渠道是否已达到并发、RPM 或 TPM 限制
+func IsChannelSaturated(channel *model.Channel) bool {
+	setting := channel.GetSetting()
+	if !setting.HasThroughputLimit() {
+		return false
+	}
+	concurrency, requests, tokens := getChannelCounters(channel.Id)
+	return (setting.MaxConcurrency > 0 && concurrency >= int64(setting.MaxConcurrency)) ||
+		(setting.RPM > 0 && requests >= int64(setting.RPM)) ||
+		(setting.TPM > 0 && tokens >= int64(setting.TPM))
+}
+
+// tryAcquireChannelLimit 尝试占用一个并发名额并计入本分钟的请求数和预估 token 数
+func tryAcquireChannelLimit(channelId int, setting dto.ChannelSettings, estimatedTokens int) (bool, error) {
+	if setting.TPM > 0 {
+		if _, _, tokens := getChannelCounters(channelId); tokens >= int64(setting.TPM) {
+			return false, nil
+		}
+	}
+	if setting.MaxConcurrency > 0 {
+		n, err := incrChannelCounter(channelId, "conc", 1)
+		if err != nil {
+			return false, err
+		}
+		if n > int64(setting.MaxConcurrency) {
+			_, _ = incrChannelCounter(channelId, "conc", -1)
+			return false, nil
+		}
+	}
+	if setting.RPM > 0 {
+		n, err := incrChannelCounter(channelId, "rpm", 1)
+		if err == nil && n > int64(setting.RPM) {
+			_, _ = incrChannelCounter(channelId, "rpm", -1)
+		}
+		if err != nil || n > int64(setting.RPM) {
+			if setting.MaxConcurrency > 0 {
+				_, _ = incrChannelCounter(channelId, "conc", -1)
+			}
+			return false, err
+		}
+	}
+	if setting.TPM > 0 && estimatedTokens > 0 {
+		_, _ = incrChannelCounter(channelId, "tpm", int64(estimatedTokens))
+	}
+	return true, nil
+}
+
+// AcquireChannelLimit 按渠道的并发、RPM、TPM 限制占用名额，达到限制时在有界队列中等待。
+// 返回的 release 需在请求结束后调用以归还并发名额。
+// 渠道配置从上下文读取，与本次请求选中的渠道保持一致
+func AcquireChannelLimit(c *gin.Context, channelId int, estimatedTokens int) (func(), *types.NewAPIError) {
+	setting, _ := common.GetContextKeyType[dto.ChannelSettings](c, constant.ContextKeyChannelSetting)
+	if !setting.HasThroughputLimit() {
+		return func() {}, nil
+	}
+	release := func() {}
+	if setting.MaxConcurrency > 0 {
+		var once sync.Once
+		release = func() {
+			once.Do(func() {
+				_, _ = incrChannelCounter(channelId, "conc", -1)
+			})
+		}
+	}
+
+	ok, err := tryAcquireChannelLimit(channelId, setting, estimatedTokens)
+	if err != nil {
+		// 计数失败时不阻塞请求
+		common.SysError(fmt.Sprintf("failed to acquire channel #%d limit: %s", channelId, err.Error()))
+		return func() {}, nil
+	}
+	if ok {
+		return release, nil
+	}
+
+	saturatedErr := types.NewErrorWithStatusCode(fmt.Errorf("channel #%d is saturated", channelId), types.ErrorCodeChannelSaturated, http.StatusTooManyRequests)
+	if setting.MaxQueueSize <= 0 || setting.QueueTimeoutSeconds <= 0 {
+		return nil, saturatedErr
+	}
+	queueLength := getChannelQueueLength(channelId)
+	if queueLength.Add(1) > int64(setting.MaxQueueSize) {
+		queueLength.Add(-1)
+		return nil, saturatedErr
+	}
+	defer queueLength.Add(-1)
+
+	timer := time.NewTimer(time.Duration(setting.QueueTimeoutSeconds) * time.Second)
+	defer timer.Stop()
+	ticker := time.NewTicker(channelLimitPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return nil, types.NewErrorWithStatusCode(errors.New("client canceled while waiting for channel"), types.ErrorCodeChannelSaturated, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+		case <-timer.C:
+			return nil, types.NewErrorWithStatusCode(fmt.Errorf("channel #%d is saturated, queue timeout", channelId), types.ErrorCodeChannelSaturated, http.StatusTooManyRequests)
+		case <-ticker.C:
+			ok, err = tryAcquireChannelLimit(channelId, setting, estimatedTokens)
+			if err != nil {
+				return func() {}, nil
+			}
+			if ok {
+				return release, nil
+			}
+		}
+	}
+}
+
+// AdjustChannelTokenUsage 请求结束后用实际 token 数修正渠道的 TPM 计数
+func AdjustChannelTokenUsage(relayInfo *relaycommon.RelayInfo, actualTokens int) {
+	if relayInfo.ChannelMeta == nil || relayInfo.ChannelSetting.TPM <= 0 {
+		return
+	}
+	if delta := actualTokens - relayInfo.GetEstimatePromptTokens(); delta != 0 {
+		_, _ = incrChannelCounter(relayInfo.ChannelId, "tpm", int64(delta))
+	}
+}
+
+// GetChannelUtilization 返回配置了并发或速率限制的渠道的当前用量
+func GetChannelUtilization(channel *model.Channel) *ChannelUtilization {
+	setting := channel.GetSetting()
+	concurrency, requests, tokens := getChannelCounters(channel.Id)
+	return &ChannelUtilization{
+		ChannelId:      channel.Id,
+		ChannelName:    channel.Name,
+		Concurrency:    concurrency,
+		MaxConcurrency: setting.MaxConcurrency,
+		Requests:       requests,
+		RPM:            setting.RPM,
+		Tokens:         tokens,
+		TPM:            setting.TPM,
+		Queued:         getChannelQueueLength(channel.Id).Load(),
+		MaxQueueSize:   setting.MaxQueueSize,
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newLimitedChannel(c *gin.Context, id int, setting dto.ChannelSettings) *model.Channel {
+	channel := &model.Channel{Id: id}
+	channel.SetSetting(setting)
+	common.SetContextKey(c, constant.ContextKeyChannelSetting, setting)
+	return channel
+}
+
+func TestChannelConcurrencyLimit(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+
+	channel := newLimitedChannel(c, 900101, dto.ChannelSettings{MaxConcurrency: 1, MaxQueueSize: 1, QueueTimeoutSeconds: 2})
+	release, err := AcquireChannelLimit(c, channel.Id, 0)
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+	if !IsChannelSaturated(channel) {
+		t.Fatal("channel should be saturated")
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		release()
+		release()
+	}()
+	start := time.Now()
+	release2, err := AcquireChannelLimit(c, channel.Id, 0)
+	if err != nil {
+		t.Fatalf("queued acquire failed: %v", err)
+	}
+	if time.Since(start) < 150*time.Millisecond {
+		t.Error("queued request should wait for the slot to be released")
+	}
+	release2()
+	if concurrency, _, _ := getChannelCounters(channel.Id); concurrency != 0 {
+		t.Errorf("concurrency = %d, want 0", concurrency)
+	}
+}
+
+func TestChannelRPMAndTPMLimit(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+
+	rpmChannel := newLimitedChannel(c, 900102, dto.ChannelSettings{RPM: 2})
+	for i := 0; i < 2; i++ {
+		if _, err := AcquireChannelLimit(c, rpmChannel.Id, 0); err != nil {
+			t.Fatalf("request %d rejected: %v", i, err)
+		}
+	}
+	if _, err := AcquireChannelLimit(c, rpmChannel.Id, 0); err == nil {
+		t.Fatal("third request should exceed rpm")
+	}
+
+	tpmChannel := newLimitedChannel(c, 900103, dto.ChannelSettings{TPM: 100})
+	if _, err := AcquireChannelLimit(c, tpmChannel.Id, 120); err != nil {
+		t.Fatalf("first request rejected: %v", err)
+	}
+	if _, err := AcquireChannelLimit(c, tpmChannel.Id, 10); err == nil {
+		t.Fatal("tpm should be exhausted")
+	}
+}
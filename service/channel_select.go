@@ -2,12 +2,15 @@ package service
 
 import (
 	"errors"
+	"net/http"
+	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/gin-gonic/gin"
 )
 
@@ -115,7 +118,7 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			}
 			logger.LogDebug(param.Ctx, "Auto selecting group: %s, priorityRetry: %d", autoGroup, priorityRetry)
 
-			channel, _ = model.GetRandomSatisfiedChannel(autoGroup, param.ModelName, priorityRetry)
+			channel, _ = getRandomSatisfiedChannel(param.Ctx, autoGroup, param.ModelName, priorityRetry)
 			if channel == nil {
 				// Current group has no available channel for this model, try next group
 				// 当前分组没有该模型的可用渠道，尝试下一个分组
@@ -153,10 +156,84 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			break
 		}
 	} else {
-		channel, err = model.GetRandomSatisfiedChannel(param.TokenGroup, param.ModelName, param.GetRetry())
+		channel, err = getRandomSatisfiedChannel(param.Ctx, param.TokenGroup, param.ModelName, param.GetRetry())
 		if err != nil {
 			return nil, param.TokenGroup, err
 		}
 	}
 	return channel, selectGroup, nil
 }
+
+// isChannelAvailable 渠道未熔断、未被上游限流冷却且未达到并发和速率限制
+func isChannelAvailable(channel *model.Channel) bool {
+	return !channel.IsCoolingDown() && !IsChannelSaturated(channel) && IsChannelCircuitAllowed(channel.Id)
+}
+
+// filterAvailableChannels 过滤不可用的渠道；全部不可用时不过滤，由请求排队等待或直接失败
+func filterAvailableChannels(channels []*model.Channel) []*model.Channel {
+	allowed := make([]*model.Channel, 0, len(channels))
+	circuitStates := getChannelCircuitStates(channels)
+	for _, channel := range channels {
+		if !channel.IsCoolingDown() && !IsChannelSaturated(channel) && circuitStateAllowed(circuitStates[channel.Id]) {
+			allowed = append(allowed, channel)
+		}
+	}
+	if len(allowed) == 0 {
+		return channels
+	}
+	return allowed
+}
+
+// IsChannelSupportRequest 渠道是否支持当前请求的接口，不支持的渠道在选择时直接跳过，避免预扣费后才失败
+func IsChannelSupportRequest(c *gin.Context, channel *model.Channel) bool {
+	if c == nil || c.Request == nil {
+		return true
+	}
+	if strings.HasPrefix(c.Request.URL.Path, "/v1/images/variations") {
+		// 只有 OpenAI 兼容渠道、阿里渠道和智谱渠道实现了图片变体
+		apiType, _ := common.ChannelType2APIType(channel.Type)
+		return apiType == constant.APITypeOpenAI || apiType == constant.APITypeAli || apiType == constant.APITypeZhipuV4
+	}
+	if c.Request.Method == http.MethodPost && c.Request.URL.Path == "/v1/files" {
+		// 文件只能透传到 OpenAI 类型渠道，随机选中其他渠道会退回本地存储
+		return IsFileUpstreamChannel(channel)
+	}
+	return true
+}
+
+// getRandomSatisfiedChannel 按分组配置的选择策略选择渠道，并跳过不可用和不支持当前请求的渠道
+func getRandomSatisfiedChannel(c *gin.Context, group string, modelName string, retry int) (*model.Channel, error) {
+	if !common.MemoryCacheEnabled {
+		// 数据库模式无法获取候选列表，选中不可用的渠道时重新选择
+		var channel *model.Channel
+		var err error
+		for i := 0; i < 5; i++ {
+			channel, err = model.GetRandomSatisfiedChannel(group, modelName, retry)
+			if err != nil || channel == nil || (IsChannelSupportRequest(c, channel) && isChannelAvailable(channel)) {
+				break
+			}
+		}
+		if channel != nil && !IsChannelSupportRequest(c, channel) {
+			return nil, err
+		}
+		return channel, err
+	}
+	channels, err := model.GetPriorityChannels(group, modelName, retry)
+	if err != nil || len(channels) == 0 {
+		return nil, err
+	}
+	supported := make([]*model.Channel, 0, len(channels))
+	for _, channel := range channels {
+		if IsChannelSupportRequest(c, channel) {
+			supported = append(supported, channel)
+		}
+	}
+	if len(supported) == 0 {
+		return nil, nil
+	}
+	channels = filterAvailableChannels(supported)
+	if operation_setting.GetChannelSelectSetting().GetGroupMode(group) == operation_setting.ChannelSelectModeAdaptive {
+		return pickAdaptiveChannel(channels, modelName), nil
+	}
+	return model.PickWeightedChannel(channels)
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+type channelStatsKey struct {
+	ChannelId int
+	Model     string
+}
+
+// channelStats 渠道在某个模型上的近期表现，数值均为滑动平均
+type channelStats struct {
+	mu             sync.Mutex
+	latency        float64
+	ttft           float64
+	errorRate      float64
+	samples        int
+	latencySamples int
+	ttftSamples    int
+	lastUpdate     time.Time
+}
+
+// ChannelStatsSnapshot 渠道表现统计快照，Score 为自适应选择时的权重调整系数
+type ChannelStatsSnapshot struct {
+	ChannelId      int     `json:"channel_id"`
+	ChannelName    string  `json:"channel_name,omitempty"`
+	Model          string  `json:"model"`
+	LatencyMs      float64 `json:"latency_ms"`
+	TTFTMs         float64 `json:"ttft_ms"`
+	ErrorRate      float64 `json:"error_rate"`
+	Samples        int     `json:"samples"`
+	LatencySamples int     `json:"latency_samples"`
+	TTFTSamples    int     `json:"ttft_samples"`
+	LastUpdate     int64   `json:"last_update"`
+	Score          float64 `json:"score"`
+}
+
+var channelStatsMap sync.Map
+
+func ewma(current float64, sample float64, count int, alpha float64) float64 {
+	if count == 0 {
+		return sample
+	}
+	return alpha*sample + (1-alpha)*current
+}
+
+// RecordChannelResult 记录一次渠道请求的结果，ttft 为 0 表示非流式请求或未收到首字
+func RecordChannelResult(channelId int, modelName string, latency time.Duration, ttft time.Duration, success bool) {
+	if channelId == 0 || modelName == "" {
+		return
+	}
+	alpha := operation_setting.GetChannelSelectSetting().Alpha
+	if alpha <= 0 || alpha > 1 {
+		alpha = 0.2
+	}
+	v, _ := channelStatsMap.LoadOrStore(channelStatsKey{ChannelId: channelId, Model: modelName}, &channelStats{})
+	stats := v.(*channelStats)
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	if stats.isExpired() {
+		*stats = channelStats{}
+	}
+	errorSample := 1.0
+	if success {
+		errorSample = 0
+		// 失败请求通常很快返回，不计入延迟统计
+		stats.latency = ewma(stats.latency, float64(latency.Milliseconds()), stats.latencySamples, alpha)
+		stats.latencySamples++
+		if ttft > 0 {
+			stats.ttft = ewma(stats.ttft, float64(ttft.Milliseconds()), stats.ttftSamples, alpha)
+			stats.ttftSamples++
+		}
+	}
+	stats.errorRate = ewma(stats.errorRate, errorSample, stats.samples, alpha)
+	stats.samples++
+	stats.lastUpdate = time.Now()
+}
+
+// IsChannelFaultError 判断错误是否应计入渠道错误率，请求本身的问题不计入
+func IsChannelFaultError(err *types.NewAPIError) bool {
+	if types.IsChannelError(err) {
+		return true
+	}
+	if types.IsSkipRetryError(err) {
+		return false
+	}
+	code := err.StatusCode
+	return code < 100 || code >= 500 || code == 429 || code == 408
+}
+
+func (s *channelStats) isExpired() bool {
+	ttl := operation_setting.GetChannelSelectSetting().StatsTTLSeconds
+	return ttl > 0 && !s.lastUpdate.IsZero() && time.Since(s.lastUpdate) > time.Duration(ttl)*time.Second
+}
+
+func (s *channelStats) snapshot(key channelStatsKey) *ChannelStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.samples == 0 || s.isExpired() {
+		return nil
+	}
+	return &ChannelStatsSnapshot{
+		ChannelId:      key.ChannelId,
+		Model:          key.Model,
+		LatencyMs:      s.latency,
+		TTFTMs:         s.ttft,
+		ErrorRate:      s.errorRate,
+		Samples:        s.samples,
+		LatencySamples: s.latencySamples,
+		TTFTSamples:    s.ttftSamples,
+		LastUpdate:     s.lastUpdate.Unix(),
+		Score:          1,
+	}
+}
+
+func getChannelStatsSnapshot(channelId int, modelName string) *ChannelStatsSnapshot {
+	key := channelStatsKey{ChannelId: channelId, Model: modelName}
+	v, ok := channelStatsMap.Load(key)
+	if !ok {
+		return nil
+	}
+	return v.(*channelStats).snapshot(key)
+}
+
+// scoreChannelStats 计算同一模型下各渠道的权重调整系数：速度以表现最好的渠道为基准，错误率按惩罚指数衰减
+func scoreChannelStats(snapshots []*ChannelStatsSnapshot) []float64 {
+	setting := operation_setting.GetChannelSelectSetting()
+	minSamples := common.Max(setting.MinSamples, 1)
+
+	refLatency, refTTFT := math.MaxFloat64, math.MaxFloat64
+	for _, s := range snapshots {
+		if s == nil {
+			continue
+		}
+		if s.LatencySamples >= minSamples && s.LatencyMs > 0 {
+			refLatency = math.Min(refLatency, s.LatencyMs)
+		}
+		if s.TTFTSamples >= minSamples && s.TTFTMs > 0 {
+			refTTFT = math.Min(refTTFT, s.TTFTMs)
+		}
+	}
+
+	factors := make([]float64, len(snapshots))
+	for i, s := range snapshots {
+		factor := 1.0
+		if s != nil {
+			// 流式请求以首字时间衡量速度，否则使用总耗时
+			if s.TTFTSamples >= minSamples && s.TTFTMs > 0 && refTTFT != math.MaxFloat64 {
+				factor *= refTTFT / s.TTFTMs
+			} else if s.LatencySamples >= minSamples && s.LatencyMs > 0 && refLatency != math.MaxFloat64 {
+				factor *= refLatency / s.LatencyMs
+			}
+			if s.Samples >= minSamples {
+				factor *= math.Pow(1-s.ErrorRate, setting.ErrorPenalty)
+			}
+		}
+		factors[i] = math.Max(factor, setting.MinFactor)
+	}
+	return factors
+}
+
+// pickAdaptiveChannel 按 静态权重 × 表现系数 随机选择渠道
+func pickAdaptiveChannel(channels []*model.Channel, modelName string) *model.Channel {
+	if len(channels) == 1 {
+		return channels[0]
+	}
+	snapshots := make([]*ChannelStatsSnapshot, len(channels))
+	allZero := true
+	for i, channel := range channels {
+		snapshots[i] = getChannelStatsSnapshot(channel.Id, modelName)
+		if channel.GetWeight() > 0 {
+			allZero = false
+		}
+	}
+	factors := scoreChannelStats(snapshots)
+
+	weights := make([]float64, len(channels))
+	total := 0.0
+	for i, channel := range channels {
+		weight := float64(channel.GetWeight())
+		if allZero {
+			weight = 1
+		}
+		weights[i] = weight * factors[i]
+		total += weights[i]
+	}
+	if total <= 0 {
+		return channels[rand.Intn(len(channels))]
+	}
+	randomWeight := rand.Float64() * total
+	for i, channel := range channels {
+		randomWeight -= weights[i]
+		if randomWeight < 0 {
+			return channel
+		}
+	}
+	return channels[len(channels)-1]
+}
+
+// GetChannelStatsSnapshots 返回全部渠道的表现统计，Score 在同一模型的渠道之间计算
+func GetChannelStatsSnapshots() []*ChannelStatsSnapshot {
+	byModel := make(map[string][]*ChannelStatsSnapshot)
+	channelStatsMap.Range(func(k, v any) bool {
+		key := k.(channelStatsKey)
+		if s := v.(*channelStats).snapshot(key); s != nil {
+			byModel[key.Model] = append(byModel[key.Model], s)
+		}
+		return true
+	})
+	result := make([]*ChannelStatsSnapshot, 0)
+	for _, snapshots := range byModel {
+		for i, factor := range scoreChannelStats(snapshots) {
+			snapshots[i].Score = factor
+		}
+		result = append(result, snapshots...)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Model != result[j].Model {
+			return result[i].Model < result[j].Model
+		}
+		return result[i].ChannelId < result[j].ChannelId
+	})
+	return result
+}
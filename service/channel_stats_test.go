@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+)
+
+func TestAdaptiveChannelScore(t *testing.T) {
+	const modelName = "adaptive-test-model"
+	for i := 0; i < 10; i++ {
+		RecordChannelResult(1, modelName, 100*time.Millisecond, 0, true)
+		RecordChannelResult(2, modelName, 400*time.Millisecond, 0, true)
+		RecordChannelResult(3, modelName, 100*time.Millisecond, 0, i%2 == 0)
+	}
+	RecordChannelResult(4, modelName, time.Second, 0, true)
+
+	snapshots := []*ChannelStatsSnapshot{
+		getChannelStatsSnapshot(1, modelName),
+		getChannelStatsSnapshot(2, modelName),
+		getChannelStatsSnapshot(3, modelName),
+		getChannelStatsSnapshot(4, modelName),
+		getChannelStatsSnapshot(5, modelName),
+	}
+	if snapshots[0].LatencyMs != 100 || snapshots[0].ErrorRate != 0 {
+		t.Fatalf("unexpected stats: %+v", snapshots[0])
+	}
+	factors := scoreChannelStats(snapshots)
+	if factors[0] != 1 {
+		t.Errorf("fastest channel factor = %v, want 1", factors[0])
+	}
+	if factors[1] != 0.25 {
+		t.Errorf("slow channel factor = %v, want 0.25", factors[1])
+	}
+	if factors[2] >= factors[0] {
+		t.Errorf("failing channel factor %v should be below %v", factors[2], factors[0])
+	}
+	if factors[3] != 1 || factors[4] != 1 {
+		t.Errorf("channels without enough samples should keep factor 1, got %v %v", factors[3], factors[4])
+	}
+
+	channels := []*model.Channel{{Id: 1}, {Id: 2}}
+	picked := map[int]int{}
+	for i := 0; i < 2000; i++ {
+		picked[pickAdaptiveChannel(channels, modelName).Id]++
+	}
+	if picked[1] <= picked[2]*2 {
+		t.Errorf("fast channel should be preferred, got %v", picked)
+	}
+}
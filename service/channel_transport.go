@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+var (
+	profileClientLock sync.Mutex
+	// profileClients 按渠道缓存的独立客户端，同一渠道按代理和设置内容区分
+	profileClients = make(map[int]map[string]*http.Client)
+)
+
+// GetChannelHttpClient 获取渠道使用的 HTTP 客户端，配置了连接设置时按渠道、代理和设置内容缓存独立的客户端
+func GetChannelHttpClient(channelId int, proxyURL string, transport *dto.ChannelTransportSettings) (*http.Client, error) {
+	if !transport.HasClientProfile() {
+		return GetHttpClientWithProxy(proxyURL)
+	}
+
+	key, err := transportProfileKey(proxyURL, transport)
+	if err != nil {
+		return nil, err
+	}
+	profileClientLock.Lock()
+	if client, ok := profileClients[channelId][key]; ok {
+		profileClientLock.Unlock()
+		return client, nil
+	}
+	profileClientLock.Unlock()
+
+	base, err := NewProxyHttpClient(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newProfileHttpClient(base, transport)
+	if err != nil {
+		return nil, err
+	}
+	profileClientLock.Lock()
+	defer profileClientLock.Unlock()
+	if existing, ok := profileClients[channelId][key]; ok {
+		client.CloseIdleConnections()
+		return existing, nil
+	}
+	if profileClients[channelId] == nil {
+		profileClients[channelId] = make(map[string]*http.Client)
+	}
+	profileClients[channelId][key] = client
+	return client, nil
+}
+
+// ValidateChannelTransport 校验渠道连接设置，证书无法解析时返回错误
+func ValidateChannelTransport(transport *dto.ChannelTransportSettings) error {
+	if transport == nil {
+		return nil
+	}
+	if transport.ConnectTimeoutSeconds < 0 || transport.ResponseHeaderTimeoutSeconds < 0 ||
+		transport.IdleStreamTimeoutSeconds < 0 || transport.MaxIdleConns < 0 {
+		return errors.New("timeout and max idle conns must not be negative")
+	}
+	_, err := buildTransportTLSConfig(nil, transport)
+	return err
+}
+
+// EvictChannelHttpClient 移除渠道缓存的独立客户端，渠道更新或删除后调用
+func EvictChannelHttpClient(channelId int) {
+	profileClientLock.Lock()
+	clients := profileClients[channelId]
+	delete(profileClients, channelId)
+	profileClientLock.Unlock()
+	for _, client := range clients {
+		client.CloseIdleConnections()
+	}
+}
+
+func transportProfileKey(proxyURL string, transport *dto.ChannelTransportSettings) (string, error) {
+	data, err := common.Marshal(transport)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(proxyURL+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// newProfileHttpClient 在代理客户端的基础上应用渠道连接设置
+func newProfileHttpClient(base *http.Client, settings *dto.ChannelTransportSettings) (*http.Client, error) {
+	baseTransport, ok := base.Transport.(*http.Transport)
+	if !ok || baseTransport == nil {
+		baseTransport = http.DefaultTransport.(*http.Transport)
+	}
+	transport := baseTransport.Clone()
+
+	if settings.ConnectTimeoutSeconds > 0 {
+		connectTimeout := time.Duration(settings.ConnectTimeoutSeconds) * time.Second
+		if dialContext := transport.DialContext; dialContext != nil {
+			// SOCKS5 代理已有自定义拨号器，通过上下文限制拨号时间
+			transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+				defer cancel()
+				return dialContext(ctx, network, addr)
+			}
+		} else {
+			transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		}
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+	if settings.ResponseHeaderTimeoutSeconds > 0 {
+		transport.ResponseHeaderTimeout = time.Duration(settings.ResponseHeaderTimeoutSeconds) * time.Second
+	}
+	if settings.MaxIdleConns > 0 {
+		transport.MaxIdleConns = settings.MaxIdleConns
+		transport.MaxIdleConnsPerHost = settings.MaxIdleConns
+	}
+	if settings.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	tlsConfig, err := buildTransportTLSConfig(transport.TLSClientConfig, settings)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       base.Timeout,
+		CheckRedirect: checkRedirect,
+	}, nil
+}
+
+func buildTransportTLSConfig(base *tls.Config, settings *dto.ChannelTransportSettings) (*tls.Config, error) {
+	if settings.CACert == "" && settings.ClientCert == "" && settings.ClientKey == "" {
+		return base, nil
+	}
+	var tlsConfig *tls.Config
+	if base != nil {
+		tlsConfig = base.Clone()
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+	if settings.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(settings.CACert)) {
+			return nil, errors.New("invalid ca certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if settings.ClientCert != "" || settings.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(settings.ClientCert), []byte(settings.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func TestGetChannelHttpClientWithCustomCA(t *testing.T) {
+	InitHttpClient()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	defer EvictChannelHttpClient(1)
+
+	// 全局客户端不信任测试服务器的证书
+	if resp, err := GetHttpClient().Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected certificate error with the global client")
+	}
+
+	caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	transport := &dto.ChannelTransportSettings{CACert: caCert, DisableHTTP2: true, ResponseHeaderTimeoutSeconds: 5}
+	if err := ValidateChannelTransport(transport); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	client, err := GetChannelHttpClient(1, "", transport)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with custom ca failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+
+	// 相同设置复用同一个客户端
+	cached, _ := GetChannelHttpClient(1, "", &dto.ChannelTransportSettings{CACert: caCert, DisableHTTP2: true, ResponseHeaderTimeoutSeconds: 5})
+	if cached != client {
+		t.Fatal("expected cached client for the same profile")
+	}
+	if other, _ := GetChannelHttpClient(1, "", &dto.ChannelTransportSettings{CACert: caCert}); other == client {
+		t.Fatal("expected a different client for a different profile")
+	}
+	EvictChannelHttpClient(1)
+	if evicted, _ := GetChannelHttpClient(1, "", transport); evicted == client {
+		t.Fatal("expected a new client after the channel is evicted")
+	}
+
+	if err := ValidateChannelTransport(&dto.ChannelTransportSettings{CACert: "invalid"}); err == nil {
+		t.Fatal("expected error for invalid ca certificate")
+	}
+	if err := ValidateChannelTransport(&dto.ChannelTransportSettings{ClientCert: caCert}); err == nil {
+		t.Fatal("expected error for client certificate without key")
+	}
+}
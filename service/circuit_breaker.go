@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/samber/hot"
+)
+
+const (
+	CircuitStateClosed   = "closed"
+	CircuitStateOpen     = "open"
+	CircuitStateHalfOpen = "half_open"
+
+	circuitBreakerNamespace = "new-api:circuit_breaker:v1"
+)
+
+// CircuitBreakerState 熔断器状态，启用 Redis 时在所有节点间共享
+type CircuitBreakerState struct {
+	State     string `json:"state"`
+	Failures  int    `json:"failures"`
+	Successes int    `json:"successes"`
+	Trips     int    `json:"trips"`
+	OpenUntil int64  `json:"open_until"`
+}
+
+var (
+	circuitBreakerCacheOnce sync.Once
+	circuitBreakerCache     *cachex.HybridCache[CircuitBreakerState]
+	// 未启用 Redis 时保护进程内的状态更新，启用 Redis 时由 Lua 脚本保证原子性
+	circuitBreakerLocks [64]sync.Mutex
+)
+
+func getCircuitBreakerCache() *cachex.HybridCache[CircuitBreakerState] {
+	circuitBreakerCacheOnce.Do(func() {
+		circuitBreakerCache = cachex.NewHybridCache[CircuitBreakerState](cachex.HybridCacheConfig[CircuitBreakerState]{
+			Namespace: cachex.Namespace(circuitBreakerNamespace),
+			Redis:     common.RDB,
+			RedisEnabled: func() bool {
+				return common.RedisEnabled && common.RDB != nil
+			},
+			RedisCodec: cachex.JSONCodec[CircuitBreakerState]{},
+			Memory: func() *hot.HotCache[string, CircuitBreakerState] {
+				return hot.NewHotCache[string, CircuitBreakerState](hot.LRU, 100_000).
+					WithTTL(circuitBreakerTTL()).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+	return circuitBreakerCache
+}
+
+func circuitBreakerLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return &circuitBreakerLocks[h.Sum32()%uint32(len(circuitBreakerLocks))]
+}
+
+// circuitBreakerTTL 状态保留时间，长时间无请求的熔断器自然过期回到关闭状态
+func circuitBreakerTTL() time.Duration {
+	return time.Duration(common.Max(operation_setting.GetCircuitBreakerSetting().MaxCooldownSeconds, 600)*2) * time.Second
+}
+
+func channelCircuitKey(channelId int) string {
+	return fmt.Sprintf("channel:%d", channelId)
+}
+
+func keyCircuitKey(channelId int, keyIndex int) string {
+	return fmt.Sprintf("key:%d:%d", channelId, keyIndex)
+}
+
+// effectiveState 冷却时间结束的熔断器视为半开
+func (s CircuitBreakerState) effectiveState(now int64) string {
+	if s.State == CircuitStateOpen && now >= s.OpenUntil {
+		return CircuitStateHalfOpen
+	}
+	if s.State == "" {
+		return CircuitStateClosed
+	}
+	return s.State
+}
+
+func getCircuitState(key string) CircuitBreakerState {
+	state, found, err := getCircuitBreakerCache().Get(key)
+	if err != nil || !found {
+		return CircuitBreakerState{State: CircuitStateClosed}
+	}
+	return state
+}
+
+// getChannelCircuitStates 批量读取渠道熔断器状态，启用 Redis 时只发起一次 MGET
+func getChannelCircuitStates(channels []*model.Channel) map[int]CircuitBreakerState {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled || len(channels) == 0 {
+		return nil
+	}
+	states := make(map[int]CircuitBreakerState, len(channels))
+	cache := getCircuitBreakerCache()
+	if !common.RedisEnabled || common.RDB == nil {
+		for _, channel := range channels {
+			states[channel.Id] = getCircuitState(channelCircuitKey(channel.Id))
+		}
+		return states
+	}
+	keys := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		keys = append(keys, cache.FullKey(channelCircuitKey(channel.Id)))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	values, err := common.RDB.MGet(ctx, keys...).Result()
+	if err != nil {
+		return states
+	}
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var state CircuitBreakerState
+		if err := common.UnmarshalJsonStr(raw, &state); err == nil {
+			states[channels[i].Id] = state
+		}
+	}
+	return states
+}
+
+func circuitAllowed(key string) bool {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return true
+	}
+	return circuitStateAllowed(getCircuitState(key))
+}
+
+// circuitStateAllowed 按熔断器状态判断是否放行，半开状态下按比例放行探测流量
+func circuitStateAllowed(state CircuitBreakerState) bool {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return true
+	}
+	switch state.effectiveState(time.Now().Unix()) {
+	case CircuitStateOpen:
+		return false
+	case CircuitStateHalfOpen:
+		return rand.Float64() < operation_setting.GetCircuitBreakerSetting().HalfOpenProbeRatio
+	}
+	return true
+}
+
+// IsChannelCircuitAllowed 渠道熔断器是否放行本次请求，半开状态下按比例放行探测流量
+func IsChannelCircuitAllowed(channelId int) bool {
+	return circuitAllowed(channelCircuitKey(channelId))
+}
+
+// IsKeyCircuitAllowed 多 Key 渠道中指定 Key 的熔断器是否放行本次请求
+func IsKeyCircuitAllowed(channelId int, keyIndex int) bool {
+	return circuitAllowed(keyCircuitKey(channelId, keyIndex))
+}
+
+// circuitCooldown 第 trips+1 次熔断的冷却时间，每次熔断翻倍，不超过最大冷却时间
+func circuitCooldown(trips int) int {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	cooldown := common.Max(setting.CooldownSeconds, 1)
+	for i := 1; i < trips+1 && cooldown < setting.MaxCooldownSeconds; i++ {
+		cooldown *= 2
+	}
+	if setting.MaxCooldownSeconds > 0 && cooldown > setting.MaxCooldownSeconds {
+		cooldown = setting.MaxCooldownSeconds
+	}
+	return cooldown
+}
+
+// applyCircuitResult 按请求结果迁移熔断器状态，返回发生的事件和熔断冷却时间；
+// 启用 Redis 时由 limiter.UpdateCircuitBreaker 在 Redis 中完成相同的迁移
+func applyCircuitResult(state *CircuitBreakerState, now int64, success bool, force bool) (int, int) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	trip := func() int {
+		cooldown := circuitCooldown(state.Trips)
+		state.State = CircuitStateOpen
+		state.Trips++
+		state.Failures = 0
+		state.Successes = 0
+		state.OpenUntil = now + int64(cooldown)
+		return cooldown
+	}
+	switch state.effectiveState(now) {
+	case CircuitStateClosed:
+		if success {
+			state.Failures = 0
+			return limiter.CircuitEventNone, 0
+		}
+		state.Failures++
+		if force || state.Failures >= common.Max(setting.FailureThreshold, 1) {
+			return limiter.CircuitEventOpened, trip()
+		}
+	case CircuitStateHalfOpen:
+		if success {
+			state.Successes++
+			if state.Successes >= common.Max(setting.HalfOpenSuccessThreshold, 1) {
+				*state = CircuitBreakerState{State: CircuitStateClosed}
+				return limiter.CircuitEventClosed, 0
+			}
+			// 记录为半开，避免下次读取时仍按冷却结束时间推算
+			state.State = CircuitStateHalfOpen
+		} else {
+			return limiter.CircuitEventOpened, trip()
+		}
+	}
+	return limiter.CircuitEventNone, 0
+}
+
+func updateCircuit(key string, success bool, force bool, reason string) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	cache := getCircuitBreakerCache()
+	now := time.Now().Unix()
+
+	var event, cooldown int
+	if common.RedisEnabled && common.RDB != nil {
+		var err error
+		event, cooldown, err = limiter.UpdateCircuitBreaker(context.Background(), common.RDB, cache.FullKey(key), limiter.CircuitBreakerParams{
+			Success:                  success,
+			Force:                    force,
+			Now:                      now,
+			FailureThreshold:         common.Max(setting.FailureThreshold, 1),
+			HalfOpenSuccessThreshold: common.Max(setting.HalfOpenSuccessThreshold, 1),
+			CooldownSeconds:          common.Max(setting.CooldownSeconds, 1),
+			MaxCooldownSeconds:       setting.MaxCooldownSeconds,
+			TTLSeconds:               int64(circuitBreakerTTL() / time.Second),
+		})
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to update circuit breaker %s: %s", key, err.Error()))
+			return
+		}
+	} else {
+		lock := circuitBreakerLock(key)
+		lock.Lock()
+		state := getCircuitState(key)
+		before := state
+		event, cooldown = applyCircuitResult(&state, now, success, force)
+		if state != before {
+			_ = cache.SetWithTTL(key, state, circuitBreakerTTL())
+		}
+		lock.Unlock()
+	}
+	switch event {
+	case limiter.CircuitEventOpened:
+		common.SysLog(fmt.Sprintf("circuit breaker %s opened for %ds, reason: %s", key, cooldown, reason))
+	case limiter.CircuitEventClosed:
+		common.SysLog(fmt.Sprintf("circuit breaker %s closed", key))
+	}
+}
+
+// RecordCircuitResult 记录一次请求结果，多 Key 渠道同时更新对应 Key 的熔断器
+func RecordCircuitResult(channelId int, keyIndex int, isMultiKey bool, success bool, reason string) {
+	if !operation_setting.GetCircuitBreakerSetting().Enabled || channelId == 0 {
+		return
+	}
+	updateCircuit(channelCircuitKey(channelId), success, false, reason)
+	if isMultiKey {
+		updateCircuit(keyCircuitKey(channelId, keyIndex), success, false, reason)
+	}
+}
+
+// ShouldCircuitReplaceAutoBan 是否以熔断代替自动禁用渠道
+func ShouldCircuitReplaceAutoBan() bool {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	return setting.Enabled && setting.ReplaceAutoBan
+}
+
+// TripCircuit 立即熔断，多 Key 渠道只熔断出错的 Key
+func TripCircuit(channelId int, keyIndex int, isMultiKey bool, reason string) {
+	if isMultiKey {
+		updateCircuit(keyCircuitKey(channelId, keyIndex), false, true, reason)
+		return
+	}
+	updateCircuit(channelCircuitKey(channelId), false, true, reason)
+}
+
+// GetNextCircuitAllowedKey 获取下一个可用 Key，跳过熔断中的 Key；全部熔断时使用轮询得到的第一个 Key
+func GetNextCircuitAllowedKey(channel *model.Channel) (string, int, *types.NewAPIError) {
+	key, index, newAPIError := channel.GetNextEnabledKey()
+	if newAPIError != nil {
+		return "", 0, newAPIError
+	}
+	if !channel.ChannelInfo.IsMultiKey || !operation_setting.GetCircuitBreakerSetting().Enabled {
+		return key, index, nil
+	}
+	firstKey, firstIndex := key, index
+	for i := 0; i < channel.ChannelInfo.MultiKeySize; i++ {
+		if IsKeyCircuitAllowed(channel.Id, index) {
+			return key, index, nil
+		}
+		key, index, newAPIError = channel.GetNextEnabledKey()
+		if newAPIError != nil {
+			break
+		}
+	}
+	return firstKey, firstIndex, nil
+}
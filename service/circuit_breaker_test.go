@@ -0,0 +1,64 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	original := *setting
+	defer func() { *setting = original }()
+	setting.Enabled = true
+	setting.FailureThreshold = 3
+	setting.CooldownSeconds = 60
+	setting.HalfOpenProbeRatio = 1
+	setting.HalfOpenSuccessThreshold = 2
+
+	const channelId = 900001
+	key := channelCircuitKey(channelId)
+	for i := 0; i < 2; i++ {
+		RecordCircuitResult(channelId, 0, false, false, "upstream error")
+	}
+	RecordCircuitResult(channelId, 0, false, true, "")
+	RecordCircuitResult(channelId, 0, false, false, "upstream error")
+	if !IsChannelCircuitAllowed(channelId) {
+		t.Fatal("success should reset consecutive failures")
+	}
+	for i := 0; i < 2; i++ {
+		RecordCircuitResult(channelId, 0, false, false, "upstream error")
+	}
+	if IsChannelCircuitAllowed(channelId) {
+		t.Fatal("circuit should be open after consecutive failures")
+	}
+
+	// 模拟冷却结束，进入半开状态
+	state := getCircuitState(key)
+	state.OpenUntil = 0
+	_ = getCircuitBreakerCache().SetWithTTL(key, state, circuitBreakerTTL())
+	if !IsChannelCircuitAllowed(channelId) {
+		t.Fatal("half open circuit should allow probes")
+	}
+	RecordCircuitResult(channelId, 0, false, false, "probe failed")
+	state = getCircuitState(key)
+	if state.State != CircuitStateOpen || state.Trips != 2 {
+		t.Fatalf("failed probe should reopen the circuit, got %+v", state)
+	}
+
+	state.OpenUntil = 0
+	_ = getCircuitBreakerCache().SetWithTTL(key, state, circuitBreakerTTL())
+	RecordCircuitResult(channelId, 0, false, true, "")
+	if getCircuitState(key).effectiveState(0) != CircuitStateHalfOpen {
+		t.Fatal("circuit should stay half open until enough probes succeed")
+	}
+	RecordCircuitResult(channelId, 0, false, true, "")
+	if getCircuitState(key).State != CircuitStateClosed {
+		t.Fatal("circuit should close after successful probes")
+	}
+
+	TripCircuit(channelId, 2, true, "invalid api key")
+	if IsKeyCircuitAllowed(channelId, 2) || !IsKeyCircuitAllowed(channelId, 1) || !IsChannelCircuitAllowed(channelId) {
+		t.Fatal("multi key trip should only open the failing key")
+	}
+}
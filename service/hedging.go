@@ -0,0 +1,28 @@
+package service
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetHedgeDelay 返回令牌的对冲延迟，未启用对冲时返回 0
+func GetHedgeDelay(c *gin.Context) time.Duration {
+	setting := operation_setting.GetHedgingSetting()
+	delayMs := common.GetContextKeyInt(c, constant.ContextKeyTokenHedgeDelayMs)
+	if !setting.Enabled || delayMs <= 0 {
+		return 0
+	}
+	// 指定渠道或固定到文件所在渠道的请求不对冲
+	if _, ok := c.Get(string(constant.ContextKeyTokenSpecificChannelId)); ok {
+		return 0
+	}
+	if common.GetContextKeyInt(c, constant.ContextKeyFileChannelId) > 0 {
+		return 0
+	}
+	return time.Duration(max(delayMs, setting.MinDelayMs)) * time.Millisecond
+}
@@ -42,6 +42,9 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 	other["model_price"] = modelPrice
 	other["user_group_ratio"] = userGroupRatio
 	other["frt"] = float64(relayInfo.FirstResponseTime.UnixMilli() - relayInfo.StartTime.UnixMilli())
+	if ttft := relayInfo.GetFirstTokenLatency(); relayInfo.IsStream && ttft > 0 {
+		other["ttft"] = ttft.Milliseconds()
+	}
 	if relayInfo.ReasoningEffort != "" {
 		other["reasoning_effort"] = relayInfo.ReasoningEffort
 	}
@@ -50,6 +53,14 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		other["upstream_model_name"] = relayInfo.UpstreamModelName
 	}
 
+	if virtualModel := common.GetContextKeyString(ctx, constant.ContextKeyVirtualModel); virtualModel != "" {
+		other["virtual_model"] = virtualModel
+	}
+	if fallbackFrom := common.GetContextKeyString(ctx, constant.ContextKeyModelFallbackFrom); fallbackFrom != "" {
+		other["model_fallback_from"] = fallbackFrom
+		other["served_model"] = relayInfo.OriginModelName
+	}
+
 	isSystemPromptOverwritten := common.GetContextKeyBool(ctx, constant.ContextKeySystemPromptOverride)
 	if isSystemPromptOverwritten {
 		other["is_system_prompt_overwritten"] = true
@@ -68,6 +79,14 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 		adminInfo["local_count_tokens"] = isLocalCountTokens
 	}
 
+	if hedgeChannels, ok := common.GetContextKeyType[[]int](ctx, constant.ContextKeyHedgeChannels); ok {
+		adminInfo["hedge_channels"] = hedgeChannels
+	}
+
+	if poolProxy := common.GetContextKeyString(ctx, constant.ContextKeyChannelPoolProxy); poolProxy != "" {
+		adminInfo["proxy"] = RedactProxyURL(poolProxy)
+	}
+
 	AppendChannelAffinityAdminInfo(ctx, adminInfo)
 
 	other["admin_info"] = adminInfo
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServedModelHeader 发生模型回退时返回实际提供服务的模型
+const ServedModelHeader = "X-New-Api-Served-Model"
+
+// GetModelFallbackChain 获取模型的回退链，令牌配置优先于分组和全局配置
+func GetModelFallbackChain(c *gin.Context, modelName string) []string {
+	setting := operation_setting.GetModelFallbackSetting()
+	if !setting.Enabled {
+		return nil
+	}
+	if fallbacks, ok := common.GetContextKeyType[map[string][]string](c, constant.ContextKeyTokenModelFallbacks); ok {
+		if chain, ok := fallbacks[modelName]; ok {
+			return chain
+		}
+	}
+	return setting.GetChain(common.GetContextKeyString(c, constant.ContextKeyUsingGroup), modelName)
+}
+
+// IsModelAllowedForToken 令牌是否允许使用该模型
+func IsModelAllowedForToken(c *gin.Context, modelName string) bool {
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		return true
+	}
+	tokenModelLimits, _ := common.GetContextKeyType[*model.TokenModelLimits](c, constant.ContextKeyTokenModelLimit)
+	return tokenModelLimits.IsAllowed(modelName)
+}
+
+// GetFallbackModels 返回当前模型之后可回退的模型，跳过令牌不允许使用的模型
+func GetFallbackModels(c *gin.Context, currentModel string) []string {
+	// 指定渠道或固定到文件所在渠道的请求不回退
+	if _, ok := c.Get(string(constant.ContextKeyTokenSpecificChannelId)); ok {
+		return nil
+	}
+	if common.GetContextKeyInt(c, constant.ContextKeyFileChannelId) > 0 {
+		return nil
+	}
+	requestedModel := common.GetContextKeyString(c, constant.ContextKeyModelFallbackFrom)
+	if requestedModel == "" {
+		requestedModel = currentModel
+	}
+	chain := GetModelFallbackChain(c, requestedModel)
+	start := 0
+	for i, m := range chain {
+		if m == currentModel {
+			start = i + 1
+		}
+	}
+	models := make([]string, 0, len(chain))
+	for _, m := range chain[start:] {
+		if m != "" && m != requestedModel && IsModelAllowedForToken(c, m) {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// ShouldFallbackModel 渠道不可用或上游故障时才回退模型，请求本身的错误不回退
+func ShouldFallbackModel(err *types.NewAPIError) bool {
+	if err == nil {
+		return false
+	}
+	return err.GetErrorCode() == types.ErrorCodeGetChannelFailed || IsChannelFaultError(err)
+}
+
+// ResetAutoGroupSelection 切换模型后 auto 分组重新从第一个分组开始选择
+func ResetAutoGroupSelection(c *gin.Context) {
+	common.SetContextKey(c, constant.ContextKeyAutoGroupIndex, 0)
+	common.SetContextKey(c, constant.ContextKeyAutoGroupRetryIndex, 0)
+}
+
+// MarkModelFallback 记录发生了模型回退，并通过响应头返回实际使用的模型
+func MarkModelFallback(c *gin.Context, requestedModel string, servedModel string) {
+	if common.GetContextKeyString(c, constant.ContextKeyModelFallbackFrom) == "" {
+		common.SetContextKey(c, constant.ContextKeyModelFallbackFrom, requestedModel)
+	}
+	c.Header(ServedModelHeader, servedModel)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetFallbackModels(t *testing.T) {
+	setting := operation_setting.GetModelFallbackSetting()
+	saved := *setting
+	defer func() { *setting = saved }()
+	setting.Enabled = true
+	setting.Chains = map[string][]string{"gpt-4o": {"gpt-4.1", "claude-sonnet"}}
+	setting.GroupChains = map[string]map[string][]string{"vip": {"gpt-4o": {"o3"}}}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, "default")
+
+	if got := GetFallbackModels(c, "gpt-4o"); !reflect.DeepEqual(got, []string{"gpt-4.1", "claude-sonnet"}) {
+		t.Fatalf("unexpected chain: %v", got)
+	}
+
+	// 回退后从当前模型之后继续
+	MarkModelFallback(c, "gpt-4o", "gpt-4.1")
+	if got := GetFallbackModels(c, "gpt-4.1"); !reflect.DeepEqual(got, []string{"claude-sonnet"}) {
+		t.Fatalf("unexpected remaining chain: %v", got)
+	}
+	if got := c.Writer.Header().Get(ServedModelHeader); got != "gpt-4.1" {
+		t.Fatalf("unexpected served model header: %q", got)
+	}
+
+	// 令牌模型限制过滤，令牌回退链优先于分组回退链
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	common.SetContextKey(c, constant.ContextKeyUsingGroup, "vip")
+	if got := GetFallbackModels(c, "gpt-4o"); !reflect.DeepEqual(got, []string{"o3"}) {
+		t.Fatalf("unexpected group chain: %v", got)
+	}
+	common.SetContextKey(c, constant.ContextKeyTokenModelFallbacks, map[string][]string{"gpt-4o": {"gpt-4o-mini", "gpt-4.1"}})
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimitEnabled, true)
+	tokenModelLimits, _ := model.ParseTokenModelLimits("gpt-4o,gpt-4.1", true)
+	common.SetContextKey(c, constant.ContextKeyTokenModelLimit, tokenModelLimits)
+	if got := GetFallbackModels(c, "gpt-4o"); !reflect.DeepEqual(got, []string{"gpt-4.1"}) {
+		t.Fatalf("unexpected token chain: %v", got)
+	}
+
+	setting.Enabled = false
+	if got := GetFallbackModels(c, "gpt-4o"); len(got) != 0 {
+		t.Fatalf("fallback should be disabled: %v", got)
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const modelRateLimitRedisPrefix = "new-api:model_rate_limit:v1"
+
+// modelRateLimitCounter 一条规则在当前时间窗口内按用户或全局的请求计数
+type modelRateLimitCounter struct {
+	limiter.TokenCounter
+	scope    string
+	duration int64
+	resetAt  int64
+}
+
+func matchModelRateLimitRule(rule *operation_setting.ModelRateLimitRule, modelName string, group string) bool {
+	if len(rule.Groups) > 0 && !common.StringsContains(rule.Groups, group) {
+		return false
+	}
+	if rule.Model != "" && rule.Model == modelName {
+		return true
+	}
+	re := rule.GetModelRegex()
+	return re != nil && re.MatchString(modelName)
+}
+
+// modelRateLimitRuleKey 按规则内容生成计数键，调整规则顺序不影响已有计数
+func modelRateLimitRuleKey(rule *operation_setting.ModelRateLimitRule) string {
+	data, _ := common.Marshal(rule)
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func buildModelRateLimitCounters(relayInfo *relaycommon.RelayInfo) []modelRateLimitCounter {
+	setting := operation_setting.GetModelRateLimitSetting()
+	if !setting.Enabled {
+		return nil
+	}
+	group := relayInfo.UsingGroup
+	if group == "" {
+		group = relayInfo.TokenGroup
+	}
+	now := time.Now().Unix()
+	var counters []modelRateLimitCounter
+	for i := range setting.Rules {
+		rule := &setting.Rules[i]
+		if !matchModelRateLimitRule(rule, relayInfo.OriginModelName, group) {
+			continue
+		}
+		duration := rule.GetDurationSeconds()
+		window := now / duration
+		ruleKey := modelRateLimitRuleKey(rule)
+		add := func(scope string, subject string, limit int) {
+			if limit <= 0 {
+				return
+			}
+			counters = append(counters, modelRateLimitCounter{
+				TokenCounter: limiter.TokenCounter{Key: fmt.Sprintf("%s:%s:%s:%d", modelRateLimitRedisPrefix, ruleKey, subject, window), Limit: int64(limit), TTLSeconds: duration * 2},
+				scope:        scope,
+				duration:     duration,
+				resetAt:      (window + 1) * duration,
+			})
+		}
+		add("用户", fmt.Sprintf("user:%d", relayInfo.UserId), rule.UserLimit)
+		add("全局", "global", rule.GlobalLimit)
+	}
+	return counters
+}
+
+// CheckModelRateLimit 按模型限流规则计数本次请求，超限时设置 x-ratelimit-* 和 Retry-After 响应头
+func CheckModelRateLimit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *types.NewAPIError {
+	counters := buildModelRateLimitCounters(relayInfo)
+	if len(counters) == 0 {
+		return nil
+	}
+	tokenCounters := make([]limiter.TokenCounter, 0, len(counters))
+	for _, counter := range counters {
+		tokenCounters = append(tokenCounters, counter.TokenCounter)
+	}
+	exceeded, err := reserveCounters(c.Request.Context(), tokenCounters, 1)
+	if err != nil {
+		common.SysError("failed to check model rate limit: " + err.Error())
+		return nil
+	}
+	if exceeded < 0 {
+		return nil
+	}
+	counter := counters[exceeded]
+	retryAfter := max(counter.resetAt-time.Now().Unix(), 1)
+	c.Header("x-ratelimit-limit-requests", strconv.FormatInt(counter.Limit, 10))
+	c.Header("x-ratelimit-remaining-requests", "0")
+	c.Header("x-ratelimit-reset-requests", fmt.Sprintf("%ds", retryAfter))
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	return types.NewErrorWithStatusCode(fmt.Errorf("模型 %s 已达到%s请求数限制：%d 次/%d 秒", relayInfo.OriginModelName, counter.scope, counter.Limit, counter.duration),
+		types.ErrorCodeRateLimitExceeded, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+}
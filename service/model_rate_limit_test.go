@@ -0,0 +1,70 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestModelRateLimit(t *testing.T) {
+	setting := operation_setting.GetModelRateLimitSetting()
+	saved := *setting
+	defer func() { *setting = saved }()
+	setting.Enabled = true
+	setting.Rules = []operation_setting.ModelRateLimitRule{
+		{ModelRegex: "^o1-pro", DurationSeconds: 3600, UserLimit: 2, GlobalLimit: 3},
+		{Model: "gpt-4o", Groups: []string{"vip"}, DurationSeconds: 3600, UserLimit: 1},
+	}
+	operation_setting.CompileModelRateLimitRules()
+
+	check := func(userId int, modelName string, group string) (*httptest.ResponseRecorder, bool) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		info := &relaycommon.RelayInfo{UserId: userId, OriginModelName: modelName, UsingGroup: group}
+		return recorder, CheckModelRateLimit(c, info) == nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := check(900301, "o1-pro-2025", "default"); !ok {
+			t.Fatalf("request %d should pass", i)
+		}
+	}
+	recorder, ok := check(900301, "o1-pro", "default")
+	if ok {
+		t.Fatal("expected user limit to be exceeded")
+	}
+	if recorder.Header().Get("x-ratelimit-limit-requests") != "2" || recorder.Header().Get("Retry-After") == "" {
+		t.Fatalf("unexpected headers: %v", recorder.Header())
+	}
+	if _, ok := check(900302, "o1-pro", "default"); !ok {
+		t.Fatal("another user should pass")
+	}
+	if _, ok := check(900303, "o1-pro", "default"); ok {
+		t.Fatal("expected global limit to be exceeded")
+	}
+
+	// 限定分组的规则只对该分组生效
+	for i := 0; i < 2; i++ {
+		if _, ok := check(900301, "gpt-4o", "default"); !ok {
+			t.Fatal("rule of other group should not apply")
+		}
+	}
+	check(900301, "gpt-4o", "vip")
+	if _, ok := check(900301, "gpt-4o", "vip"); ok {
+		t.Fatal("expected group rule to apply")
+	}
+}
+
+func TestValidateModelRateLimitRules(t *testing.T) {
+	if err := operation_setting.ValidateModelRateLimitRules(`[{"model_regex":"^o1-(pro|mini)","user_limit":1}]`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := operation_setting.ValidateModelRateLimitRules(`[{"model":"gpt-4o"},{"model_regex":"o1-(","user_limit":1}]`); err == nil {
+		t.Fatal("expected invalid regex to be rejected")
+	}
+}
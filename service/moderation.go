@@ -0,0 +1,139 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// moderationRegexCache 缓存已编译的审核正则，编译失败的规则缓存为 nil
+var moderationRegexCache sync.Map
+
+func getModerationRegexp(pattern string) *regexp.Regexp {
+	if v, ok := moderationRegexCache.Load(pattern); ok {
+		re, _ := v.(*regexp.Regexp)
+		return re
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		common.SysError(fmt.Sprintf("invalid moderation pattern %q: %s", pattern, err.Error()))
+		re = nil
+	}
+	moderationRegexCache.Store(pattern, re)
+	return re
+}
+
+func nonEmptyWords(words []string) []string {
+	result := make([]string, 0, len(words))
+	for _, w := range words {
+		if strings.TrimSpace(w) != "" {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+// LocalModerate 使用本地规则审核文本，返回命中的类别及命中内容
+func LocalModerate(text string) map[string][]string {
+	if text == "" {
+		return nil
+	}
+	moderationSetting := operation_setting.GetModerationSetting()
+	checkText := strings.ToLower(text)
+	hits := make(map[string][]string)
+	if category := moderationSetting.SensitiveWordsCategory; category != "" {
+		if ok, words := AcSearch(checkText, setting.SensitiveWords, false); ok {
+			hits[category] = append(hits[category], words...)
+		}
+	}
+	for category, rule := range moderationSetting.Rules {
+		if ok, words := AcSearch(checkText, nonEmptyWords(rule.Words), false); ok {
+			hits[category] = append(hits[category], words...)
+		}
+		for _, pattern := range rule.Patterns {
+			re := getModerationRegexp(pattern)
+			if re == nil {
+				continue
+			}
+			if match := re.FindString(text); match != "" {
+				hits[category] = append(hits[category], match)
+			}
+		}
+	}
+	for category, words := range hits {
+		hits[category] = RemoveDuplicate(words)
+	}
+	return hits
+}
+
+// CheckModerationText 本地审核预检，返回是否违规及违规类别
+func CheckModerationText(text string) (bool, []string) {
+	hits := LocalModerate(text)
+	if len(hits) == 0 {
+		return false, nil
+	}
+	categories := make([]string, 0, len(hits))
+	for category := range hits {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return true, categories
+}
+
+// BuildLocalModerationResponse 对每条输入进行本地审核，生成 OpenAI 格式的审核结果
+func BuildLocalModerationResponse(model string, inputs []string) *dto.OpenAIModerationResponse {
+	categories := make([]string, 0, len(dto.OpenAIModerationCategories))
+	categories = append(categories, dto.OpenAIModerationCategories...)
+	for category := range operation_setting.GetModerationSetting().Rules {
+		categories = append(categories, category)
+	}
+	if category := operation_setting.GetModerationSetting().SensitiveWordsCategory; category != "" {
+		categories = append(categories, category)
+	}
+
+	response := &dto.OpenAIModerationResponse{
+		ID:      "modr-" + common.GetUUID(),
+		Model:   model,
+		Results: make([]dto.OpenAIModerationResult, 0, len(inputs)),
+	}
+	for _, input := range inputs {
+		hits := LocalModerate(input)
+		result := dto.OpenAIModerationResult{
+			Flagged:        len(hits) > 0,
+			Categories:     make(map[string]bool, len(categories)),
+			CategoryScores: make(map[string]float64, len(categories)),
+		}
+		for _, category := range categories {
+			_, hit := hits[category]
+			result.Categories[category] = hit
+			result.CategoryScores[category] = 0
+			if hit {
+				result.CategoryScores[category] = 1
+			}
+		}
+		response.Results = append(response.Results, result)
+	}
+	return response
+}
+
+// ShouldUseLocalModeration 当前分组的审核请求是否直接使用本地审核
+func ShouldUseLocalModeration(c *gin.Context) bool {
+	group := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+	return operation_setting.GetModerationSetting().GetGroupMode(group) == operation_setting.ModerationModeLocal
+}
+
+// ShouldFallbackToLocalModeration 当前分组没有可用的审核渠道时是否回退到本地审核
+func ShouldFallbackToLocalModeration(c *gin.Context) bool {
+	group := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+	return operation_setting.GetModerationSetting().GetGroupMode(group) == operation_setting.ModerationModeAuto
+}
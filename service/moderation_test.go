@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func TestLocalModeration(t *testing.T) {
+	moderationSetting := operation_setting.GetModerationSetting()
+	original := *moderationSetting
+	defer func() { *moderationSetting = original }()
+
+	moderationSetting.Rules = map[string]operation_setting.ModerationCategoryRule{
+		"violence": {Words: []string{"Kill", ""}},
+		"pii":      {Patterns: []string{`\d{3}-\d{4}`, `(`}},
+	}
+
+	flagged, categories := CheckModerationText("I will KILL it, call 555-1234")
+	if !flagged || len(categories) != 2 || categories[0] != "pii" || categories[1] != "violence" {
+		t.Fatalf("categories = %v", categories)
+	}
+
+	response := BuildLocalModerationResponse("omni-moderation-latest", []string{"hello", "kill"})
+	if len(response.Results) != 2 || response.Results[0].Flagged || !response.Results[1].Flagged {
+		t.Fatalf("results = %+v", response.Results)
+	}
+	if !response.Results[1].Categories["violence"] || response.Results[1].CategoryScores["violence"] != 1 {
+		t.Errorf("violence not flagged: %+v", response.Results[1])
+	}
+	if _, ok := response.Results[0].Categories["hate"]; !ok {
+		t.Error("standard categories should always be present")
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 批量任务支持的端点
+var batchSupportedEndpoints = map[string]struct{}{
+	"/v1/chat/completions": {},
+	"/v1/completions":      {},
+	"/v1/embeddings":       {},
+	"/v1/responses":        {},
+}
+
+// 批次级错误最多记录的条数
+const batchMaxReportedErrors = 100
+
+func IsBatchEndpointSupported(endpoint string) bool {
+	_, ok := batchSupportedEndpoints[endpoint]
+	return ok
+}
+
+// ParseBatchInput 解析并校验批量任务输入 JSONL
+// 返回所有请求行；存在非法行时返回行级错误列表（行号从 1 开始），此时批次应整体失败
+func ParseBatchInput(reader io.Reader, endpoint string, maxLines int) ([]dto.BatchRequestLine, []dto.OpenAIBatchError, error) {
+	var (
+		lines     []dto.BatchRequestLine
+		errs      []dto.OpenAIBatchError
+		customIds = make(map[string]struct{})
+	)
+	addError := func(lineNo int, code string, message string) {
+		if len(errs) >= batchMaxReportedErrors {
+			return
+		}
+		line := lineNo
+		errs = append(errs, dto.OpenAIBatchError{Code: code, Message: message, Line: &line})
+	}
+
+	br := bufio.NewReader(reader)
+	lineNo := 0
+	for {
+		raw, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, err
+		}
+		if len(raw) > 0 {
+			lineNo++
+			raw = bytes.TrimSpace(raw)
+			if len(raw) > 0 {
+				var line dto.BatchRequestLine
+				if uErr := common.Unmarshal(raw, &line); uErr != nil {
+					addError(lineNo, "invalid_json_line", "This line is not parseable as valid JSON.")
+				} else if line.CustomId == "" {
+					addError(lineNo, "missing_required_parameter", "The custom_id parameter is required.")
+				} else if _, dup := customIds[line.CustomId]; dup {
+					addError(lineNo, "duplicate_custom_id", fmt.Sprintf("The custom_id for this request is a duplicate of another request: %s", line.CustomId))
+				} else if line.Method != http.MethodPost {
+					addError(lineNo, "invalid_method", "The method for this request must be POST.")
+				} else if line.Url != endpoint {
+					addError(lineNo, "mismatched_endpoint", fmt.Sprintf("The provided url %s does not match the batch endpoint %s.", line.Url, endpoint))
+				} else if common.GetJsonType(line.Body) != "object" {
+					addError(lineNo, "invalid_request", "The body for this request must be a JSON object.")
+				} else {
+					customIds[line.CustomId] = struct{}{}
+					lines = append(lines, line)
+				}
+				if maxLines > 0 && len(lines)+len(errs) > maxLines {
+					return nil, []dto.OpenAIBatchError{{
+						Code:    "too_many_requests",
+						Message: fmt.Sprintf("The input file contains more than %d requests.", maxLines),
+					}}, nil
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	if len(errs) == 0 && len(lines) == 0 {
+		errs = append(errs, dto.OpenAIBatchError{Code: "empty_file", Message: "The input file is empty."})
+	}
+	return lines, errs, nil
+}
+
+// LoadBatchInput 读取并解析批次的输入文件
+func LoadBatchInput(batch *model.Batch) ([]dto.BatchRequestLine, []dto.OpenAIBatchError, error) {
+	file, err := model.GetUserFile(batch.UserId, batch.TokenId, batch.InputFileId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if file == nil {
+		return nil, []dto.OpenAIBatchError{{
+			Code:    "invalid_input_file",
+			Message: fmt.Sprintf("Input file %s not found.", batch.InputFileId),
+		}}, nil
+	}
+	content, err := OpenFileContent(context.Background(), file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer content.Close()
+	return ParseBatchInput(content, batch.Endpoint, operation_setting.GetBatchSetting().MaxRequestsPerBatch)
+}
+
+// AppendBatchResults 将执行结果追加到批次的输出/错误文件，返回成功和失败条数
+func AppendBatchResults(batch *model.Batch, results []dto.BatchResponseLine) (int, int, error) {
+	var output, errorOutput bytes.Buffer
+	completed, failed := 0, 0
+	for _, result := range results {
+		data, err := common.Marshal(result)
+		if err != nil {
+			return 0, 0, err
+		}
+		if result.Error == nil && result.Response != nil && result.Response.StatusCode >= 200 && result.Response.StatusCode < 300 {
+			output.Write(data)
+			output.WriteByte('\n')
+			completed++
+		} else {
+			errorOutput.Write(data)
+			errorOutput.WriteByte('\n')
+			failed++
+		}
+	}
+	if output.Len() > 0 {
+		if err := common.AppendDiskStoreFile(batch.OutputPath, output.Bytes()); err != nil {
+			return 0, 0, err
+		}
+	}
+	if errorOutput.Len() > 0 {
+		if err := common.AppendDiskStoreFile(batch.ErrorPath, errorOutput.Bytes()); err != nil {
+			return 0, 0, err
+		}
+	}
+	return completed, failed, nil
+}
+
+// FinalizeBatchFiles 为批次的输出/错误结果创建文件记录，空结果文件直接删除
+func FinalizeBatchFiles(batch *model.Batch) error {
+	var err error
+	if batch.OutputFileId == "" {
+		batch.OutputFileId, err = createBatchResultFile(batch, batch.OutputPath, "output")
+		if err != nil {
+			return err
+		}
+	}
+	if batch.ErrorFileId == "" {
+		batch.ErrorFileId, err = createBatchResultFile(batch, batch.ErrorPath, "error")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createBatchResultFile(batch *model.Batch, storagePath string, kind string) (string, error) {
+	if storagePath == "" {
+		return "", nil
+	}
+	info, err := os.Stat(storagePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	if info.Size() == 0 {
+		_ = os.Remove(storagePath)
+		return "", nil
+	}
+	file := &model.File{
+		FileId:      model.GenerateFileId(),
+		UserId:      batch.UserId,
+		TokenId:     batch.TokenId,
+		Filename:    fmt.Sprintf("%s_%s.jsonl", batch.BatchId, kind),
+		Purpose:     "batch_output",
+		Bytes:       info.Size(),
+		Status:      dto.OpenAIFileStatusProcessed,
+		StoragePath: storagePath,
+		CreatedAt:   time.Now().Unix(),
+	}
+	if days := operation_setting.GetFileSetting().LocalRetentionDays; days > 0 {
+		file.ExpiresAt = file.CreatedAt + int64(days)*24*3600
+	}
+	if err := file.Insert(); err != nil {
+		return "", err
+	}
+	return file.FileId, nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBatchInput(t *testing.T) {
+	t.Parallel()
+
+	input := `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o-mini"}}
+
+{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o-mini"}}`
+	lines, errs, err := ParseBatchInput(strings.NewReader(input), "/v1/chat/completions", 0)
+	require.NoError(t, err)
+	require.Empty(t, errs)
+	require.Len(t, lines, 2)
+	require.Equal(t, "b", lines[1].CustomId)
+
+	invalid := `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{}}
+{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{}}
+{"custom_id":"c","method":"GET","url":"/v1/chat/completions","body":{}}
+{"custom_id":"d","method":"POST","url":"/v1/embeddings","body":{}}
+not json`
+	_, errs, err = ParseBatchInput(strings.NewReader(invalid), "/v1/chat/completions", 0)
+	require.NoError(t, err)
+	require.Len(t, errs, 4)
+	codes := []string{errs[0].Code, errs[1].Code, errs[2].Code, errs[3].Code}
+	require.Equal(t, []string{"duplicate_custom_id", "invalid_method", "mismatched_endpoint", "invalid_json_line"}, codes)
+	require.Equal(t, 5, *errs[3].Line)
+
+	_, errs, err = ParseBatchInput(strings.NewReader(input), "/v1/chat/completions", 1)
+	require.NoError(t, err)
+	require.Equal(t, "too_many_requests", errs[0].Code)
+}
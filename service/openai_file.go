@@ -0,0 +1,312 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	fileCleanupTickInterval = 10 * time.Minute
+	fileCleanupBatchSize    = 200
+)
+
+var (
+	fileCleanupOnce sync.Once
+
+	// 匹配请求体中引用的文件 id，例如 {"type":"input_file","file_id":"file-xxx"}
+	fileIdReferencePattern = regexp.MustCompile(`"(?:file_id|input_file_id)"\s*:\s*"([^"]+)"`)
+	// 请求体不含该字段时跳过正则匹配和数据库查询
+	fileIdReferenceField = []byte(`file_id"`)
+)
+
+// IsFileUpstreamChannel 渠道是否支持透传 /v1/files
+func IsFileUpstreamChannel(channel *model.Channel) bool {
+	return channel != nil && channel.Type == constant.ChannelTypeOpenAI
+}
+
+// SelectFileUploadChannel 按令牌分组为文件上传选择一个 OpenAI 类型渠道，选不到时返回 nil
+// 候选渠道在选择时已按 IsChannelSupportRequest 过滤为 OpenAI 类型，这里的检查只兜底
+func SelectFileUploadChannel(c *gin.Context, modelName string) (*model.Channel, error) {
+	usingGroup := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
+	channel, _, err := CacheGetRandomSatisfiedChannel(&RetryParam{
+		Ctx:        c,
+		ModelName:  modelName,
+		TokenGroup: usingGroup,
+		Retry:      common.GetPointer(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !IsFileUpstreamChannel(channel) {
+		return nil, nil
+	}
+	return channel, nil
+}
+
+func newFileUpstreamRequest(ctx context.Context, channel *model.Channel, method string, path string, body io.Reader) (*http.Request, *http.Client, error) {
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[constant.ChannelTypeOpenAI]
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(baseURL, "/")+path, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, _, newAPIError := channel.GetNextEnabledKey()
+	if newAPIError != nil {
+		return nil, nil, newAPIError
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	if channel.OpenAIOrganization != nil && *channel.OpenAIOrganization != "" {
+		req.Header.Set("OpenAI-Organization", *channel.OpenAIOrganization)
+	}
+	setting := channel.GetSetting()
+	proxyURL := setting.Proxy
+	if setting.ProxyPool != "" {
+		proxyURL, err = SelectPoolProxy(setting.ProxyPool, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("select proxy from pool failed: %w", err)
+		}
+	}
+	client, err := GetChannelHttpClient(channel.Id, proxyURL, channel.GetOtherSettings().Transport)
+	if err != nil {
+		return nil, nil, err
+	}
+	return req, client, nil
+}
+
+func doFileUpstreamRequest(ctx context.Context, channel *model.Channel, method string, path string, body io.Reader, contentType string) (*http.Response, *types.NewAPIError) {
+	req, client, err := newFileUpstreamRequest(ctx, channel, method, path, body)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		newAPIError := RelayErrorHandler(ctx, resp, false)
+		CloseResponseBodyGracefully(resp)
+		return nil, newAPIError
+	}
+	return resp, nil
+}
+
+func decodeFileUpstreamResponse(resp *http.Response, v any) *types.NewAPIError {
+	defer CloseResponseBodyGracefully(resp)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeReadResponseBodyFailed)
+	}
+	if err := common.Unmarshal(body, v); err != nil {
+		return types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	return nil
+}
+
+// UploadFileToChannel 将文件以 multipart 形式透传到上游渠道
+func UploadFileToChannel(ctx context.Context, channel *model.Channel, purpose string, filename string, reader io.Reader) (*dto.OpenAIFile, *types.NewAPIError) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		err := writer.WriteField("purpose", purpose)
+		if err == nil {
+			var part io.Writer
+			part, err = writer.CreateFormFile("file", filename)
+			if err == nil {
+				_, err = io.Copy(part, reader)
+			}
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	resp, newAPIError := doFileUpstreamRequest(ctx, channel, http.MethodPost, "/v1/files", pr, writer.FormDataContentType())
+	// 确保上传协程在请求提前失败时退出
+	_ = pr.Close()
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+	var file dto.OpenAIFile
+	if newAPIError = decodeFileUpstreamResponse(resp, &file); newAPIError != nil {
+		return nil, newAPIError
+	}
+	return &file, nil
+}
+
+// RetrieveChannelFile 获取上游渠道中的文件信息
+func RetrieveChannelFile(ctx context.Context, channel *model.Channel, fileId string) (*dto.OpenAIFile, *types.NewAPIError) {
+	resp, newAPIError := doFileUpstreamRequest(ctx, channel, http.MethodGet, "/v1/files/"+fileId, nil, "")
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+	var file dto.OpenAIFile
+	if newAPIError = decodeFileUpstreamResponse(resp, &file); newAPIError != nil {
+		return nil, newAPIError
+	}
+	return &file, nil
+}
+
+// DeleteChannelFile 删除上游渠道中的文件
+func DeleteChannelFile(ctx context.Context, channel *model.Channel, fileId string) *types.NewAPIError {
+	resp, newAPIError := doFileUpstreamRequest(ctx, channel, http.MethodDelete, "/v1/files/"+fileId, nil, "")
+	if newAPIError != nil {
+		return newAPIError
+	}
+	var deleted dto.OpenAIFileDeleted
+	return decodeFileUpstreamResponse(resp, &deleted)
+}
+
+// OpenChannelFileContent 获取上游文件内容，调用方负责关闭 resp.Body
+func OpenChannelFileContent(ctx context.Context, channel *model.Channel, fileId string) (*http.Response, *types.NewAPIError) {
+	return doFileUpstreamRequest(ctx, channel, http.MethodGet, "/v1/files/"+fileId+"/content", nil, "")
+}
+
+// OpenFileContent 打开文件内容，本地文件直接读取磁盘，上游文件从所在渠道下载，调用方负责关闭
+func OpenFileContent(ctx context.Context, file *model.File) (io.ReadCloser, error) {
+	if file.IsLocal() {
+		return common.OpenDiskStoreFile(file.StoragePath)
+	}
+	channel, err := model.CacheGetChannel(file.ChannelId)
+	if err != nil {
+		return nil, err
+	}
+	resp, newAPIError := OpenChannelFileContent(ctx, channel, file.FileId)
+	if newAPIError != nil {
+		return nil, newAPIError
+	}
+	return resp.Body, nil
+}
+
+// ExtractReferencedFileIds 提取请求体中引用的文件 id（去重，保持顺序）
+func ExtractReferencedFileIds(body []byte) []string {
+	matches := fileIdReferencePattern.FindAllSubmatch(body, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(matches))
+	seen := make(map[string]struct{}, len(matches))
+	for _, m := range matches {
+		id := string(m[1])
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// GetFilePinnedChannel 请求引用了已透传到上游的文件时，返回该文件所在渠道 id，否则返回 0
+// 上游文件只存在于上传时的渠道中，引用它的请求必须发往同一渠道
+func GetFilePinnedChannel(c *gin.Context) int {
+	if !strings.Contains(c.Request.Header.Get("Content-Type"), "application/json") {
+		return 0
+	}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return 0
+	}
+	body, err := storage.Bytes()
+	if err != nil || !bytes.Contains(body, fileIdReferenceField) {
+		return 0
+	}
+	fileIds := ExtractReferencedFileIds(body)
+	if len(fileIds) == 0 {
+		return 0
+	}
+	userId := common.GetContextKeyInt(c, constant.ContextKeyUserId)
+	tokenId := common.GetContextKeyInt(c, constant.ContextKeyTokenId)
+	files, err := model.GetUserFilesByIds(userId, tokenId, fileIds)
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to query referenced files: %s", err.Error()))
+		return 0
+	}
+	for _, id := range fileIds {
+		for _, file := range files {
+			if file.FileId == id && !file.IsLocal() {
+				return file.ChannelId
+			}
+		}
+	}
+	return 0
+}
+
+// StartFileCleanupTask 定期删除已过期的本地文件
+func StartFileCleanupTask() {
+	fileCleanupOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("file cleanup task started: tick=%s", fileCleanupTickInterval))
+			ticker := time.NewTicker(fileCleanupTickInterval)
+			defer ticker.Stop()
+
+			runFileCleanupOnce()
+			for range ticker.C {
+				runFileCleanupOnce()
+			}
+		})
+	})
+}
+
+func runFileCleanupOnce() {
+	ctx := context.Background()
+	total := 0
+	for {
+		files, err := model.GetExpiredLocalFiles(time.Now().Unix(), fileCleanupBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("file cleanup task failed: %v", err))
+			return
+		}
+		for _, file := range files {
+			if err := RemoveLocalFile(file); err != nil {
+				logger.LogWarn(ctx, fmt.Sprintf("failed to remove expired file %s: %v", file.FileId, err))
+				return
+			}
+		}
+		total += len(files)
+		if len(files) < fileCleanupBatchSize {
+			break
+		}
+	}
+	if common.DebugEnabled && total > 0 {
+		logger.LogDebug(ctx, "file cleanup: removed_count=%d", total)
+	}
+}
+
+// RemoveLocalFile 删除本地文件的存储内容和记录
+func RemoveLocalFile(file *model.File) error {
+	if file.StoragePath != "" {
+		if err := os.Remove(file.StoragePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return file.Delete()
+}
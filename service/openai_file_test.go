@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractReferencedFileIds(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"model":"gpt-4o","input":[{"role":"user","content":[` +
+		`{"type":"input_file","file_id":"file-abc"},` +
+		`{"type":"input_file", "file_id" : "file-def"},` +
+		`{"type":"input_file","file_id":"file-abc"}]}],"input_file_id":"file-batch"}`)
+	require.Equal(t, []string{"file-abc", "file-def", "file-batch"}, ExtractReferencedFileIds(body))
+	require.Nil(t, ExtractReferencedFileIds([]byte(`{"model":"gpt-4o","messages":[]}`)))
+}
+
+func TestIsChannelSupportRequestFileUpload(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/files", nil)
+	require.True(t, IsChannelSupportRequest(c, &model.Channel{Type: constant.ChannelTypeOpenAI}))
+	require.False(t, IsChannelSupportRequest(c, &model.Channel{Type: constant.ChannelTypeAnthropic}))
+	require.False(t, IsChannelSupportRequest(c, &model.Channel{Type: constant.ChannelTypeAzure}))
+
+	// 其他接口不受影响
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	require.True(t, IsChannelSupportRequest(c, &model.Channel{Type: constant.ChannelTypeAnthropic}))
+}
+
+func TestRetrieveChannelFileUsesPoolProxy(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHost = r.URL.Host
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"file-abc","object":"file","bytes":3,"filename":"a.txt","purpose":"assistants"}`))
+	}))
+	defer proxy.Close()
+
+	saved := proxyPools
+	defer func() { proxyPools = saved }()
+	proxyPools = map[string]*proxyPoolState{
+		"pool": {name: "pool", strategy: ProxyPoolStrategyRoundRobin, proxies: []string{proxy.URL}, unhealthy: map[string]bool{}},
+	}
+
+	baseURL := "http://upstream.invalid"
+	setting := `{"proxy":"http://unused.invalid:8080","proxy_pool":"pool"}`
+	channel := &model.Channel{Id: 1, Type: constant.ChannelTypeOpenAI, Key: "sk-test", BaseURL: &baseURL, Setting: &setting}
+	file, newAPIError := RetrieveChannelFile(context.Background(), channel, "file-abc")
+	require.Nil(t, newAPIError)
+	require.Equal(t, "file-abc", file.Id)
+	// 请求经代理池中的代理发出，而不是渠道的固定代理
+	require.Equal(t, "upstream.invalid", proxiedHost)
+}
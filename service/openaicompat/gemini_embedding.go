@@ -0,0 +1,70 @@
+package openaicompat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// GeminiEmbeddingRequestsToOpenAI 将 Gemini embedContent / batchEmbedContents 请求转换为 OpenAI embeddings 请求，
+// 每个 Gemini 请求对应一条 input，其中多个文本片段以换行拼接
+func GeminiEmbeddingRequestsToOpenAI(requests []*dto.GeminiEmbeddingRequest, model string) (*dto.EmbeddingRequest, error) {
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("requests is empty")
+	}
+	inputs := make([]string, 0, len(requests))
+	dimensions := 0
+	for i, request := range requests {
+		if request == nil {
+			return nil, fmt.Errorf("requests[%d] is empty", i)
+		}
+		var texts []string
+		for _, part := range request.Content.Parts {
+			if part.Text != "" {
+				texts = append(texts, part.Text)
+			}
+		}
+		if len(texts) == 0 {
+			return nil, fmt.Errorf("requests[%d] has no text part", i)
+		}
+		inputs = append(inputs, strings.Join(texts, "\n"))
+		if request.OutputDimensionality > 0 {
+			if dimensions > 0 && dimensions != request.OutputDimensionality {
+				return nil, fmt.Errorf("requests[%d] has a different outputDimensionality", i)
+			}
+			dimensions = request.OutputDimensionality
+		}
+	}
+	return &dto.EmbeddingRequest{
+		Model:      model,
+		Input:      inputs,
+		Dimensions: dimensions,
+	}, nil
+}
+
+// OpenAIEmbeddingResponseToGemini 按 index 将 OpenAI embeddings 结果还原为 Gemini 的 embeddings 顺序
+func OpenAIEmbeddingResponseToGemini(response *dto.OpenAIEmbeddingResponse, count int) (*dto.GeminiBatchEmbeddingResponse, error) {
+	if len(response.Data) != count {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", count, len(response.Data))
+	}
+	// 上游未返回有效 index（缺失或重复）时按返回顺序处理
+	useIndex := true
+	seen := make([]bool, count)
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= count || seen[item.Index] {
+			useIndex = false
+			break
+		}
+		seen[item.Index] = true
+	}
+	embeddings := make([]*dto.ContentEmbedding, count)
+	for i, item := range response.Data {
+		index := i
+		if useIndex {
+			index = item.Index
+		}
+		embeddings[index] = &dto.ContentEmbedding{Values: item.Embedding}
+	}
+	return &dto.GeminiBatchEmbeddingResponse{Embeddings: embeddings}, nil
+}
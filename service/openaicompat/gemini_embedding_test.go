@@ -0,0 +1,57 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func TestGeminiEmbeddingRequestsToOpenAI(t *testing.T) {
+	requests := []*dto.GeminiEmbeddingRequest{
+		{Content: dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: "hello"}, {Text: "world"}}}, OutputDimensionality: 256},
+		{Content: dto.GeminiChatContent{Parts: []dto.GeminiPart{{Text: "bye"}}}},
+	}
+	request, err := GeminiEmbeddingRequestsToOpenAI(requests, "text-embedding-3-small")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	inputs, ok := request.Input.([]string)
+	if !ok || len(inputs) != 2 || inputs[0] != "hello\nworld" || inputs[1] != "bye" {
+		t.Errorf("input = %#v", request.Input)
+	}
+	if request.Model != "text-embedding-3-small" || request.Dimensions != 256 {
+		t.Errorf("model = %q, dimensions = %d", request.Model, request.Dimensions)
+	}
+
+	if _, err := GeminiEmbeddingRequestsToOpenAI([]*dto.GeminiEmbeddingRequest{{}}, "m"); err == nil {
+		t.Error("expected error for request without text")
+	}
+}
+
+func TestOpenAIEmbeddingResponseToGemini(t *testing.T) {
+	response := &dto.OpenAIEmbeddingResponse{Data: []dto.OpenAIEmbeddingResponseItem{
+		{Index: 1, Embedding: []float64{2}},
+		{Index: 0, Embedding: []float64{1}},
+	}}
+	geminiResponse, err := OpenAIEmbeddingResponseToGemini(response, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if geminiResponse.Embeddings[0].Values[0] != 1 || geminiResponse.Embeddings[1].Values[0] != 2 {
+		t.Errorf("embeddings not ordered by index: %+v", geminiResponse.Embeddings)
+	}
+
+	// index 缺失时按返回顺序处理
+	response.Data[0].Index, response.Data[1].Index = 0, 0
+	geminiResponse, err = OpenAIEmbeddingResponseToGemini(response, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if geminiResponse.Embeddings[0].Values[0] != 2 {
+		t.Errorf("embeddings not in response order: %+v", geminiResponse.Embeddings)
+	}
+
+	if _, err := OpenAIEmbeddingResponseToGemini(response, 3); err == nil {
+		t.Error("expected error for count mismatch")
+	}
+}
@@ -0,0 +1,200 @@
+package openaicompat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ResponsesRequestToChatCompletionsRequest 将 Responses 请求转换为 Chat Completions 请求，
+// 供只实现了 Chat 转换的渠道复用；reasoning 条目和非 function 工具会被忽略
+func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	if req == nil {
+		return nil, errors.New("request is nil")
+	}
+	if req.Model == "" {
+		return nil, errors.New("model is required")
+	}
+
+	items, err := req.ParseInputItems()
+	if err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+
+	messages := make([]dto.Message, 0, len(items)+1)
+	if instructions := strings.TrimSpace(req.GetInstructions()); instructions != "" {
+		messages = append(messages, dto.Message{Role: "system", Content: instructions})
+	}
+
+	for _, item := range items {
+		switch item.Type {
+		case "message":
+			role := item.Role
+			if role == "developer" {
+				role = "system"
+			}
+			message := dto.Message{Role: role}
+			content, err := responsesContentToChatContent(item.Content)
+			if err != nil {
+				return nil, err
+			}
+			if len(content) == 1 && content[0].Type == dto.ContentTypeText {
+				message.SetStringContent(content[0].Text)
+			} else {
+				message.SetMediaContent(content)
+			}
+			messages = append(messages, message)
+		case "function_call":
+			toolCall := dto.ToolCallRequest{
+				ID:   item.CallId,
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			}
+			// 连续的函数调用合并到同一条 assistant 消息
+			if n := len(messages); n > 0 && messages[n-1].Role == "assistant" {
+				toolCalls := append(messages[n-1].ParseToolCalls(), toolCall)
+				messages[n-1].SetToolCalls(toolCalls)
+				continue
+			}
+			message := dto.Message{Role: "assistant"}
+			message.SetToolCalls([]dto.ToolCallRequest{toolCall})
+			messages = append(messages, message)
+		case "function_call_output":
+			var output strings.Builder
+			for _, part := range dto.ParseResponsesContent(item.Output, "input_text") {
+				output.WriteString(part.Text)
+			}
+			messages = append(messages, dto.Message{
+				Role:       "tool",
+				ToolCallId: item.CallId,
+				Content:    output.String(),
+			})
+		}
+	}
+
+	out := &dto.GeneralOpenAIRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Stream:      req.Stream,
+		MaxTokens:   req.MaxOutputTokens,
+		Temperature: req.Temperature,
+		User:        req.User,
+	}
+	if req.TopP != nil {
+		out.TopP = *req.TopP
+	}
+	if req.Reasoning != nil {
+		out.ReasoningEffort = req.Reasoning.Effort
+	}
+	if len(req.ParallelToolCalls) > 0 {
+		var parallelToolCalls bool
+		if err := common.Unmarshal(req.ParallelToolCalls, &parallelToolCalls); err == nil {
+			out.ParallelTooCalls = &parallelToolCalls
+		}
+	}
+
+	if len(req.Tools) > 0 {
+		var tools []map[string]any
+		if err := common.Unmarshal(req.Tools, &tools); err != nil {
+			return nil, fmt.Errorf("invalid tools: %w", err)
+		}
+		for _, tool := range tools {
+			if common.Interface2String(tool["type"]) != "function" {
+				continue
+			}
+			out.Tools = append(out.Tools, dto.ToolCallRequest{
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:        common.Interface2String(tool["name"]),
+					Description: common.Interface2String(tool["description"]),
+					Parameters:  tool["parameters"],
+				},
+			})
+		}
+	}
+
+	if len(req.ToolChoice) > 0 {
+		var toolChoice any
+		if err := common.Unmarshal(req.ToolChoice, &toolChoice); err == nil {
+			// Responses: {"type":"function","name":"..."}
+			// Chat: {"type":"function","function":{"name":"..."}}
+			if m, ok := toolChoice.(map[string]any); ok && m["type"] == "function" {
+				if name := common.Interface2String(m["name"]); name != "" {
+					toolChoice = map[string]any{
+						"type":     "function",
+						"function": map[string]any{"name": name},
+					}
+				}
+			}
+			out.ToolChoice = toolChoice
+		}
+	}
+
+	out.ResponseFormat = convertResponsesTextToChatResponseFormat(req)
+	return out, nil
+}
+
+func responsesContentToChatContent(raw []byte) ([]dto.MediaContent, error) {
+	parts := dto.ParseResponsesContent(raw, "input_text")
+	content := make([]dto.MediaContent, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			content = append(content, dto.MediaContent{
+				Type: dto.ContentTypeText,
+				Text: part.Text,
+			})
+		case "input_image":
+			content = append(content, dto.MediaContent{
+				Type: dto.ContentTypeImageURL,
+				ImageUrl: &dto.MessageImageUrl{
+					Url:    part.GetImageUrl(),
+					Detail: part.Detail,
+				},
+			})
+		case "input_file":
+			if part.FileUrl != "" {
+				return nil, errors.New("input_file with file_url is not supported for this channel")
+			}
+			content = append(content, dto.MediaContent{
+				Type: dto.ContentTypeFile,
+				File: &dto.MessageFile{
+					FileName: part.Filename,
+					FileData: part.FileData,
+					FileId:   part.FileId,
+				},
+			})
+		}
+	}
+	return content, nil
+}
+
+// convertResponsesTextToChatResponseFormat text.format 转换为 response_format，
+// json_schema 的 name/schema/strict 等字段收拢到 json_schema 下
+func convertResponsesTextToChatResponseFormat(req *dto.OpenAIResponsesRequest) *dto.ResponseFormat {
+	if len(req.Text) == 0 {
+		return nil
+	}
+	var text struct {
+		Format map[string]any `json:"format"`
+	}
+	if err := common.Unmarshal(req.Text, &text); err != nil || text.Format == nil {
+		return nil
+	}
+	formatType := common.Interface2String(text.Format["type"])
+	if formatType == "" || formatType == "text" {
+		return nil
+	}
+	responseFormat := &dto.ResponseFormat{Type: formatType}
+	if formatType == "json_schema" {
+		delete(text.Format, "type")
+		responseFormat.JsonSchema, _ = common.Marshal(text.Format)
+	}
+	return responseFormat
+}
@@ -0,0 +1,70 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+func TestResponsesRequestToChatCompletionsRequest(t *testing.T) {
+	request := &dto.OpenAIResponsesRequest{
+		Model:           "gemini-2.5-flash",
+		Instructions:    json.RawMessage(`"be brief"`),
+		MaxOutputTokens: 512,
+		Reasoning:       &dto.Reasoning{Effort: "low"},
+		Text:            json.RawMessage(`{"format":{"type":"json_schema","name":"weather","schema":{"type":"object"}}}`),
+		Tools:           json.RawMessage(`[{"type":"function","name":"get_weather","parameters":{"type":"object"}},{"type":"web_search_preview"}]`),
+		ToolChoice:      json.RawMessage(`{"type":"function","name":"get_weather"}`),
+		Input: json.RawMessage(`[
+			{"role":"developer","content":"use celsius"},
+			{"role":"user","content":[{"type":"input_text","text":"weather?"},{"type":"input_image","image_url":"https://example.com/a.png"}]},
+			{"type":"reasoning","summary":[]},
+			{"type":"message","role":"assistant","content":[{"type":"output_text","text":"checking"}]},
+			{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"},
+			{"type":"function_call","call_id":"call_2","name":"get_weather","arguments":"{\"city\":\"Rome\"}"},
+			{"type":"function_call_output","call_id":"call_1","output":"sunny"}
+		]`),
+	}
+
+	chatRequest, err := ResponsesRequestToChatCompletionsRequest(request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if chatRequest.MaxTokens != 512 || chatRequest.ReasoningEffort != "low" {
+		t.Errorf("max_tokens = %d, reasoning_effort = %q", chatRequest.MaxTokens, chatRequest.ReasoningEffort)
+	}
+	if len(chatRequest.Tools) != 1 || chatRequest.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("tools = %+v", chatRequest.Tools)
+	}
+	if toolChoice, ok := chatRequest.ToolChoice.(map[string]any); !ok || toolChoice["function"].(map[string]any)["name"] != "get_weather" {
+		t.Errorf("tool_choice = %+v", chatRequest.ToolChoice)
+	}
+	if chatRequest.ResponseFormat == nil || chatRequest.ResponseFormat.Type != "json_schema" {
+		t.Fatalf("response_format = %+v", chatRequest.ResponseFormat)
+	}
+	var schema dto.FormatJsonSchema
+	if err := json.Unmarshal(chatRequest.ResponseFormat.JsonSchema, &schema); err != nil || schema.Name != "weather" {
+		t.Errorf("json_schema = %s", chatRequest.ResponseFormat.JsonSchema)
+	}
+
+	messages := chatRequest.Messages
+	if len(messages) != 5 {
+		t.Fatalf("len(messages) = %d, want 5", len(messages))
+	}
+	if messages[0].Role != "system" || messages[0].StringContent() != "be brief" {
+		t.Errorf("messages[0] = %+v", messages[0])
+	}
+	if messages[1].Role != "system" || messages[1].StringContent() != "use celsius" {
+		t.Errorf("messages[1] = %+v", messages[1])
+	}
+	if content := messages[2].ParseContent(); len(content) != 2 || content[1].GetImageMedia().Url != "https://example.com/a.png" {
+		t.Errorf("messages[2] content = %+v", content)
+	}
+	if messages[3].Role != "assistant" || messages[3].StringContent() != "checking" || len(messages[3].ParseToolCalls()) != 2 {
+		t.Errorf("messages[3] = %+v", messages[3])
+	}
+	if messages[4].Role != "tool" || messages[4].ToolCallId != "call_1" || messages[4].StringContent() != "sunny" {
+		t.Errorf("messages[4] = %+v", messages[4])
+	}
+}
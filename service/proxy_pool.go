@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	ProxyPoolStrategyRoundRobin = "round_robin"
+	ProxyPoolStrategyRandom     = "random"
+	ProxyPoolStrategySticky     = "sticky"
+
+	defaultProxyHealthCheckURL    = "https://www.gstatic.com/generate_204"
+	proxyPoolHealthCheckInterval  = time.Minute
+	proxyPoolHealthCheckTimeout   = 10 * time.Second
+	proxyPoolHealthCheckMaxActive = 8
+)
+
+type proxyPoolState struct {
+	name           string
+	strategy       string
+	healthCheckURL string
+	proxies        []string
+	next           atomic.Uint64
+
+	mu        sync.RWMutex
+	unhealthy map[string]bool
+}
+
+var (
+	proxyPoolsLock      sync.RWMutex
+	proxyPools          = make(map[string]*proxyPoolState)
+	proxyPoolHealthOnce sync.Once
+)
+
+// ValidateProxyPool 校验代理池配置
+func ValidateProxyPool(pool *model.ProxyPool) error {
+	if pool.Name == "" {
+		return errors.New("proxy pool name is empty")
+	}
+	switch pool.Strategy {
+	case "":
+		pool.Strategy = ProxyPoolStrategyRoundRobin
+	case ProxyPoolStrategyRoundRobin, ProxyPoolStrategyRandom, ProxyPoolStrategySticky:
+	default:
+		return fmt.Errorf("unsupported strategy: %s, must be round_robin, random or sticky", pool.Strategy)
+	}
+	var proxies []string
+	if err := common.UnmarshalJsonStr(pool.Proxies, &proxies); err != nil {
+		return fmt.Errorf("proxies must be a json array of proxy urls: %w", err)
+	}
+	if len(proxies) == 0 {
+		return errors.New("proxy pool has no proxies")
+	}
+	for _, proxy := range proxies {
+		parsedURL, err := url.Parse(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy url %s: %w", RedactProxyURL(proxy), err)
+		}
+		switch parsedURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("unsupported proxy scheme: %s, must be http, https, socks5 or socks5h", parsedURL.Scheme)
+		}
+	}
+	if pool.HealthCheckURL != "" {
+		if parsedURL, err := url.Parse(pool.HealthCheckURL); err != nil || parsedURL.Host == "" {
+			return errors.New("invalid health check url")
+		}
+	}
+	return nil
+}
+
+// RedactProxyURL 隐藏代理地址中的认证信息，用于日志记录
+func RedactProxyURL(proxy string) string {
+	parsedURL, err := url.Parse(proxy)
+	if err != nil || parsedURL.User == nil {
+		return proxy
+	}
+	return parsedURL.Redacted()
+}
+
+// ReloadProxyPools 从数据库重新加载代理池，保留未变化代理的健康状态
+func ReloadProxyPools() error {
+	pools, err := model.GetAllProxyPools()
+	if err != nil {
+		return err
+	}
+	proxyPoolsLock.Lock()
+	defer proxyPoolsLock.Unlock()
+	newPools := make(map[string]*proxyPoolState, len(pools))
+	for _, pool := range pools {
+		state := &proxyPoolState{
+			name:           pool.Name,
+			strategy:       pool.Strategy,
+			healthCheckURL: pool.HealthCheckURL,
+			proxies:        pool.GetProxies(),
+			unhealthy:      make(map[string]bool),
+		}
+		if old, ok := proxyPools[pool.Name]; ok {
+			old.mu.RLock()
+			for _, proxy := range state.proxies {
+				if old.unhealthy[proxy] {
+					state.unhealthy[proxy] = true
+				}
+			}
+			old.mu.RUnlock()
+			state.next.Store(old.next.Load())
+		}
+		newPools[pool.Name] = state
+	}
+	proxyPools = newPools
+	return nil
+}
+
+func getProxyPoolState(name string) *proxyPoolState {
+	proxyPoolsLock.RLock()
+	defer proxyPoolsLock.RUnlock()
+	return proxyPools[name]
+}
+
+// healthyProxies 返回未被剔除的代理，全部被剔除时返回全部代理，避免健康检查地址不可用时整个池不可用
+func (s *proxyPoolState) healthyProxies() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.unhealthy) == 0 {
+		return s.proxies
+	}
+	healthy := make([]string, 0, len(s.proxies))
+	for _, proxy := range s.proxies {
+		if !s.unhealthy[proxy] {
+			healthy = append(healthy, proxy)
+		}
+	}
+	if len(healthy) == 0 {
+		return s.proxies
+	}
+	return healthy
+}
+
+// SelectPoolProxy 按代理池的轮换策略选择代理，sticky 策略下相同的 stickyKey 使用同一个代理
+func SelectPoolProxy(poolName string, stickyKey string) (string, error) {
+	state := getProxyPoolState(poolName)
+	if state == nil {
+		return "", fmt.Errorf("proxy pool %s not found", poolName)
+	}
+	proxies := state.healthyProxies()
+	if len(proxies) == 0 {
+		return "", fmt.Errorf("proxy pool %s has no proxies", poolName)
+	}
+	switch state.strategy {
+	case ProxyPoolStrategyRandom:
+		return proxies[rand.Intn(len(proxies))], nil
+	case ProxyPoolStrategySticky:
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(stickyKey))
+		return proxies[h.Sum32()%uint32(len(proxies))], nil
+	default:
+		return proxies[(state.next.Add(1)-1)%uint64(len(proxies))], nil
+	}
+}
+
+// StartProxyPoolHealthCheckTask 定期重新加载代理池并检查代理可用性，每个节点独立维护代理健康状态
+func StartProxyPoolHealthCheckTask() {
+	proxyPoolHealthOnce.Do(func() {
+		gopool.Go(func() {
+			ticker := time.NewTicker(proxyPoolHealthCheckInterval)
+			defer ticker.Stop()
+			for {
+				runProxyPoolHealthCheckOnce()
+				<-ticker.C
+			}
+		})
+	})
+}
+
+func runProxyPoolHealthCheckOnce() {
+	ctx := context.Background()
+	if err := ReloadProxyPools(); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("failed to load proxy pools: %v", err))
+		return
+	}
+	proxyPoolsLock.RLock()
+	states := make([]*proxyPoolState, 0, len(proxyPools))
+	for _, state := range proxyPools {
+		states = append(states, state)
+	}
+	proxyPoolsLock.RUnlock()
+
+	sem := make(chan struct{}, proxyPoolHealthCheckMaxActive)
+	var wg sync.WaitGroup
+	for _, state := range states {
+		for _, proxy := range state.proxies {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(state *proxyPoolState, proxy string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				err := checkProxy(state.healthCheckURL, proxy)
+				state.mu.Lock()
+				wasUnhealthy := state.unhealthy[proxy]
+				if err != nil {
+					state.unhealthy[proxy] = true
+				} else {
+					delete(state.unhealthy, proxy)
+				}
+				state.mu.Unlock()
+				if err != nil && !wasUnhealthy {
+					logger.LogWarn(ctx, fmt.Sprintf("proxy %s in pool %s ejected: %v", RedactProxyURL(proxy), state.name, err))
+				} else if err == nil && wasUnhealthy {
+					logger.LogInfo(ctx, fmt.Sprintf("proxy %s in pool %s recovered", RedactProxyURL(proxy), state.name))
+				}
+			}(state, proxy)
+		}
+	}
+	wg.Wait()
+}
+
+func checkProxy(healthCheckURL string, proxy string) error {
+	if healthCheckURL == "" {
+		healthCheckURL = defaultProxyHealthCheckURL
+	}
+	client, err := NewProxyHttpClient(proxy)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), proxyPoolHealthCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthCheckURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	// 代理认证失败或代理无法连接目标时视为不可用
+	if resp.StatusCode == http.StatusProxyAuthRequired || resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+)
+
+func TestSelectPoolProxy(t *testing.T) {
+	proxies := []string{"http://p1:8080", "http://p2:8080", "socks5://user:pass@p3:1080"}
+	saved := proxyPools
+	defer func() { proxyPools = saved }()
+	proxyPools = map[string]*proxyPoolState{
+		"rr":     {name: "rr", strategy: ProxyPoolStrategyRoundRobin, proxies: proxies, unhealthy: map[string]bool{}},
+		"sticky": {name: "sticky", strategy: ProxyPoolStrategySticky, proxies: proxies, unhealthy: map[string]bool{}},
+	}
+
+	for i := 0; i < 6; i++ {
+		got, err := SelectPoolProxy("rr", "")
+		if err != nil || got != proxies[i%3] {
+			t.Fatalf("round robin #%d: got %q, %v", i, got, err)
+		}
+	}
+
+	first, _ := SelectPoolProxy("sticky", "sk-key")
+	for i := 0; i < 5; i++ {
+		if got, _ := SelectPoolProxy("sticky", "sk-key"); got != first {
+			t.Fatalf("sticky proxy changed: %q != %q", got, first)
+		}
+	}
+
+	// 被剔除的代理不再被选中，全部剔除时回退到全部代理
+	proxyPools["rr"].unhealthy = map[string]bool{proxies[0]: true, proxies[1]: true}
+	for i := 0; i < 3; i++ {
+		if got, _ := SelectPoolProxy("rr", ""); got != proxies[2] {
+			t.Fatalf("expected healthy proxy, got %q", got)
+		}
+	}
+	proxyPools["rr"].unhealthy[proxies[2]] = true
+	if got, err := SelectPoolProxy("rr", ""); err != nil || got == "" {
+		t.Fatalf("expected fallback to all proxies, got %q, %v", got, err)
+	}
+
+	if _, err := SelectPoolProxy("missing", ""); err == nil {
+		t.Fatal("expected error for missing pool")
+	}
+	if got := RedactProxyURL(proxies[2]); got != "socks5://user:xxxxx@p3:1080" {
+		t.Fatalf("unexpected redacted proxy: %s", got)
+	}
+}
+
+func TestValidateProxyPool(t *testing.T) {
+	pool := &model.ProxyPool{Name: "pool", Proxies: `["http://p1:8080","socks5h://p2:1080"]`}
+	if err := ValidateProxyPool(pool); err != nil || pool.Strategy != ProxyPoolStrategyRoundRobin {
+		t.Fatalf("unexpected result: %v, strategy %q", err, pool.Strategy)
+	}
+	for _, invalid := range []*model.ProxyPool{
+		{Name: "pool", Proxies: `[]`},
+		{Name: "pool", Proxies: `["ftp://p1"]`},
+		{Name: "pool", Proxies: `["http://p1"]`, Strategy: "weighted"},
+	} {
+		if err := ValidateProxyPool(invalid); err == nil {
+			t.Fatalf("expected error for %+v", invalid)
+		}
+	}
+}
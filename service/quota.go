@@ -310,6 +310,8 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
+	AdjustChannelTokenUsage(relayInfo, usage.PromptTokens+usage.CompletionTokens)
+	AdjustTokenRateLimitUsage(ctx, usage.PromptTokens+usage.CompletionTokens)
 
 	other := GenerateClaudeOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio,
 		cacheTokens, cacheRatio,
@@ -416,6 +418,8 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
+	AdjustChannelTokenUsage(relayInfo, usage.PromptTokens+usage.CompletionTokens)
+	AdjustTokenRateLimitUsage(ctx, usage.PromptTokens+usage.CompletionTokens)
 
 	logModel := relayInfo.OriginModelName
 	if extraContent != "" {
@@ -456,6 +460,15 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	if !relayInfo.TokenUnlimited && token.RemainQuota < quota {
 		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(token.RemainQuota), logger.FormatQuota(quota))
 	}
+	if token.HasBudget() {
+		if err := model.RefreshTokenBudget(token, common.GetTimestamp()); err != nil {
+			return err
+		}
+		if token.BudgetUsed+quota > token.BudgetQuota {
+			return fmt.Errorf("token %s budget is not enough, remain budget: %s, need quota: %s", token.BudgetPeriod,
+				logger.FormatQuota(max(token.BudgetQuota-token.BudgetUsed, 0)), logger.FormatQuota(quota))
+		}
+	}
 	err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
 	if err != nil {
 		return err
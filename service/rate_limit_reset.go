@@ -0,0 +1,103 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// rateLimitResetHeaders 上游限流重置头及对应的剩余额度头
+var rateLimitResetHeaders = []struct {
+	reset     string
+	remaining string
+}{
+	// OpenAI：重置时间为时长，如 "1s"、"6m0s"、"20ms"
+	{"x-ratelimit-reset-requests", "x-ratelimit-remaining-requests"},
+	{"x-ratelimit-reset-tokens", "x-ratelimit-remaining-tokens"},
+	// Anthropic：重置时间为 RFC 3339 时间
+	{"anthropic-ratelimit-requests-reset", "anthropic-ratelimit-requests-remaining"},
+	{"anthropic-ratelimit-tokens-reset", "anthropic-ratelimit-tokens-remaining"},
+	{"anthropic-ratelimit-input-tokens-reset", "anthropic-ratelimit-input-tokens-remaining"},
+	{"anthropic-ratelimit-output-tokens-reset", "anthropic-ratelimit-output-tokens-remaining"},
+	// 通用：秒数或 Unix 时间戳
+	{"x-ratelimit-reset", "x-ratelimit-remaining"},
+}
+
+// ParseRetryAfter 解析上游要求的等待时间，优先使用 Retry-After，其次使用已耗尽额度的限流重置头
+func ParseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if header == nil {
+		return 0
+	}
+	if v := strings.TrimSpace(header.Get("retry-after-ms")); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	if v := strings.TrimSpace(header.Get("Retry-After")); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			if seconds > 0 {
+				return time.Duration(seconds * float64(time.Second))
+			}
+		} else if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+
+	// 只有剩余额度为 0 的维度才是限流原因；都没有剩余额度头时取最长的重置时间
+	var exhausted, longest time.Duration
+	for _, h := range rateLimitResetHeaders {
+		wait := parseRateLimitReset(header.Get(h.reset), now)
+		if wait <= 0 {
+			continue
+		}
+		longest = max(longest, wait)
+		if strings.TrimSpace(header.Get(h.remaining)) == "0" {
+			exhausted = max(exhausted, wait)
+		}
+	}
+	if exhausted > 0 {
+		return exhausted
+	}
+	return longest
+}
+
+func parseRateLimitReset(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Sub(now)
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		// 大于一年的秒数视为 Unix 时间戳
+		if n > 365*24*3600 {
+			return time.Unix(int64(n), 0).Sub(now)
+		}
+		return time.Duration(n * float64(time.Second))
+	}
+	return 0
+}
+
+// KeyCooldownDuration 根据上游错误计算 Key 的冷却时间
+func KeyCooldownDuration(err *types.NewAPIError) time.Duration {
+	setting := operation_setting.GetKeyCooldownSetting()
+	if !setting.Enabled || err == nil {
+		return 0
+	}
+	cooldown := err.RetryAfter
+	if cooldown <= 0 && err.StatusCode == http.StatusTooManyRequests {
+		cooldown = time.Duration(setting.DefaultSeconds) * time.Second
+	}
+	if maxCooldown := time.Duration(setting.MaxSeconds) * time.Second; maxCooldown > 0 && cooldown > maxCooldown {
+		cooldown = maxCooldown
+	}
+	return cooldown
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+	}{
+		{"retry-after seconds", map[string]string{"Retry-After": "7"}, 7 * time.Second},
+		{"retry-after date", map[string]string{"Retry-After": now.Add(90 * time.Second).Format(http.TimeFormat)}, 90 * time.Second},
+		{"retry-after-ms", map[string]string{"retry-after-ms": "1500", "Retry-After": "2"}, 1500 * time.Millisecond},
+		{"openai exhausted dimension", map[string]string{
+			"x-ratelimit-reset-requests":     "6m0s",
+			"x-ratelimit-remaining-requests": "10",
+			"x-ratelimit-reset-tokens":       "20ms",
+			"x-ratelimit-remaining-tokens":   "0",
+		}, 20 * time.Millisecond},
+		{"anthropic reset time", map[string]string{
+			"anthropic-ratelimit-tokens-reset":     now.Add(30 * time.Second).Format(time.RFC3339),
+			"anthropic-ratelimit-tokens-remaining": "0",
+		}, 30 * time.Second},
+		{"unix timestamp", map[string]string{"x-ratelimit-reset": "1735689660"}, time.Minute},
+		{"none", map[string]string{}, 0},
+	}
+	for _, tc := range cases {
+		header := http.Header{}
+		for k, v := range tc.header {
+			header.Set(k, v)
+		}
+		if got := ParseRetryAfter(header, now); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
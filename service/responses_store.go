@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	responseStoreCleanupTickInterval = 10 * time.Minute
+	responseStoreCleanupBatchSize    = 500
+	// 展开 previous_response_id 时最多回溯的轮数
+	responseStoreMaxChainDepth = 1000
+)
+
+var responseStoreCleanupOnce sync.Once
+
+// responsesInputItems 将 input 规范化为条目数组，字符串 input 视为一条 user 消息
+func responsesInputItems(input json.RawMessage) ([]json.RawMessage, error) {
+	switch common.GetJsonType(input) {
+	case "string":
+		item, err := common.Marshal(map[string]any{
+			"type":    "message",
+			"role":    "user",
+			"content": input,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []json.RawMessage{item}, nil
+	case "array":
+		var items []json.RawMessage
+		if err := common.Unmarshal(input, &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+	return []json.RawMessage{}, nil
+}
+
+// ExpandPreviousResponse 网关侧存在 previous_response_id 对应的响应时，
+// 沿响应链将各轮的 input 与 output 条目拼接到当前 input 之前并清空 previous_response_id，
+// 返回展开所用的响应链；未找到或链已不完整时保持原样，由上游自行处理（不支持的转换渠道会返回错误）
+func ExpandPreviousResponse(c *gin.Context, request *dto.OpenAIResponsesRequest) ([]*model.StoredResponse, error) {
+	if request.PreviousResponseID == "" || !operation_setting.GetResponseStoreSetting().Enabled {
+		return nil, nil
+	}
+	chain, err := model.GetUserStoredResponseChain(c.GetInt("id"), c.GetInt("token_id"), request.PreviousResponseID, responseStoreMaxChainDepth)
+	if err != nil {
+		if errors.Is(err, model.ErrStoredResponseChainBroken) {
+			logger.LogWarn(c, fmt.Sprintf("failed to expand previous response %s: %s", request.PreviousResponseID, err.Error()))
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+
+	items := make([]json.RawMessage, 0)
+	for _, stored := range chain {
+		input, err := responsesInputItems(json.RawMessage(stored.Input))
+		if err != nil {
+			return nil, fmt.Errorf("invalid stored input of %s: %w", stored.ResponseId, err)
+		}
+		var previous struct {
+			Output []json.RawMessage `json:"output"`
+		}
+		if err := common.UnmarshalJsonStr(stored.Response, &previous); err != nil {
+			return nil, fmt.Errorf("invalid stored response of %s: %w", stored.ResponseId, err)
+		}
+		items = append(items, input...)
+		items = append(items, previous.Output...)
+	}
+	current, err := responsesInputItems(request.Input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input: %w", err)
+	}
+	items = append(items, current...)
+
+	input, err := common.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	request.Input = input
+	request.PreviousResponseID = ""
+	return chain, nil
+}
+
+// SaveResponsesResult 保存转换渠道生成的响应，request 为展开前的原始请求，只保存本轮新增的 input；
+// chain 为展开所用的响应链，store 显式为 false 或上游原生处理（未产生转换结果）时不保存
+func SaveResponsesResult(c *gin.Context, request *dto.OpenAIResponsesRequest, chain []*model.StoredResponse) {
+	setting := operation_setting.GetResponseStoreSetting()
+	if !setting.Enabled || strings.TrimSpace(string(request.Store)) == "false" {
+		return
+	}
+	response, ok := common.GetContextKeyType[*dto.OpenAIResponsesResponse](c, constant.ContextKeyResponsesResult)
+	if !ok || response == nil {
+		return
+	}
+
+	items, err := responsesInputItems(request.Input)
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to store response %s: %s", response.ID, err.Error()))
+		return
+	}
+	input, err := common.Marshal(items)
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to store response %s: %s", response.ID, err.Error()))
+		return
+	}
+	stored := *response
+	stored.PreviousResponseID = request.PreviousResponseID
+	responseData, err := common.Marshal(stored)
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to store response %s: %s", response.ID, err.Error()))
+		return
+	}
+
+	record := &model.StoredResponse{
+		ResponseId:         response.ID,
+		UserId:             c.GetInt("id"),
+		TokenId:            c.GetInt("token_id"),
+		PreviousResponseId: request.PreviousResponseID,
+		Model:              response.Model,
+		Input:              string(input),
+		Response:           string(responseData),
+	}
+	if len(chain) > 0 {
+		record.ParentResponseId = chain[len(chain)-1].ResponseId
+	}
+	if setting.RetentionHours > 0 {
+		record.ExpiresAt = time.Now().Add(time.Duration(setting.RetentionHours) * time.Hour).Unix()
+	}
+	if err := record.Insert(); err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to store response %s: %s", response.ID, err.Error()))
+		return
+	}
+	// 链上较早的响应至少保留到与本轮相同的时间，避免历史先于后续轮次过期
+	if len(chain) > 0 {
+		ids := make([]int, 0, len(chain))
+		for _, r := range chain {
+			ids = append(ids, r.Id)
+		}
+		if err := model.ExtendStoredResponsesExpiry(ids, record.ExpiresAt); err != nil {
+			logger.LogWarn(c, fmt.Sprintf("failed to extend response chain of %s: %s", response.ID, err.Error()))
+		}
+	}
+}
+
+// StartResponseStoreCleanupTask 定期删除已过期的网关侧响应
+func StartResponseStoreCleanupTask() {
+	responseStoreCleanupOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("response store cleanup task started: tick=%s", responseStoreCleanupTickInterval))
+			ticker := time.NewTicker(responseStoreCleanupTickInterval)
+			defer ticker.Stop()
+
+			runResponseStoreCleanupOnce()
+			for range ticker.C {
+				runResponseStoreCleanupOnce()
+			}
+		})
+	})
+}
+
+func runResponseStoreCleanupOnce() {
+	ctx := context.Background()
+	var total int64
+	for {
+		deleted, err := model.DeleteExpiredStoredResponses(time.Now().Unix(), responseStoreCleanupBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("response store cleanup task failed: %v", err))
+			return
+		}
+		total += deleted
+		if deleted < responseStoreCleanupBatchSize {
+			break
+		}
+	}
+	if common.DebugEnabled && total > 0 {
+		logger.LogDebug(ctx, "response store cleanup: removed_count=%d", total)
+	}
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesInputItems(t *testing.T) {
+	t.Parallel()
+
+	items, err := responsesInputItems(json.RawMessage(`"hi"`))
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.JSONEq(t, `{"type":"message","role":"user","content":"hi"}`, string(items[0]))
+
+	items, err = responsesInputItems(json.RawMessage(`[{"role":"user","content":"a"},{"type":"function_call_output","call_id":"c","output":"b"}]`))
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	items, err = responsesInputItems(nil)
+	require.NoError(t, err)
+	require.Empty(t, items)
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenPolicyRequestFeatures 令牌策略检查使用的请求特征
+type tokenPolicyRequestFeatures struct {
+	hasTools     bool
+	hasWebSearch bool
+}
+
+// CheckTokenPolicy 按令牌的请求策略检查请求，违反策略时返回 400，超过上限的最大输出 token 数和推理级别会被降低
+func CheckTokenPolicy(c *gin.Context, relayFormat types.RelayFormat, request dto.Request) *types.NewAPIError {
+	policy, ok := common.GetContextKeyType[*dto.TokenPolicy](c, constant.ContextKeyTokenPolicy)
+	if !ok || policy == nil {
+		return nil
+	}
+	if err := checkTokenPolicy(c, policy, relayFormat, request); err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeTokenPolicyDenied, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	return nil
+}
+
+func checkTokenPolicy(c *gin.Context, policy *dto.TokenPolicy, relayFormat types.RelayFormat, request dto.Request) error {
+	if len(policy.AllowedEndpoints) > 0 {
+		endpoint := string(relayFormat)
+		// Gemini 的向量接口与对话共用 RelayFormatGemini
+		path := c.Request.URL.Path
+		if relayFormat == types.RelayFormatGemini && (strings.Contains(path, ":embedContent") || strings.Contains(path, ":batchEmbedContents")) {
+			endpoint = types.RelayFormatEmbedding
+		}
+		if !common.StringsContains(policy.AllowedEndpoints, endpoint) {
+			return fmt.Errorf("this token is not allowed to access %s endpoint", endpoint)
+		}
+	}
+	if policy.ForbidStream && request.IsStream(c) {
+		return fmt.Errorf("this token is not allowed to use streaming")
+	}
+	if policy.ForbidImageInput {
+		for _, file := range request.GetTokenCountMeta().Files {
+			if file.FileType == types.FileTypeImage {
+				return fmt.Errorf("this token is not allowed to send image inputs")
+			}
+		}
+	}
+	if policy.ForbidTools || policy.ForbidWebSearch {
+		features := getTokenPolicyRequestFeatures(request)
+		if policy.ForbidTools && features.hasTools {
+			return fmt.Errorf("this token is not allowed to use tools")
+		}
+		if policy.ForbidWebSearch && features.hasWebSearch {
+			return fmt.Errorf("this token is not allowed to use web search")
+		}
+	}
+	if policy.MaxOutputTokens > 0 {
+		limitMaxOutputTokens(request, uint(policy.MaxOutputTokens))
+	}
+	if policy.MaxReasoningEffort != "" {
+		modelName := common.GetContextKeyString(c, constant.ContextKeyOriginalModel)
+		if err := limitReasoningEffort(modelName, request, policy.MaxReasoningEffort); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getTokenPolicyRequestFeatures(request dto.Request) tokenPolicyRequestFeatures {
+	var features tokenPolicyRequestFeatures
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		features.hasTools = len(r.Tools) > 0 || len(r.Functions) > 0
+		features.hasWebSearch = r.WebSearchOptions != nil || len(r.WebSearch) > 0
+	case *dto.OpenAIResponsesRequest:
+		for _, tool := range r.GetToolsMap() {
+			collectTokenPolicyToolType(&features, common.Interface2String(tool["type"]))
+		}
+	case *dto.ClaudeRequest:
+		for _, tool := range r.GetTools() {
+			switch t := tool.(type) {
+			case map[string]any:
+				collectTokenPolicyToolType(&features, common.Interface2String(t["type"]))
+			case *dto.ClaudeWebSearchTool, dto.ClaudeWebSearchTool:
+				features.hasWebSearch = true
+			default:
+				features.hasTools = true
+			}
+		}
+	case *dto.GeminiChatRequest:
+		for _, tool := range r.GetTools() {
+			if tool.GoogleSearch != nil || tool.GoogleSearchRetrieval != nil {
+				features.hasWebSearch = true
+			}
+			if tool.FunctionDeclarations != nil || tool.CodeExecution != nil || tool.URLContext != nil {
+				features.hasTools = true
+			}
+		}
+	}
+	return features
+}
+
+// collectTokenPolicyToolType 按工具类型区分联网搜索（如 web_search_preview、web_search_20250305）和其他工具
+func collectTokenPolicyToolType(features *tokenPolicyRequestFeatures, toolType string) {
+	if strings.HasPrefix(toolType, "web_search") {
+		features.hasWebSearch = true
+	} else {
+		features.hasTools = true
+	}
+}
+
+// limitMaxOutputTokens 将超过上限的最大输出 token 数降低到上限，未设置时使用上限；没有输出 token 数参数的请求不处理
+func limitMaxOutputTokens(request dto.Request, maxTokens uint) {
+	limit := func(value *uint) {
+		if *value > maxTokens {
+			*value = maxTokens
+		}
+	}
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		if r.MaxTokens == 0 && r.MaxCompletionTokens == 0 {
+			r.MaxTokens = maxTokens
+		}
+		limit(&r.MaxTokens)
+		limit(&r.MaxCompletionTokens)
+	case *dto.OpenAIResponsesRequest:
+		if r.MaxOutputTokens == 0 {
+			r.MaxOutputTokens = maxTokens
+		}
+		limit(&r.MaxOutputTokens)
+	case *dto.ClaudeRequest:
+		if r.MaxTokens == 0 && r.MaxTokensToSample == 0 {
+			r.MaxTokens = maxTokens
+		}
+		limit(&r.MaxTokens)
+		limit(&r.MaxTokensToSample)
+		// 思考预算必须小于 max_tokens
+		if r.Thinking != nil && r.Thinking.BudgetTokens != nil && r.MaxTokens > 0 && *r.Thinking.BudgetTokens >= int(r.MaxTokens) {
+			r.Thinking.BudgetTokens = common.GetPointer(int(r.MaxTokens) - 1)
+		}
+	case *dto.GeminiChatRequest:
+		if r.GenerationConfig.MaxOutputTokens == 0 {
+			r.GenerationConfig.MaxOutputTokens = maxTokens
+		}
+		limit(&r.GenerationConfig.MaxOutputTokens)
+	}
+}
+
+// reasoningEffortBudgetTokens 各 reasoning_effort 对应的思考预算上限，用于限制 Claude 和 Gemini 的思考预算
+var reasoningEffortBudgetTokens = map[string]int{
+	"none":    0,
+	"minimal": 1024,
+	"low":     1280,
+	"medium":  2048,
+	"high":    4096,
+}
+
+// modelSuffixReasoningEffort 解析模型名称后缀中的推理级别（如 o3-mini-high、gpt-5-max），
+// -thinking 视为 high，-thinking-<预算> 按预算折算
+func modelSuffixReasoningEffort(modelName string) (string, bool) {
+	if idx := strings.LastIndex(modelName, "-thinking-"); idx >= 0 {
+		if budget, err := strconv.Atoi(modelName[idx+len("-thinking-"):]); err == nil {
+			return reasoningEffortOfBudget(budget), true
+		}
+	}
+	if strings.HasSuffix(modelName, "-thinking") {
+		return "high", true
+	}
+	if strings.HasSuffix(modelName, "-max") {
+		return "xhigh", true
+	}
+	for _, effort := range dto.ReasoningEffortLevels {
+		if strings.HasSuffix(modelName, "-"+effort) {
+			return effort, true
+		}
+	}
+	return "", false
+}
+
+// reasoningEffortOfBudget 返回能覆盖该思考预算的最低推理级别
+func reasoningEffortOfBudget(budget int) string {
+	for _, effort := range dto.ReasoningEffortLevels {
+		if limit, ok := reasoningEffortBudgetTokens[effort]; ok && budget <= limit {
+			return effort
+		}
+	}
+	return "xhigh"
+}
+
+// limitReasoningEffort 将请求中超过上限的推理级别和思考预算降低到上限，
+// 推理级别由模型名称后缀指定且超过上限时无法降低，返回错误
+func limitReasoningEffort(modelName string, request dto.Request, maxEffort string) error {
+	maxLevel := slices.Index(dto.ReasoningEffortLevels, maxEffort)
+	if maxLevel < 0 || maxLevel == len(dto.ReasoningEffortLevels)-1 {
+		return nil
+	}
+	if effort, ok := modelSuffixReasoningEffort(modelName); ok && slices.Index(dto.ReasoningEffortLevels, effort) > maxLevel {
+		return fmt.Errorf("reasoning effort %s of model %s exceeds the limit of this token: %s", effort, modelName, maxEffort)
+	}
+	limit := func(effort string) string {
+		if slices.Index(dto.ReasoningEffortLevels, effort) > maxLevel {
+			return maxEffort
+		}
+		return effort
+	}
+	maxBudget := reasoningEffortBudgetTokens[maxEffort]
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		r.ReasoningEffort = limit(r.ReasoningEffort)
+		if len(r.Reasoning) > 0 {
+			var reasoning map[string]any
+			if err := common.Unmarshal(r.Reasoning, &reasoning); err != nil {
+				return fmt.Errorf("invalid reasoning: %w", err)
+			}
+			if effort, ok := reasoning["effort"].(string); ok {
+				reasoning["effort"] = limit(effort)
+			}
+			if budget, ok := reasoning["max_tokens"].(float64); ok && int(budget) > maxBudget {
+				reasoning["max_tokens"] = maxBudget
+			}
+			data, err := common.Marshal(reasoning)
+			if err != nil {
+				return err
+			}
+			r.Reasoning = data
+		}
+	case *dto.OpenAIResponsesRequest:
+		if r.Reasoning != nil {
+			r.Reasoning.Effort = limit(r.Reasoning.Effort)
+		}
+	case *dto.ClaudeRequest:
+		return limitClaudeThinking(r, maxEffort, maxBudget)
+	case *dto.GeminiChatRequest:
+		thinkingConfig := r.GenerationConfig.ThinkingConfig
+		if thinkingConfig == nil {
+			return nil
+		}
+		// thinkingBudget 为 -1 表示动态预算，同样需要限制
+		if thinkingConfig.ThinkingBudget != nil && (*thinkingConfig.ThinkingBudget < 0 || *thinkingConfig.ThinkingBudget > maxBudget) {
+			thinkingConfig.ThinkingBudget = common.GetPointer(maxBudget)
+		}
+		if thinkingConfig.ThinkingLevel != "" {
+			thinkingConfig.ThinkingLevel = limit(strings.ToLower(thinkingConfig.ThinkingLevel))
+		}
+	}
+	return nil
+}
+
+// claudeEffortLevels Claude output_config.effort 取值从低到高排列
+var claudeEffortLevels = []string{"low", "medium", "high", "max"}
+
+// limitClaudeThinking 限制 Claude 的思考预算和 output_config.effort，上限为 none 时关闭思考
+func limitClaudeThinking(r *dto.ClaudeRequest, maxEffort string, maxBudget int) error {
+	if r.Thinking != nil && r.Thinking.Type != "disabled" {
+		if maxBudget < 1024 {
+			r.Thinking = &dto.Thinking{Type: "disabled"}
+		} else if r.Thinking.BudgetTokens != nil && *r.Thinking.BudgetTokens > maxBudget {
+			r.Thinking.BudgetTokens = common.GetPointer(maxBudget)
+		}
+	}
+	if len(r.OutputConfig) == 0 {
+		return nil
+	}
+	var outputConfig map[string]any
+	if err := common.Unmarshal(r.OutputConfig, &outputConfig); err != nil {
+		return fmt.Errorf("invalid output_config: %w", err)
+	}
+	effort, ok := outputConfig["effort"].(string)
+	if !ok {
+		return nil
+	}
+	maxClaudeEffort := maxEffort
+	if !slices.Contains(claudeEffortLevels, maxClaudeEffort) {
+		maxClaudeEffort = claudeEffortLevels[0]
+	}
+	if slices.Index(claudeEffortLevels, effort) <= slices.Index(claudeEffortLevels, maxClaudeEffort) {
+		return nil
+	}
+	outputConfig["effort"] = maxClaudeEffort
+	data, err := common.Marshal(outputConfig)
+	if err != nil {
+		return err
+	}
+	r.OutputConfig = data
+	return nil
+}
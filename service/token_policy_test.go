@@ -0,0 +1,118 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func checkTokenPolicyRequest(t *testing.T, policy *dto.TokenPolicy, path string, relayFormat types.RelayFormat, body string) (dto.Request, *types.NewAPIError) {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", path, nil)
+	common.SetContextKey(c, constant.ContextKeyTokenPolicy, policy)
+	var request dto.Request
+	switch relayFormat {
+	case types.RelayFormatGemini:
+		request = &dto.GeminiChatRequest{}
+	case types.RelayFormatOpenAIImage:
+		request = &dto.ImageRequest{}
+	case types.RelayFormatClaude:
+		request = &dto.ClaudeRequest{}
+	case types.RelayFormatOpenAIResponses:
+		request = &dto.OpenAIResponsesRequest{}
+	case types.RelayFormatEmbedding:
+		request = &dto.EmbeddingRequest{}
+	default:
+		request = &dto.GeneralOpenAIRequest{}
+	}
+	if err := common.UnmarshalJsonStr(body, request); err != nil {
+		t.Fatal(err)
+	}
+	var fields struct {
+		Model string `json:"model"`
+	}
+	_ = common.UnmarshalJsonStr(body, &fields)
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, fields.Model)
+	return request, CheckTokenPolicy(c, relayFormat, request)
+}
+
+func TestCheckTokenPolicy(t *testing.T) {
+	policy := &dto.TokenPolicy{
+		MaxOutputTokens:    1000,
+		ForbidStream:       true,
+		ForbidWebSearch:    true,
+		ForbidImageInput:   true,
+		MaxReasoningEffort: "medium",
+	}
+	cases := []struct {
+		name        string
+		path        string
+		relayFormat types.RelayFormat
+		body        string
+		denied      bool
+	}{
+		{"allowed", "/v1/chat/completions", types.RelayFormatOpenAI, `{"model":"o3","max_tokens":1000,"messages":[{"role":"user","content":"hi"}]}`, false},
+		{"image generation", "/v1/images/generations", types.RelayFormatOpenAIImage, `{"model":"dall-e-3","prompt":"cat"}`, false},
+		{"effort suffix", "/v1/chat/completions", types.RelayFormatOpenAI, `{"model":"o3-mini-high"}`, true},
+		{"thinking suffix", "/v1/messages", types.RelayFormatClaude, `{"model":"claude-3-7-sonnet-20250219-thinking","max_tokens":100}`, true},
+		{"thinking budget suffix", "/v1/chat/completions", types.RelayFormatOpenAI, `{"model":"gemini-2.5-flash-thinking-1024"}`, false},
+		{"stream", "/v1/chat/completions", types.RelayFormatOpenAI, `{"model":"o3","stream":true}`, true},
+		{"image", "/v1/chat/completions", types.RelayFormatOpenAI, `{"model":"o3","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}]}`, true},
+		{"function tools allowed", "/v1/messages", types.RelayFormatClaude, `{"model":"claude","max_tokens":100,"tools":[{"name":"get_weather","input_schema":{}}]}`, false},
+		{"claude web search", "/v1/messages", types.RelayFormatClaude, `{"model":"claude","max_tokens":100,"tools":[{"type":"web_search_20250305","name":"web_search"}]}`, true},
+		{"responses web search", "/v1/responses", types.RelayFormatOpenAIResponses, `{"model":"o3","tools":[{"type":"web_search_preview"}]}`, true},
+	}
+	for _, tc := range cases {
+		_, err := checkTokenPolicyRequest(t, policy, tc.path, tc.relayFormat, tc.body)
+		if (err != nil) != tc.denied {
+			t.Errorf("%s: denied = %v, want %v (%v)", tc.name, err != nil, tc.denied, err)
+		}
+		if err != nil && (err.StatusCode != 400 || err.GetErrorCode() != types.ErrorCodeTokenPolicyDenied) {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+	}
+
+	request, _ := checkTokenPolicyRequest(t, policy, "/v1/chat/completions", types.RelayFormatOpenAI, `{"model":"o3","reasoning_effort":"high"}`)
+	if effort := request.(*dto.GeneralOpenAIRequest).ReasoningEffort; effort != "medium" {
+		t.Errorf("reasoning effort = %s, want medium", effort)
+	}
+	if maxTokens := request.(*dto.GeneralOpenAIRequest).MaxTokens; maxTokens != 1000 {
+		t.Errorf("max tokens = %d, want the inserted limit 1000", maxTokens)
+	}
+	request, _ = checkTokenPolicyRequest(t, policy, "/v1/chat/completions", types.RelayFormatOpenAI, `{"model":"o3","max_completion_tokens":1001}`)
+	if maxTokens := request.(*dto.GeneralOpenAIRequest).MaxCompletionTokens; maxTokens != 1000 {
+		t.Errorf("max completion tokens = %d, want 1000", maxTokens)
+	}
+
+	request, _ = checkTokenPolicyRequest(t, policy, "/v1/messages", types.RelayFormatClaude,
+		`{"model":"claude-sonnet-4","max_tokens":8000,"thinking":{"type":"enabled","budget_tokens":6000},"output_config":{"effort":"max"}}`)
+	claudeRequest := request.(*dto.ClaudeRequest)
+	if claudeRequest.MaxTokens != 1000 || claudeRequest.Thinking.GetBudgetTokens() != 999 {
+		t.Errorf("claude max tokens = %d, budget = %d", claudeRequest.MaxTokens, claudeRequest.Thinking.GetBudgetTokens())
+	}
+	if outputConfig := string(claudeRequest.OutputConfig); outputConfig != `{"effort":"medium"}` {
+		t.Errorf("claude output config = %s, want effort medium", outputConfig)
+	}
+
+	request, _ = checkTokenPolicyRequest(t, policy, "/v1beta/models/gemini-2.5-flash:generateContent", types.RelayFormatGemini,
+		`{"contents":[],"generationConfig":{"thinkingConfig":{"thinkingBudget":-1}}}`)
+	geminiConfig := request.(*dto.GeminiChatRequest).GenerationConfig
+	if geminiConfig.MaxOutputTokens != 1000 || *geminiConfig.ThinkingConfig.ThinkingBudget != 2048 {
+		t.Errorf("gemini max output tokens = %d, thinking budget = %d", geminiConfig.MaxOutputTokens, *geminiConfig.ThinkingConfig.ThinkingBudget)
+	}
+
+	embeddingsOnly := &dto.TokenPolicy{AllowedEndpoints: []string{types.RelayFormatEmbedding}}
+	if _, err := checkTokenPolicyRequest(t, embeddingsOnly, "/v1/chat/completions", types.RelayFormatOpenAI, `{"model":"gpt-4o"}`); err == nil {
+		t.Error("chat completions should be denied for embeddings only token")
+	}
+	if _, err := checkTokenPolicyRequest(t, embeddingsOnly, "/v1/embeddings", types.RelayFormatEmbedding, `{"model":"text-embedding-3-small","input":"hi"}`); err != nil {
+		t.Errorf("embeddings should be allowed: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tokenRateLimitRedisPrefix = "new-api:token_rate_limit:v1"
+
+// tokenRateLimitCounter 一个限流维度在一个时间窗口内的计数器
+type tokenRateLimitCounter struct {
+	limiter.TokenCounter
+	// 超限时返回给用户的说明，如 "令牌 TPM"
+	scope string
+}
+
+// TokenRateLimitReservation 本次请求预占的 token，结算或失败时修正一次
+type TokenRateLimitReservation struct {
+	counters []tokenRateLimitCounter
+	reserved int
+	done     atomic.Bool
+}
+
+// memoryTokenCounters 未启用 Redis 时的限流计数，键名包含窗口编号，过期后清理
+var memoryTokenCounters = struct {
+	sync.Mutex
+	values    map[string]int64
+	expires   map[string]int64
+	lastPurge int64
+}{values: map[string]int64{}, expires: map[string]int64{}}
+
+func buildTokenRateLimitCounters(c *gin.Context, relayInfo *relaycommon.RelayInfo) []tokenRateLimitCounter {
+	setting := operation_setting.GetTokenRateLimitSetting()
+	if !setting.Enabled {
+		return nil
+	}
+	now := time.Now().Unix()
+	minute, day := now/60, now/86400
+	counters := make([]tokenRateLimitCounter, 0, 6)
+	add := func(scope string, subject string, limit operation_setting.TokenRateLimit) {
+		if limit.TPM > 0 {
+			counters = append(counters, tokenRateLimitCounter{
+				TokenCounter: limiter.TokenCounter{Key: fmt.Sprintf("%s:%s:m:%d", tokenRateLimitRedisPrefix, subject, minute), Limit: int64(limit.TPM), TTLSeconds: 120},
+				scope:        scope + " TPM",
+			})
+		}
+		if limit.TPD > 0 {
+			counters = append(counters, tokenRateLimitCounter{
+				TokenCounter: limiter.TokenCounter{Key: fmt.Sprintf("%s:%s:d:%d", tokenRateLimitRedisPrefix, subject, day), Limit: int64(limit.TPD), TTLSeconds: 86400 + 60},
+				scope:        scope + " TPD",
+			})
+		}
+	}
+
+	add("令牌", fmt.Sprintf("token:%d", relayInfo.TokenId), operation_setting.TokenRateLimit{
+		TPM: common.GetContextKeyInt(c, constant.ContextKeyTokenTPM),
+		TPD: common.GetContextKeyInt(c, constant.ContextKeyTokenTPD),
+	})
+	// 与请求数限流一致，优先使用令牌分组
+	group := common.GetContextKeyString(c, constant.ContextKeyTokenGroup)
+	if group == "" {
+		group = common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+	}
+	if limit, ok := setting.GroupLimits[group]; ok {
+		add("分组 "+group, fmt.Sprintf("user:%d", relayInfo.UserId), limit)
+	}
+	if limit, ok := setting.ModelLimits[relayInfo.OriginModelName]; ok {
+		add("模型 "+relayInfo.OriginModelName, fmt.Sprintf("user:%d:model:%s", relayInfo.UserId, relayInfo.OriginModelName), limit)
+	}
+	return counters
+}
+
+// reserveCounters 检查所有计数器，全部未超限时各增加 requested，返回第一个超限计数器的下标，未超限时返回 -1
+func reserveCounters(ctx context.Context, counters []limiter.TokenCounter, requested int64) (int, error) {
+	if common.RedisEnabled && common.RDB != nil {
+		return limiter.ReserveTokens(ctx, common.RDB, counters, requested)
+	}
+	return reserveMemoryCounters(counters, requested), nil
+}
+
+func reserveMemoryCounters(counters []limiter.TokenCounter, requested int64) int {
+	store := &memoryTokenCounters
+	store.Lock()
+	defer store.Unlock()
+	now := time.Now().Unix()
+	if now-store.lastPurge >= 60 {
+		for key, expireAt := range store.expires {
+			if expireAt <= now {
+				delete(store.values, key)
+				delete(store.expires, key)
+			}
+		}
+		store.lastPurge = now
+	}
+	for i, counter := range counters {
+		used := store.values[counter.Key]
+		if used >= counter.Limit || used+requested > counter.Limit {
+			return i
+		}
+	}
+	for _, counter := range counters {
+		store.values[counter.Key] += requested
+		store.expires[counter.Key] = now + counter.TTLSeconds
+	}
+	return -1
+}
+
+func adjustTokenCounters(counters []tokenRateLimitCounter, delta int64) {
+	if common.RedisEnabled && common.RDB != nil {
+		ctx := context.Background()
+		pipe := common.RDB.Pipeline()
+		for _, counter := range counters {
+			pipe.IncrBy(ctx, counter.Key, delta)
+			pipe.Expire(ctx, counter.Key, time.Duration(counter.TTLSeconds)*time.Second)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			common.SysError("failed to adjust token rate limit: " + err.Error())
+		}
+		return
+	}
+	store := &memoryTokenCounters
+	store.Lock()
+	defer store.Unlock()
+	for _, counter := range counters {
+		if _, ok := store.values[counter.Key]; ok {
+			store.values[counter.Key] += delta
+		}
+	}
+}
+
+// ReserveTokenRateLimit 检查令牌、分组和模型的 TPM/TPD 限制，并按预估的提示 token 数预占
+func ReserveTokenRateLimit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *types.NewAPIError {
+	counters := buildTokenRateLimitCounters(c, relayInfo)
+	if len(counters) == 0 {
+		return nil
+	}
+	requested := int64(relayInfo.GetEstimatePromptTokens())
+	tokenCounters := make([]limiter.TokenCounter, 0, len(counters))
+	for _, counter := range counters {
+		tokenCounters = append(tokenCounters, counter.TokenCounter)
+	}
+	exceeded, err := reserveCounters(c.Request.Context(), tokenCounters, requested)
+	if err != nil {
+		// 计数失败时不阻塞请求
+		common.SysError(err.Error())
+		return nil
+	}
+	if exceeded >= 0 {
+		counter := counters[exceeded]
+		return types.NewErrorWithStatusCode(fmt.Errorf("已达到%s 限制：%d tokens", counter.scope, counter.Limit),
+			types.ErrorCodeRateLimitExceeded, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+	}
+	common.SetContextKey(c, constant.ContextKeyTokenRateLimitReservation, &TokenRateLimitReservation{
+		counters: counters,
+		reserved: int(requested),
+	})
+	return nil
+}
+
+func getTokenRateLimitReservation(c *gin.Context) *TokenRateLimitReservation {
+	reservation, ok := common.GetContextKeyType[*TokenRateLimitReservation](c, constant.ContextKeyTokenRateLimitReservation)
+	if !ok || reservation == nil || !reservation.done.CompareAndSwap(false, true) {
+		return nil
+	}
+	return reservation
+}
+
+// AdjustTokenRateLimitUsage 结算后用实际 token 数修正预占的计数
+func AdjustTokenRateLimitUsage(c *gin.Context, actualTokens int) {
+	reservation := getTokenRateLimitReservation(c)
+	if reservation == nil {
+		return
+	}
+	if delta := int64(actualTokens - reservation.reserved); delta != 0 {
+		adjustTokenCounters(reservation.counters, delta)
+	}
+}
+
+// ReleaseTokenRateLimit 请求失败时归还预占的 token
+func ReleaseTokenRateLimit(c *gin.Context) {
+	reservation := getTokenRateLimitReservation(c)
+	if reservation == nil || reservation.reserved == 0 {
+		return
+	}
+	adjustTokenCounters(reservation.counters, -int64(reservation.reserved))
+}
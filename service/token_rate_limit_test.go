@@ -0,0 +1,64 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTokenRateLimitRequest(estimatedTokens int) (*gin.Context, *relaycommon.RelayInfo) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	common.SetContextKey(c, constant.ContextKeyUserGroup, "default")
+	common.SetContextKey(c, constant.ContextKeyTokenTPD, 1000)
+	info := &relaycommon.RelayInfo{UserId: 900201, TokenId: 900201, OriginModelName: "gpt-4o"}
+	info.SetEstimatePromptTokens(estimatedTokens)
+	return c, info
+}
+
+func TestTokenRateLimit(t *testing.T) {
+	setting := operation_setting.GetTokenRateLimitSetting()
+	saved := *setting
+	defer func() { *setting = saved }()
+	setting.Enabled = true
+	setting.GroupLimits = map[string]operation_setting.TokenRateLimit{"default": {TPM: 100}}
+	setting.ModelLimits = map[string]operation_setting.TokenRateLimit{}
+
+	c1, info1 := newTokenRateLimitRequest(60)
+	if err := ReserveTokenRateLimit(c1, info1); err != nil {
+		t.Fatalf("first reserve failed: %v", err)
+	}
+	c2, info2 := newTokenRateLimitRequest(60)
+	err := ReserveTokenRateLimit(c2, info2)
+	if err == nil || err.GetErrorCode() != types.ErrorCodeRateLimitExceeded || err.StatusCode != 429 {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+
+	// 实际用量少于预估时归还差额，修正只生效一次
+	AdjustTokenRateLimitUsage(c1, 20)
+	AdjustTokenRateLimitUsage(c1, 0)
+	if err := ReserveTokenRateLimit(c2, info2); err != nil {
+		t.Fatalf("reserve after adjust failed: %v", err)
+	}
+	// 失败的请求归还预占
+	ReleaseTokenRateLimit(c2)
+	c3, info3 := newTokenRateLimitRequest(80)
+	if err := ReserveTokenRateLimit(c3, info3); err != nil {
+		t.Fatalf("reserve after release failed: %v", err)
+	}
+
+	// 令牌 TPD 按令牌计数
+	c4, info4 := newTokenRateLimitRequest(0)
+	setting.GroupLimits = map[string]operation_setting.TokenRateLimit{}
+	AdjustTokenRateLimitUsage(c3, 2000)
+	if err := ReserveTokenRateLimit(c4, info4); err == nil {
+		t.Fatal("expected token tpd limit error")
+	}
+}
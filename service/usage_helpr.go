@@ -31,3 +31,31 @@ func ResponseText2Usage(c *gin.Context, responseText string, modeName string, pr
 func ValidUsage(usage *dto.Usage) bool {
 	return usage != nil && (usage.PromptTokens != 0 || usage.CompletionTokens != 0)
 }
+
+// BuildResponsesUsage 按 OpenAI Responses 的语义生成返回给客户端的 usage，
+// inputTokens 为包含缓存读取和写入在内的全部输入 tokens
+func BuildResponsesUsage(usage *dto.Usage, inputTokens int) *dto.Usage {
+	cachedTokens := usage.PromptTokensDetails.CachedTokens
+	return &dto.Usage{
+		PromptTokens:     inputTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      inputTokens + usage.CompletionTokens,
+		PromptTokensDetails: dto.InputTokenDetails{
+			CachedTokens:         cachedTokens,
+			CachedCreationTokens: usage.PromptTokensDetails.CachedCreationTokens,
+		},
+		CompletionTokenDetails: dto.OutputTokenDetails{
+			ReasoningTokens: usage.CompletionTokenDetails.ReasoningTokens,
+		},
+		InputTokens:  inputTokens,
+		OutputTokens: usage.CompletionTokens,
+		InputTokensDetails: &dto.InputTokenDetails{
+			CachedTokens: cachedTokens,
+		},
+		OutputTokensDetails: &dto.OutputTokenDetails{
+			ReasoningTokens: usage.CompletionTokenDetails.ReasoningTokens,
+		},
+		ClaudeCacheCreation5mTokens: usage.ClaudeCacheCreation5mTokens,
+		ClaudeCacheCreation1hTokens: usage.ClaudeCacheCreation1hTokens,
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// virtualModelRequestFeatures 规则匹配使用的请求体特征
+type virtualModelRequestFeatures struct {
+	promptTokens int
+	hasTools     bool
+	hasImages    bool
+}
+
+// ResolveVirtualModel 按规则顺序解析虚拟模型，返回命中的规则；modelName 不是虚拟模型时返回 nil
+func ResolveVirtualModel(c *gin.Context, modelName string) (*operation_setting.VirtualModelRule, error) {
+	virtualModel, ok := operation_setting.GetVirtualModelSetting().GetVirtualModel(modelName)
+	if !ok {
+		return nil, nil
+	}
+	var features *virtualModelRequestFeatures
+	for i := range virtualModel.Rules {
+		rule := &virtualModel.Rules[i]
+		if rule.Model == "" {
+			continue
+		}
+		if rule.NeedsRequestBody() && features == nil {
+			var err error
+			features, err = getVirtualModelRequestFeatures(c, modelName)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if matchVirtualModelRule(c, rule, features) {
+			common.SetContextKey(c, constant.ContextKeyVirtualModel, modelName)
+			return rule, nil
+		}
+	}
+	return nil, fmt.Errorf("no rule of virtual model %s matches the request", modelName)
+}
+
+func matchVirtualModelRule(c *gin.Context, rule *operation_setting.VirtualModelRule, features *virtualModelRequestFeatures) bool {
+	if len(rule.TokenIds) > 0 && !slices.Contains(rule.TokenIds, common.GetContextKeyInt(c, constant.ContextKeyTokenId)) {
+		return false
+	}
+	if len(rule.UserGroups) > 0 && !common.StringsContains(rule.UserGroups, common.GetContextKeyString(c, constant.ContextKeyUserGroup)) {
+		return false
+	}
+	for name, value := range rule.Headers {
+		header := c.GetHeader(name)
+		if header == "" || (value != "" && header != value) {
+			return false
+		}
+	}
+	if !rule.NeedsRequestBody() {
+		return true
+	}
+	if rule.MinPromptTokens > 0 && features.promptTokens < rule.MinPromptTokens {
+		return false
+	}
+	if rule.MaxPromptTokens > 0 && features.promptTokens > rule.MaxPromptTokens {
+		return false
+	}
+	if rule.HasTools != nil && *rule.HasTools != features.hasTools {
+		return false
+	}
+	if rule.HasImages != nil && *rule.HasImages != features.hasImages {
+		return false
+	}
+	return true
+}
+
+func getVirtualModelRequestFeatures(c *gin.Context, modelName string) (*virtualModelRequestFeatures, error) {
+	features := &virtualModelRequestFeatures{}
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return nil, err
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	// 非 JSON 请求（如表单上传）没有可匹配的特征
+	if !gjson.ValidBytes(body) {
+		return features, nil
+	}
+	root := gjson.ParseBytes(body)
+	for _, key := range []string{"tools", "functions"} {
+		if tools := root.Get(key); tools.IsArray() && len(tools.Array()) > 0 {
+			features.hasTools = true
+		}
+	}
+	var text strings.Builder
+	collectVirtualModelFeatures(root, "", &text, features)
+	features.promptTokens = EstimateTokenByModel(modelName, text.String())
+	return features, nil
+}
+
+// collectVirtualModelFeatures 遍历请求体，收集提示文本并检查是否包含图片，兼容 OpenAI、Claude 和 Gemini 格式
+func collectVirtualModelFeatures(value gjson.Result, key string, text *strings.Builder, features *virtualModelRequestFeatures) {
+	switch {
+	case value.IsObject():
+		switch value.Get("type").String() {
+		case "image_url", "image", "input_image":
+			features.hasImages = true
+		}
+		for _, path := range []string{"inline_data.mime_type", "inlineData.mimeType", "file_data.mime_type", "fileData.mimeType"} {
+			if strings.HasPrefix(value.Get(path).String(), "image/") {
+				features.hasImages = true
+			}
+		}
+		value.ForEach(func(k, v gjson.Result) bool {
+			collectVirtualModelFeatures(v, k.String(), text, features)
+			return true
+		})
+	case value.IsArray():
+		value.ForEach(func(_, v gjson.Result) bool {
+			collectVirtualModelFeatures(v, key, text, features)
+			return true
+		})
+	case value.Type == gjson.String:
+		switch key {
+		case "content", "text", "prompt", "input", "system", "instructions":
+			text.WriteString(value.String())
+			text.WriteByte('\n')
+		}
+	}
+}
+
+// GetVisibleVirtualModels 返回用户分组可以使用的虚拟模型名称
+func GetVisibleVirtualModels(userGroup string) []string {
+	setting := operation_setting.GetVirtualModelSetting()
+	if !setting.Enabled {
+		return nil
+	}
+	models := make([]string, 0, len(setting.Models))
+	for name, virtualModel := range setting.Models {
+		for _, rule := range virtualModel.Rules {
+			if len(rule.UserGroups) == 0 || common.StringsContains(rule.UserGroups, userGroup) {
+				models = append(models, name)
+				break
+			}
+		}
+	}
+	return models
+}
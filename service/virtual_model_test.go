@@ -0,0 +1,77 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newVirtualModelTestContext(body string, header map[string]string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	for k, v := range header {
+		c.Request.Header.Set(k, v)
+	}
+	common.SetContextKey(c, constant.ContextKeyUserGroup, "default")
+	return c
+}
+
+func TestResolveVirtualModel(t *testing.T) {
+	setting := operation_setting.GetVirtualModelSetting()
+	saved := *setting
+	defer func() { *setting = saved }()
+	yes := true
+	setting.Enabled = true
+	setting.Models = map[string]operation_setting.VirtualModel{
+		"cheap-fast": {Rules: []operation_setting.VirtualModelRule{
+			{Headers: map[string]string{"X-Tier": "premium"}, Model: "gpt-4o", Group: "vip"},
+			{HasImages: &yes, Model: "gpt-4o-mini"},
+			{HasTools: &yes, Model: "gpt-4.1-mini"},
+			{MaxPromptTokens: 100, Model: "gpt-4.1-nano"},
+			{UserGroups: []string{"vip"}, Model: "gpt-4.1"},
+		}},
+	}
+
+	cases := []struct {
+		name   string
+		body   string
+		header map[string]string
+		model  string
+	}{
+		{"header", `{"messages":[{"role":"user","content":"hi"}]}`, map[string]string{"X-Tier": "premium"}, "gpt-4o"},
+		{"images", `{"messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}]}`, nil, "gpt-4o-mini"},
+		{"gemini images", `{"contents":[{"parts":[{"inline_data":{"mime_type":"image/png","data":"AAAA"}}]}]}`, nil, "gpt-4o-mini"},
+		{"tools", `{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function"}]}`, nil, "gpt-4.1-mini"},
+		{"short prompt", `{"messages":[{"role":"user","content":"hi"}]}`, nil, "gpt-4.1-nano"},
+	}
+	for _, tc := range cases {
+		c := newVirtualModelTestContext(tc.body, tc.header)
+		rule, err := ResolveVirtualModel(c, "cheap-fast")
+		if err != nil || rule == nil || rule.Model != tc.model {
+			t.Fatalf("%s: unexpected rule %+v, err %v", tc.name, rule, err)
+		}
+		if got := common.GetContextKeyString(c, constant.ContextKeyVirtualModel); got != "cheap-fast" {
+			t.Fatalf("%s: unexpected virtual model in context: %q", tc.name, got)
+		}
+	}
+
+	// 长提示且不满足其他条件时没有命中的规则
+	c := newVirtualModelTestContext(`{"messages":[{"role":"user","content":"`+strings.Repeat("hello world ", 200)+`"}]}`, nil)
+	if _, err := ResolveVirtualModel(c, "cheap-fast"); err == nil {
+		t.Fatal("expected error when no rule matches")
+	}
+
+	if rule, err := ResolveVirtualModel(newVirtualModelTestContext(`{}`, nil), "gpt-4o"); rule != nil || err != nil {
+		t.Fatalf("real model should not be resolved: %+v, %v", rule, err)
+	}
+	if got := GetVisibleVirtualModels("default"); len(got) != 1 || got[0] != "cheap-fast" {
+		t.Fatalf("unexpected visible virtual models: %v", got)
+	}
+}
@@ -0,0 +1,43 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// BatchSetting /v1/batches 相关配置
+type BatchSetting struct {
+	// 是否启用 Batch API
+	Enabled bool `json:"enabled"`
+	// 单个批次内同时执行的请求数
+	Concurrency int `json:"concurrency"`
+	// 同时执行的批次数
+	MaxRunningBatches int `json:"max_running_batches"`
+	// 单个批次最大请求行数
+	MaxRequestsPerBatch int `json:"max_requests_per_batch"`
+	// 批量请求计费折扣倍率，1 表示不打折
+	DiscountRatio float64 `json:"discount_ratio"`
+}
+
+// 默认配置
+var batchSetting = BatchSetting{
+	Enabled:             true,
+	Concurrency:         4,
+	MaxRunningBatches:   2,
+	MaxRequestsPerBatch: 50000,
+	DiscountRatio:       1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("batch_setting", &batchSetting)
+}
+
+func GetBatchSetting() *BatchSetting {
+	return &batchSetting
+}
+
+// GetBatchDiscountRatio 获取批量请求折扣倍率，非法值按 1 处理
+func GetBatchDiscountRatio() float64 {
+	if batchSetting.DiscountRatio <= 0 || batchSetting.DiscountRatio > 1 {
+		return 1
+	}
+	return batchSetting.DiscountRatio
+}
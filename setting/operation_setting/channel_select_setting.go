@@ -0,0 +1,61 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// 渠道选择策略
+const (
+	// ChannelSelectModeWeighted 同优先级内按静态权重随机选择
+	ChannelSelectModeWeighted = "weighted"
+	// ChannelSelectModeAdaptive 在静态权重基础上，根据渠道近期的延迟、首字时间和错误率调整权重
+	ChannelSelectModeAdaptive = "adaptive"
+)
+
+// ChannelSelectSetting 渠道选择策略配置
+type ChannelSelectSetting struct {
+	// 默认选择策略：weighted / adaptive
+	DefaultMode string `json:"default_mode"`
+	// 按分组指定选择策略，未配置的分组使用 DefaultMode
+	GroupModes map[string]string `json:"group_modes"`
+	// 滑动平均的平滑系数，越大越偏向最近的请求
+	Alpha float64 `json:"alpha"`
+	// 样本数少于该值的渠道不调整权重
+	MinSamples int `json:"min_samples"`
+	// 错误率惩罚指数，权重乘以 (1 - 错误率)^ErrorPenalty
+	ErrorPenalty float64 `json:"error_penalty"`
+	// 权重调整系数的下限，保证表现差的渠道仍有少量流量用于探测恢复
+	MinFactor float64 `json:"min_factor"`
+	// 统计数据超过该时间（秒）未更新则视为失效
+	StatsTTLSeconds int `json:"stats_ttl_seconds"`
+}
+
+// 默认配置
+var channelSelectSetting = ChannelSelectSetting{
+	DefaultMode:     ChannelSelectModeWeighted,
+	GroupModes:      map[string]string{},
+	Alpha:           0.2,
+	MinSamples:      5,
+	ErrorPenalty:    2,
+	MinFactor:       0.05,
+	StatsTTLSeconds: 1800,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_select_setting", &channelSelectSetting)
+}
+
+func GetChannelSelectSetting() *ChannelSelectSetting {
+	return &channelSelectSetting
+}
+
+// GetGroupMode 获取分组使用的渠道选择策略
+func (s *ChannelSelectSetting) GetGroupMode(group string) string {
+	mode, ok := s.GroupModes[group]
+	if !ok {
+		mode = s.DefaultMode
+	}
+	if mode == ChannelSelectModeAdaptive {
+		return mode
+	}
+	return ChannelSelectModeWeighted
+}
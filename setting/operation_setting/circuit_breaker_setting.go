@@ -0,0 +1,40 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// CircuitBreakerSetting 渠道熔断配置，按渠道和多 Key 渠道中的单个 Key 分别熔断
+type CircuitBreakerSetting struct {
+	Enabled bool `json:"enabled"`
+	// 连续失败次数达到该值后熔断
+	FailureThreshold int `json:"failure_threshold"`
+	// 首次熔断的冷却时间（秒），连续熔断时按倍数增长
+	CooldownSeconds int `json:"cooldown_seconds"`
+	// 冷却时间上限（秒）
+	MaxCooldownSeconds int `json:"max_cooldown_seconds"`
+	// 半开状态下放行的流量比例，用于探测渠道是否恢复
+	HalfOpenProbeRatio float64 `json:"half_open_probe_ratio"`
+	// 半开状态下连续成功该次数后恢复
+	HalfOpenSuccessThreshold int `json:"half_open_success_threshold"`
+	// 启用后，原本会触发自动禁用的错误改为立即熔断，不再禁用渠道
+	ReplaceAutoBan bool `json:"replace_auto_ban"`
+}
+
+// 默认配置
+var circuitBreakerSetting = CircuitBreakerSetting{
+	Enabled:                  false,
+	FailureThreshold:         5,
+	CooldownSeconds:          30,
+	MaxCooldownSeconds:       600,
+	HalfOpenProbeRatio:       0.1,
+	HalfOpenSuccessThreshold: 2,
+	ReplaceAutoBan:           true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("circuit_breaker_setting", &circuitBreakerSetting)
+}
+
+func GetCircuitBreakerSetting() *CircuitBreakerSetting {
+	return &circuitBreakerSetting
+}
@@ -0,0 +1,48 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	FileStorageModeLocal    = "local"    // 保存在本地磁盘
+	FileStorageModeUpstream = "upstream" // 透传到 OpenAI 类型渠道
+)
+
+// FileSetting /v1/files 相关配置
+type FileSetting struct {
+	// 存储方式：local / upstream
+	StorageMode string `json:"storage_mode"`
+	// 透传模式下用于选择上游渠道的模型，请求中携带 model 字段时优先使用请求值
+	UpstreamModel string `json:"upstream_model"`
+	// 透传模式下选不到 OpenAI 类型渠道时是否回退到本地存储
+	FallbackToLocal bool `json:"fallback_to_local"`
+	// 单个文件最大大小（MB）
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+	// 本地文件默认保留天数，0 表示永久保留
+	LocalRetentionDays int `json:"local_retention_days"`
+}
+
+// 默认配置
+var fileSetting = FileSetting{
+	StorageMode:        FileStorageModeLocal,
+	UpstreamModel:      "gpt-4o-mini",
+	FallbackToLocal:    true,
+	MaxFileSizeMB:      512,
+	LocalRetentionDays: 30,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("file_setting", &fileSetting)
+}
+
+func GetFileSetting() *FileSetting {
+	return &fileSetting
+}
+
+// GetMaxFileSizeBytes 获取单个文件最大字节数
+func GetMaxFileSizeBytes() int64 {
+	if fileSetting.MaxFileSizeMB <= 0 {
+		return 512 << 20
+	}
+	return int64(fileSetting.MaxFileSizeMB) << 20
+}
@@ -0,0 +1,35 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// FirstTokenTimeoutSetting 流式请求首个 token 超时配置，超时且尚未向下游写出时中止本次尝试并重试其他渠道。
+// 渠道设置中的超时优先于按模型配置的超时
+type FirstTokenTimeoutSetting struct {
+	// 默认超时（秒），0 表示不限制
+	DefaultSeconds int `json:"default_seconds"`
+	// 按模型配置的超时（秒）
+	ModelSeconds map[string]int `json:"model_seconds"`
+}
+
+// 默认配置
+var firstTokenTimeoutSetting = FirstTokenTimeoutSetting{
+	DefaultSeconds: 0,
+	ModelSeconds:   map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("first_token_timeout_setting", &firstTokenTimeoutSetting)
+}
+
+func GetFirstTokenTimeoutSetting() *FirstTokenTimeoutSetting {
+	return &firstTokenTimeoutSetting
+}
+
+// GetModelSeconds 获取模型的首个 token 超时（秒），未配置时使用默认值
+func (s *FirstTokenTimeoutSetting) GetModelSeconds(modelName string) int {
+	if seconds, ok := s.ModelSeconds[modelName]; ok {
+		return seconds
+	}
+	return s.DefaultSeconds
+}
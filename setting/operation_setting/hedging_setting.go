@@ -0,0 +1,25 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// HedgingSetting 对冲请求配置，令牌设置了对冲延迟后，首个渠道在延迟内未返回首字节时向第二个渠道发起同样的请求
+type HedgingSetting struct {
+	Enabled bool `json:"enabled"`
+	// 令牌可设置的最小对冲延迟（毫秒），避免每个请求都被发送两次
+	MinDelayMs int `json:"min_delay_ms"`
+}
+
+// 默认配置
+var hedgingSetting = HedgingSetting{
+	Enabled:    false,
+	MinDelayMs: 200,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("hedging_setting", &hedgingSetting)
+}
+
+func GetHedgingSetting() *HedgingSetting {
+	return &hedgingSetting
+}
@@ -0,0 +1,28 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// KeyCooldownSetting 上游限流后的 Key 冷却配置
+type KeyCooldownSetting struct {
+	Enabled bool `json:"enabled"`
+	// 上游返回 429 但没有 Retry-After 或限流重置头时的冷却时间（秒），0 表示不冷却
+	DefaultSeconds int `json:"default_seconds"`
+	// 冷却时间上限（秒），避免异常的重置头导致 Key 长时间不可用
+	MaxSeconds int `json:"max_seconds"`
+}
+
+// 默认配置
+var keyCooldownSetting = KeyCooldownSetting{
+	Enabled:        true,
+	DefaultSeconds: 0,
+	MaxSeconds:     3600,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("key_cooldown_setting", &keyCooldownSetting)
+}
+
+func GetKeyCooldownSetting() *KeyCooldownSetting {
+	return &keyCooldownSetting
+}
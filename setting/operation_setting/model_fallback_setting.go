@@ -0,0 +1,38 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ModelFallbackSetting 模型回退链配置，主模型的所有渠道都失败或不可用时依次改用链上的下一个模型
+type ModelFallbackSetting struct {
+	Enabled bool `json:"enabled"`
+	// 全局回退链，如 {"gpt-4o": ["gpt-4.1", "claude-sonnet-4"]}
+	Chains map[string][]string `json:"chains"`
+	// 按分组配置的回退链，优先于全局回退链
+	GroupChains map[string]map[string][]string `json:"group_chains"`
+}
+
+// 默认配置
+var modelFallbackSetting = ModelFallbackSetting{
+	Enabled:     false,
+	Chains:      map[string][]string{},
+	GroupChains: map[string]map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_fallback_setting", &modelFallbackSetting)
+}
+
+func GetModelFallbackSetting() *ModelFallbackSetting {
+	return &modelFallbackSetting
+}
+
+// GetChain 获取分组下模型的回退链，分组未配置时使用全局回退链
+func (s *ModelFallbackSetting) GetChain(group string, modelName string) []string {
+	if chains, ok := s.GroupChains[group]; ok {
+		if chain, ok := chains[modelName]; ok {
+			return chain
+		}
+	}
+	return s.Chains[modelName]
+}
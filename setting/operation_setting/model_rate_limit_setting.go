@@ -0,0 +1,90 @@
+package operation_setting
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ModelRateLimitSetting 按模型的请求数限流配置，请求命中的所有规则同时生效
+type ModelRateLimitSetting struct {
+	Enabled bool                 `json:"enabled"`
+	Rules   []ModelRateLimitRule `json:"rules"`
+}
+
+// ModelRateLimitRule 模型请求数限流规则，如 {"model": "o1-pro", "user_limit": 5, "global_limit": 100} 表示
+// o1-pro 每个用户每分钟最多 5 次请求，所有用户合计每分钟最多 100 次
+type ModelRateLimitRule struct {
+	// 精确匹配的模型名称，与 ModelRegex 同时配置时任一匹配即可
+	Model      string `json:"model,omitempty"`
+	ModelRegex string `json:"model_regex,omitempty"`
+	// 生效的分组，为空时对所有分组生效
+	Groups []string `json:"groups,omitempty"`
+	// 限流时间窗口（秒），默认 60
+	DurationSeconds int `json:"duration_seconds,omitempty"`
+	// 时间窗口内每个用户和所有用户合计的最大请求数，0 表示不限制
+	UserLimit   int `json:"user_limit,omitempty"`
+	GlobalLimit int `json:"global_limit,omitempty"`
+
+	modelRegex *regexp.Regexp
+}
+
+// 默认配置
+var modelRateLimitSetting = ModelRateLimitSetting{
+	Enabled: false,
+	Rules:   []ModelRateLimitRule{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_rate_limit_setting", &modelRateLimitSetting)
+}
+
+func GetModelRateLimitSetting() *ModelRateLimitSetting {
+	return &modelRateLimitSetting
+}
+
+func (r *ModelRateLimitRule) GetDurationSeconds() int64 {
+	if r.DurationSeconds <= 0 {
+		return 60
+	}
+	return int64(r.DurationSeconds)
+}
+
+// GetModelRegex 返回保存配置时编译好的 ModelRegex，未配置或无效时返回 nil
+func (r *ModelRateLimitRule) GetModelRegex() *regexp.Regexp {
+	return r.modelRegex
+}
+
+func compileModelRateLimitRules(rules []ModelRateLimitRule) error {
+	for i := range rules {
+		rules[i].modelRegex = nil
+		if rules[i].ModelRegex == "" {
+			continue
+		}
+		re, err := regexp.Compile(rules[i].ModelRegex)
+		if err != nil {
+			return fmt.Errorf("模型限流规则 #%d 的正则 %q 无效: %s", i+1, rules[i].ModelRegex, err.Error())
+		}
+		rules[i].modelRegex = re
+	}
+	return nil
+}
+
+// ValidateModelRateLimitRules 校验模型限流规则 JSON，正则无效时拒绝保存
+func ValidateModelRateLimitRules(value string) error {
+	var rules []ModelRateLimitRule
+	if err := common.UnmarshalJsonStr(value, &rules); err != nil {
+		return fmt.Errorf("模型限流规则格式错误: %s", err.Error())
+	}
+	return compileModelRateLimitRules(rules)
+}
+
+// CompileModelRateLimitRules 在加载或保存配置后预编译规则中的正则
+func CompileModelRateLimitRules() {
+	if err := compileModelRateLimitRules(modelRateLimitSetting.Rules); err != nil {
+		common.SysError(err.Error())
+	}
+}
@@ -0,0 +1,63 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// 审核方式
+const (
+	// ModerationModeAuto 有可用的审核渠道时转发上游，否则使用本地审核
+	ModerationModeAuto = "auto"
+	// ModerationModeUpstream 始终转发到上游审核模型
+	ModerationModeUpstream = "upstream"
+	// ModerationModeLocal 始终使用本地审核
+	ModerationModeLocal = "local"
+)
+
+// ModerationCategoryRule 本地审核中单个类别的规则，命中任一敏感词或正则即判定该类别违规
+type ModerationCategoryRule struct {
+	Words    []string `json:"words"`
+	Patterns []string `json:"patterns"`
+}
+
+// ModerationSetting 本地审核（/v1/moderations 及请求预检）配置
+type ModerationSetting struct {
+	// 默认审核方式：auto / upstream / local
+	DefaultMode string `json:"default_mode"`
+	// 按分组指定审核方式，未配置的分组使用 DefaultMode
+	GroupModes map[string]string `json:"group_modes"`
+	// 类别 -> 规则，类别名建议与 OpenAI 保持一致（如 hate、violence）
+	Rules map[string]ModerationCategoryRule `json:"rules"`
+	// 系统敏感词归入的类别，为空表示本地审核不使用系统敏感词
+	SensitiveWordsCategory string `json:"sensitive_words_category"`
+	// 是否在转发请求前使用本地审核进行预检，命中则拒绝请求
+	PreCheckEnabled bool `json:"pre_check_enabled"`
+}
+
+// 默认配置
+var moderationSetting = ModerationSetting{
+	DefaultMode: ModerationModeAuto,
+	GroupModes:  map[string]string{},
+	Rules:       map[string]ModerationCategoryRule{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("moderation_setting", &moderationSetting)
+}
+
+func GetModerationSetting() *ModerationSetting {
+	return &moderationSetting
+}
+
+// GetGroupMode 获取分组使用的审核方式
+func (s *ModerationSetting) GetGroupMode(group string) string {
+	mode, ok := s.GroupModes[group]
+	if !ok {
+		mode = s.DefaultMode
+	}
+	switch mode {
+	case ModerationModeUpstream, ModerationModeLocal:
+		return mode
+	default:
+		return ModerationModeAuto
+	}
+}
@@ -0,0 +1,27 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ResponseStoreSetting 网关侧 Responses 存储配置，
+// 用于不支持 previous_response_id 的上游（Claude、Gemini 等转换渠道）
+type ResponseStoreSetting struct {
+	// 是否启用网关侧存储
+	Enabled bool `json:"enabled"`
+	// 响应保留小时数，0 表示永久保留
+	RetentionHours int `json:"retention_hours"`
+}
+
+// 默认配置
+var responseStoreSetting = ResponseStoreSetting{
+	Enabled:        true,
+	RetentionHours: 720,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_store_setting", &responseStoreSetting)
+}
+
+func GetResponseStoreSetting() *ResponseStoreSetting {
+	return &responseStoreSetting
+}
@@ -0,0 +1,35 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// TokenRateLimitSetting 按 token 数限流配置，请求前按预估的提示 token 数预占，结算后按实际用量修正。
+// 令牌自身的 TPM/TPD 按令牌计数，分组限制按用户计数，模型限制按用户和模型计数
+type TokenRateLimitSetting struct {
+	Enabled bool `json:"enabled"`
+	// 按用户分组配置的限制，如 {"default": {"tpm": 100000, "tpd": 2000000}}
+	GroupLimits map[string]TokenRateLimit `json:"group_limits"`
+	// 按模型配置的限制
+	ModelLimits map[string]TokenRateLimit `json:"model_limits"`
+}
+
+// TokenRateLimit 每分钟和每天最多消耗的 token 数，0 表示不限制
+type TokenRateLimit struct {
+	TPM int `json:"tpm"`
+	TPD int `json:"tpd"`
+}
+
+// 默认配置
+var tokenRateLimitSetting = TokenRateLimitSetting{
+	Enabled:     false,
+	GroupLimits: map[string]TokenRateLimit{},
+	ModelLimits: map[string]TokenRateLimit{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("token_rate_limit_setting", &tokenRateLimitSetting)
+}
+
+func GetTokenRateLimitSetting() *TokenRateLimitSetting {
+	return &tokenRateLimitSetting
+}
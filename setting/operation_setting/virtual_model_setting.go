@@ -0,0 +1,63 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// VirtualModelSetting 全局虚拟模型配置，虚拟模型按规则顺序解析为实际的模型和分组
+type VirtualModelSetting struct {
+	Enabled bool `json:"enabled"`
+	// 虚拟模型名称到规则列表的映射，如 {"cheap-fast": {"rules": [...]}}
+	Models map[string]VirtualModel `json:"models"`
+}
+
+type VirtualModel struct {
+	Description string `json:"description,omitempty"`
+	// 按顺序匹配，使用第一条命中的规则
+	Rules []VirtualModelRule `json:"rules"`
+}
+
+// VirtualModelRule 虚拟模型解析规则，所有已配置的条件都满足时命中，未配置任何条件时总是命中
+type VirtualModelRule struct {
+	TokenIds   []int    `json:"token_ids,omitempty"`
+	UserGroups []string `json:"user_groups,omitempty"`
+	// 请求头需要全部匹配，值为空时只要求请求头存在
+	Headers map[string]string `json:"headers,omitempty"`
+	// 预估的提示 token 数范围，0 表示不限制
+	MinPromptTokens int   `json:"min_prompt_tokens,omitempty"`
+	MaxPromptTokens int   `json:"max_prompt_tokens,omitempty"`
+	HasTools        *bool `json:"has_tools,omitempty"`
+	HasImages       *bool `json:"has_images,omitempty"`
+
+	// 解析到的实际模型
+	Model string `json:"model"`
+	// 解析到的分组，为空时使用当前分组
+	Group string `json:"group,omitempty"`
+}
+
+// 默认配置
+var virtualModelSetting = VirtualModelSetting{
+	Enabled: false,
+	Models:  map[string]VirtualModel{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("virtual_model_setting", &virtualModelSetting)
+}
+
+func GetVirtualModelSetting() *VirtualModelSetting {
+	return &virtualModelSetting
+}
+
+// GetVirtualModel 获取启用状态下的虚拟模型
+func (s *VirtualModelSetting) GetVirtualModel(name string) (VirtualModel, bool) {
+	if !s.Enabled {
+		return VirtualModel{}, false
+	}
+	virtualModel, ok := s.Models[name]
+	return virtualModel, ok
+}
+
+// NeedsRequestBody 规则是否依赖请求体中的内容
+func (r *VirtualModelRule) NeedsRequestBody() bool {
+	return r.MinPromptTokens > 0 || r.MaxPromptTokens > 0 || r.HasTools != nil || r.HasImages != nil
+}
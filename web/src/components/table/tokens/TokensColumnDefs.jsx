@@ -39,6 +39,7 @@ import {
   renderQuota,
   getModelCategories,
   showError,
+  splitModelLimits,
 } from '../../../helpers';
 import {
   IconTreeTriangleDown,
@@ -154,7 +155,7 @@ const renderTokenKey = (text, record, showKeys, setShowKeys, copyText) => {
 // Render model limits column
 const renderModelLimits = (text, record, t) => {
   if (record.model_limits_enabled && text) {
-    const models = text.split(',').filter(Boolean);
+    const models = splitModelLimits(text);
     const categories = getModelCategories(t);
 
     const vendorAvatars = [];
@@ -27,6 +27,7 @@ import {
   renderQuotaWithPrompt,
   getModelCategories,
   selectFilter,
+  splitModelLimits,
 } from '../../../../helpers';
 import { useIsMobile } from '../../../../hooks/common/useIsMobile';
 import {
@@ -157,11 +158,7 @@ const EditTokenModal = (props) => {
       if (data.expired_time !== -1) {
         data.expired_time = timestamp2string(data.expired_time);
       }
-      if (data.model_limits !== '') {
-        data.model_limits = data.model_limits.split(',');
-      } else {
-        data.model_limits = [];
-      }
+      data.model_limits = splitModelLimits(data.model_limits);
       if (formApiRef.current) {
         formApiRef.current.setValues({ ...getInitValues(), ...data });
       }
@@ -550,8 +547,11 @@ const EditTokenModal = (props) => {
                         '请选择该令牌支持的模型，留空支持所有模型',
                       )}
                       multiple
+                      allowCreate
                       optionList={models}
-                      extraText={t('非必要，不建议启用模型限制')}
+                      extraText={t(
+                        '非必要，不建议启用模型限制。可输入 gpt-4o* 通配符、/^o[13]-mini$/ 正则，! 前缀表示禁止',
+                      )}
                       filter={selectFilter}
                       autoClearSearchValue={false}
                       searchPosition='dropdown'
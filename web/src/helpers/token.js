@@ -61,3 +61,49 @@ export function getServerAddress() {
 
   return serverAddress;
 }
+
+/**
+ * 拆分令牌模型限制规则，与后端一致：按逗号或换行分隔，/.../ 正则中的逗号不作为分隔符
+ * @param {string} limits 模型限制规则字符串
+ * @returns {string[]} 规则列表
+ */
+export function splitModelLimits(limits) {
+  const rules = [];
+  let rule = '';
+  let inRegex = false;
+  const flush = () => {
+    const trimmed = rule.trim();
+    if (trimmed) {
+      rules.push(trimmed);
+    }
+    rule = '';
+  };
+  for (let i = 0; i < (limits || '').length; i++) {
+    const ch = limits[i];
+    if (ch === '\n') {
+      inRegex = false;
+      flush();
+      continue;
+    }
+    if (inRegex) {
+      rule += ch;
+      if (ch === '\\' && i + 1 < limits.length && limits[i + 1] !== '\n') {
+        i++;
+        rule += limits[i];
+      } else if (ch === '/') {
+        inRegex = false;
+      }
+      continue;
+    }
+    if (ch === ',') {
+      flush();
+      continue;
+    }
+    if (ch === '/' && (rule.trim() === '' || rule.trim() === '!')) {
+      inRegex = true;
+    }
+    rule += ch;
+  }
+  flush();
+  return rules;
+}